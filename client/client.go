@@ -0,0 +1,171 @@
+// Package client calls the user SOAP service from Go. UserClient has a
+// method per operation taking and returning the model types; its
+// Transport sends the envelopes over HTTP, UDP or TCP. Faults are
+// returned as *Fault errors that match ErrNotFound and the other
+// sentinels with errors.Is.
+//
+//	c := client.NewUserClient(&client.HTTPTransport{URL: "http://localhost:8180/soap/user"})
+//	resp, err := c.GetUserByID(ctx, 1)
+//	if errors.Is(err, client.ErrNotFound) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// Defaults used by NewUserClient.
+const (
+	DefaultTimeout      = 10 * time.Second
+	DefaultMaxRetries   = 2
+	DefaultRetryBackoff = 200 * time.Millisecond
+)
+
+// Operation describes a SOAP operation to Call.
+type Operation struct {
+	Name   string
+	Action string
+	// Idempotent operations are retried after any transport error. Others
+	// are only retried when the request cannot have reached the server.
+	Idempotent bool
+}
+
+// Client sends SOAP requests through a Transport. Its fields must not be
+// changed while calls are in progress.
+type Client struct {
+	Transport Transport
+	// Timeout bounds each attempt of a call; zero leaves only the deadline
+	// of the context.
+	Timeout time.Duration
+	// MaxRetries is how many times a failed attempt is retried.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled for each
+	// one after it.
+	RetryBackoff time.Duration
+	// Session is sent in the Session header of every request when set. It
+	// is the token returned by AuthenticateUser.
+	Session string
+}
+
+type requestEnvelope struct {
+	XMLName xml.Name          `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+	Header  *model.SoapHeader `xml:",omitempty"`
+	Body    struct {
+		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
+		Payload any
+	}
+}
+
+// Call sends request, a struct with the XMLName of the operation's
+// request element, and decodes the response element into response. A
+// fault is returned as a *Fault.
+func (c *Client) Call(ctx context.Context, op Operation, request, response any) error {
+	var env requestEnvelope
+	if c.Session != "" {
+		env.Header = &model.SoapHeader{Session: &model.SessionHeader{Token: c.Session}}
+	}
+	env.Body.Payload = request
+	output, err := xml.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("%s: encoding request failed: %w", op.Name, err)
+	}
+	output = append([]byte(xml.Header), output...)
+
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err = c.attempt(ctx, op, output, response)
+		if err == nil || attempt >= c.MaxRetries || !retryable(op, err) || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", op.Name, ctx.Err())
+		}
+		backoff *= 2
+	}
+	if err != nil {
+		if fault := (*Fault)(nil); errors.As(err, &fault) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op.Name, err)
+	}
+	return nil
+}
+
+func (c *Client) attempt(ctx context.Context, op Operation, request []byte, response any) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	data, err := c.Transport.RoundTrip(ctx, op.Action, request)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(data, response)
+}
+
+// retryable reports whether a failed attempt of op may be repeated:
+// faults are answers and never are, and requests that are not idempotent
+// only are if the connection could not be made.
+func retryable(op Operation, err error) bool {
+	if fault := (*Fault)(nil); errors.As(err, &fault) {
+		return false
+	}
+	if op.Idempotent {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// decodeResponse decodes the first element of the Body of a response
+// envelope into response, or returns the fault it holds. The element is
+// decoded in place so that namespace prefixes declared on the envelope
+// stay in scope.
+func decodeResponse(data []byte, response any) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	inBody := false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return errors.New("response has no SOAP Body content")
+		}
+		if err != nil {
+			return fmt.Errorf("invalid response: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if !inBody {
+			inBody = start.Name.Space == soapEnvelopeNamespace && start.Name.Local == "Body"
+			continue
+		}
+
+		if start.Name.Space == soapEnvelopeNamespace && start.Name.Local == "Fault" {
+			var fault model.SoapFault
+			if err := d.DecodeElement(&fault, &start); err != nil {
+				return fmt.Errorf("invalid fault: %w", err)
+			}
+			return newFault(fault)
+		}
+		if err := d.DecodeElement(response, &start); err != nil {
+			return fmt.Errorf("invalid response: %w", err)
+		}
+		return nil
+	}
+}
+
+const soapEnvelopeNamespace = "http://schemas.xmlsoap.org/soap/envelope/"
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// Sentinels matched by errors.Is on the faults of the corresponding kind
+// of service error.
+var (
+	ErrInvalidArgument  = errors.New("invalid argument")
+	ErrUnauthenticated  = errors.New("unauthenticated")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrNotFound         = errors.New("not found")
+	ErrConflict         = errors.New("conflict")
+	ErrInternal         = errors.New("internal error")
+)
+
+var faultKinds = map[string]error{
+	"InvalidArgument":  ErrInvalidArgument,
+	"Unauthenticated":  ErrUnauthenticated,
+	"PermissionDenied": ErrPermissionDenied,
+	"NotFound":         ErrNotFound,
+	"Conflict":         ErrConflict,
+	"Internal":         ErrInternal,
+}
+
+// Fault is a SOAP fault returned by the service.
+type Fault struct {
+	Code   string
+	String string
+	// Kind is the kind of service error from the fault detail, such as
+	// "NotFound". It is empty for faults about the message itself, such
+	// as a malformed envelope.
+	Kind string
+}
+
+func newFault(f model.SoapFault) *Fault {
+	fault := &Fault{Code: f.Code, String: f.String}
+	if f.Detail != nil {
+		fault.Kind = f.Detail.ErrorKind
+	}
+	return fault
+}
+
+func (f *Fault) Error() string {
+	return "SOAP fault " + f.Code + ": " + f.String
+}
+
+// Is matches the sentinel of the fault's kind. Client faults without a
+// kind, which reject the message itself, match ErrInvalidArgument.
+func (f *Fault) Is(target error) bool {
+	if kind, ok := faultKinds[f.Kind]; ok {
+		return target == kind
+	}
+	// Fault codes may be qualified, as in soap:Client.
+	code := f.Code[strings.LastIndex(f.Code, ":")+1:]
+	return f.Kind == "" && code == "Client" && target == ErrInvalidArgument
+}
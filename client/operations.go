@@ -0,0 +1,355 @@
+// Code generated by wsdl2go from user.wsdl. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// AddGroupMember calls the AddGroupMember operation.
+func (c *UserClient) AddGroupMember(ctx context.Context, request model.AddGroupMemberRequest) (*model.AddGroupMemberResponse, error) {
+	var response model.AddGroupMemberResponse
+	op := Operation{Name: "AddGroupMember", Action: "urn:user-service/AddGroupMember", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// AuthenticateUser calls the AuthenticateUser operation.
+func (c *UserClient) AuthenticateUser(ctx context.Context, request model.AuthenticateUserRequest) (*model.AuthenticateUserResponse, error) {
+	var response model.AuthenticateUserResponse
+	op := Operation{Name: "AuthenticateUser", Action: "urn:user-service/AuthenticateUser", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// BackupDatabase calls the BackupDatabase operation.
+func (c *UserClient) BackupDatabase(ctx context.Context) (*model.BackupDatabaseResponse, error) {
+	var request model.BackupDatabaseRequest
+	var response model.BackupDatabaseResponse
+	op := Operation{Name: "BackupDatabase", Action: "urn:user-service/BackupDatabase", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// BatchUsers calls the BatchUsers operation.
+func (c *UserClient) BatchUsers(ctx context.Context, request model.BatchUsersRequest) (*model.BatchUsersResponse, error) {
+	var response model.BatchUsersResponse
+	op := Operation{Name: "BatchUsers", Action: "urn:user-service/BatchUsers", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ChangePassword calls the ChangePassword operation.
+func (c *UserClient) ChangePassword(ctx context.Context, request model.ChangePasswordRequest) (*model.ChangePasswordResponse, error) {
+	var response model.ChangePasswordResponse
+	op := Operation{Name: "ChangePassword", Action: "urn:user-service/ChangePassword", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// CreateGroup calls the CreateGroup operation.
+func (c *UserClient) CreateGroup(ctx context.Context, request model.CreateGroupRequest) (*model.CreateGroupResponse, error) {
+	var response model.CreateGroupResponse
+	op := Operation{Name: "CreateGroup", Action: "urn:user-service/CreateGroup", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// CreateUser calls the CreateUser operation.
+func (c *UserClient) CreateUser(ctx context.Context, request model.CreateUserRequest) (*model.CreateUserResponse, error) {
+	var response model.CreateUserResponse
+	op := Operation{Name: "CreateUser", Action: "urn:user-service/CreateUser", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// CreateWebhook calls the CreateWebhook operation.
+func (c *UserClient) CreateWebhook(ctx context.Context, request model.CreateWebhookRequest) (*model.CreateWebhookResponse, error) {
+	var response model.CreateWebhookResponse
+	op := Operation{Name: "CreateWebhook", Action: "urn:user-service/CreateWebhook", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// DeleteGroup calls the DeleteGroup operation.
+func (c *UserClient) DeleteGroup(ctx context.Context, id int) (*model.DeleteGroupResponse, error) {
+	request := model.DeleteGroupRequest{ID: id}
+	var response model.DeleteGroupResponse
+	op := Operation{Name: "DeleteGroup", Action: "urn:user-service/DeleteGroup", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// DeleteUser calls the DeleteUser operation.
+func (c *UserClient) DeleteUser(ctx context.Context, id int) (*model.DeleteUserResponse, error) {
+	request := model.DeleteUserRequest{ID: id}
+	var response model.DeleteUserResponse
+	op := Operation{Name: "DeleteUser", Action: "urn:user-service/DeleteUser", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// DeleteWebhook calls the DeleteWebhook operation.
+func (c *UserClient) DeleteWebhook(ctx context.Context, id int) (*model.DeleteWebhookResponse, error) {
+	request := model.DeleteWebhookRequest{ID: id}
+	var response model.DeleteWebhookResponse
+	op := Operation{Name: "DeleteWebhook", Action: "urn:user-service/DeleteWebhook", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ExportUsers calls the ExportUsers operation.
+func (c *UserClient) ExportUsers(ctx context.Context, request model.ExportUsersRequest) (*model.ExportUsersResponse, error) {
+	var response model.ExportUsersResponse
+	op := Operation{Name: "ExportUsers", Action: "urn:user-service/ExportUsers", Idempotent: true}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetChanges calls the GetChanges operation.
+func (c *UserClient) GetChanges(ctx context.Context, request model.GetChangesRequest) (*model.GetChangesResponse, error) {
+	var response model.GetChangesResponse
+	op := Operation{Name: "GetChanges", Action: "urn:user-service/GetChanges", Idempotent: true}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetGroup calls the GetGroup operation.
+func (c *UserClient) GetGroup(ctx context.Context, id int) (*model.GetGroupResponse, error) {
+	request := model.GetGroupRequest{ID: id}
+	var response model.GetGroupResponse
+	op := Operation{Name: "GetGroup", Action: "urn:user-service/GetGroup", Idempotent: true}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetUserAvatar calls the GetUserAvatar operation.
+func (c *UserClient) GetUserAvatar(ctx context.Context, id int) (*model.GetUserAvatarResponse, error) {
+	request := model.GetUserAvatarRequest{ID: id}
+	var response model.GetUserAvatarResponse
+	op := Operation{Name: "GetUserAvatar", Action: "urn:user-service/GetUserAvatar", Idempotent: true}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetUserByID calls the GetUserByID operation.
+func (c *UserClient) GetUserByID(ctx context.Context, id int) (*model.GetUserByIDResponse, error) {
+	request := model.GetUserByIDRequest{ID: id}
+	var response model.GetUserByIDResponse
+	op := Operation{Name: "GetUserByID", Action: "urn:user-service/GetUserByID", Idempotent: true}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetUserHistory calls the GetUserHistory operation.
+func (c *UserClient) GetUserHistory(ctx context.Context, request model.GetUserHistoryRequest) (*model.GetUserHistoryResponse, error) {
+	var response model.GetUserHistoryResponse
+	op := Operation{Name: "GetUserHistory", Action: "urn:user-service/GetUserHistory", Idempotent: true}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ImportUsers calls the ImportUsers operation.
+func (c *UserClient) ImportUsers(ctx context.Context, request model.ImportUsersRequest) (*model.ImportUsersResponse, error) {
+	var response model.ImportUsersResponse
+	op := Operation{Name: "ImportUsers", Action: "urn:user-service/ImportUsers", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ListGroupMembers calls the ListGroupMembers operation.
+func (c *UserClient) ListGroupMembers(ctx context.Context, groupID int) (*model.ListGroupMembersResponse, error) {
+	request := model.ListGroupMembersRequest{GroupID: groupID}
+	var response model.ListGroupMembersResponse
+	op := Operation{Name: "ListGroupMembers", Action: "urn:user-service/ListGroupMembers", Idempotent: true}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ListUserGroups calls the ListUserGroups operation.
+func (c *UserClient) ListUserGroups(ctx context.Context, userID int) (*model.ListUserGroupsResponse, error) {
+	request := model.ListUserGroupsRequest{UserID: userID}
+	var response model.ListUserGroupsResponse
+	op := Operation{Name: "ListUserGroups", Action: "urn:user-service/ListUserGroups", Idempotent: true}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ListWebhookDeadLetters calls the ListWebhookDeadLetters operation.
+func (c *UserClient) ListWebhookDeadLetters(ctx context.Context, webhookID int) (*model.ListWebhookDeadLettersResponse, error) {
+	request := model.ListWebhookDeadLettersRequest{WebhookID: webhookID}
+	var response model.ListWebhookDeadLettersResponse
+	op := Operation{Name: "ListWebhookDeadLetters", Action: "urn:user-service/ListWebhookDeadLetters", Idempotent: true}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ListWebhooks calls the ListWebhooks operation.
+func (c *UserClient) ListWebhooks(ctx context.Context) (*model.ListWebhooksResponse, error) {
+	var request model.ListWebhooksRequest
+	var response model.ListWebhooksResponse
+	op := Operation{Name: "ListWebhooks", Action: "urn:user-service/ListWebhooks", Idempotent: true}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// PurgeUser calls the PurgeUser operation.
+func (c *UserClient) PurgeUser(ctx context.Context, id int) (*model.PurgeUserResponse, error) {
+	request := model.PurgeUserRequest{ID: id}
+	var response model.PurgeUserResponse
+	op := Operation{Name: "PurgeUser", Action: "urn:user-service/PurgeUser", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// RedeliverWebhook calls the RedeliverWebhook operation.
+func (c *UserClient) RedeliverWebhook(ctx context.Context, deliveryID uint64) (*model.RedeliverWebhookResponse, error) {
+	request := model.RedeliverWebhookRequest{DeliveryID: deliveryID}
+	var response model.RedeliverWebhookResponse
+	op := Operation{Name: "RedeliverWebhook", Action: "urn:user-service/RedeliverWebhook", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// RemoveGroupMember calls the RemoveGroupMember operation.
+func (c *UserClient) RemoveGroupMember(ctx context.Context, request model.RemoveGroupMemberRequest) (*model.RemoveGroupMemberResponse, error) {
+	var response model.RemoveGroupMemberResponse
+	op := Operation{Name: "RemoveGroupMember", Action: "urn:user-service/RemoveGroupMember", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// RequestEmailVerification calls the RequestEmailVerification operation.
+func (c *UserClient) RequestEmailVerification(ctx context.Context, id int) (*model.RequestEmailVerificationResponse, error) {
+	request := model.RequestEmailVerificationRequest{ID: id}
+	var response model.RequestEmailVerificationResponse
+	op := Operation{Name: "RequestEmailVerification", Action: "urn:user-service/RequestEmailVerification", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// RestoreUser calls the RestoreUser operation.
+func (c *UserClient) RestoreUser(ctx context.Context, id int) (*model.RestoreUserResponse, error) {
+	request := model.RestoreUserRequest{ID: id}
+	var response model.RestoreUserResponse
+	op := Operation{Name: "RestoreUser", Action: "urn:user-service/RestoreUser", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// SearchUsers calls the SearchUsers operation.
+func (c *UserClient) SearchUsers(ctx context.Context, request model.SearchUsersRequest) (*model.SearchUsersResponse, error) {
+	var response model.SearchUsersResponse
+	op := Operation{Name: "SearchUsers", Action: "urn:user-service/SearchUsers", Idempotent: true}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// SetPassword calls the SetPassword operation.
+func (c *UserClient) SetPassword(ctx context.Context, request model.SetPasswordRequest) (*model.SetPasswordResponse, error) {
+	var response model.SetPasswordResponse
+	op := Operation{Name: "SetPassword", Action: "urn:user-service/SetPassword", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// SetUserAvatar calls the SetUserAvatar operation.
+func (c *UserClient) SetUserAvatar(ctx context.Context, request model.SetUserAvatarRequest) (*model.SetUserAvatarResponse, error) {
+	var response model.SetUserAvatarResponse
+	op := Operation{Name: "SetUserAvatar", Action: "urn:user-service/SetUserAvatar", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// UpdateGroup calls the UpdateGroup operation.
+func (c *UserClient) UpdateGroup(ctx context.Context, request model.UpdateGroupRequest) (*model.UpdateGroupResponse, error) {
+	var response model.UpdateGroupResponse
+	op := Operation{Name: "UpdateGroup", Action: "urn:user-service/UpdateGroup", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// UpdateUser calls the UpdateUser operation.
+func (c *UserClient) UpdateUser(ctx context.Context, request model.UpdateUserRequest) (*model.UpdateUserResponse, error) {
+	var response model.UpdateUserResponse
+	op := Operation{Name: "UpdateUser", Action: "urn:user-service/UpdateUser", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// VerifyEmail calls the VerifyEmail operation.
+func (c *UserClient) VerifyEmail(ctx context.Context, token string) (*model.VerifyEmailResponse, error) {
+	request := model.VerifyEmailRequest{Token: token}
+	var response model.VerifyEmailResponse
+	op := Operation{Name: "VerifyEmail", Action: "urn:user-service/VerifyEmail", Idempotent: false}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
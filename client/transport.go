@@ -0,0 +1,246 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limits on the messages transports exchange with the server.
+const (
+	// MaxUDPRequestSize is the largest datagram the server reads; bigger
+	// requests are sent gzip compressed.
+	MaxUDPRequestSize = 4096
+	// MaxResponseSize bounds responses over any transport.
+	MaxResponseSize = 16 << 20
+	// MaxTCPMessageSize bounds a framed TCP message, as on the server.
+	MaxTCPMessageSize = 1 << 20
+)
+
+// Transport sends a request envelope and returns the response envelope.
+// It must respect the deadline and cancellation of ctx.
+type Transport interface {
+	RoundTrip(ctx context.Context, action string, request []byte) ([]byte, error)
+}
+
+// HTTPTransport posts envelopes to a SOAP endpoint, with the action in
+// the SOAPAction header.
+type HTTPTransport struct {
+	URL string
+	// Client is the HTTP client to use; nil means http.DefaultClient.
+	Client *http.Client
+}
+
+func (t *HTTPTransport) RoundTrip(ctx context.Context, action string, request []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", strconv.Quote(action))
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	// SOAP 1.1 servers may send faults with 500 Internal Server Error.
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	isXML := mediaType == "text/xml" || mediaType == "application/soap+xml"
+	if resp.StatusCode != http.StatusOK && (resp.StatusCode != http.StatusInternalServerError || !isXML) {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return body, nil
+}
+
+// StatusError reports an HTTP response that holds no SOAP envelope.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected HTTP status " + e.Status
+}
+
+// UDPTransport sends each request as one datagram from a fresh socket,
+// so that late answers to earlier attempts are never mistaken for the
+// response.
+type UDPTransport struct {
+	Addr string
+}
+
+func (t *UDPTransport) RoundTrip(ctx context.Context, _ string, request []byte) ([]byte, error) {
+	if len(request) > MaxUDPRequestSize {
+		compressed, err := gzipData(request)
+		if err != nil {
+			return nil, err
+		}
+		if len(compressed) > MaxUDPRequestSize {
+			return nil, fmt.Errorf("request of %d bytes does not fit in a datagram even compressed", len(request))
+		}
+		request = compressed
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", t.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := setConnDeadline(ctx, conn)
+	defer stop()
+
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, 64<<10)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	// The server compresses its answer like the request.
+	if response := buffer[:n]; !isGzip(response) {
+		return response, nil
+	}
+	return gunzipData(buffer[:n])
+}
+
+// TCPTransport sends requests over one persistent connection, each
+// framed by its length as a 4-byte big-endian integer. Calls are
+// serialised, and the connection is reopened after any error. Close it
+// when done.
+type TCPTransport struct {
+	Addr   string
+	Dialer net.Dialer
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (t *TCPTransport) RoundTrip(ctx context.Context, _ string, request []byte) ([]byte, error) {
+	if len(request) > MaxTCPMessageSize {
+		return nil, fmt.Errorf("request of %d bytes exceeds %d", len(request), MaxTCPMessageSize)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		conn, err := t.Dialer.DialContext(ctx, "tcp", t.Addr)
+		if err != nil {
+			return nil, err
+		}
+		t.conn = conn
+	}
+
+	response, err := t.exchange(ctx, request)
+	if err != nil {
+		// A half-read response would be taken for the next one.
+		t.conn.Close()
+		t.conn = nil
+		return nil, contextError(ctx, err)
+	}
+	return response, nil
+}
+
+func (t *TCPTransport) exchange(ctx context.Context, request []byte) ([]byte, error) {
+	stop := setConnDeadline(ctx, t.conn)
+	defer stop()
+
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(request)), uint32(len(request)))
+	if _, err := t.conn.Write(append(frame, request...)); err != nil {
+		return nil, err
+	}
+	var header [4]byte
+	if _, err := io.ReadFull(t.conn, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxResponseSize {
+		return nil, fmt.Errorf("response of %d bytes exceeds %d", size, MaxResponseSize)
+	}
+	response := make([]byte, size)
+	if _, err := io.ReadFull(t.conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Close closes the connection, if one is open.
+func (t *TCPTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
+
+// setConnDeadline makes I/O on conn fail once ctx is done, and returns a
+// function that undoes it.
+func setConnDeadline(ctx context.Context, conn net.Conn) (stop func()) {
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	stopCancel := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	return func() {
+		stopCancel()
+		conn.SetDeadline(time.Time{})
+	}
+}
+
+// contextError returns the error of ctx instead of err if ctx ended the
+// I/O that failed with err.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(err, os.ErrDeadlineExceeded) {
+		return ctxErr
+	}
+	return err
+}
+
+func isGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+func gzipData(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzipData(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(r, MaxResponseSize))
+}
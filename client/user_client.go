@@ -0,0 +1,27 @@
+package client
+
+// UserClient calls the operations of the user service, one method each,
+// generated from user.wsdl. WS-Eventing operations are left out: the
+// subscription manager ones take the subscription in a header.
+//
+// Methods of operations whose request has a single field take that value;
+// the others take the request type. Log in with AuthenticateUser and set
+// Session to the returned token to call operations that need a session.
+type UserClient struct {
+	Client
+}
+
+// NewUserClient returns a client using transport with the default
+// timeout and retries.
+func NewUserClient(transport Transport) *UserClient {
+	return &UserClient{Client: Client{
+		Transport:    transport,
+		Timeout:      DefaultTimeout,
+		MaxRetries:   DefaultMaxRetries,
+		RetryBackoff: DefaultRetryBackoff,
+	}}
+}
+
+// user.wsdl is a copy of the WSDL the server serves at ?wsdl.
+//go:generate go run .. wsdl -o user.wsdl
+//go:generate go run ../cmd/wsdl2go -wsdl user.wsdl -name User -types github.com/maasumiyaat/soap/model -emit operations -o operations.go -exclude Subscribe,Renew,GetStatus,Unsubscribe
@@ -0,0 +1,459 @@
+// Command wsdl2go generates Go code for a SOAP service from its WSDL 1.1
+// or 2.0 description and the schemas it imports or includes. For a
+// service named Weather it writes, to the output directory:
+//
+//   - weather_types.go: a struct per complex type with namespaced XML
+//     tags, and a type per simple type. The types of request and response
+//     elements carry an XMLName naming the element.
+//   - weather_server.go: WeatherServer, an interface with a method per
+//     operation, RegisterWeatherServer adding an implementation to a
+//     handler.Dispatcher, and UnimplementedWeatherServer to embed in
+//     implementations of some operations only.
+//   - weather_client.go: WeatherClient, a client.Client, and
+//     NewWeatherClient.
+//   - weather_operations.go: the methods of WeatherClient, one per
+//     operation.
+//
+// With -types, the request and response types are taken from an existing
+// package instead of generated, named after their schema types. -emit
+// chooses among the files; when it names one, -o may name the file to
+// write instead of a directory, as client/generate.go does to generate the
+// methods of a hand-written UserClient.
+//
+//	go run ./cmd/wsdl2go -wsdl weather.wsdl -o weather
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/maasumiyaat/soap/internal/wsdl"
+)
+
+const (
+	clientPackage  = "github.com/maasumiyaat/soap/client"
+	handlerPackage = "github.com/maasumiyaat/soap/handler"
+	modelPackage   = "github.com/maasumiyaat/soap/model"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("wsdl2go: ")
+	wsdlPath := flag.String("wsdl", "", "WSDL file or URL to read")
+	output := flag.String("o", ".", "directory to write the files to, or the file to write when -emit names one")
+	pkg := flag.String("package", "", "package of the generated files (default: $GOPACKAGE, else the directory name)")
+	name := flag.String("name", "", "prefix of the generated names and files (default: the service name without a Service suffix)")
+	typesPath := flag.String("types", "", "import path of existing request and response types to use instead of generating them")
+	emit := flag.String("emit", "types,server,client,operations", "comma-separated files to generate: types, server, client and operations")
+	exclude := flag.String("exclude", "", "comma-separated operations to leave out")
+	flag.Parse()
+	if *wsdlPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	defs, err := wsdl.Load(*wsdlPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	excluded := make(map[string]bool)
+	for _, op := range strings.Split(*exclude, ",") {
+		excluded[strings.TrimSpace(op)] = true
+	}
+	var operations []wsdl.Operation
+	for _, op := range defs.Operations {
+		if excluded[op.Name] {
+			continue
+		}
+		if op.OneWay() {
+			log.Fatalf("operation %s is one-way, which is not supported; leave it out with -exclude", op.Name)
+		}
+		operations = append(operations, op)
+	}
+
+	emitted := make(map[string]bool)
+	for _, file := range strings.Split(*emit, ",") {
+		file = strings.TrimSpace(file)
+		if file != "types" && file != "server" && file != "client" && file != "operations" {
+			log.Fatalf("unknown file %q in -emit", file)
+		}
+		emitted[file] = true
+	}
+
+	dir, single := *output, ""
+	if strings.HasSuffix(*output, ".go") {
+		if len(emitted) != 1 {
+			log.Fatalf("-o names the file %s, so -emit must name exactly one", *output)
+		}
+		dir, single = filepath.Dir(*output), *output
+	}
+
+	file := generatedFile{
+		Source:  path.Base(filepath.ToSlash(*wsdlPath)),
+		Package: *pkg,
+		Name:    *name,
+		Service: defs.Name,
+	}
+	if file.Name == "" {
+		file.Name = exportedName(strings.TrimSuffix(defs.Name, "Service"))
+	}
+	if file.Package == "" {
+		file.Package = os.Getenv("GOPACKAGE")
+	}
+	if file.Package == "" {
+		file.Package = packageName(dir)
+	}
+
+	// Types and the client package are referred to unqualified from
+	// within themselves.
+	pkgPath := importPath(dir)
+	generateTypes := *typesPath == ""
+	var qualifier string
+	if !generateTypes && *typesPath != pkgPath {
+		qualifier = path.Base(*typesPath) + "."
+		file.typesImport = *typesPath
+	}
+	if pkgPath != clientPackage {
+		file.ClientQualifier = "client."
+	}
+
+	ts := newTypeSet(defs, generateTypes, qualifier, []string{
+		file.Name + "Client", "New" + file.Name + "Client",
+		file.Name + "Server", "Register" + file.Name + "Server", "Unimplemented" + file.Name + "Server",
+	})
+	var elements []xml.Name
+	for _, op := range operations {
+		elements = append(elements, op.Input, op.Output)
+	}
+	if err := ts.addMessages(elements); err != nil {
+		log.Fatal(err)
+	}
+	if generateTypes {
+		if err := ts.writeTypes(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, op := range operations {
+		m, err := newMethod(ts, op)
+		if err != nil {
+			log.Fatal(err)
+		}
+		file.Methods = append(file.Methods, m)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	// outputName returns the name of the file with the given suffix, or
+	// the one -o names.
+	prefix := filepath.Join(dir, fileName(file.Name))
+	outputName := func(suffix string) string {
+		if single != "" {
+			return single
+		}
+		return prefix + suffix
+	}
+	var clientImports []string
+	if file.ClientQualifier != "" {
+		clientImports = append(clientImports, clientPackage)
+	}
+	if emitted["types"] && generateTypes {
+		std, imports := ts.imports()
+		writeFile(outputName("_types.go"), typesTemplate, typesFile{
+			generatedFile: file.withImports(std, imports...),
+			Decls:         ts.decls,
+		})
+	}
+	if emitted["server"] {
+		writeFile(outputName("_server.go"), serverTemplate, file.withImports([]string{"context", "errors"}, handlerPackage, file.typesImport))
+	}
+	if emitted["client"] {
+		writeFile(outputName("_client.go"), clientTemplate, file.withImports(nil, clientImports...))
+	}
+	if emitted["operations"] {
+		std := []string{"context"}
+		imports := append(clientImports, file.typesImport)
+		for _, m := range file.Methods {
+			switch {
+			case strings.HasPrefix(m.ParamType, "time."):
+				std = append(std, "time")
+			case strings.HasPrefix(m.ParamType, "model."):
+				imports = append(imports, modelPackage)
+			}
+		}
+		writeFile(outputName("_operations.go"), operationsTemplate, file.withImports(std, imports...))
+	}
+}
+
+type generatedFile struct {
+	Source  string
+	Package string
+	// Name prefixes the generated names, as in NameClient.
+	Name string
+	// Service is the name of the service in the WSDL.
+	Service string
+	// ClientQualifier is "client." outside the client package.
+	ClientQualifier string
+	Methods         []method
+
+	StdImports []string
+	Imports    []string
+
+	typesImport string
+}
+
+// withImports returns f importing the standard packages std and the
+// non-empty imports.
+func (f generatedFile) withImports(std []string, imports ...string) generatedFile {
+	f.StdImports = sortedUnique(std)
+	f.Imports = sortedUnique(imports)
+	return f
+}
+
+type typesFile struct {
+	generatedFile
+	Decls []*strings.Builder
+}
+
+// imports returns the standard and other packages the type declarations
+// use.
+func (ts *typeSet) imports() (std, imports []string) {
+	var source strings.Builder
+	for _, decl := range ts.decls {
+		source.WriteString(decl.String())
+	}
+	if strings.Contains(source.String(), "xml.Name") {
+		std = append(std, "encoding/xml")
+	}
+	if strings.Contains(source.String(), "time.Time") {
+		std = append(std, "time")
+	}
+	if strings.Contains(source.String(), "model.Attachment") {
+		imports = append(imports, modelPackage)
+	}
+	return std, imports
+}
+
+func sortedUnique(imports []string) []string {
+	imports = slices.DeleteFunc(slices.Clone(imports), func(s string) bool { return s == "" })
+	slices.Sort(imports)
+	return slices.Compact(imports)
+}
+
+type method struct {
+	// Name is the Go name of the operation, named Operation in the WSDL.
+	Name       string
+	Operation  string
+	Action     string
+	Idempotent bool
+	Request    string
+	Response   string
+	// Param and ParamType are set when the request has a single element
+	// of a simple type, which the client method then takes in place of
+	// the request.
+	Param     string
+	ParamType string
+	Field     string
+	// NoFields is set when the request is empty, so the client method
+	// takes nothing but the context.
+	NoFields bool
+}
+
+func newMethod(ts *typeSet, op wsdl.Operation) (method, error) {
+	input, output := ts.messageType(op.Input), ts.messageType(op.Output)
+	m := method{
+		Name:       exportedName(op.Name),
+		Operation:  op.Name,
+		Action:     op.Action,
+		Idempotent: isReadOnly(op.Name),
+		Request:    input.Type,
+		Response:   output.Type,
+	}
+
+	t := input.Content
+	if t == nil || t.Base != (xml.Name{}) || len(t.Attributes) > 0 {
+		return m, nil
+	}
+	switch len(t.Elements) {
+	case 0:
+		m.NoFields = true
+	case 1:
+		e := t.Elements[0]
+		if _, isComplex := ts.defs.ComplexType(e.Type); isComplex || e.ComplexType != nil || e.Repeated() {
+			break
+		}
+		goType, _, err := ts.goType(e.Type)
+		if err != nil {
+			return method{}, fmt.Errorf("operation %s: %w", op.Name, err)
+		}
+		m.Field = exportedName(e.Name.Local)
+		m.Param = unexportedName(m.Field)
+		m.ParamType = ts.qualify(goType)
+	}
+	return m, nil
+}
+
+// importPath returns the import path of the package in dir, from the
+// go.mod file of its module, or "" if it is in none.
+func importPath(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for root := abs; ; root = filepath.Dir(root) {
+		data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+		if err == nil {
+			rel, err := filepath.Rel(root, abs)
+			if err != nil {
+				return ""
+			}
+			return path.Join(modulePath(data), filepath.ToSlash(rel))
+		}
+		if filepath.Dir(root) == root {
+			return ""
+		}
+	}
+}
+
+// modulePath returns the path in the module directive of a go.mod file.
+func modulePath(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+// packageName derives a package name from the name of dir.
+func packageName(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, filepath.Base(abs))
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		log.Fatalf("cannot derive a package name from %s; set -package", dir)
+	}
+	return name
+}
+
+func writeFile(name string, tmpl *template.Template, data any) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Fatal(err)
+	}
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("formatting %s failed: %v", name, err)
+	}
+	if err := os.WriteFile(name, source, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+const header = `// Code generated by wsdl2go from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+`
+
+const imports = `
+import (
+{{- range .StdImports}}
+	"{{.}}"
+{{- end}}
+{{if and .StdImports .Imports}}
+{{end}}
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+)
+`
+
+var typesTemplate = template.Must(template.New("types").Parse(header + `
+{{- if or .StdImports .Imports}}` + imports + `{{end}}
+{{- range .Decls}}
+{{.}}
+{{- end}}
+`))
+
+var serverTemplate = template.Must(template.New("server").Parse(header + imports + `
+// {{.Name}}Server is implemented by services serving the operations of
+// {{.Service}}. Embed Unimplemented{{.Name}}Server to implement only some.
+type {{.Name}}Server interface {
+{{- range .Methods}}
+	{{.Name}}(ctx context.Context, request {{.Request}}) ({{.Response}}, error)
+{{- end}}
+}
+
+// Register{{.Name}}Server adds the operations of srv to d.
+func Register{{.Name}}Server(d *handler.Dispatcher, srv {{.Name}}Server) {
+{{- range .Methods}}
+	handler.Register(d, {{printf "%q" .Action}}, srv.{{.Name}})
+{{- end}}
+}
+
+// Unimplemented{{.Name}}Server fails every operation.
+type Unimplemented{{.Name}}Server struct{}
+{{range .Methods}}
+func (Unimplemented{{$.Name}}Server) {{.Name}}(context.Context, {{.Request}}) ({{.Response}}, error) {
+	return {{.Response}}{}, errors.New("{{.Operation}} is not implemented")
+}
+{{end}}`))
+
+var clientTemplate = template.Must(template.New("client").Parse(header + `
+{{- if .Imports}}` + imports + `{{end}}
+{{- $c := .ClientQualifier}}
+// {{.Name}}Client calls the operations of {{.Service}}, one method each.
+// Methods of operations whose request has a single field take that value;
+// the others take the request.
+type {{.Name}}Client struct {
+	{{$c}}Client
+}
+
+// New{{.Name}}Client returns a client using transport with the default
+// timeout and retries.
+func New{{.Name}}Client(transport {{$c}}Transport) *{{.Name}}Client {
+	return &{{.Name}}Client{Client: {{$c}}Client{
+		Transport:    transport,
+		Timeout:      {{$c}}DefaultTimeout,
+		MaxRetries:   {{$c}}DefaultMaxRetries,
+		RetryBackoff: {{$c}}DefaultRetryBackoff,
+	}}
+}
+`))
+
+var operationsTemplate = template.Must(template.New("operations").Parse(header + imports + `
+{{- $c := .ClientQualifier}}
+{{- range .Methods}}
+// {{.Name}} calls the {{.Operation}} operation.
+func (c *{{$.Name}}Client) {{.Name}}(ctx context.Context{{if .Param}}, {{.Param}} {{.ParamType}}{{else if not .NoFields}}, request {{.Request}}{{end}}) (*{{.Response}}, error) {
+{{- if .Param}}
+	request := {{.Request}}{ {{- .Field}}: {{.Param -}} }
+{{- else if .NoFields}}
+	var request {{.Request}}
+{{- end}}
+	var response {{.Response}}
+	op := {{$c}}Operation{Name: {{printf "%q" .Operation}}, Action: {{printf "%q" .Action}}, Idempotent: {{.Idempotent}}}
+	if err := c.Client.Call(ctx, op, request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+{{end}}`))
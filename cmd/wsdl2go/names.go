@@ -0,0 +1,96 @@
+package main
+
+import (
+	"go/token"
+	"strings"
+	"unicode"
+)
+
+// initialisms are written in capitals in Go names.
+var initialisms = map[string]bool{"ID": true, "URL": true, "URI": true, "XML": true, "HTTP": true, "JSON": true}
+
+// words splits an XML name into words at capitals and at characters Go
+// names cannot hold, such as userId into user and Id.
+func words(name string) []string {
+	var words []string
+	var word []rune
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				words = append(words, string(word))
+			}
+			word = nil
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// exportedName returns the Go name of an XML name, such as UserID for
+// userId and FirstName for first_name.
+func exportedName(name string) string {
+	var b strings.Builder
+	for _, word := range words(name) {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	if b.Len() == 0 || !unicode.IsLetter(rune(b.String()[0])) {
+		return "X" + b.String()
+	}
+	return b.String()
+}
+
+// reservedParams are names the generated client methods use themselves.
+var reservedParams = map[string]bool{"c": true, "ctx": true, "op": true, "request": true, "response": true, "err": true}
+
+// unexportedName returns the parameter name for a field name, such as
+// userID for UserID and id for ID. Names that are keywords or used by
+// the method itself get a Value suffix.
+func unexportedName(name string) string {
+	param := strings.ToLower(name[:1]) + name[1:]
+	for word := range initialisms {
+		if rest, ok := strings.CutPrefix(name, word); ok {
+			param = strings.ToLower(word) + rest
+			break
+		}
+	}
+	if token.IsKeyword(param) || reservedParams[param] {
+		return param + "Value"
+	}
+	return param
+}
+
+// fileName returns the file name prefix for a Go name, such as
+// stock_quote for StockQuote.
+func fileName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) && i > 0 && !unicode.IsUpper(rune(name[i-1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// isReadOnly reports whether an operation only reads, judging by its
+// name, so that retrying it is safe.
+func isReadOnly(name string) bool {
+	for _, prefix := range []string{"Get", "List", "Search", "Export"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
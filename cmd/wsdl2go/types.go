@@ -0,0 +1,439 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/maasumiyaat/soap/internal/wsdl"
+)
+
+// builtinTypes are the Go types of the built-in XML Schema types. Those
+// not listed, such as xs:date and xs:duration, are kept as strings.
+var builtinTypes = map[string]string{
+	"boolean":            "bool",
+	"int":                "int",
+	"long":               "int64",
+	"integer":            "int64",
+	"negativeInteger":    "int64",
+	"nonNegativeInteger": "int64",
+	"nonPositiveInteger": "int64",
+	"positiveInteger":    "int64",
+	"short":              "int16",
+	"byte":               "int8",
+	"unsignedInt":        "uint",
+	"unsignedLong":       "uint64",
+	"unsignedShort":      "uint16",
+	"unsignedByte":       "uint8",
+	"float":              "float32",
+	"double":             "float64",
+	"decimal":            "float64",
+	"dateTime":           "time.Time",
+	"base64Binary":       "model.Attachment",
+}
+
+// structTypes are the built-in Go types that are structs, which optional
+// elements point to so that they can be left out.
+var structTypes = map[string]bool{"time.Time": true, "model.Attachment": true}
+
+// message is the Go type of a request or response element.
+type message struct {
+	Type string
+	// Content is the complex type of the element when the Go type is a
+	// struct with its fields, and nil for types wrapping another.
+	Content *wsdl.ComplexType
+}
+
+// typeSet names the Go types of the schema types and writes their
+// declarations. With generate unset the types are expected to exist
+// already, named after the schema types, and only their names are used.
+type typeSet struct {
+	defs     *wsdl.Definitions
+	generate bool
+	// qualifier is prefixed to the names of the types outside the types
+	// file, such as "model.".
+	qualifier string
+
+	names     map[xml.Name]string
+	anonymous map[*wsdl.ComplexType]string
+	messages  map[xml.Name]message
+	// elementNames are the XMLName of complex types that are the type of
+	// a single request or response element and nothing else.
+	elementNames map[xml.Name]xml.Name
+	taken        map[string]bool
+
+	decls []*strings.Builder
+}
+
+func newTypeSet(defs *wsdl.Definitions, generate bool, qualifier string, reserved []string) *typeSet {
+	ts := &typeSet{
+		defs:         defs,
+		generate:     generate,
+		qualifier:    qualifier,
+		names:        make(map[xml.Name]string),
+		anonymous:    make(map[*wsdl.ComplexType]string),
+		messages:     make(map[xml.Name]message),
+		elementNames: make(map[xml.Name]xml.Name),
+		taken:        make(map[string]bool),
+	}
+	for _, name := range reserved {
+		ts.taken[name] = true
+	}
+	for _, t := range defs.SimpleTypes {
+		ts.names[t.Name] = ts.take(exportedName(t.Name.Local))
+	}
+	for _, t := range defs.ComplexTypes {
+		ts.names[t.Name] = ts.take(exportedName(t.Name.Local))
+	}
+	for _, e := range defs.Elements {
+		if e.ComplexType != nil {
+			ts.anonymous[e.ComplexType] = ts.take(exportedName(e.Name.Local))
+		}
+	}
+	return ts
+}
+
+// take returns name, or name with a suffix if it is taken, and marks the
+// result taken.
+func (ts *typeSet) take(name string) string {
+	unique := name
+	for i := 2; ts.taken[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	ts.taken[unique] = true
+	return unique
+}
+
+// addMessages decides the Go types of request and response elements.
+// Named complex types used by one such element and nowhere else become
+// the element's type; other elements get a type of their own wrapping
+// theirs.
+func (ts *typeSet) addMessages(elements []xml.Name) error {
+	refs := ts.typeReferences()
+	for _, name := range elements {
+		if _, ok := ts.messages[name]; ok {
+			continue
+		}
+		e, _ := ts.defs.Element(name)
+		if e.ComplexType != nil {
+			ts.messages[name] = message{Type: ts.anonymous[e.ComplexType], Content: e.ComplexType}
+			continue
+		}
+		if t, ok := ts.defs.ComplexType(e.Type); ok && (refs[e.Type] == 1 || !ts.generate) {
+			ts.elementNames[e.Type] = e.Name
+			ts.messages[name] = message{Type: ts.names[e.Type], Content: t}
+			continue
+		}
+		if !ts.generate {
+			return fmt.Errorf("element %s has no complex type to take from the types package", e.Name.Local)
+		}
+
+		goName := exportedName(e.Name.Local)
+		if ts.taken[goName] {
+			goName += "Element"
+		}
+		goName = ts.take(goName)
+		ts.messages[name] = message{Type: goName}
+		if err := ts.writeWrapper(goName, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typeReferences counts the uses of every named type: as the type of an
+// element, anywhere, and as a base type.
+func (ts *typeSet) typeReferences() map[xml.Name]int {
+	refs := make(map[xml.Name]int)
+	visited := make(map[*wsdl.ComplexType]bool)
+	var visit func(t *wsdl.ComplexType)
+	visit = func(t *wsdl.ComplexType) {
+		if t == nil || visited[t] {
+			return
+		}
+		visited[t] = true
+		refs[t.Base]++
+		for _, e := range t.Elements {
+			refs[e.Type]++
+			visit(e.ComplexType)
+		}
+	}
+	for _, e := range ts.defs.Elements {
+		refs[e.Type]++
+		visit(e.ComplexType)
+	}
+	for _, t := range ts.defs.ComplexTypes {
+		visit(t)
+	}
+	return refs
+}
+
+// messageType returns the Go type of a request or response element.
+func (ts *typeSet) messageType(element xml.Name) message {
+	m := ts.messages[element]
+	m.Type = ts.qualify(m.Type)
+	return m
+}
+
+// goType returns the Go type of a named schema type and whether it is a
+// struct.
+func (ts *typeSet) goType(name xml.Name) (string, bool, error) {
+	if name.Space == wsdl.XSDNamespace {
+		goType, ok := builtinTypes[name.Local]
+		if !ok {
+			goType = "string"
+		}
+		return goType, structTypes[goType], nil
+	}
+	goName, ok := ts.names[name]
+	if !ok {
+		return "", false, fmt.Errorf("type %s is not declared", formatName(name))
+	}
+	if _, ok := ts.defs.ComplexType(name); ok {
+		return goName, true, nil
+	}
+	// Simple types are aliases of struct types, see writeSimpleType.
+	_, isStruct, err := ts.underlying(name)
+	return goName, isStruct, err
+}
+
+// qualify returns a Go type for use outside the types file.
+func (ts *typeSet) qualify(goType string) string {
+	if ts.taken[goType] {
+		return ts.qualifier + goType
+	}
+	return goType
+}
+
+// underlying returns the built-in Go type a simple type comes down to.
+func (ts *typeSet) underlying(name xml.Name) (string, bool, error) {
+	// Bounded, as restrictions going round in a circle are invalid.
+	for range 100 {
+		t, ok := ts.defs.SimpleType(name)
+		if !ok {
+			break
+		}
+		name = t.Base
+	}
+	if name.Space != wsdl.XSDNamespace {
+		return "", false, fmt.Errorf("simple type %s is not declared", formatName(name))
+	}
+	goType, ok := builtinTypes[name.Local]
+	if !ok {
+		goType = "string"
+	}
+	return goType, structTypes[goType], nil
+}
+
+// writeTypes writes the declarations of every named type and of the
+// anonymous types of global elements.
+func (ts *typeSet) writeTypes() error {
+	for _, t := range ts.defs.SimpleTypes {
+		if err := ts.writeSimpleType(t); err != nil {
+			return err
+		}
+	}
+	for _, t := range ts.defs.ComplexTypes {
+		if err := ts.writeStruct(ts.names[t.Name], t, ts.elementNames[t.Name]); err != nil {
+			return err
+		}
+	}
+	for _, e := range ts.defs.Elements {
+		if e.ComplexType != nil {
+			if err := ts.writeStruct(ts.anonymous[e.ComplexType], e.ComplexType, e.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ts *typeSet) newDecl() *strings.Builder {
+	decl := new(strings.Builder)
+	ts.decls = append(ts.decls, decl)
+	return decl
+}
+
+// writeSimpleType declares a simple type as a type of its base, with a
+// constant per enumerated value. Types based on time.Time or
+// model.Attachment are aliases, keeping their XML encoding.
+func (ts *typeSet) writeSimpleType(t wsdl.SimpleType) error {
+	goName := ts.names[t.Name]
+	base, _, err := ts.goType(t.Base)
+	if err != nil {
+		return fmt.Errorf("simple type %s: %w", t.Name.Local, err)
+	}
+	underlying, isStruct, err := ts.underlying(t.Name)
+	if err != nil {
+		return err
+	}
+
+	decl := ts.newDecl()
+	if isStruct {
+		fmt.Fprintf(decl, "type %s = %s\n", goName, base)
+		return nil
+	}
+	fmt.Fprintf(decl, "type %s %s\n", goName, base)
+	if underlying != "string" || len(t.Enumeration) == 0 {
+		return nil
+	}
+	fmt.Fprintf(decl, "\nconst (\n")
+	seen := make(map[string]bool)
+	for _, value := range t.Enumeration {
+		name := goName + exportedName(value)
+		if seen[name] || ts.taken[name] {
+			continue
+		}
+		seen[name] = true
+		fmt.Fprintf(decl, "%s %s = %q\n", name, goName, value)
+	}
+	fmt.Fprintf(decl, ")\n")
+	return nil
+}
+
+// writeWrapper declares the type of an element whose schema type is
+// shared: a struct holding the element name and either embedding the
+// complex type or holding the simple content.
+func (ts *typeSet) writeWrapper(goName string, e wsdl.Element) error {
+	goType, _, err := ts.goType(e.Type)
+	if err != nil {
+		return fmt.Errorf("element %s: %w", e.Name.Local, err)
+	}
+	decl := ts.newDecl()
+	fmt.Fprintf(decl, "type %s struct {\n", goName)
+	fmt.Fprintf(decl, "XMLName xml.Name `xml:\"%s %s\"`\n", e.Name.Space, e.Name.Local)
+	if _, ok := ts.defs.ComplexType(e.Type); ok {
+		fmt.Fprintf(decl, "%s\n", goType)
+	} else {
+		fmt.Fprintf(decl, "Value %s `xml:\",chardata\"`\n", chardataType(goType))
+	}
+	fmt.Fprintf(decl, "}\n")
+	return nil
+}
+
+// writeStruct declares the struct of a complex type, with an XMLName
+// field when element is set. Anonymous types of its elements are
+// declared after it, named after the struct and field.
+func (ts *typeSet) writeStruct(goName string, t *wsdl.ComplexType, element xml.Name) error {
+	decl := ts.newDecl()
+	// Binary content, with at most a wildcard attribute such as
+	// xmime:contentType, is an attachment that MTOM messages may carry.
+	base64Binary := xml.Name{Space: wsdl.XSDNamespace, Local: "base64Binary"}
+	if t.SimpleContent && t.Base == base64Binary && len(t.Attributes) == 0 && element == (xml.Name{}) {
+		fmt.Fprintf(decl, "type %s = model.Attachment\n", goName)
+		return nil
+	}
+	fmt.Fprintf(decl, "type %s struct {\n", goName)
+	if element != (xml.Name{}) {
+		fmt.Fprintf(decl, "XMLName xml.Name `xml:\"%s %s\"`\n", element.Space, element.Local)
+	}
+
+	fields := map[string]bool{"XMLName": true}
+	field := func(name string) string {
+		unique := name
+		for i := 2; fields[unique]; i++ {
+			unique = name + strconv.Itoa(i)
+		}
+		fields[unique] = true
+		return unique
+	}
+
+	if t.Base != (xml.Name{}) && t.Base != (xml.Name{Space: wsdl.XSDNamespace, Local: "anyType"}) {
+		base, _, err := ts.goType(t.Base)
+		if err != nil {
+			return fmt.Errorf("type %s: %w", goName, err)
+		}
+		switch _, isComplex := ts.defs.ComplexType(t.Base); {
+		case isComplex:
+			// Embedded, the base type's fields are encoded as if they
+			// were this type's own.
+			fields[base] = true
+			fmt.Fprintf(decl, "%s\n", base)
+		case t.SimpleContent:
+			fmt.Fprintf(decl, "%s %s `xml:\",chardata\"`\n", field("Value"), chardataType(base))
+		}
+	}
+
+	for _, e := range t.Elements {
+		name := field(exportedName(e.Name.Local))
+		goType, isStruct, err := ts.elementType(goName+name, e)
+		if err != nil {
+			return fmt.Errorf("type %s: %w", goName, err)
+		}
+		tag := e.Name.Local
+		// Unqualified names match the namespace in effect, which is that
+		// of the enclosing element.
+		if e.Name.Space != "" && e.Name.Space != t.Namespace {
+			tag = e.Name.Space + " " + tag
+		}
+		switch {
+		case e.Repeated():
+			goType = "[]" + goType
+		case e.Optional() && isStruct:
+			goType = "*" + goType
+			tag += ",omitempty"
+		case e.Optional():
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(decl, "%s %s `xml:\"%s\"`\n", name, goType, tag)
+	}
+
+	for _, a := range t.Attributes {
+		if a.Use == "prohibited" {
+			continue
+		}
+		goType, _, err := ts.goType(a.Type)
+		if err != nil {
+			return fmt.Errorf("type %s: attribute %s: %w", goName, a.Name.Local, err)
+		}
+		tag := a.Name.Local + ",attr"
+		if a.Name.Space != "" {
+			tag = a.Name.Space + " " + tag
+		}
+		if a.Use != "required" {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(decl, "%s %s `xml:\"%s\"`\n", field(exportedName(a.Name.Local)), chardataType(goType), tag)
+	}
+	fmt.Fprintf(decl, "}\n")
+	return nil
+}
+
+// elementType returns the Go type of an element, declaring its anonymous
+// complex type as anonymousName if it has one.
+func (ts *typeSet) elementType(anonymousName string, e wsdl.Element) (string, bool, error) {
+	if e.ComplexType == nil {
+		goType, isStruct, err := ts.goType(e.Type)
+		if err != nil {
+			return "", false, fmt.Errorf("element %s: %w", e.Name.Local, err)
+		}
+		return goType, isStruct, nil
+	}
+	goName, ok := ts.anonymous[e.ComplexType]
+	if !ok {
+		goName = ts.take(anonymousName)
+		ts.anonymous[e.ComplexType] = goName
+		if err := ts.writeStruct(goName, e.ComplexType, xml.Name{}); err != nil {
+			return "", false, err
+		}
+	}
+	return goName, true, nil
+}
+
+// chardataType returns the Go type of text content or of an attribute
+// of type goType: binary data is kept as its base64 text there.
+func chardataType(goType string) string {
+	if goType == "model.Attachment" {
+		return "string"
+	}
+	return goType
+}
+
+// formatName writes a QName as {namespace}local for error messages.
+func formatName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return fmt.Sprintf("{%s}%s", name.Space, name.Local)
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/handler"
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+	bolt "go.etcd.io/bbolt"
+)
+
+// cliCaller is recorded in the audit trail for changes made by subcommands.
+var cliCaller = model.Caller{Principal: "system", Transport: model.TransportCLI}
+
+// commands are the subcommands accepted in place of starting the servers.
+var commands = map[string]func(args []string) error{
+	"import":  runImport,
+	"export":  runExport,
+	"migrate": runMigrate,
+
+	"set-password": runSetPassword,
+	"grant-admin":  runGrantAdmin,
+
+	"backup":        runBackup,
+	"verify-backup": runVerifyBackup,
+	"restore":       runRestore,
+
+	"wsdl": runWSDL,
+}
+
+func runCommand(args []string) error {
+	command, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q (known: import, export, migrate, set-password, grant-admin, backup, verify-backup, restore, wsdl)", args[0])
+	}
+	err := command(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dbPath := flags.String("db", defaultDBPath(), "database file")
+	format := flags.String("format", "", "input format: csv, jsonl or xml (default: from the file extension)")
+	dryRun := flags.Bool("dry-run", false, "validate the input without storing anything")
+	preserveIDs := flags.Bool("preserve-ids", false, "keep the user IDs given in the input")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: soap import [flags] FILE|-")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("import needs exactly one input file")
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = formatFromPath(path)
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	if err := openDatabase(*dbPath); err != nil {
+		return err
+	}
+	defer database.DB.Close()
+
+	s := &service.UserService{}
+	ctx := service.WithCaller(context.Background(), cliCaller)
+	report, err := s.ImportUsers(ctx, in, service.ImportOptions{
+		Format:      *format,
+		DryRun:      *dryRun,
+		PreserveIDs: *preserveIDs,
+	})
+	printImportReport(os.Stdout, report)
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d records failed", report.Failed, report.Processed)
+	}
+	return nil
+}
+
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	dbPath := flags.String("db", defaultDBPath(), "database file")
+	format := flags.String("format", "", "output format: csv, jsonl or xml (default: from -o, else jsonl)")
+	output := flags.String("o", "-", "output file, - for standard output")
+	includeDeleted := flags.Bool("include-deleted", false, "also export deleted users")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = formatFromPath(*output)
+	}
+
+	if err := openExistingDatabase(*dbPath); err != nil {
+		return err
+	}
+	defer database.DB.Close()
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	s := &service.UserService{}
+	ctx := service.WithCaller(context.Background(), cliCaller)
+	count, err := s.ExportUsers(ctx, out, service.ExportOptions{
+		Format:         *format,
+		IncludeDeleted: *includeDeleted,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d users\n", count)
+	return nil
+}
+
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dbPath := flags.String("db", defaultDBPath(), "database file")
+	dryRun := flags.Bool("dry-run", false, "check the pending migrations against the data and roll them back")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: soap migrate [flags] [status]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 || (flags.NArg() == 1 && flags.Arg(0) != "status") {
+		flags.Usage()
+		return errors.New("migrate takes no arguments other than status")
+	}
+
+	if err := database.Open(*dbPath); err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return fmt.Errorf("database %s is in use; stop the server first", *dbPath)
+		}
+		return err
+	}
+	defer database.DB.Close()
+
+	if flags.Arg(0) == "status" {
+		version, states, err := database.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Schema version %d of %d\n", version, database.LatestSchemaVersion())
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = "applied " + state.AppliedAt.Format(time.RFC3339)
+			} else if state.Version <= version {
+				applied = "applied"
+			}
+			fmt.Printf("  %3d  %-40s %s\n", state.Version, state.Description, applied)
+		}
+		return nil
+	}
+
+	applied, err := database.Migrate(*dryRun)
+	for _, m := range applied {
+		fmt.Printf("Migration %d: %s\n", m.Version, m.Description)
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(applied) == 0:
+		fmt.Println("Schema is up to date")
+	case *dryRun:
+		fmt.Printf("%d pending migrations apply cleanly (dry run, nothing changed)\n", len(applied))
+	default:
+		fmt.Printf("Applied %d migrations\n", len(applied))
+	}
+	return nil
+}
+
+// runSetPassword sets the password of a user from the command line, which
+// is how the first admin gets one: over SOAP only admins may set passwords.
+func runSetPassword(args []string) error {
+	flags := flag.NewFlagSet("set-password", flag.ContinueOnError)
+	dbPath := flags.String("db", defaultDBPath(), "database file")
+	id := flags.Int("id", 0, "ID of the user")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: soap set-password [flags] < PASSWORD_FILE")
+		fmt.Fprintln(flags.Output(), "The password is read from the first line of standard input.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *id <= 0 || flags.NArg() != 0 {
+		flags.Usage()
+		return errors.New("set-password needs -id and no arguments")
+	}
+
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	if err := openDatabase(*dbPath); err != nil {
+		return err
+	}
+	defer database.DB.Close()
+
+	s := &service.UserService{}
+	ctx := service.WithCaller(context.Background(), cliCaller)
+	if err := s.SetPassword(ctx, *id, password); err != nil {
+		return err
+	}
+	fmt.Printf("Password set for user with ID %d\n", *id)
+	return nil
+}
+
+// runGrantAdmin adds a user to the admins group. Over SOAP only admins may
+// change the group, so this is how the first admin is made.
+func runGrantAdmin(args []string) error {
+	flags := flag.NewFlagSet("grant-admin", flag.ContinueOnError)
+	dbPath := flags.String("db", defaultDBPath(), "database file")
+	id := flags.Int("id", 0, "ID of the user")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *id <= 0 || flags.NArg() != 0 {
+		flags.Usage()
+		return errors.New("grant-admin needs -id and no arguments")
+	}
+
+	if err := openDatabase(*dbPath); err != nil {
+		return err
+	}
+	defer database.DB.Close()
+
+	if err := service.GrantAdmin(*id); err != nil {
+		return err
+	}
+	fmt.Printf("User with ID %d is now a member of %s\n", *id, service.AdminGroupName)
+	return nil
+}
+
+func runBackup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	dbPath := flags.String("db", defaultDBPath(), "database file to back up while the server is stopped")
+	url := flags.String("url", "", "back up a running server from its /admin/backup endpoint instead")
+	token := flags.String("token", os.Getenv("SOAP_TOKEN"), "admin session token for -url (also $SOAP_TOKEN)")
+	output := flags.String("o", "", "backup file to write")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" {
+		return errors.New("backup needs -o FILE")
+	}
+
+	if *url != "" {
+		if err := downloadBackup(*url, *token, *output); err != nil {
+			return err
+		}
+	} else {
+		if err := openExistingDatabase(*dbPath); err != nil {
+			return err
+		}
+		defer database.DB.Close()
+		if _, err := database.BackupToFile(*output); err != nil {
+			return err
+		}
+	}
+
+	info, err := database.VerifyBackup(*output)
+	if err != nil {
+		return err
+	}
+	printBackupInfo(info)
+	return nil
+}
+
+// downloadBackup saves the backup served at url to path. The download goes
+// to a temporary file first so a failed transfer leaves nothing behind.
+func downloadBackup(url, token, path string) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("backup request failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	tmp := path + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func runVerifyBackup(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: soap verify-backup FILE")
+	}
+	info, err := database.VerifyBackup(args[0])
+	if err != nil {
+		return err
+	}
+	printBackupInfo(info)
+	return nil
+}
+
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	dbPath := flags.String("db", defaultDBPath(), "database file to replace; the server must be stopped")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: soap restore [flags] BACKUP")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("restore needs exactly one backup file")
+	}
+
+	// Opening an existing database takes its lock, which fails while the
+	// server is using it.
+	if _, err := os.Stat(*dbPath); err == nil {
+		if err := database.Open(*dbPath); err != nil {
+			return fmt.Errorf("database %s is in use; stop the server first", *dbPath)
+		}
+		database.DB.Close()
+	}
+
+	info, err := database.RestoreBackup(flags.Arg(0), *dbPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s to %s; the previous database was kept as %s.pre-restore\n", flags.Arg(0), *dbPath, *dbPath)
+	printBackupInfo(info)
+	return nil
+}
+
+// runWSDL writes the WSDL the server serves at ?wsdl, for code generators
+// and clients that cannot fetch it.
+func runWSDL(args []string) error {
+	flags := flag.NewFlagSet("wsdl", flag.ContinueOnError)
+	location := flags.String("location", "http://localhost"+HTTPPort+"/soap/user", "address of the SOAP port")
+	output := flags.String("o", "-", "output file, - for standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	wsdl, err := handler.WSDL(*location)
+	if err != nil {
+		return err
+	}
+	wsdl = append(wsdl, '\n')
+	if *output == "-" {
+		_, err = os.Stdout.Write(wsdl)
+		return err
+	}
+	return os.WriteFile(*output, wsdl, 0o644)
+}
+
+func printBackupInfo(info model.BackupInfo) {
+	fmt.Printf("%s: %d bytes, schema version %d, %d users\n", info.Path, info.Size, info.SchemaVersion, info.Users)
+}
+
+// openDatabase opens the database for a subcommand, explaining the lock
+// timeout bbolt reports while the server holds the file.
+func openDatabase(path string) error {
+	err := database.InitDB(path)
+	if errors.Is(err, bolt.ErrTimeout) {
+		return fmt.Errorf("database %s is in use; stop the server first", path)
+	}
+	return err
+}
+
+// openExistingDatabase opens a database for a subcommand that copies it,
+// leaving its schema as it is: a backup or export taken before an upgrade
+// must hold the data as it was. Databases written by a newer build are
+// refused with database.ErrUnknownSchemaVersion.
+func openExistingDatabase(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	err := database.Open(path)
+	if errors.Is(err, bolt.ErrTimeout) {
+		return fmt.Errorf("database %s is in use; stop the server first", path)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := database.CheckSchemaVersion(); err != nil {
+		database.DB.Close()
+		return err
+	}
+	return nil
+}
+
+// formatFromPath picks a bulk format from a file extension, defaulting to
+// JSON Lines.
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return model.BulkFormatCSV
+	case ".xml":
+		return model.BulkFormatXML
+	default:
+		return model.BulkFormatJSONL
+	}
+}
+
+func printImportReport(w io.Writer, report model.ImportReport) {
+	mode := ""
+	if report.DryRun {
+		mode = " (dry run, nothing stored)"
+	}
+	fmt.Fprintf(w, "Processed %d records: %d imported, %d failed%s\n",
+		report.Processed, report.Imported, report.Failed, mode)
+	for _, e := range report.Errors {
+		fmt.Fprintf(w, "  record %d: %s\n", e.Record, e.Message)
+	}
+	if report.ErrorsTruncated {
+		fmt.Fprintf(w, "  ... %d more failures not listed\n", report.Failed-len(report.Errors))
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maasumiyaat/soap/database"
+	bolt "go.etcd.io/bbolt"
+)
+
+// writeOldDatabase creates a database at path as written by a build with
+// the given schema version, holding one user.
+func writeOldDatabase(t *testing.T, path string, version uint64) {
+	t.Helper()
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = db.Update(func(tx *bolt.Tx) error {
+		users, err := tx.CreateBucket(database.UserBucket)
+		if err != nil {
+			return err
+		}
+		if err := users.Put([]byte("00000001"), []byte(`{"id":1,"name":"Ada","email":"Ada@EXAMPLE.com"}`)); err != nil {
+			return err
+		}
+		if version == 0 {
+			return nil
+		}
+		meta, err := tx.CreateBucket(database.MetaBucket)
+		if err != nil {
+			return err
+		}
+		// The key the migrations record the schema version under.
+		return meta.Put([]byte("schemaVersion"), binary.BigEndian.AppendUint64(nil, version))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupAndExportLeaveTheSchemaAlone(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "user.db")
+	writeOldDatabase(t, dbPath, 0)
+
+	backup := filepath.Join(dir, "backup.db")
+	if err := runBackup([]string{"-db", dbPath, "-o", backup}); err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if err := runExport([]string{"-db", dbPath, "-o", filepath.Join(dir, "users.jsonl")}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	for _, path := range []string{dbPath, backup} {
+		info, err := database.VerifyBackup(path)
+		if err != nil {
+			t.Fatalf("VerifyBackup(%s): %v", path, err)
+		}
+		if info.SchemaVersion != 0 || info.Users != 1 {
+			t.Errorf("%s has %d users at schema version %d, want 1 at version 0", path, info.Users, info.SchemaVersion)
+		}
+	}
+}
+
+func TestBackupAndExportRefuseNewerSchema(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "user.db")
+	writeOldDatabase(t, dbPath, uint64(database.LatestSchemaVersion()+1))
+
+	backup := filepath.Join(dir, "backup.db")
+	if err := runBackup([]string{"-db", dbPath, "-o", backup}); !errors.Is(err, database.ErrUnknownSchemaVersion) {
+		t.Errorf("backup error = %v, want ErrUnknownSchemaVersion", err)
+	}
+	export := filepath.Join(dir, "users.jsonl")
+	if err := runExport([]string{"-db", dbPath, "-o", export}); !errors.Is(err, database.ErrUnknownSchemaVersion) {
+		t.Errorf("export error = %v, want ErrUnknownSchemaVersion", err)
+	}
+	for _, path := range []string{backup, export} {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s was written: %v", path, err)
+		}
+	}
+
+	if err := runBackup([]string{"-db", filepath.Join(dir, "missing.db"), "-o", backup}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("backup of a missing database error = %v, want ErrNotExist", err)
+	}
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// AuditBucket holds one nested bucket per user ID, keyed by a big-endian
+// sequence number so entries iterate in the order they were written.
+var AuditBucket = []byte("UserAudit")
+
+// recordChange is called inside every user-mutating transaction, so the
+// audit entry, the change log entry and any webhook deliveries commit or roll
+// back together with the change itself.
+func recordChange(tx *bolt.Tx, caller model.Caller, action string, before, after *model.User) error {
+	userID := 0
+	if after != nil {
+		userID = after.ID
+	} else if before != nil {
+		userID = before.ID
+	}
+
+	entry := &model.AuditEntry{
+		UserID:     userID,
+		Action:     action,
+		Before:     before,
+		After:      after,
+		Principal:  caller.Principal,
+		Transport:  caller.Transport,
+		ClientAddr: caller.ClientAddr,
+		Timestamp:  time.Now().UTC(),
+	}
+	if err := appendAudit(tx, entry); err != nil {
+		return err
+	}
+
+	change := &model.Change{
+		Action:    action,
+		UserID:    userID,
+		Timestamp: entry.Timestamp,
+		User:      after,
+	}
+	if err := appendChange(tx, change); err != nil {
+		return err
+	}
+	return enqueueWebhookDeliveries(tx, change)
+}
+
+func appendAudit(tx *bolt.Tx, entry *model.AuditEntry) error {
+	root, err := tx.CreateBucketIfNotExists(AuditBucket)
+	if err != nil {
+		return err
+	}
+	bucket, err := root.CreateBucketIfNotExists([]byte(strconv.Itoa(entry.UserID)))
+	if err != nil {
+		return err
+	}
+
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	entry.Sequence = seq
+
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(sequenceKey(seq), buf)
+}
+
+// GetUserHistory returns up to limit audit entries for a user with a sequence
+// greater than afterSequence, oldest first. more reports whether further
+// entries exist beyond the returned page.
+func GetUserHistory(userID int, afterSequence uint64, limit int) (entries []model.AuditEntry, more bool, err error) {
+	err = DB.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(AuditBucket)
+		if root == nil {
+			return nil
+		}
+		bucket := root.Bucket([]byte(strconv.Itoa(userID)))
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.Seek(sequenceKey(afterSequence + 1)); k != nil; k, v = c.Next() {
+			if len(entries) == limit {
+				more = true
+				break
+			}
+
+			var entry model.AuditEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	return entries, more, err
+}
+
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// AvatarBucket maps user IDs to avatar metadata and AvatarBlobBucket to
+// the images, so that reading the metadata never loads an image.
+var (
+	AvatarBucket     = []byte("Avatars")
+	AvatarBlobBucket = []byte("AvatarBlobs")
+)
+
+// ErrAvatarNotFound is returned for users without an avatar.
+var ErrAvatarNotFound = errors.New("avatar not found")
+
+// SetAvatar stores the avatar of an active user, replacing any previous
+// one, and records the change in the audit trail.
+func SetAvatar(caller model.Caller, avatar *model.Avatar, data []byte) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		user, err := getActiveUser(tx, avatar.UserID)
+		if err != nil {
+			return err
+		}
+
+		meta, err := tx.CreateBucketIfNotExists(AvatarBucket)
+		if err != nil {
+			return err
+		}
+		blobs, err := tx.CreateBucketIfNotExists(AvatarBlobBucket)
+		if err != nil {
+			return err
+		}
+		buf, err := json.Marshal(avatar)
+		if err != nil {
+			return err
+		}
+		key := []byte(strconv.Itoa(avatar.UserID))
+		if err := meta.Put(key, buf); err != nil {
+			return err
+		}
+		if err := blobs.Put(key, data); err != nil {
+			return err
+		}
+
+		return appendAudit(tx, &model.AuditEntry{
+			UserID:     user.ID,
+			Action:     model.AuditActionAvatar,
+			Principal:  caller.Principal,
+			Transport:  caller.Transport,
+			ClientAddr: caller.ClientAddr,
+			Timestamp:  avatar.UpdatedAt,
+		})
+	})
+}
+
+// GetAvatar returns the avatar of an active user and its image.
+func GetAvatar(userID int) (*model.Avatar, []byte, error) {
+	var avatar model.Avatar
+	var data []byte
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		if _, err := getActiveUser(tx, userID); err != nil {
+			return err
+		}
+		meta, blobs := tx.Bucket(AvatarBucket), tx.Bucket(AvatarBlobBucket)
+		if meta == nil || blobs == nil {
+			return ErrAvatarNotFound
+		}
+		key := []byte(strconv.Itoa(userID))
+		v, blob := meta.Get(key), blobs.Get(key)
+		if v == nil || blob == nil {
+			return ErrAvatarNotFound
+		}
+		// Values are only valid during the transaction.
+		data = bytes.Clone(blob)
+		return json.Unmarshal(v, &avatar)
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return &avatar, data, nil
+}
+
+// deleteAvatar removes the avatar of a user, if any.
+func deleteAvatar(tx *bolt.Tx, userID int) error {
+	key := []byte(strconv.Itoa(userID))
+	for _, name := range [][]byte{AvatarBucket, AvatarBlobBucket} {
+		if bucket := tx.Bucket(name); bucket != nil {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrInvalidBackup is matched by every error returned for a backup file that
+// fails verification.
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// Backup streams a consistent copy of the open database to w from a single
+// read transaction, so writers are not blocked while it runs.
+func Backup(w io.Writer) (int64, error) {
+	var n int64
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// BackupToFile writes a backup of the open database to path and verifies
+// it. The copy is written to a temporary file in the same directory and
+// renamed into place, so path never holds a partial backup.
+func BackupToFile(path string) (model.BackupInfo, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return model.BackupInfo{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := Backup(tmp); err != nil {
+		tmp.Close()
+		return model.BackupInfo{}, fmt.Errorf("writing backup: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return model.BackupInfo{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return model.BackupInfo{}, err
+	}
+
+	if _, err := VerifyBackup(tmp.Name()); err != nil {
+		return model.BackupInfo{}, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return model.BackupInfo{}, err
+	}
+	return VerifyBackup(path)
+}
+
+// VerifyBackup opens a backup file read-only, checks the consistency of
+// every page and bucket, and makes sure this build understands its schema
+// version.
+func VerifyBackup(path string) (model.BackupInfo, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return model.BackupInfo{}, err
+	}
+	info := model.BackupInfo{
+		Path:      path,
+		Size:      stat.Size(),
+		CreatedAt: stat.ModTime().UTC(),
+	}
+
+	db, err := bolt.Open(path, 0400, &bolt.Options{ReadOnly: true, Timeout: 1 * time.Second})
+	if err != nil {
+		return info, fmt.Errorf("%w: %s: %v", ErrInvalidBackup, path, err)
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		// Check reports from a goroutine reading tx, so drain every error
+		// before the transaction ends.
+		var checkErr error
+		for err := range tx.Check() {
+			if checkErr == nil {
+				checkErr = err
+			}
+		}
+		if checkErr != nil {
+			return checkErr
+		}
+
+		version, err := schemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		if version > LatestSchemaVersion() {
+			return fmt.Errorf("schema version %d is newer than %d", version, LatestSchemaVersion())
+		}
+		info.SchemaVersion = version
+
+		if bucket := tx.Bucket(UserBucket); bucket != nil {
+			info.Users = bucket.Stats().KeyN
+		}
+		return nil
+	})
+	if err != nil {
+		return info, fmt.Errorf("%w: %s: %v", ErrInvalidBackup, path, err)
+	}
+	return info, nil
+}
+
+// RestoreBackup verifies a backup file and swaps it in as the database at
+// dbPath, which must not be open. An existing database is kept next to it
+// with a ".pre-restore" suffix.
+func RestoreBackup(backupPath, dbPath string) (model.BackupInfo, error) {
+	info, err := VerifyBackup(backupPath)
+	if err != nil {
+		return info, err
+	}
+
+	src, err := os.Open(backupPath)
+	if err != nil {
+		return info, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), filepath.Base(dbPath)+".restore*")
+	if err != nil {
+		return info, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return info, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return info, err
+	}
+	if err := tmp.Close(); err != nil {
+		return info, err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return info, err
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := os.Rename(dbPath, dbPath+".pre-restore"); err != nil {
+			return info, err
+		}
+	}
+	if err := os.Rename(tmp.Name(), dbPath); err != nil {
+		return info, err
+	}
+	info.Path = dbPath
+	return info, nil
+}
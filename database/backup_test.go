@@ -0,0 +1,80 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	openTestDB(t)
+	alice := createTestUser(t, "Alice", "alice@example.com")
+	createTestUser(t, "Bob", "bob@example.com")
+
+	backup := filepath.Join(t.TempDir(), "backup.db")
+	info, err := BackupToFile(backup)
+	if err != nil {
+		t.Fatalf("BackupToFile: %v", err)
+	}
+	if info.Path != backup || info.Users != 2 || info.SchemaVersion != LatestSchemaVersion() {
+		t.Errorf("BackupToFile = %+v, want 2 users at schema version %d", info, LatestSchemaVersion())
+	}
+	if verified, err := VerifyBackup(backup); err != nil || verified.Users != 2 {
+		t.Fatalf("VerifyBackup = %+v, %v", verified, err)
+	}
+
+	// Changes after the backup are undone by restoring it.
+	createTestUser(t, "Carol", "carol@example.com")
+	if _, err := DeleteUserIfExists(testCaller, alice.ID); err != nil {
+		t.Fatalf("DeleteUserIfExists: %v", err)
+	}
+	dbPath := DB.Path()
+	DB.Close()
+
+	restored, err := RestoreBackup(backup, dbPath)
+	if err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+	if restored.Path != dbPath || restored.Users != 2 {
+		t.Errorf("RestoreBackup = %+v, want 2 users at %s", restored, dbPath)
+	}
+	if previous, err := VerifyBackup(dbPath + ".pre-restore"); err != nil || previous.Users != 3 {
+		t.Errorf("the replaced database = %+v, %v, want it kept with 3 users", previous, err)
+	}
+
+	if err := Open(dbPath); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if user, err := GetUserByID(alice.ID); err != nil || user.Email != alice.Email {
+		t.Errorf("GetUserByID(alice) = %+v, %v after the restore", user, err)
+	}
+	if users, err := FindUsersByEmail("carol@example.com"); err != nil || len(users) != 0 {
+		t.Errorf("FindUsersByEmail(carol) = %v, %v after the restore, want none", users, err)
+	}
+}
+
+func TestRestoreInvalidBackup(t *testing.T) {
+	dir := t.TempDir()
+	backup := filepath.Join(dir, "backup.db")
+	if err := os.WriteFile(backup, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(dir, "user.db")
+	if err := os.WriteFile(dbPath, []byte("live"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := VerifyBackup(backup); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("VerifyBackup error = %v, want ErrInvalidBackup", err)
+	}
+	if _, err := RestoreBackup(backup, dbPath); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("RestoreBackup error = %v, want ErrInvalidBackup", err)
+	}
+	if data, err := os.ReadFile(dbPath); err != nil || string(data) != "live" {
+		t.Errorf("the database was replaced by an invalid backup: %q, %v", data, err)
+	}
+	if _, err := os.Stat(dbPath + ".pre-restore"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("an invalid backup left a .pre-restore file: %v", err)
+	}
+}
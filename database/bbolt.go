@@ -10,19 +10,24 @@ import (
 var DB *bolt.DB
 var UserBucket = []byte("Users")
 
-func InitDB(dbPath string) error {
+// Open opens the database file without changing its contents.
+func Open(dbPath string) error {
 	var err error
 	DB, err = bolt.Open(dbPath, 0600, &bolt.Options{Timeout: 1 * time.Second})
-	if err != nil {
+	return err
+}
+
+// InitDB opens the database and applies any pending schema migrations. It
+// refuses databases with a schema version this build does not know.
+func InitDB(dbPath string) error {
+	if err := Open(dbPath); err != nil {
 		return err
 	}
 
-	return DB.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(UserBucket)
-		if err != nil {
-			return err
-		}
-		log.Println("bbolt database initialized successfully.")
-		return nil
-	})
+	if _, err := Migrate(false); err != nil {
+		DB.Close()
+		return err
+	}
+	log.Println("bbolt database initialized successfully.")
+	return nil
 }
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ImportUser stores a user read from an import file and records its
+// creation. A zero ID is assigned as in CreateUser; any other ID is kept and
+// must not belong to an existing record, deleted or not. Only active users
+// need an unused email.
+func (t *Tx) ImportUser(user *model.User) error {
+	if t.broken != nil {
+		return t.broken
+	}
+	if user.ID != 0 {
+		if _, err := getUser(t.tx, user.ID); err == nil {
+			return fmt.Errorf("user with ID %d: %w", user.ID, ErrUserExists)
+		} else if !errors.Is(err, ErrUserNotFound) {
+			return err
+		}
+	}
+	if user.DeletedAt == nil {
+		if err := checkEmailAvailable(t.tx, user.Email, 0); err != nil {
+			return err
+		}
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now().UTC()
+	}
+
+	return t.write(func() error {
+		if err := putUser(t.tx, user); err != nil {
+			return err
+		}
+		return recordChange(t.tx, t.caller, model.AuditActionCreate, nil, user)
+	})
+}
+
+// ExportUsers calls fn for every stored user, in key order, from a single
+// read transaction so the export is a consistent snapshot even while
+// writes continue. Deleted users are skipped unless includeDeleted is set.
+// Iteration stops at the first error returned by fn.
+func ExportUsers(includeDeleted bool, fn func(user *model.User) error) error {
+	return DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(UserBucket)
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", UserBucket)
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var user model.User
+			if err := json.Unmarshal(v, &user); err != nil {
+				return err
+			}
+			if user.DeletedAt != nil && !includeDeleted {
+				return nil
+			}
+			return fn(&user)
+		})
+	})
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ChangeLogBucket is the change data capture feed: one entry per user
+// mutation, keyed by a big-endian global sequence number.
+var ChangeLogBucket = []byte("ChangeLog")
+
+// changeSignal is closed and replaced whenever a transaction that appended
+// to the change log commits.
+var changeSignal = struct {
+	sync.Mutex
+	ch chan struct{}
+}{ch: make(chan struct{})}
+
+// ChangesCommitted returns a channel that is closed the next time changes
+// are committed. Take the channel before reading the log so that a commit
+// in between is not missed.
+func ChangesCommitted() <-chan struct{} {
+	changeSignal.Lock()
+	defer changeSignal.Unlock()
+	return changeSignal.ch
+}
+
+func notifyChangesCommitted() {
+	changeSignal.Lock()
+	defer changeSignal.Unlock()
+	close(changeSignal.ch)
+	changeSignal.ch = make(chan struct{})
+}
+
+// appendChange adds a change to the log within tx. Waiters are woken only
+// once tx commits.
+func appendChange(tx *bolt.Tx, change *model.Change) error {
+	bucket, err := tx.CreateBucketIfNotExists(ChangeLogBucket)
+	if err != nil {
+		return err
+	}
+
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	change.Sequence = seq
+
+	buf, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	if err := bucket.Put(sequenceKey(seq), buf); err != nil {
+		return err
+	}
+	tx.OnCommit(notifyChangesCommitted)
+	return nil
+}
+
+// LatestChangeSequence returns the sequence of the most recent change, or
+// zero when nothing has been logged yet.
+func LatestChangeSequence() (uint64, error) {
+	var seq uint64
+	err := DB.View(func(tx *bolt.Tx) error {
+		seq = latestChangeSequence(tx)
+		return nil
+	})
+	return seq, err
+}
+
+func latestChangeSequence(tx *bolt.Tx) uint64 {
+	if bucket := tx.Bucket(ChangeLogBucket); bucket != nil {
+		return bucket.Sequence()
+	}
+	return 0
+}
+
+// GetChanges returns up to limit changes with a sequence greater than
+// sinceSequence, oldest first, whether more follow, and the oldest sequence
+// still in the log (zero when it is empty).
+func GetChanges(sinceSequence uint64, limit int) (changes []model.Change, more bool, oldest uint64, err error) {
+	err = DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(ChangeLogBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		if k, _ := c.First(); k != nil {
+			oldest = binary.BigEndian.Uint64(k)
+		}
+		for k, v := c.Seek(sequenceKey(sinceSequence + 1)); k != nil; k, v = c.Next() {
+			if len(changes) == limit {
+				more = true
+				break
+			}
+
+			var change model.Change
+			if err := json.Unmarshal(v, &change); err != nil {
+				return err
+			}
+			changes = append(changes, change)
+		}
+		return nil
+	})
+	return changes, more, oldest, err
+}
+
+// PurgeChangesBefore removes changes older than cutoff from the start of the
+// log and returns how many were removed. The sequence keeps counting, so
+// consumers can tell they missed changes from OldestSequence.
+func PurgeChangesBefore(cutoff time.Time) (int, error) {
+	purged := 0
+
+	err := DB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(ChangeLogBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		var expired [][]byte
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var change model.Change
+			if err := json.Unmarshal(v, &change); err != nil {
+				return err
+			}
+			if !change.Timestamp.Before(cutoff) {
+				break
+			}
+			expired = append(expired, bytes.Clone(k))
+		}
+
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		purged = len(expired)
+		return nil
+	})
+	return purged, err
+}
@@ -0,0 +1,268 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// CredentialBucket maps user IDs to password hashes, kept apart from the
+// profile data in UserBucket. SessionBucket maps hashed session tokens to
+// the session they identify.
+var (
+	CredentialBucket = []byte("UserCredentials")
+	SessionBucket    = []byte("Sessions")
+)
+
+var (
+	// ErrNoCredential is returned for users that have no password set.
+	ErrNoCredential = errors.New("no password set")
+	// ErrSessionNotFound is returned for unknown or expired session tokens.
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// GetCredential returns the stored password state of a user.
+func GetCredential(userID int) (*model.Credential, error) {
+	var cred *model.Credential
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		var err error
+		cred, err = getCredential(tx, userID)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// SetCredential stores a new password hash for an active user, clears any
+// lockout and ends the user's existing sessions. The change is recorded in
+// the audit trail without the hash.
+func SetCredential(caller model.Caller, userID int, hash string) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		user, err := getActiveUser(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		cred := &model.Credential{
+			UserID:    userID,
+			Hash:      hash,
+			UpdatedAt: time.Now().UTC(),
+		}
+		if err := putCredential(tx, cred); err != nil {
+			return err
+		}
+		if err := deleteUserSessions(tx, userID); err != nil {
+			return err
+		}
+
+		return appendAudit(tx, &model.AuditEntry{
+			UserID:     user.ID,
+			Action:     model.AuditActionPassword,
+			Principal:  caller.Principal,
+			Transport:  caller.Transport,
+			ClientAddr: caller.ClientAddr,
+			Timestamp:  cred.UpdatedAt,
+		})
+	})
+}
+
+// RecordLoginFailure counts a failed password check and locks the account
+// for lockout once maxAttempts consecutive failures are reached. It returns
+// the updated credential.
+func RecordLoginFailure(userID, maxAttempts int, lockout time.Duration) (*model.Credential, error) {
+	var cred *model.Credential
+
+	err := DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		cred, err = getCredential(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		cred.FailedAttempts++
+		if cred.FailedAttempts >= maxAttempts {
+			until := time.Now().UTC().Add(lockout)
+			cred.LockedUntil = &until
+			cred.FailedAttempts = 0
+		}
+		return putCredential(tx, cred)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// RecordLoginSuccess clears the failure count of a user and, if session is
+// not nil, stores it under the hash of its token.
+func RecordLoginSuccess(userID int, tokenHash []byte, session *model.Session) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		cred, err := getCredential(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		cred.FailedAttempts = 0
+		cred.LockedUntil = nil
+		if err := putCredential(tx, cred); err != nil {
+			return err
+		}
+
+		if session == nil {
+			return nil
+		}
+		bucket, err := tx.CreateBucketIfNotExists(SessionBucket)
+		if err != nil {
+			return err
+		}
+		buf, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(tokenHash, buf)
+	})
+}
+
+// GetSession returns the unexpired session stored under tokenHash.
+func GetSession(tokenHash []byte) (*model.Session, error) {
+	var session model.Session
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(SessionBucket)
+		if bucket == nil {
+			return ErrSessionNotFound
+		}
+		v := bucket.Get(tokenHash)
+		if v == nil {
+			return ErrSessionNotFound
+		}
+		return json.Unmarshal(v, &session)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(session.ExpiresAt) {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+// PurgeExpiredSessions removes sessions that expired before now and returns
+// how many were removed.
+func PurgeExpiredSessions(now time.Time) (int, error) {
+	purged := 0
+
+	err := DB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(SessionBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		var expired [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			var session model.Session
+			if err := json.Unmarshal(v, &session); err != nil {
+				return err
+			}
+			if !now.Before(session.ExpiresAt) {
+				expired = append(expired, bytes.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		purged = len(expired)
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+func getCredential(tx *bolt.Tx, userID int) (*model.Credential, error) {
+	bucket := tx.Bucket(CredentialBucket)
+	if bucket == nil {
+		return nil, ErrNoCredential
+	}
+	v := bucket.Get([]byte(strconv.Itoa(userID)))
+	if v == nil {
+		return nil, ErrNoCredential
+	}
+
+	var cred model.Credential
+	if err := json.Unmarshal(v, &cred); err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+func putCredential(tx *bolt.Tx, cred *model.Credential) error {
+	bucket, err := tx.CreateBucketIfNotExists(CredentialBucket)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(cred)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(strconv.Itoa(cred.UserID)), buf)
+}
+
+// deleteUserCredentials removes the password and every session of a user.
+func deleteUserCredentials(tx *bolt.Tx, userID int) error {
+	if bucket := tx.Bucket(CredentialBucket); bucket != nil {
+		if err := bucket.Delete([]byte(strconv.Itoa(userID))); err != nil {
+			return err
+		}
+	}
+	return deleteUserSessions(tx, userID)
+}
+
+func deleteUserSessions(tx *bolt.Tx, userID int) error {
+	bucket := tx.Bucket(SessionBucket)
+	if bucket == nil {
+		return nil
+	}
+
+	var owned [][]byte
+	err := bucket.ForEach(func(k, v []byte) error {
+		var session model.Session
+		if err := json.Unmarshal(v, &session); err != nil {
+			return err
+		}
+		if session.UserID == userID {
+			owned = append(owned, bytes.Clone(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range owned {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
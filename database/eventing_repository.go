@@ -0,0 +1,204 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// EventSubscriptionBucket maps WS-Eventing subscription identifiers to
+// subscriptions.
+var EventSubscriptionBucket = []byte("EventSubscriptions")
+
+// ErrSubscriptionNotFound is returned for unknown subscription identifiers.
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+// CreateEventSubscription stores a new subscription. Only changes committed
+// after it are delivered.
+func CreateEventSubscription(sub *model.EventSubscription) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		sub.LastSequence = latestChangeSequence(tx)
+		sub.CreatedAt = time.Now().UTC()
+		return putEventSubscription(tx, sub)
+	})
+}
+
+// GetEventSubscription returns a subscription, whether or not it has
+// expired.
+func GetEventSubscription(id string) (*model.EventSubscription, error) {
+	var sub *model.EventSubscription
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		var err error
+		sub, err = getEventSubscription(tx, id)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// ListEventSubscriptions returns every stored subscription.
+func ListEventSubscriptions() ([]model.EventSubscription, error) {
+	var subs []model.EventSubscription
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(EventSubscriptionBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var sub model.EventSubscription
+			if err := json.Unmarshal(v, &sub); err != nil {
+				return err
+			}
+			subs = append(subs, sub)
+			return nil
+		})
+	})
+	return subs, err
+}
+
+// RenewEventSubscription moves the expiry of a subscription.
+func RenewEventSubscription(id string, expires time.Time) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		sub, err := getEventSubscription(tx, id)
+		if err != nil {
+			return err
+		}
+		sub.Expires = expires
+		return putEventSubscription(tx, sub)
+	})
+}
+
+// DeleteEventSubscription removes a subscription and returns it.
+func DeleteEventSubscription(id string) (*model.EventSubscription, error) {
+	var sub *model.EventSubscription
+
+	err := DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		if sub, err = getEventSubscription(tx, id); err != nil {
+			return err
+		}
+		return tx.Bucket(EventSubscriptionBucket).Delete([]byte(id))
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// RecordEventDelivery advances a subscription past the changes delivered up
+// to lastSequence. A non-nil deliveryErr counts as a failed attempt, to be
+// retried at nextAttempt; success resets the failure count. It returns the
+// updated subscription, or nil if it was removed in the meantime.
+func RecordEventDelivery(id string, lastSequence uint64, deliveryErr error, nextAttempt time.Time) (*model.EventSubscription, error) {
+	var sub *model.EventSubscription
+
+	err := DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		sub, err = getEventSubscription(tx, id)
+		if errors.Is(err, ErrSubscriptionNotFound) {
+			sub = nil
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		sub.LastSequence = max(sub.LastSequence, lastSequence)
+		if deliveryErr == nil {
+			sub.Failures = 0
+			sub.NextAttemptAt = time.Time{}
+			sub.LastError = ""
+		} else {
+			sub.Failures++
+			sub.NextAttemptAt = nextAttempt
+			sub.LastError = deliveryErr.Error()
+		}
+		return putEventSubscription(tx, sub)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// PurgeExpiredSubscriptions removes subscriptions that expired before now
+// and returns how many were removed.
+func PurgeExpiredSubscriptions(now time.Time) (int, error) {
+	purged := 0
+
+	err := DB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(EventSubscriptionBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		var expired [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			var sub model.EventSubscription
+			if err := json.Unmarshal(v, &sub); err != nil {
+				return err
+			}
+			if !now.Before(sub.Expires) {
+				expired = append(expired, bytes.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		purged = len(expired)
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+func getEventSubscription(tx *bolt.Tx, id string) (*model.EventSubscription, error) {
+	bucket := tx.Bucket(EventSubscriptionBucket)
+	if bucket == nil {
+		return nil, fmt.Errorf("subscription %s: %w", id, ErrSubscriptionNotFound)
+	}
+	v := bucket.Get([]byte(id))
+	if v == nil {
+		return nil, fmt.Errorf("subscription %s: %w", id, ErrSubscriptionNotFound)
+	}
+
+	var sub model.EventSubscription
+	if err := json.Unmarshal(v, &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func putEventSubscription(tx *bolt.Tx, sub *model.EventSubscription) error {
+	bucket, err := tx.CreateBucketIfNotExists(EventSubscriptionBucket)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(sub)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(sub.ID), buf)
+}
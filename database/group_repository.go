@@ -0,0 +1,411 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// GroupBucket maps group IDs to groups and GroupNameBucket maps lowercase
+// group names to IDs. Memberships are indexed in both directions:
+// GroupMemberBucket holds a nested bucket per group keyed by user ID, and
+// UserGroupBucket a nested bucket per user keyed by group ID.
+var (
+	GroupBucket       = []byte("Groups")
+	GroupNameBucket   = []byte("GroupNames")
+	GroupMemberBucket = []byte("GroupMembers")
+	UserGroupBucket   = []byte("UserGroups")
+)
+
+var (
+	// ErrGroupNotFound is matched by every error returned for a missing group.
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrGroupNameTaken is returned when a group name is already in use.
+	ErrGroupNameTaken = errors.New("group name already in use")
+	// ErrMembershipNotFound is returned when removing a user that is not a
+	// member of the group.
+	ErrMembershipNotFound = errors.New("membership not found")
+)
+
+// GroupNotFoundError reports the ID of a group that does not exist.
+type GroupNotFoundError struct {
+	ID int
+}
+
+func (e *GroupNotFoundError) Error() string {
+	return fmt.Sprintf("group with ID %d not found", e.ID)
+}
+
+func (e *GroupNotFoundError) Is(target error) bool {
+	return target == ErrGroupNotFound
+}
+
+func GetGroupByID(id int) (*model.Group, error) {
+	var group *model.Group
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		var err error
+		group, err = getGroup(tx, id)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// GetGroupByName looks a group up by name, ignoring case.
+func GetGroupByName(name string) (*model.Group, error) {
+	var group *model.Group
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		names := tx.Bucket(GroupNameBucket)
+		if names == nil {
+			return fmt.Errorf("group %q: %w", name, ErrGroupNotFound)
+		}
+		v := names.Get(groupNameKey(name))
+		if v == nil {
+			return fmt.Errorf("group %q: %w", name, ErrGroupNotFound)
+		}
+
+		id, err := strconv.Atoi(string(v))
+		if err != nil {
+			return err
+		}
+		group, err = getGroup(tx, id)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// CreateGroup stores a new group, assigning its ID.
+func CreateGroup(group *model.Group) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(GroupBucket)
+		if err != nil {
+			return err
+		}
+		id, _ := bucket.NextSequence()
+		group.ID = int(id)
+		group.CreatedAt = time.Now().UTC()
+		return putGroup(tx, group, "")
+	})
+}
+
+// UpdateGroup loads a group, applies fn to it and stores the result in a
+// single transaction.
+func UpdateGroup(id int, fn func(group *model.Group) error) (*model.Group, error) {
+	var group *model.Group
+
+	err := DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		group, err = getGroup(tx, id)
+		if err != nil {
+			return err
+		}
+		oldName := group.Name
+
+		if err := fn(group); err != nil {
+			return err
+		}
+
+		group.ID = id
+		return putGroup(tx, group, oldName)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// DeleteGroup removes a group together with all of its memberships.
+func DeleteGroup(id int) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		group, err := getGroup(tx, id)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range listMemberships(tx, GroupMemberBucket, id) {
+			if err := deleteMembership(tx, m.GroupID, m.UserID); err != nil {
+				return err
+			}
+		}
+		if members := tx.Bucket(GroupMemberBucket); members != nil && members.Bucket(idKeyString(id)) != nil {
+			if err := members.DeleteBucket(idKeyString(id)); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Bucket(GroupNameBucket).Delete(groupNameKey(group.Name)); err != nil {
+			return err
+		}
+		return tx.Bucket(GroupBucket).Delete(idKeyString(id))
+	})
+}
+
+// AddGroupMember adds an active user to a group, or changes the role of an
+// existing member.
+func AddGroupMember(groupID, userID int, role string) (*model.Membership, error) {
+	membership := &model.Membership{
+		GroupID: groupID,
+		UserID:  userID,
+		Role:    role,
+		AddedAt: time.Now().UTC(),
+	}
+
+	err := DB.Update(func(tx *bolt.Tx) error {
+		if _, err := getGroup(tx, groupID); err != nil {
+			return err
+		}
+		if _, err := getActiveUser(tx, userID); err != nil {
+			return err
+		}
+		return putMembership(tx, membership)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// RemoveGroupMember removes a user from a group.
+func RemoveGroupMember(groupID, userID int) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		if _, err := getGroup(tx, groupID); err != nil {
+			return err
+		}
+		if getMembership(tx, groupID, userID) == nil {
+			return fmt.Errorf("user %d in group %d: %w", userID, groupID, ErrMembershipNotFound)
+		}
+		return deleteMembership(tx, groupID, userID)
+	})
+}
+
+// GetMembership returns the membership of a user in a group, or nil if the
+// user is not a member.
+func GetMembership(groupID, userID int) (*model.Membership, error) {
+	var membership *model.Membership
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		membership = getMembership(tx, groupID, userID)
+		return nil
+	})
+	return membership, err
+}
+
+// ListGroupMembers returns the members of a group with their user records.
+func ListGroupMembers(groupID int) ([]model.GroupMember, error) {
+	var members []model.GroupMember
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		if _, err := getGroup(tx, groupID); err != nil {
+			return err
+		}
+		for _, m := range listMemberships(tx, GroupMemberBucket, groupID) {
+			user, err := getUser(tx, m.UserID)
+			if err != nil {
+				return err
+			}
+			members = append(members, model.GroupMember{User: *user, Role: m.Role, AddedAt: m.AddedAt})
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// ListUserGroups returns the groups an active user belongs to.
+func ListUserGroups(userID int) ([]model.UserGroup, error) {
+	var groups []model.UserGroup
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		if _, err := getActiveUser(tx, userID); err != nil {
+			return err
+		}
+		for _, m := range listMemberships(tx, UserGroupBucket, userID) {
+			group, err := getGroup(tx, m.GroupID)
+			if err != nil {
+				return err
+			}
+			groups = append(groups, model.UserGroup{Group: *group, Role: m.Role, AddedAt: m.AddedAt})
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// removeUserMemberships drops a user from every group it belongs to.
+func removeUserMemberships(tx *bolt.Tx, userID int) error {
+	for _, m := range listMemberships(tx, UserGroupBucket, userID) {
+		if err := deleteMembership(tx, m.GroupID, m.UserID); err != nil {
+			return err
+		}
+	}
+	if groups := tx.Bucket(UserGroupBucket); groups != nil && groups.Bucket(idKeyString(userID)) != nil {
+		return groups.DeleteBucket(idKeyString(userID))
+	}
+	return nil
+}
+
+func getGroup(tx *bolt.Tx, id int) (*model.Group, error) {
+	bucket := tx.Bucket(GroupBucket)
+	if bucket == nil {
+		return nil, &GroupNotFoundError{ID: id}
+	}
+	v := bucket.Get(idKeyString(id))
+	if v == nil {
+		return nil, &GroupNotFoundError{ID: id}
+	}
+
+	var group model.Group
+	if err := json.Unmarshal(v, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// putGroup stores a group and moves its name index entry from oldName.
+func putGroup(tx *bolt.Tx, group *model.Group, oldName string) error {
+	names, err := tx.CreateBucketIfNotExists(GroupNameBucket)
+	if err != nil {
+		return err
+	}
+	if v := names.Get(groupNameKey(group.Name)); v != nil && string(v) != strconv.Itoa(group.ID) {
+		return fmt.Errorf("group %q: %w", group.Name, ErrGroupNameTaken)
+	}
+	if oldName != "" {
+		if err := names.Delete(groupNameKey(oldName)); err != nil {
+			return err
+		}
+	}
+	if err := names.Put(groupNameKey(group.Name), idKeyString(group.ID)); err != nil {
+		return err
+	}
+
+	bucket, err := tx.CreateBucketIfNotExists(GroupBucket)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(group)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(idKeyString(group.ID), buf)
+}
+
+func getMembership(tx *bolt.Tx, groupID, userID int) *model.Membership {
+	members := nestedBucket(tx, GroupMemberBucket, groupID)
+	if members == nil {
+		return nil
+	}
+	v := members.Get(idKeyString(userID))
+	if v == nil {
+		return nil
+	}
+
+	var m model.Membership
+	if err := json.Unmarshal(v, &m); err != nil {
+		return nil
+	}
+	return &m
+}
+
+// putMembership writes a membership under both the group and the user.
+func putMembership(tx *bolt.Tx, m *model.Membership) error {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	for _, side := range []struct {
+		bucket   []byte
+		owner    int
+		memberID int
+	}{
+		{GroupMemberBucket, m.GroupID, m.UserID},
+		{UserGroupBucket, m.UserID, m.GroupID},
+	} {
+		root, err := tx.CreateBucketIfNotExists(side.bucket)
+		if err != nil {
+			return err
+		}
+		bucket, err := root.CreateBucketIfNotExists(idKeyString(side.owner))
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(idKeyString(side.memberID), buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteMembership removes a membership from both directions.
+func deleteMembership(tx *bolt.Tx, groupID, userID int) error {
+	if members := nestedBucket(tx, GroupMemberBucket, groupID); members != nil {
+		if err := members.Delete(idKeyString(userID)); err != nil {
+			return err
+		}
+	}
+	if groups := nestedBucket(tx, UserGroupBucket, userID); groups != nil {
+		if err := groups.Delete(idKeyString(groupID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listMemberships returns the memberships stored under owner in one of the
+// two membership buckets.
+func listMemberships(tx *bolt.Tx, root []byte, owner int) []model.Membership {
+	bucket := nestedBucket(tx, root, owner)
+	if bucket == nil {
+		return nil
+	}
+
+	var memberships []model.Membership
+	_ = bucket.ForEach(func(k, v []byte) error {
+		var m model.Membership
+		if err := json.Unmarshal(v, &m); err == nil {
+			memberships = append(memberships, m)
+		}
+		return nil
+	})
+	return memberships
+}
+
+func nestedBucket(tx *bolt.Tx, root []byte, id int) *bolt.Bucket {
+	bucket := tx.Bucket(root)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Bucket(idKeyString(id))
+}
+
+func groupNameKey(name string) []byte {
+	return []byte(strings.ToLower(strings.TrimSpace(name)))
+}
+
+func idKeyString(id int) []byte {
+	return []byte(strconv.Itoa(id))
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+func createTestGroup(t *testing.T, name string) *model.Group {
+	t.Helper()
+	group := &model.Group{Name: name}
+	if err := CreateGroup(group); err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	return group
+}
+
+func TestGroupNames(t *testing.T) {
+	openTestDB(t)
+	staff := createTestGroup(t, "Staff")
+	createTestGroup(t, "Guests")
+
+	if err := CreateGroup(&model.Group{Name: " staff "}); !errors.Is(err, ErrGroupNameTaken) {
+		t.Errorf("CreateGroup(staff) error = %v, want ErrGroupNameTaken", err)
+	}
+	if _, err := UpdateGroup(staff.ID, func(g *model.Group) error { g.Name = "GUESTS"; return nil }); !errors.Is(err, ErrGroupNameTaken) {
+		t.Errorf("renaming to GUESTS error = %v, want ErrGroupNameTaken", err)
+	}
+
+	// Renaming frees the old name.
+	if _, err := UpdateGroup(staff.ID, func(g *model.Group) error { g.Name = "Employees"; return nil }); err != nil {
+		t.Fatalf("UpdateGroup: %v", err)
+	}
+	if group, err := GetGroupByName("employees"); err != nil || group.ID != staff.ID {
+		t.Errorf("GetGroupByName(employees) = %+v, %v, want group %d", group, err, staff.ID)
+	}
+	if _, err := GetGroupByName("Staff"); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("GetGroupByName(Staff) error = %v, want ErrGroupNotFound", err)
+	}
+	createTestGroup(t, "Staff")
+}
+
+func TestGroupMembers(t *testing.T) {
+	openTestDB(t)
+	staff := createTestGroup(t, "Staff")
+	guests := createTestGroup(t, "Guests")
+	ada := createTestUser(t, "Ada", "ada@example.com")
+	charles := createTestUser(t, "Charles", "charles@example.com")
+
+	add := func(group *model.Group, user *model.User, role string) {
+		t.Helper()
+		if _, err := AddGroupMember(group.ID, user.ID, role); err != nil {
+			t.Fatalf("AddGroupMember(%s, %s): %v", group.Name, user.Name, err)
+		}
+	}
+	add(staff, ada, "member")
+	add(staff, ada, "owner")
+	add(staff, charles, "member")
+	add(guests, ada, "member")
+
+	members, err := ListGroupMembers(staff.ID)
+	if err != nil || len(members) != 2 || members[0].User.ID != ada.ID || members[0].Role != "owner" {
+		t.Errorf("ListGroupMembers = %+v, %v, want Ada as owner and Charles", members, err)
+	}
+	if groups, err := ListUserGroups(ada.ID); err != nil || len(groups) != 2 {
+		t.Errorf("ListUserGroups(ada) = %+v, %v, want 2 groups", groups, err)
+	}
+
+	if err := RemoveGroupMember(guests.ID, charles.ID); !errors.Is(err, ErrMembershipNotFound) {
+		t.Errorf("RemoveGroupMember(non-member) error = %v, want ErrMembershipNotFound", err)
+	}
+	if _, err := AddGroupMember(staff.ID+guests.ID, ada.ID, "member"); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("AddGroupMember(missing group) error = %v, want ErrGroupNotFound", err)
+	}
+
+	// Deleting a user or a group drops the memberships on both sides.
+	if _, err := DeleteUserIfExists(testCaller, charles.ID); err != nil {
+		t.Fatalf("DeleteUserIfExists: %v", err)
+	}
+	if _, err := AddGroupMember(staff.ID, charles.ID, "member"); err == nil {
+		t.Error("AddGroupMember accepted a deleted user")
+	}
+	if err := DeleteGroup(guests.ID); err != nil {
+		t.Fatalf("DeleteGroup: %v", err)
+	}
+	if members, err := ListGroupMembers(staff.ID); err != nil || len(members) != 1 || members[0].User.ID != ada.ID {
+		t.Errorf("ListGroupMembers after deleting Charles = %+v, %v, want only Ada", members, err)
+	}
+	if groups, err := ListUserGroups(ada.ID); err != nil || len(groups) != 1 || groups[0].Group.ID != staff.ID {
+		t.Errorf("ListUserGroups(ada) after deleting Guests = %+v, %v, want only Staff", groups, err)
+	}
+}
@@ -0,0 +1,278 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// MetaBucket holds database-wide metadata such as the schema version.
+	MetaBucket = []byte("Meta")
+	// migrationBucket, nested in MetaBucket, records when each migration
+	// was applied, keyed by version.
+	migrationBucket  = []byte("Migrations")
+	schemaVersionKey = []byte("schemaVersion")
+)
+
+var (
+	// ErrUnknownSchemaVersion is returned for databases written by a newer
+	// build than this one, which this build must not modify.
+	ErrUnknownSchemaVersion = errors.New("unknown schema version")
+
+	// errMigrationDryRun rolls back the transaction of a dry run.
+	errMigrationDryRun = errors.New("migration dry run")
+)
+
+// Migration upgrades the stored data from schema version Version-1 to
+// Version.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *bolt.Tx) error
+}
+
+// MigrationState is a registered migration and when it was applied, if it
+// has been.
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// migrations is the ordered list of schema changes. Versions must stay
+// contiguous from 1: append new migrations at the end and never renumber,
+// reorder or remove existing ones. Databases written before versioning have
+// no MetaBucket and are treated as version 0, so every migration must cope
+// with data that already has the change applied.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create the user bucket",
+		Up: func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(UserBucket)
+			return err
+		},
+	},
+	{
+		Version:     2,
+		Description: "build the user search index",
+		Up: func(tx *bolt.Tx) error {
+			if tx.Bucket(SearchIndexBucket) != nil {
+				return nil
+			}
+			return rebuildSearchIndex(tx)
+		},
+	},
+	{
+		Version:     3,
+		Description: "lowercase the domain of stored emails",
+		Up:          lowercaseEmailDomains,
+	},
+	{
+		Version:     4,
+		Description: "count users in the search index",
+		Up:          rebuildSearchIndex,
+	},
+	{
+		Version:     5,
+		Description: "index the webhook outbox by due time",
+		Up:          rebuildWebhookSchedule,
+	},
+}
+
+// LatestSchemaVersion is the version a database has once every registered
+// migration has been applied.
+func LatestSchemaVersion() int {
+	return len(migrations)
+}
+
+// SchemaVersion returns the schema version of the open database.
+func SchemaVersion() (int, error) {
+	version := 0
+	err := DB.View(func(tx *bolt.Tx) error {
+		var err error
+		version, err = schemaVersion(tx)
+		return err
+	})
+	return version, err
+}
+
+// CheckSchemaVersion returns the schema version of the open database, or
+// an error matching ErrUnknownSchemaVersion if it is newer than
+// LatestSchemaVersion.
+func CheckSchemaVersion() (int, error) {
+	version, err := SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if version > len(migrations) {
+		return 0, fmt.Errorf("database has schema version %d but this build only knows up to %d: %w",
+			version, len(migrations), ErrUnknownSchemaVersion)
+	}
+	return version, nil
+}
+
+// MigrationStatus returns the schema version of the open database and the
+// state of every registered migration.
+func MigrationStatus() (int, []MigrationState, error) {
+	version := 0
+	states := make([]MigrationState, len(migrations))
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		var err error
+		if version, err = schemaVersion(tx); err != nil {
+			return err
+		}
+
+		var applied *bolt.Bucket
+		if meta := tx.Bucket(MetaBucket); meta != nil {
+			applied = meta.Bucket(migrationBucket)
+		}
+		for i, m := range migrations {
+			states[i].Migration = m
+			if applied == nil {
+				continue
+			}
+			if v := applied.Get(sequenceKey(uint64(m.Version))); v != nil {
+				var at time.Time
+				if err := at.UnmarshalText(v); err != nil {
+					return err
+				}
+				states[i].AppliedAt = &at
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return 0, nil, err
+	}
+	return version, states, nil
+}
+
+// Migrate applies the pending migrations in order, each in its own
+// transaction together with the new schema version, and returns the
+// migrations applied. With dryRun set all pending migrations run in a single
+// transaction that is rolled back, so they are checked against the real
+// data without changing it. Databases with a schema version newer than
+// LatestSchemaVersion are refused with ErrUnknownSchemaVersion.
+func Migrate(dryRun bool) ([]Migration, error) {
+	version, err := CheckSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version == len(migrations) {
+		return nil, nil
+	}
+	pending := migrations[version:]
+
+	if dryRun {
+		err := DB.Update(func(tx *bolt.Tx) error {
+			for _, m := range pending {
+				if err := applyMigration(tx, m); err != nil {
+					return err
+				}
+			}
+			return errMigrationDryRun
+		})
+		if !errors.Is(err, errMigrationDryRun) {
+			return nil, err
+		}
+		return pending, nil
+	}
+
+	var applied []Migration
+	for _, m := range pending {
+		if err := DB.Update(func(tx *bolt.Tx) error { return applyMigration(tx, m) }); err != nil {
+			return applied, err
+		}
+		log.Printf("Applied schema migration %d: %s", m.Version, m.Description)
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// applyMigration runs m and records it as applied within tx.
+func applyMigration(tx *bolt.Tx, m Migration) error {
+	if err := m.Up(tx); err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+	}
+
+	meta, err := tx.CreateBucketIfNotExists(MetaBucket)
+	if err != nil {
+		return err
+	}
+	applied, err := meta.CreateBucketIfNotExists(migrationBucket)
+	if err != nil {
+		return err
+	}
+	at, err := time.Now().UTC().MarshalText()
+	if err != nil {
+		return err
+	}
+	if err := applied.Put(sequenceKey(uint64(m.Version)), at); err != nil {
+		return err
+	}
+	return meta.Put(schemaVersionKey, sequenceKey(uint64(m.Version)))
+}
+
+// schemaVersion reads the schema version within tx; databases without a
+// MetaBucket are version 0.
+func schemaVersion(tx *bolt.Tx) (int, error) {
+	meta := tx.Bucket(MetaBucket)
+	if meta == nil {
+		return 0, nil
+	}
+	v := meta.Get(schemaVersionKey)
+	if v == nil {
+		return 0, nil
+	}
+	if len(v) != 8 {
+		return 0, fmt.Errorf("invalid schema version %q", v)
+	}
+	return int(binary.BigEndian.Uint64(v)), nil
+}
+
+// lowercaseEmailDomains rewrites emails stored before addresses were
+// normalized so that their domain is lowercase, keeping the search index in
+// step.
+func lowercaseEmailDomains(tx *bolt.Tx) error {
+	bucket := tx.Bucket(UserBucket)
+	if bucket == nil {
+		return nil
+	}
+
+	var changed []*model.User
+	err := bucket.ForEach(func(k, v []byte) error {
+		var user model.User
+		if err := json.Unmarshal(v, &user); err != nil {
+			return fmt.Errorf("user %s: %w", k, err)
+		}
+		at := strings.LastIndex(user.Email, "@")
+		if at < 0 {
+			return nil
+		}
+		if email := user.Email[:at] + strings.ToLower(user.Email[at:]); email != user.Email {
+			user.Email = email
+			changed = append(changed, &user)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, user := range changed {
+		if err := putUser(tx, user); err != nil {
+			return fmt.Errorf("user %d: %w", user.ID, err)
+		}
+	}
+	return nil
+}
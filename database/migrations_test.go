@@ -0,0 +1,124 @@
+package database
+
+import (
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// writeDatabase creates a database at path as an older build would have
+// left it: the given users under their IDs and, unless version is zero, the
+// schema version, but none of the buckets the migrations add.
+func writeDatabase(t *testing.T, path string, version uint64, users map[string]string) {
+	t.Helper()
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(UserBucket)
+		if err != nil {
+			return err
+		}
+		for id, user := range users {
+			if err := bucket.Put([]byte(id), []byte(user)); err != nil {
+				return err
+			}
+		}
+		if version == 0 {
+			return nil
+		}
+		meta, err := tx.CreateBucket(MetaBucket)
+		if err != nil {
+			return err
+		}
+		return meta.Put(schemaVersionKey, binary.BigEndian.AppendUint64(nil, version))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateUnversionedDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	writeDatabase(t, path, 0, map[string]string{
+		"1": `{"id":1,"name":"Ada","email":"Ada@EXAMPLE.com"}`,
+		"2": `{"id":2,"name":"Bob","email":"bob@example.org"}`,
+	})
+	if err := Open(path); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { DB.Close() })
+
+	// A dry run reports every migration and changes nothing.
+	pending, err := Migrate(true)
+	if err != nil || len(pending) != LatestSchemaVersion() {
+		t.Fatalf("Migrate(dry run) = %d migrations, %v, want %d", len(pending), err, LatestSchemaVersion())
+	}
+	if version, err := SchemaVersion(); err != nil || version != 0 {
+		t.Errorf("schema version after a dry run = %d, %v, want 0", version, err)
+	}
+	if user, err := GetUserByID(1); err != nil || user.Email != "Ada@EXAMPLE.com" {
+		t.Errorf("user 1 after a dry run = %+v, %v, want it unchanged", user, err)
+	}
+
+	applied, err := Migrate(false)
+	if err != nil || len(applied) != LatestSchemaVersion() {
+		t.Fatalf("Migrate = %d migrations, %v, want %d", len(applied), err, LatestSchemaVersion())
+	}
+	version, states, err := MigrationStatus()
+	if err != nil || version != LatestSchemaVersion() {
+		t.Fatalf("MigrationStatus = %d, %v, want version %d", version, err, LatestSchemaVersion())
+	}
+	for _, state := range states {
+		if state.AppliedAt == nil {
+			t.Errorf("migration %d is not recorded as applied", state.Version)
+		}
+	}
+
+	// Migration 3 lowercases the domain only; the search index built by
+	// migration 2 and counted by migration 4 finds the users.
+	if user, err := GetUserByID(1); err != nil || user.Email != "Ada@example.com" {
+		t.Errorf("user 1 = %+v, %v, want email Ada@example.com", user, err)
+	}
+	if users, err := FindUsersByEmail("ada@example.com"); err != nil || len(users) != 1 {
+		t.Errorf("FindUsersByEmail = %v, %v, want user 1", users, err)
+	}
+	if _, total, err := SearchUsers(SearchQuery{Limit: 10}); err != nil || total != 2 {
+		t.Errorf("SearchUsers total = %d, %v, want 2", total, err)
+	}
+
+	if applied, err := Migrate(false); err != nil || len(applied) != 0 {
+		t.Errorf("Migrate of an up-to-date database = %d migrations, %v, want none", len(applied), err)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new.db")
+	newer := LatestSchemaVersion() + 1
+	writeDatabase(t, path, uint64(newer), map[string]string{
+		"1": `{"id":1,"name":"Ada","email":"Ada@EXAMPLE.com"}`,
+	})
+
+	if err := InitDB(path); !errors.Is(err, ErrUnknownSchemaVersion) {
+		t.Fatalf("InitDB error = %v, want ErrUnknownSchemaVersion", err)
+	}
+
+	if err := Open(path); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { DB.Close() })
+	if _, err := Migrate(true); !errors.Is(err, ErrUnknownSchemaVersion) {
+		t.Errorf("Migrate(dry run) error = %v, want ErrUnknownSchemaVersion", err)
+	}
+	if version, err := SchemaVersion(); err != nil || version != newer {
+		t.Errorf("schema version = %d, %v, want it left at %d", version, err, newer)
+	}
+	if user, err := GetUserByID(1); err != nil || user.Email != "Ada@EXAMPLE.com" {
+		t.Errorf("user 1 = %+v, %v, want it unchanged", user, err)
+	}
+}
@@ -0,0 +1,534 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// SearchIndexBucket holds the secondary indexes used by SearchUsers. It is
+// kept in step with UserBucket by putUser and removeUser.
+//
+// Every index key ends in the big-endian user ID and has no value:
+//
+//	words/    <field> 0x00 <lowercase word or whole value> 0x00 <id>
+//	trigrams/ <field> 0x00 <lowercase trigram> 0x00 <id>
+//	created/  <created-at unix nanos> <id>
+//
+// Next to the index buckets, the userCount and deletedCount keys hold the
+// number of stored users and of soft-deleted ones, as big-endian uint64s.
+var SearchIndexBucket = []byte("UserSearchIndex")
+
+var (
+	wordIndexBucket    = []byte("words")
+	trigramIndexBucket = []byte("trigrams")
+	createdIndexBucket = []byte("created")
+
+	userCountKey    = []byte("userCount")
+	deletedCountKey = []byte("deletedCount")
+)
+
+// ErrSearchIndexMissing is returned by searches of a database whose search
+// index has not been built yet, such as one opened with Open before its
+// migrations were applied.
+var ErrSearchIndexMissing = errors.New("search index missing, run migrate")
+
+// Indexed fields.
+const (
+	nameField  byte = 'n'
+	emailField byte = 'e'
+)
+
+// SearchQuery selects users for SearchUsers. Text matching is
+// case-insensitive; an empty Text matches every user.
+type SearchQuery struct {
+	Text string
+	// Field is model.SearchFieldName, model.SearchFieldEmail or
+	// model.SearchFieldAny.
+	Field string
+	// Match is model.SearchMatchPrefix or model.SearchMatchSubstring.
+	Match string
+	// Status is model.UserStatusActive, model.UserStatusDeleted or
+	// model.SearchStatusAll.
+	Status        string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Offset        int
+	Limit         int
+}
+
+// SearchUsers returns the page of users matching q together with the total
+// number of matches. Matches are ordered by ID, except that a query with no
+// text or date criterion lists users in creation order, which only reads
+// the records up to the end of the page.
+func SearchUsers(q SearchQuery) ([]model.User, int, error) {
+	var page []model.User
+	total := 0
+	text := strings.ToLower(strings.TrimSpace(q.Text))
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		index := tx.Bucket(SearchIndexBucket)
+		if index == nil {
+			return ErrSearchIndexMissing
+		}
+		if text == "" && q.CreatedAfter == nil && q.CreatedBefore == nil {
+			var err error
+			page, total, err = listUsers(tx, index, q)
+			return err
+		}
+
+		for _, id := range searchCandidates(index, q, text) {
+			user, err := getUser(tx, id)
+			if err != nil {
+				return err
+			}
+			if !matchesSearch(user, q, text) {
+				continue
+			}
+
+			total++
+			if total > q.Offset && len(page) < q.Limit {
+				page = append(page, *user)
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+	return page, total, nil
+}
+
+// FindUsersByEmail returns the active users whose email equals email,
+// ignoring case.
+func FindUsersByEmail(email string) ([]model.User, error) {
+	var users []model.User
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		var err error
+		users, err = findUsersByEmail(tx, email)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func findUsersByEmail(tx *bolt.Tx, email string) ([]model.User, error) {
+	var users []model.User
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	index := tx.Bucket(SearchIndexBucket)
+	if index == nil {
+		return nil, ErrSearchIndexMissing
+	}
+	for id := range scanIDs(index.Bucket(wordIndexBucket), termKey(emailField, email)) {
+		user, err := getUser(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if user.DeletedAt == nil && strings.ToLower(user.Email) == email {
+			users = append(users, *user)
+		}
+	}
+	return users, nil
+}
+
+// listUsers pages through the users with q's status in creation order,
+// stopping at the end of the page. The total comes from the counts kept in
+// the index.
+func listUsers(tx *bolt.Tx, index *bolt.Bucket, q SearchQuery) ([]model.User, int, error) {
+	users, deleted := indexCount(index, userCountKey), indexCount(index, deletedCountKey)
+	var total int
+	switch q.Status {
+	case model.SearchStatusAll:
+		total = users
+	case model.UserStatusDeleted:
+		total = deleted
+	default:
+		total = users - deleted
+	}
+
+	var page []model.User
+	bucket := index.Bucket(createdIndexBucket)
+	if bucket == nil {
+		return nil, total, nil
+	}
+	end := min(q.Offset+q.Limit, total)
+	matched := 0
+	c := bucket.Cursor()
+	for k, _ := c.First(); k != nil && matched < end; k, _ = c.Next() {
+		user, err := getUser(tx, keyID(k))
+		if err != nil {
+			return nil, 0, err
+		}
+		if !matchesSearch(user, q, "") {
+			continue
+		}
+		matched++
+		if matched > q.Offset {
+			page = append(page, *user)
+		}
+	}
+	return page, total, nil
+}
+
+// searchCandidates narrows a query with a text or date criterion down to a
+// sorted list of user IDs using the indexes. The candidates are a superset
+// of the matches; every one is checked against the stored record by
+// matchesSearch.
+func searchCandidates(index *bolt.Bucket, q SearchQuery, text string) []int {
+	var set map[int]struct{}
+	if text != "" {
+		set = make(map[int]struct{})
+		for _, field := range searchFields(q.Field) {
+			var ids map[int]struct{}
+			if q.Match == model.SearchMatchSubstring {
+				ids = substringCandidates(index, field, text)
+			} else {
+				ids = scanIDs(index.Bucket(wordIndexBucket), fieldKey(field, text))
+			}
+			for id := range ids {
+				set[id] = struct{}{}
+			}
+		}
+	} else {
+		set = createdCandidates(index.Bucket(createdIndexBucket), q.CreatedAfter, q.CreatedBefore)
+	}
+
+	ids := make([]int, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// substringCandidates intersects the trigram postings of text. Queries
+// shorter than a trigram fall back to scanning the field's word index, which
+// is far smaller than the user records.
+func substringCandidates(index *bolt.Bucket, field byte, text string) map[int]struct{} {
+	grams := trigrams(text)
+	if len(grams) == 0 {
+		ids := make(map[int]struct{})
+		prefix := fieldKey(field, "")
+		bucket := index.Bucket(wordIndexBucket)
+		if bucket == nil {
+			return ids
+		}
+		c := bucket.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			term := k[len(prefix) : len(k)-9]
+			if bytes.Contains(term, []byte(text)) {
+				ids[keyID(k)] = struct{}{}
+			}
+		}
+		return ids
+	}
+
+	var result map[int]struct{}
+	for _, gram := range grams {
+		ids := scanIDs(index.Bucket(trigramIndexBucket), termKey(field, gram))
+		if result == nil {
+			result = ids
+			continue
+		}
+		for id := range result {
+			if _, ok := ids[id]; !ok {
+				delete(result, id)
+			}
+		}
+	}
+	return result
+}
+
+func createdCandidates(bucket *bolt.Bucket, after, before *time.Time) map[int]struct{} {
+	ids := make(map[int]struct{})
+	if bucket == nil {
+		return ids
+	}
+	c := bucket.Cursor()
+
+	var k []byte
+	if after != nil {
+		k, _ = c.Seek(timeKey(*after))
+	} else {
+		k, _ = c.First()
+	}
+	for ; k != nil; k, _ = c.Next() {
+		if before != nil && bytes.Compare(k[:8], timeKey(*before)) >= 0 {
+			break
+		}
+		ids[keyID(k)] = struct{}{}
+	}
+	return ids
+}
+
+// scanIDs collects the IDs of every index key starting with prefix. A
+// missing bucket has no keys.
+func scanIDs(bucket *bolt.Bucket, prefix []byte) map[int]struct{} {
+	ids := make(map[int]struct{})
+	if bucket == nil {
+		return ids
+	}
+	c := bucket.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		ids[keyID(k)] = struct{}{}
+	}
+	return ids
+}
+
+// matchesSearch checks a stored user against every criterion of q.
+func matchesSearch(user *model.User, q SearchQuery, text string) bool {
+	switch q.Status {
+	case model.SearchStatusAll:
+	case model.UserStatusDeleted:
+		if user.DeletedAt == nil {
+			return false
+		}
+	default:
+		if user.DeletedAt != nil {
+			return false
+		}
+	}
+
+	if q.CreatedAfter != nil && user.CreatedAt.Before(*q.CreatedAfter) {
+		return false
+	}
+	if q.CreatedBefore != nil && !user.CreatedAt.Before(*q.CreatedBefore) {
+		return false
+	}
+
+	if text == "" {
+		return true
+	}
+	for _, field := range searchFields(q.Field) {
+		value := strings.ToLower(fieldValue(user, field))
+		if q.Match == model.SearchMatchSubstring {
+			if strings.Contains(value, text) {
+				return true
+			}
+			continue
+		}
+		for _, word := range words(value) {
+			if strings.HasPrefix(word, text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func searchFields(field string) []byte {
+	switch field {
+	case model.SearchFieldName:
+		return []byte{nameField}
+	case model.SearchFieldEmail:
+		return []byte{emailField}
+	default:
+		return []byte{nameField, emailField}
+	}
+}
+
+func fieldValue(user *model.User, field byte) string {
+	if field == emailField {
+		return user.Email
+	}
+	return user.Name
+}
+
+// indexUser replaces the index entries of before with those of after. Either
+// may be nil when a user is created or removed.
+func indexUser(tx *bolt.Tx, before, after *model.User) error {
+	index, err := tx.CreateBucketIfNotExists(SearchIndexBucket)
+	if err != nil {
+		return err
+	}
+
+	oldKeys := indexKeys(before)
+	newKeys := indexKeys(after)
+	for name, keys := range oldKeys {
+		bucket := index.Bucket([]byte(name))
+		if bucket == nil {
+			continue
+		}
+		for key := range keys {
+			if _, keep := newKeys[name][key]; keep {
+				continue
+			}
+			if err := bucket.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+	}
+	for name, keys := range newKeys {
+		bucket, err := index.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+		for key := range keys {
+			if _, exists := oldKeys[name][key]; exists {
+				continue
+			}
+			if err := bucket.Put([]byte(key), nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	users, deleted := 0, 0
+	if before != nil {
+		users--
+		if before.DeletedAt != nil {
+			deleted--
+		}
+	}
+	if after != nil {
+		users++
+		if after.DeletedAt != nil {
+			deleted++
+		}
+	}
+	if err := addIndexCount(index, userCountKey, users); err != nil {
+		return err
+	}
+	return addIndexCount(index, deletedCountKey, deleted)
+}
+
+// indexCount returns the count stored under key in the search index; a
+// missing count is zero.
+func indexCount(index *bolt.Bucket, key []byte) int {
+	v := index.Get(key)
+	if len(v) != 8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(v))
+}
+
+func addIndexCount(index *bolt.Bucket, key []byte, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	return index.Put(key, sequenceKey(uint64(indexCount(index, key)+delta)))
+}
+
+// indexKeys returns the index keys of a user grouped by index bucket name.
+func indexKeys(user *model.User) map[string]map[string]struct{} {
+	keys := map[string]map[string]struct{}{
+		string(wordIndexBucket):    {},
+		string(trigramIndexBucket): {},
+		string(createdIndexBucket): {},
+	}
+	if user == nil {
+		return keys
+	}
+
+	for _, field := range []byte{nameField, emailField} {
+		value := strings.ToLower(fieldValue(user, field))
+		for _, word := range words(value) {
+			keys[string(wordIndexBucket)][string(idKey(termKey(field, word), user.ID))] = struct{}{}
+		}
+		for _, gram := range trigrams(value) {
+			keys[string(trigramIndexBucket)][string(idKey(termKey(field, gram), user.ID))] = struct{}{}
+		}
+	}
+	keys[string(createdIndexBucket)][string(idKey(timeKey(user.CreatedAt), user.ID))] = struct{}{}
+	return keys
+}
+
+// rebuildSearchIndex drops and recreates the search index, counts included,
+// from UserBucket.
+// The index buckets are created even when there are no users, so that
+// queries on an empty database find them.
+func rebuildSearchIndex(tx *bolt.Tx) error {
+	if tx.Bucket(SearchIndexBucket) != nil {
+		if err := tx.DeleteBucket(SearchIndexBucket); err != nil {
+			return err
+		}
+	}
+	index, err := tx.CreateBucket(SearchIndexBucket)
+	if err != nil {
+		return err
+	}
+	for _, name := range [][]byte{wordIndexBucket, trigramIndexBucket, createdIndexBucket} {
+		if _, err := index.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+
+	bucket := tx.Bucket(UserBucket)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(k, v []byte) error {
+		var user model.User
+		if err := json.Unmarshal(v, &user); err != nil {
+			return err
+		}
+		return indexUser(tx, nil, &user)
+	})
+}
+
+// words splits a lowercase value into its letter/digit runs. The whole value
+// is included as well so that queries spanning several words still match
+// from the start.
+func words(value string) []string {
+	if value == "" {
+		return nil
+	}
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return append(fields, value)
+}
+
+func trigrams(value string) []string {
+	runes := []rune(value)
+	if len(runes) < 3 {
+		return nil
+	}
+	grams := make([]string, 0, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		grams = append(grams, string(runes[i:i+3]))
+	}
+	return grams
+}
+
+// fieldKey is the key prefix shared by every term of field starting with term.
+func fieldKey(field byte, term string) []byte {
+	return append([]byte{field, 0}, term...)
+}
+
+// termKey is the key prefix of exactly term within field.
+func termKey(field byte, term string) []byte {
+	return append(fieldKey(field, term), 0)
+}
+
+// timeKey orders timestamps bytewise. Users stored before creation times
+// were recorded have a zero CreatedAt and sort first.
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	if t.After(time.Unix(0, 0)) {
+		binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	}
+	return key
+}
+
+func idKey(prefix []byte, id int) []byte {
+	return append(prefix, sequenceKey(uint64(id))...)
+}
+
+func keyID(key []byte) int {
+	return int(binary.BigEndian.Uint64(key[len(key)-8:]))
+}
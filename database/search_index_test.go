@@ -0,0 +1,126 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestSearchUsersEmptyDatabase(t *testing.T) {
+	openTestDB(t)
+	since := time.Now().Add(-time.Hour)
+
+	queries := map[string]SearchQuery{
+		"prefix":          {Text: "ada", Match: model.SearchMatchPrefix},
+		"substring":       {Text: "ada", Match: model.SearchMatchSubstring},
+		"short substring": {Text: "a", Match: model.SearchMatchSubstring},
+		"created":         {CreatedAfter: &since},
+		"all":             {},
+	}
+	for name, q := range queries {
+		q.Limit = 10
+		users, total, err := SearchUsers(q)
+		if err != nil || total != 0 || len(users) != 0 {
+			t.Errorf("%s: SearchUsers = %v, %d, %v, want no users", name, users, total, err)
+		}
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	openTestDB(t)
+	ada := createTestUser(t, "Ada Lovelace", "ada@example.com")
+	createTestUser(t, "Charles Babbage", "charles@example.com")
+	since := time.Now().Add(-time.Hour)
+
+	queries := map[string]SearchQuery{
+		"prefix":          {Text: "love", Match: model.SearchMatchPrefix},
+		"substring":       {Text: "velac", Match: model.SearchMatchSubstring},
+		"short substring": {Text: "da", Match: model.SearchMatchSubstring, Field: model.SearchFieldName},
+		"email":           {Text: "ADA@", Field: model.SearchFieldEmail},
+	}
+	for name, q := range queries {
+		q.Limit = 10
+		users, total, err := SearchUsers(q)
+		if err != nil || total != 1 || len(users) != 1 || users[0].ID != ada.ID {
+			t.Errorf("%s: SearchUsers = %v, %d, %v, want only user %d", name, users, total, err, ada.ID)
+		}
+	}
+
+	users, total, err := SearchUsers(SearchQuery{CreatedAfter: &since, Limit: 1})
+	if err != nil || total != 2 || len(users) != 1 {
+		t.Errorf("created: SearchUsers = %v, %d, %v, want a page of 1 of 2 users", users, total, err)
+	}
+}
+
+func TestSearchUsersWithoutCriteria(t *testing.T) {
+	openTestDB(t)
+	var ids []int
+	for _, name := range []string{"Ada", "Barbara", "Charles", "Dorothy", "Edsger"} {
+		ids = append(ids, createTestUser(t, name, name+"@example.com").ID)
+	}
+	if _, err := DeleteUserIfExists(testCaller, ids[1]); err != nil {
+		t.Fatalf("DeleteUserIfExists: %v", err)
+	}
+	if err := PurgeUser(testCaller, ids[4]); err != nil {
+		t.Fatalf("PurgeUser: %v", err)
+	}
+
+	tests := []struct {
+		status        string
+		offset, limit int
+		want          []int
+		total         int
+	}{
+		{model.UserStatusActive, 0, 10, []int{ids[0], ids[2], ids[3]}, 3},
+		{model.UserStatusActive, 1, 1, []int{ids[2]}, 3},
+		{model.UserStatusActive, 3, 10, nil, 3},
+		{model.UserStatusDeleted, 0, 10, []int{ids[1]}, 1},
+		{model.SearchStatusAll, 1, 2, []int{ids[1], ids[2]}, 4},
+	}
+	for _, test := range tests {
+		users, total, err := SearchUsers(SearchQuery{Status: test.status, Offset: test.offset, Limit: test.limit})
+		var got []int
+		for _, user := range users {
+			got = append(got, user.ID)
+		}
+		if err != nil || total != test.total || !slices.Equal(got, test.want) {
+			t.Errorf("%s, offset %d, limit %d: SearchUsers = %v, %d, %v, want %v, %d",
+				test.status, test.offset, test.limit, got, total, err, test.want, test.total)
+		}
+	}
+}
+
+func TestSearchIndexMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "unmigrated.db")
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket(UserBucket)
+		return err
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Open(path); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { DB.Close() })
+	if _, _, err := SearchUsers(SearchQuery{Limit: 10}); !errors.Is(err, ErrSearchIndexMissing) {
+		t.Errorf("SearchUsers error = %v, want ErrSearchIndexMissing", err)
+	}
+	if _, _, err := SearchUsers(SearchQuery{Text: "ada", Limit: 10}); !errors.Is(err, ErrSearchIndexMissing) {
+		t.Errorf("SearchUsers(ada) error = %v, want ErrSearchIndexMissing", err)
+	}
+	if _, err := FindUsersByEmail("ada@example.com"); !errors.Is(err, ErrSearchIndexMissing) {
+		t.Errorf("FindUsersByEmail error = %v, want ErrSearchIndexMissing", err)
+	}
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Tx groups several audited user mutations into a single bbolt read-write
+// transaction. It is only valid inside the function passed to Update.
+//
+// Errors returned by Tx methods before anything was written (a missing user,
+// an error from an update function) leave the transaction usable, so callers
+// may record them and carry on. A failure after a write has started poisons
+// the transaction: every later method returns that error and Update rolls
+// everything back.
+type Tx struct {
+	tx     *bolt.Tx
+	caller model.Caller
+	broken error
+}
+
+// Update runs fn inside a single read-write transaction on behalf of caller.
+// The transaction commits only if fn returns nil and no write failed.
+func Update(caller model.Caller, fn func(t *Tx) error) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		t := &Tx{tx: tx, caller: caller}
+		if err := fn(t); err != nil {
+			return err
+		}
+		return t.broken
+	})
+}
+
+// GetUser reads the active user with the given ID within t.
+func (t *Tx) GetUser(id int) (*model.User, error) {
+	if t.broken != nil {
+		return nil, t.broken
+	}
+	return getActiveUser(t.tx, id)
+}
+
+// CreateUser stores a new user, assigning its ID, and records the creation.
+// The email must not belong to another active user.
+func (t *Tx) CreateUser(user *model.User) error {
+	if t.broken != nil {
+		return t.broken
+	}
+	if err := checkEmailAvailable(t.tx, user.Email, 0); err != nil {
+		return err
+	}
+	return t.write(func() error {
+		if err := putUser(t.tx, user); err != nil {
+			return err
+		}
+		return recordChange(t.tx, t.caller, model.AuditActionCreate, nil, user)
+	})
+}
+
+// UpdateUser loads the active user with the given ID, applies fn to it and
+// stores the result. Deleted users are reported as not found.
+func (t *Tx) UpdateUser(id int, fn func(user *model.User) error) (*model.User, error) {
+	if t.broken != nil {
+		return nil, t.broken
+	}
+
+	user, err := getActiveUser(t.tx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := *user
+
+	if err := fn(user); err != nil {
+		return nil, err
+	}
+
+	user.ID = id
+	if err := checkEmailAvailable(t.tx, user.Email, id); err != nil {
+		return nil, err
+	}
+	err = t.write(func() error {
+		if err := putUser(t.tx, user); err != nil {
+			return err
+		}
+		return recordChange(t.tx, t.caller, model.AuditActionUpdate, &before, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// DeleteUser marks the active user with the given ID as deleted, removes it
+// from every group and reports whether such a user was found.
+func (t *Tx) DeleteUser(id int) (bool, error) {
+	if t.broken != nil {
+		return false, t.broken
+	}
+
+	user, err := getActiveUser(t.tx, id)
+	if errors.Is(err, ErrUserNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	before := *user
+
+	now := time.Now().UTC()
+	user.DeletedAt = &now
+	err = t.write(func() error {
+		if err := putUser(t.tx, user); err != nil {
+			return err
+		}
+		if err := removeUserMemberships(t.tx, id); err != nil {
+			return err
+		}
+		return recordChange(t.tx, t.caller, model.AuditActionDelete, &before, user)
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RestoreUser clears the tombstone of a deleted user.
+func (t *Tx) RestoreUser(id int) (*model.User, error) {
+	if t.broken != nil {
+		return nil, t.broken
+	}
+
+	user, err := getUser(t.tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt == nil {
+		return nil, fmt.Errorf("user with ID %d: %w", id, ErrUserNotDeleted)
+	}
+	if err := checkEmailAvailable(t.tx, user.Email, id); err != nil {
+		return nil, err
+	}
+	before := *user
+
+	user.DeletedAt = nil
+	err = t.write(func() error {
+		if err := putUser(t.tx, user); err != nil {
+			return err
+		}
+		return recordChange(t.tx, t.caller, model.AuditActionRestore, &before, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// PurgeUser permanently removes a user record, whether or not it has been
+// deleted first.
+func (t *Tx) PurgeUser(id int) error {
+	if t.broken != nil {
+		return t.broken
+	}
+
+	user, err := getUser(t.tx, id)
+	if err != nil {
+		return err
+	}
+
+	return t.write(func() error {
+		if err := removeUser(t.tx, user); err != nil {
+			return err
+		}
+		return recordChange(t.tx, t.caller, model.AuditActionPurge, user, nil)
+	})
+}
+
+// checkEmailAvailable fails if an active user other than exceptID already
+// uses email, ignoring case.
+func checkEmailAvailable(tx *bolt.Tx, email string, exceptID int) error {
+	users, err := findUsersByEmail(tx, email)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if user.ID != exceptID {
+			return fmt.Errorf("email %s: %w", email, ErrEmailTaken)
+		}
+	}
+	return nil
+}
+
+// write runs the write phase of a mutation, poisoning the transaction if it
+// fails part-way through.
+func (t *Tx) write(fn func() error) error {
+	if err := fn(); err != nil {
+		t.broken = err
+		return err
+	}
+	return nil
+}
@@ -2,73 +2,279 @@ package database
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/maasumiyaat/soap/model"
 	bolt "go.etcd.io/bbolt"
 )
 
+// ErrUserNotFound is matched (via errors.Is) by every error returned for a
+// missing user record.
+var ErrUserNotFound = errors.New("user not found")
+
+// UserNotFoundError reports the ID of a user that does not exist.
+type UserNotFoundError struct {
+	ID int
+}
+
+func (e *UserNotFoundError) Error() string {
+	return fmt.Sprintf("user with ID %d not found", e.ID)
+}
+
+func (e *UserNotFoundError) Is(target error) bool {
+	return target == ErrUserNotFound
+}
+
+var (
+	// ErrUserNotDeleted is returned when restoring a user that has no tombstone.
+	ErrUserNotDeleted = errors.New("user is not deleted")
+	// ErrEmailTaken is returned when an email already belongs to another
+	// active user.
+	ErrEmailTaken = errors.New("email already in use")
+	// ErrUserExists is returned when importing a user whose ID is taken.
+	ErrUserExists = errors.New("user already exists")
+)
+
 func GetUserByID(id int) (*model.User, error) {
-	var user model.User
-	userIDStr := strconv.Itoa(id)
+	var user *model.User
 
 	err := DB.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket(UserBucket)
-		if bucket == nil {
-			return fmt.Errorf("bucket %s not found", UserBucket)
-		}
+		var err error
+		user, err = getActiveUser(tx, id)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// HasUsers reports whether any user, deleted or not, is stored.
+func HasUsers() (bool, error) {
+	found := false
 
-		v := bucket.Get([]byte(userIDStr))
-		if v == nil {
-			return fmt.Errorf("user with ID %d not found", id)
+	err := DB.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(UserBucket); bucket != nil {
+			k, _ := bucket.Cursor().First()
+			found = k != nil
 		}
+		return nil
+	})
+	return found, err
+}
+
+// CreateUser stores a new user, assigning its ID, and records the creation.
+func CreateUser(caller model.Caller, user *model.User) error {
+	return Update(caller, func(t *Tx) error {
+		return t.CreateUser(user)
+	})
+}
+
+// UpdateUser loads the user with the given ID, applies fn to it and stores
+// the result, all within a single read-write transaction. If fn returns an
+// error the transaction is rolled back and the error is returned unchanged.
+// Deleted users are reported as not found.
+func UpdateUser(caller model.Caller, id int, fn func(user *model.User) error) (*model.User, error) {
+	var user *model.User
 
-		return json.Unmarshal(v, &user)
+	err := Update(caller, func(t *Tx) error {
+		var err error
+		user, err = t.UpdateUser(id, fn)
+		return err
 	})
 
 	if err != nil {
 		return nil, err
 	}
-	return &user, nil
+	return user, nil
 }
 
-func SaveUser(user *model.User) error {
-	return DB.Update(func(tx *bolt.Tx) error {
-		bucket, err := tx.CreateBucketIfNotExists(UserBucket)
-		if err != nil {
-			return err
-		}
-		if user.ID == 0 {
-			id, _ := bucket.NextSequence()
-			user.ID = int(id)
+// DeleteUserIfExists marks the user with the given ID as deleted in a single
+// transaction and reports whether an active record was found. The record is
+// kept as a tombstone until it is restored or purged.
+func DeleteUserIfExists(caller model.Caller, id int) (bool, error) {
+	deleted := false
+
+	err := Update(caller, func(t *Tx) error {
+		var err error
+		deleted, err = t.DeleteUser(id)
+		return err
+	})
+
+	if err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+func DeleteUser(caller model.Caller, id int) error {
+	deleted, err := DeleteUserIfExists(caller, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return &UserNotFoundError{ID: id}
+	}
+	return nil
+}
+
+// RestoreUser clears the tombstone of a deleted user.
+func RestoreUser(caller model.Caller, id int) (*model.User, error) {
+	var user *model.User
+
+	err := Update(caller, func(t *Tx) error {
+		var err error
+		user, err = t.RestoreUser(id)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// PurgeUser permanently removes a user record, whether or not it has been
+// deleted first.
+func PurgeUser(caller model.Caller, id int) error {
+	return Update(caller, func(t *Tx) error {
+		return t.PurgeUser(id)
+	})
+}
+
+// PurgeDeletedBefore permanently removes every user whose tombstone is older
+// than cutoff and returns the number of records removed.
+func PurgeDeletedBefore(caller model.Caller, cutoff time.Time) (int, error) {
+	purged := 0
+
+	err := Update(caller, func(t *Tx) error {
+		bucket := t.tx.Bucket(UserBucket)
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", UserBucket)
 		}
 
-		buf, err := json.Marshal(user)
+		var expired []int
+		err := bucket.ForEach(func(k, v []byte) error {
+			var user model.User
+			if err := json.Unmarshal(v, &user); err != nil {
+				return err
+			}
+			if user.DeletedAt != nil && user.DeletedAt.Before(cutoff) {
+				expired = append(expired, user.ID)
+			}
+			return nil
+		})
 		if err != nil {
 			return err
 		}
 
-		key := []byte(strconv.Itoa(user.ID))
-		return bucket.Put(key, buf)
+		// IDs are collected first because bbolt does not allow modifying a
+		// bucket while iterating over it.
+		for _, id := range expired {
+			if err := t.PurgeUser(id); err != nil {
+				return err
+			}
+		}
+		purged = len(expired)
+		return nil
 	})
+
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
 }
 
-func DeleteUser(id int) error {
-	userIDStr := strconv.Itoa(id)
+// getUser reads a single user inside an existing transaction.
+func getUser(tx *bolt.Tx, id int) (*model.User, error) {
+	bucket := tx.Bucket(UserBucket)
+	if bucket == nil {
+		return nil, fmt.Errorf("bucket %s not found", UserBucket)
+	}
 
-	return DB.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket(UserBucket)
-		if bucket == nil {
-			return fmt.Errorf("bucket %s not found", UserBucket)
+	v := bucket.Get([]byte(strconv.Itoa(id)))
+	if v == nil {
+		return nil, &UserNotFoundError{ID: id}
+	}
+
+	var user model.User
+	if err := json.Unmarshal(v, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// getActiveUser is like getUser but treats deleted users as missing.
+func getActiveUser(tx *bolt.Tx, id int) (*model.User, error) {
+	user, err := getUser(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, &UserNotFoundError{ID: id}
+	}
+	return user, nil
+}
+
+// putUser writes a user inside an existing read-write transaction, assigning
+// a new ID and creation time when the user has none yet, and keeps the
+// search index in step with the stored record. Explicit IDs beyond the
+// bucket sequence advance it so later assigned IDs do not collide.
+func putUser(tx *bolt.Tx, user *model.User) error {
+	bucket, err := tx.CreateBucketIfNotExists(UserBucket)
+	if err != nil {
+		return err
+	}
+	if user.ID == 0 {
+		id, _ := bucket.NextSequence()
+		user.ID = int(id)
+		if user.CreatedAt.IsZero() {
+			user.CreatedAt = time.Now().UTC()
+		}
+	} else if uint64(user.ID) > bucket.Sequence() {
+		if err := bucket.SetSequence(uint64(user.ID)); err != nil {
+			return err
 		}
+	}
 
-		// Check if user exists
-		if bucket.Get([]byte(userIDStr)) == nil {
-			return fmt.Errorf("user with ID %d not found", id)
+	key := []byte(strconv.Itoa(user.ID))
+	var before *model.User
+	if v := bucket.Get(key); v != nil {
+		before = &model.User{}
+		if err := json.Unmarshal(v, before); err != nil {
+			return err
 		}
+	}
 
-		// Delete the user
-		return bucket.Delete([]byte(userIDStr))
-	})
+	buf, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	if err := bucket.Put(key, buf); err != nil {
+		return err
+	}
+	return indexUser(tx, before, user)
+}
+
+// removeUser permanently deletes a stored user together with its index
+// entries, group memberships, password and sessions.
+func removeUser(tx *bolt.Tx, user *model.User) error {
+	if err := tx.Bucket(UserBucket).Delete([]byte(strconv.Itoa(user.ID))); err != nil {
+		return err
+	}
+	if err := removeUserMemberships(tx, user.ID); err != nil {
+		return err
+	}
+	if err := deleteUserCredentials(tx, user.ID); err != nil {
+		return err
+	}
+	if err := deleteAvatar(tx, user.ID); err != nil {
+		return err
+	}
+	return indexUser(tx, user, nil)
 }
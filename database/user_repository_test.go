@@ -0,0 +1,180 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+var testCaller = model.Caller{Principal: "test", Transport: "test"}
+
+// openTestDB points DB at a new, migrated database for the duration of t.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	if err := InitDB(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { DB.Close() })
+}
+
+func createTestUser(t *testing.T, name, email string) *model.User {
+	t.Helper()
+	user := &model.User{Name: name, Email: email}
+	if err := CreateUser(testCaller, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+// incrementName treats the name of a user as a counter.
+func incrementName(user *model.User) error {
+	n, err := strconv.Atoi(user.Name)
+	if err != nil {
+		return err
+	}
+	user.Name = strconv.Itoa(n + 1)
+	return nil
+}
+
+func TestUpdateUserConcurrent(t *testing.T) {
+	openTestDB(t)
+	user := createTestUser(t, "0", "counter@example.com")
+
+	const workers, updates = 16, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*updates)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range updates {
+				if _, err := UpdateUser(testCaller, user.ID, incrementName); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("UpdateUser: %v", err)
+	}
+
+	got, err := GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if want := strconv.Itoa(workers * updates); got.Name != want {
+		t.Errorf("counter = %s after %s updates, updates were lost", got.Name, want)
+	}
+
+	history, _, err := GetUserHistory(user.ID, 0, 1000)
+	if err != nil {
+		t.Fatalf("GetUserHistory: %v", err)
+	}
+	if want := 1 + workers*updates; len(history) != want {
+		t.Errorf("audit trail has %d entries, want %d", len(history), want)
+	}
+}
+
+func TestDeleteUserIfExistsConcurrent(t *testing.T) {
+	openTestDB(t)
+	user := createTestUser(t, "Ada", "ada@example.com")
+
+	const workers = 32
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	deletions := 0
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			deleted, err := DeleteUserIfExists(testCaller, user.ID)
+			if err != nil {
+				t.Errorf("DeleteUserIfExists: %v", err)
+				return
+			}
+			if deleted {
+				mu.Lock()
+				deletions++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if deletions != 1 {
+		t.Errorf("%d calls reported deleting the user, want 1", deletions)
+	}
+	if _, err := GetUserByID(user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserByID after delete: got %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestUpdateUserConcurrentWithDelete(t *testing.T) {
+	openTestDB(t)
+	user := createTestUser(t, "0", "racer@example.com")
+
+	const workers, updates = 8, 25
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	applied := 0
+	deleted := false
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range updates {
+				if i == 0 && j == updates/2 {
+					ok, err := DeleteUserIfExists(testCaller, user.ID)
+					if err != nil || !ok {
+						t.Errorf("DeleteUserIfExists = %v, %v, want true, nil", ok, err)
+					}
+					mu.Lock()
+					deleted = true
+					mu.Unlock()
+					continue
+				}
+
+				mu.Lock()
+				afterDelete := deleted
+				mu.Unlock()
+
+				_, err := UpdateUser(testCaller, user.ID, incrementName)
+				switch {
+				case err == nil:
+					if afterDelete {
+						t.Errorf("UpdateUser succeeded after the user was deleted")
+					}
+					mu.Lock()
+					applied++
+					mu.Unlock()
+				case !errors.Is(err, ErrUserNotFound):
+					t.Errorf("UpdateUser: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := GetUserByID(user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("GetUserByID after delete: got %v, want ErrUserNotFound", err)
+	}
+
+	// The tombstone keeps the last update applied before the delete.
+	history, _, err := GetUserHistory(user.ID, 0, 1000)
+	if err != nil {
+		t.Fatalf("GetUserHistory: %v", err)
+	}
+	last := history[len(history)-1]
+	if last.Action != model.AuditActionDelete {
+		t.Fatalf("last audit action = %s, want %s", last.Action, model.AuditActionDelete)
+	}
+	if want := strconv.Itoa(applied); last.Before.Name != want {
+		t.Errorf("counter = %s when deleted, but %s updates succeeded", last.Before.Name, want)
+	}
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// EmailVerificationBucket maps hashed verification tokens to the address
+// they confirm.
+var EmailVerificationBucket = []byte("EmailVerifications")
+
+var (
+	// ErrVerificationNotFound is returned for unknown or already used tokens.
+	ErrVerificationNotFound = errors.New("verification token not found")
+	// ErrVerificationExpired is returned for tokens past their expiry.
+	ErrVerificationExpired = errors.New("verification token expired")
+	// ErrVerificationStale is returned when the user's email changed after
+	// the token was issued.
+	ErrVerificationStale = errors.New("verification token is for a previous email address")
+)
+
+// CreateEmailVerification stores a verification token hash within t.
+func (t *Tx) CreateEmailVerification(tokenHash []byte, v *model.EmailVerification) error {
+	if t.broken != nil {
+		return t.broken
+	}
+	return t.write(func() error {
+		return putVerification(t.tx, tokenHash, v)
+	})
+}
+
+// VerifyEmail consumes a verification token and marks the user's email as
+// verified. The token is removed even when it turns out to be expired or
+// stale.
+func VerifyEmail(caller model.Caller, tokenHash []byte) (*model.User, error) {
+	var user *model.User
+	var tokenErr error
+
+	err := Update(caller, func(t *Tx) error {
+		bucket := t.tx.Bucket(EmailVerificationBucket)
+		if bucket == nil {
+			return ErrVerificationNotFound
+		}
+		data := bucket.Get(tokenHash)
+		if data == nil {
+			return ErrVerificationNotFound
+		}
+
+		var v model.EmailVerification
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		if err := bucket.Delete(tokenHash); err != nil {
+			return err
+		}
+		if !time.Now().Before(v.ExpiresAt) {
+			tokenErr = ErrVerificationExpired
+			return nil
+		}
+
+		var err error
+		user, err = t.UpdateUser(v.UserID, func(user *model.User) error {
+			if user.Email != v.Email {
+				return ErrVerificationStale
+			}
+			user.Verified = true
+			return nil
+		})
+		if errors.Is(err, ErrVerificationStale) {
+			tokenErr = err
+			return nil
+		}
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+	return user, nil
+}
+
+// PurgeExpiredVerifications removes tokens that expired before now and
+// returns how many were removed.
+func PurgeExpiredVerifications(now time.Time) (int, error) {
+	purged := 0
+
+	err := DB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(EmailVerificationBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		var expired [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			var verification model.EmailVerification
+			if err := json.Unmarshal(v, &verification); err != nil {
+				return err
+			}
+			if !now.Before(verification.ExpiresAt) {
+				expired = append(expired, bytes.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		purged = len(expired)
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+func putVerification(tx *bolt.Tx, tokenHash []byte, v *model.EmailVerification) error {
+	bucket, err := tx.CreateBucketIfNotExists(EmailVerificationBucket)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(tokenHash, buf)
+}
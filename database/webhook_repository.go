@@ -0,0 +1,405 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// WebhookBucket maps webhook IDs to subscriptions.
+	WebhookBucket = []byte("Webhooks")
+	// WebhookOutboxBucket holds pending deliveries keyed by delivery ID.
+	WebhookOutboxBucket = []byte("WebhookOutbox")
+	// WebhookScheduleBucket indexes the outbox by when each delivery is
+	// next due: keys are <next-attempt unix nanos> <delivery ID> and have no
+	// value, so due deliveries are found without reading the others.
+	WebhookScheduleBucket = []byte("WebhookSchedule")
+	// WebhookDeadLetterBucket holds deliveries that ran out of attempts,
+	// under the same IDs.
+	WebhookDeadLetterBucket = []byte("WebhookDeadLetters")
+)
+
+var (
+	// ErrWebhookNotFound is returned for unknown webhook IDs.
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrDeliveryNotFound is returned for unknown or already handled
+	// deliveries.
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// CreateWebhook stores a new subscription, assigning its ID.
+func CreateWebhook(webhook *model.Webhook) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(WebhookBucket)
+		if err != nil {
+			return err
+		}
+		id, _ := bucket.NextSequence()
+		webhook.ID = int(id)
+		webhook.CreatedAt = time.Now().UTC()
+
+		buf, err := json.Marshal(webhook)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(idKeyString(webhook.ID), buf)
+	})
+}
+
+// GetWebhook returns a subscription including its secret.
+func GetWebhook(id int) (*model.Webhook, error) {
+	var webhook *model.Webhook
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		var err error
+		webhook, err = getWebhook(tx, id)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// ListWebhooks returns every subscription in ID order, including secrets.
+func ListWebhooks() ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		var err error
+		webhooks, err = listWebhooks(tx)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(webhooks, func(a, b model.Webhook) int { return a.ID - b.ID })
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a subscription together with its pending and
+// dead-lettered deliveries.
+func DeleteWebhook(id int) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		if _, err := getWebhook(tx, id); err != nil {
+			return err
+		}
+
+		for _, name := range [][]byte{WebhookOutboxBucket, WebhookDeadLetterBucket} {
+			bucket := tx.Bucket(name)
+			if bucket == nil {
+				continue
+			}
+			var keys [][]byte
+			err := bucket.ForEach(func(k, v []byte) error {
+				var delivery model.WebhookDelivery
+				if err := json.Unmarshal(v, &delivery); err != nil {
+					return err
+				}
+				if delivery.WebhookID != id {
+					return nil
+				}
+				keys = append(keys, bytes.Clone(k))
+				if bytes.Equal(name, WebhookOutboxBucket) {
+					return unscheduleDelivery(tx, &delivery)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, k := range keys {
+				if err := bucket.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Bucket(WebhookBucket).Delete(idKeyString(id))
+	})
+}
+
+// DueWebhookDeliveries returns up to limit outbox deliveries due at now,
+// earliest due first, skipping those for which skip returns true. Only the
+// schedule up to now is walked.
+func DueWebhookDeliveries(now time.Time, limit int, skip func(id uint64) bool) ([]model.WebhookDelivery, error) {
+	var due []model.WebhookDelivery
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		schedule := tx.Bucket(WebhookScheduleBucket)
+		outbox := tx.Bucket(WebhookOutboxBucket)
+		if schedule == nil || outbox == nil {
+			return nil
+		}
+
+		end := timeKey(now)
+		c := schedule.Cursor()
+		for k, _ := c.First(); k != nil && len(due) < limit && bytes.Compare(k[:8], end) <= 0; k, _ = c.Next() {
+			id := binary.BigEndian.Uint64(k[8:])
+			if skip(id) {
+				continue
+			}
+			v := outbox.Get(sequenceKey(id))
+			if v == nil {
+				return fmt.Errorf("scheduled delivery %d: %w", id, ErrDeliveryNotFound)
+			}
+			var delivery model.WebhookDelivery
+			if err := json.Unmarshal(v, &delivery); err != nil {
+				return err
+			}
+			due = append(due, delivery)
+		}
+		return nil
+	})
+	return due, err
+}
+
+// CompleteWebhookDelivery removes a delivered entry from the outbox.
+func CompleteWebhookDelivery(id uint64) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		outbox := tx.Bucket(WebhookOutboxBucket)
+		if outbox == nil {
+			return nil
+		}
+		v := outbox.Get(sequenceKey(id))
+		if v == nil {
+			return nil
+		}
+		var delivery model.WebhookDelivery
+		if err := json.Unmarshal(v, &delivery); err != nil {
+			return err
+		}
+		if err := unscheduleDelivery(tx, &delivery); err != nil {
+			return err
+		}
+		return outbox.Delete(sequenceKey(id))
+	})
+}
+
+// FailWebhookDelivery records a failed attempt. The delivery is retried at
+// nextAttempt, or moved to the dead-letter bucket when deadLetter is set.
+func FailWebhookDelivery(id uint64, deliveryErr error, nextAttempt time.Time, deadLetter bool) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		outbox := tx.Bucket(WebhookOutboxBucket)
+		if outbox == nil {
+			return fmt.Errorf("delivery %d: %w", id, ErrDeliveryNotFound)
+		}
+		v := outbox.Get(sequenceKey(id))
+		if v == nil {
+			// The webhook was deleted while the attempt was running.
+			return nil
+		}
+
+		var delivery model.WebhookDelivery
+		if err := json.Unmarshal(v, &delivery); err != nil {
+			return err
+		}
+		if err := unscheduleDelivery(tx, &delivery); err != nil {
+			return err
+		}
+		delivery.Attempts++
+		delivery.LastError = deliveryErr.Error()
+		delivery.NextAttemptAt = nextAttempt
+
+		if !deadLetter {
+			return putOutboxDelivery(tx, outbox, &delivery)
+		}
+		dead, err := tx.CreateBucketIfNotExists(WebhookDeadLetterBucket)
+		if err != nil {
+			return err
+		}
+		if err := putDelivery(dead, &delivery); err != nil {
+			return err
+		}
+		return outbox.Delete(sequenceKey(id))
+	})
+}
+
+// ListWebhookDeadLetters returns the dead-lettered deliveries of a webhook,
+// or of every webhook when webhookID is zero.
+func ListWebhookDeadLetters(webhookID int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+
+	err := DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(WebhookDeadLetterBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var delivery model.WebhookDelivery
+			if err := json.Unmarshal(v, &delivery); err != nil {
+				return err
+			}
+			if webhookID == 0 || delivery.WebhookID == webhookID {
+				deliveries = append(deliveries, delivery)
+			}
+			return nil
+		})
+	})
+	return deliveries, err
+}
+
+// RequeueWebhookDeadLetter moves a dead-lettered delivery back into the
+// outbox, due immediately and with its attempts reset.
+func RequeueWebhookDeadLetter(id uint64) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		dead := tx.Bucket(WebhookDeadLetterBucket)
+		if dead == nil || dead.Get(sequenceKey(id)) == nil {
+			return fmt.Errorf("dead letter %d: %w", id, ErrDeliveryNotFound)
+		}
+
+		var delivery model.WebhookDelivery
+		if err := json.Unmarshal(dead.Get(sequenceKey(id)), &delivery); err != nil {
+			return err
+		}
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = time.Now().UTC()
+
+		outbox, err := tx.CreateBucketIfNotExists(WebhookOutboxBucket)
+		if err != nil {
+			return err
+		}
+		if err := putOutboxDelivery(tx, outbox, &delivery); err != nil {
+			return err
+		}
+		return dead.Delete(sequenceKey(id))
+	})
+}
+
+// enqueueWebhookDeliveries adds an outbox entry within tx for every webhook
+// subscribed to the change, so deliveries commit or roll back together with
+// the change itself.
+func enqueueWebhookDeliveries(tx *bolt.Tx, change *model.Change) error {
+	webhooks, err := listWebhooks(tx)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	outbox, err := tx.CreateBucketIfNotExists(WebhookOutboxBucket)
+	if err != nil {
+		return err
+	}
+	for _, webhook := range webhooks {
+		if len(webhook.Events) > 0 && !slices.Contains(webhook.Events, change.Action) {
+			continue
+		}
+
+		id, err := outbox.NextSequence()
+		if err != nil {
+			return err
+		}
+		delivery := &model.WebhookDelivery{
+			ID:            id,
+			WebhookID:     webhook.ID,
+			Change:        *change,
+			NextAttemptAt: change.Timestamp,
+			CreatedAt:     change.Timestamp,
+		}
+		if err := putOutboxDelivery(tx, outbox, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getWebhook(tx *bolt.Tx, id int) (*model.Webhook, error) {
+	bucket := tx.Bucket(WebhookBucket)
+	if bucket == nil {
+		return nil, fmt.Errorf("webhook %d: %w", id, ErrWebhookNotFound)
+	}
+	v := bucket.Get(idKeyString(id))
+	if v == nil {
+		return nil, fmt.Errorf("webhook %d: %w", id, ErrWebhookNotFound)
+	}
+
+	var webhook model.Webhook
+	if err := json.Unmarshal(v, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func listWebhooks(tx *bolt.Tx) ([]model.Webhook, error) {
+	bucket := tx.Bucket(WebhookBucket)
+	if bucket == nil {
+		return nil, nil
+	}
+
+	var webhooks []model.Webhook
+	err := bucket.ForEach(func(k, v []byte) error {
+		var webhook model.Webhook
+		if err := json.Unmarshal(v, &webhook); err != nil {
+			return err
+		}
+		webhooks = append(webhooks, webhook)
+		return nil
+	})
+	return webhooks, err
+}
+
+func putDelivery(bucket *bolt.Bucket, delivery *model.WebhookDelivery) error {
+	buf, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(sequenceKey(delivery.ID), buf)
+}
+
+// putOutboxDelivery stores a delivery in the outbox and schedules it for
+// its NextAttemptAt. A rescheduled delivery must be unscheduled first.
+func putOutboxDelivery(tx *bolt.Tx, outbox *bolt.Bucket, delivery *model.WebhookDelivery) error {
+	if err := putDelivery(outbox, delivery); err != nil {
+		return err
+	}
+	schedule, err := tx.CreateBucketIfNotExists(WebhookScheduleBucket)
+	if err != nil {
+		return err
+	}
+	return schedule.Put(scheduleKey(delivery), nil)
+}
+
+func unscheduleDelivery(tx *bolt.Tx, delivery *model.WebhookDelivery) error {
+	schedule := tx.Bucket(WebhookScheduleBucket)
+	if schedule == nil {
+		return nil
+	}
+	return schedule.Delete(scheduleKey(delivery))
+}
+
+func scheduleKey(delivery *model.WebhookDelivery) []byte {
+	return append(timeKey(delivery.NextAttemptAt), sequenceKey(delivery.ID)...)
+}
+
+// rebuildWebhookSchedule recreates WebhookScheduleBucket from the outbox.
+func rebuildWebhookSchedule(tx *bolt.Tx) error {
+	if tx.Bucket(WebhookScheduleBucket) != nil {
+		if err := tx.DeleteBucket(WebhookScheduleBucket); err != nil {
+			return err
+		}
+	}
+	schedule, err := tx.CreateBucket(WebhookScheduleBucket)
+	if err != nil {
+		return err
+	}
+
+	outbox := tx.Bucket(WebhookOutboxBucket)
+	if outbox == nil {
+		return nil
+	}
+	return outbox.ForEach(func(k, v []byte) error {
+		var delivery model.WebhookDelivery
+		if err := json.Unmarshal(v, &delivery); err != nil {
+			return err
+		}
+		return schedule.Put(scheduleKey(&delivery), nil)
+	})
+}
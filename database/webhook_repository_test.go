@@ -0,0 +1,73 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// scheduled returns the number of entries in WebhookScheduleBucket.
+func scheduled(t *testing.T) int {
+	t.Helper()
+	n := 0
+	err := DB.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(WebhookScheduleBucket); bucket != nil {
+			n = bucket.Stats().KeyN
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestWebhookSchedule(t *testing.T) {
+	openTestDB(t)
+	webhook := &model.Webhook{URL: "http://localhost/hook", Secret: "0123456789abcdef"}
+	if err := CreateWebhook(webhook); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	createTestUser(t, "Ada", "ada@example.com")
+	createTestUser(t, "Charles", "charles@example.com")
+	none := func(uint64) bool { return false }
+
+	now := time.Now()
+	due, err := DueWebhookDeliveries(now, 10, none)
+	if err != nil || len(due) != 2 {
+		t.Fatalf("DueWebhookDeliveries = %v, %v, want both creates", due, err)
+	}
+	first, second := due[0], due[1]
+
+	// A failed delivery is due again only at its next attempt.
+	later := now.Add(time.Hour)
+	if err := FailWebhookDelivery(first.ID, errors.New("503"), later, false); err != nil {
+		t.Fatalf("FailWebhookDelivery: %v", err)
+	}
+	if due, err := DueWebhookDeliveries(now, 10, none); err != nil || len(due) != 1 || due[0].ID != second.ID {
+		t.Errorf("DueWebhookDeliveries(now) = %v, %v, want only delivery %d", due, err, second.ID)
+	}
+	due, err = DueWebhookDeliveries(later, 10, none)
+	if err != nil || len(due) != 2 || due[0].ID != second.ID || due[1].ID != first.ID || due[1].Attempts != 1 {
+		t.Errorf("DueWebhookDeliveries(later) = %v, %v, want %d then %d after one attempt", due, err, second.ID, first.ID)
+	}
+	if due, err := DueWebhookDeliveries(later, 10, func(id uint64) bool { return id == second.ID }); err != nil || len(due) != 1 {
+		t.Errorf("DueWebhookDeliveries skipping %d = %v, %v", second.ID, due, err)
+	}
+
+	if err := CompleteWebhookDelivery(second.ID); err != nil {
+		t.Fatalf("CompleteWebhookDelivery: %v", err)
+	}
+	if n := scheduled(t); n != 1 {
+		t.Errorf("%d deliveries scheduled after one completed, want 1", n)
+	}
+	if err := DeleteWebhook(webhook.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	if n := scheduled(t); n != 0 {
+		t.Errorf("%d deliveries scheduled after the webhook was deleted, want 0", n)
+	}
+}
@@ -50,7 +50,7 @@ CREATE_USER_REQUEST='<?xml version="1.0" encoding="UTF-8"?>
 
 send_udp_soap "CreateUser" "$CREATE_USER_REQUEST"
 
-# Test 3: UpdateUser
+# Test 3: UpdateUser without a session, refused with a PermissionDenied fault
 UPDATE_USER_REQUEST='<?xml version="1.0" encoding="UTF-8"?>
 <soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
   <soap:Body>
@@ -64,7 +64,7 @@ UPDATE_USER_REQUEST='<?xml version="1.0" encoding="UTF-8"?>
 
 send_udp_soap "UpdateUser" "$UPDATE_USER_REQUEST"
 
-# Test 4: DeleteUser
+# Test 4: DeleteUser without a session, refused with a PermissionDenied fault
 DELETE_USER_REQUEST='<?xml version="1.0" encoding="UTF-8"?>
 <soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
   <soap:Body>
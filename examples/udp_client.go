@@ -108,8 +108,10 @@ func main() {
 		fmt.Printf("Response:\n%s\n", response)
 	}
 
-	// Test 3: UpdateUser
-	fmt.Println("\n3. Testing UpdateUser...")
+	// Test 3: UpdateUser. Like DeleteUser, it is refused with a
+	// PermissionDenied fault without the Session header of the user or an
+	// admin.
+	fmt.Println("\n3. Testing UpdateUser without a session (should return fault)...")
 	updateUserRequest := `<?xml version="1.0" encoding="UTF-8"?>
 <soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
   <soap:Body>
@@ -128,8 +130,9 @@ func main() {
 		fmt.Printf("Response:\n%s\n", response)
 	}
 
-	// Test 4: DeleteUser
-	fmt.Println("\n4. Testing DeleteUser...")
+	// Test 4: DeleteUser. Without a Session header it is refused with a
+	// PermissionDenied fault: only the user or an admin may delete a user.
+	fmt.Println("\n4. Testing DeleteUser without a session (should return fault)...")
 	deleteUserRequest := `<?xml version="1.0" encoding="UTF-8"?>
 <soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
   <soap:Body>
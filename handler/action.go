@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// requestAction returns the action an HTTP request declares outside its
+// envelope: the SOAPAction header of SOAP 1.1, or the action parameter of
+// an application/soap+xml Content-Type as used by SOAP 1.2. The quotes
+// around a SOAPAction are removed; an empty value means no action.
+func requestAction(r *http.Request) string {
+	if values, ok := r.Header["Soapaction"]; ok && len(values) > 0 {
+		return strings.TrimSpace(strings.Trim(strings.TrimSpace(values[0]), `"`))
+	}
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && mediaType == "application/soap+xml" {
+		return strings.TrimSpace(params["action"])
+	}
+	return ""
+}
+
+// routes is what a service's SOAP requests are routed by: the request
+// element of every operation and the operation every action names. The
+// user service and services served by a Dispatcher share it, so both
+// route requests the same way.
+type routes struct {
+	// requests maps the name of every operation, the local name of its
+	// request element, to that element.
+	requests map[string]xml.Name
+	// byAction maps the action of every operation to its name.
+	byAction map[string]string
+}
+
+func newRoutes() routes {
+	return routes{requests: make(map[string]xml.Name), byAction: make(map[string]string)}
+}
+
+// add routes requests for the element request, and those declaring
+// action, to the operation named by the element's local name.
+func (rt routes) add(request xml.Name, action string) {
+	rt.requests[request.Local] = request
+	if action != "" {
+		rt.byAction[action] = request.Local
+	}
+}
+
+// route returns the name of the operation a request envelope is for, and
+// its WS-Addressing headers, or else the fault to answer with. soapAction
+// is the action given by the transport, if any; see resolve for how it
+// and the wsa:Action header are used.
+func (rt routes) route(body []byte, soapAction string, strict bool) (string, *model.MessageAddressing, *model.SoapEnvelope) {
+	reject := func(code, message string) (string, *model.MessageAddressing, *model.SoapEnvelope) {
+		fault := model.NewSoapFault(code, message)
+		return "", nil, &fault
+	}
+
+	bodyName, err := requestOperation(body)
+	if errors.Is(err, errEmptyBody) {
+		return reject("Client", "SOAP Body is empty")
+	}
+	if err != nil {
+		log.Printf("Error un-marshalling SOAP envelope: %v", err)
+		return reject("Client", "Invalid SOAP message")
+	}
+
+	addressing, err := requestAddressing(body)
+	if err != nil {
+		log.Printf("Error reading WS-Addressing headers: %v", err)
+		return reject("Client", "Invalid WS-Addressing headers")
+	}
+
+	var wsaAction string
+	if addressing != nil {
+		wsaAction = addressing.Action
+	}
+	name, err := rt.resolve(bodyName, wsaAction, soapAction, strict)
+	if err != nil {
+		return reject("Client", err.Error())
+	}
+	if _, ok := rt.requests[name]; !ok {
+		return reject("MustUnderstand", fmt.Sprintf("Unknown operation: %s", name))
+	}
+	return name, addressing, nil
+}
+
+// resolve picks the operation for a request whose first Body element is
+// bodyName. A declared action, the wsa:Action header or else the
+// transport's SOAPAction, routes the request when it names a known
+// operation for that element. Otherwise the request is routed by its Body
+// element: in strict mode a disagreement is a fault, in lenient mode it is
+// logged. An unknown wsa:Action is always a fault, as WS-Addressing
+// requires.
+func (rt routes) resolve(bodyName xml.Name, wsaAction, soapAction string, strict bool) (string, error) {
+	if wsaAction != "" && soapAction != "" && wsaAction != soapAction {
+		if strict {
+			return "", fmt.Errorf("SOAPAction %s does not match wsa:Action %s", soapAction, wsaAction)
+		}
+		log.Printf("SOAPAction %s does not match wsa:Action %s, using wsa:Action", soapAction, wsaAction)
+	}
+
+	source, action := "wsa:Action", wsaAction
+	if action == "" {
+		source, action = "SOAPAction", soapAction
+	}
+	if action == "" {
+		return bodyName.Local, nil
+	}
+
+	name, ok := rt.byAction[action]
+	switch {
+	case !ok && (strict || source == "wsa:Action"):
+		return "", fmt.Errorf("Action not supported: %s", action)
+	case !ok:
+		log.Printf("Ignoring unknown %s %s, routing by Body element %s", source, action, bodyName.Local)
+		return bodyName.Local, nil
+	}
+
+	if request := rt.requests[name]; request != bodyName {
+		if strict {
+			return "", fmt.Errorf("%s %s does not match Body element {%s}%s", source, action, bodyName.Space, bodyName.Local)
+		}
+		log.Printf("%s %s does not match Body element {%s}%s, routing by Body element", source, action, bodyName.Space, bodyName.Local)
+		return bodyName.Local, nil
+	}
+	return name, nil
+}
@@ -0,0 +1,306 @@
+package handler
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+// soapRequest is a request on its way through dispatch, shared by the HTTP
+// and UDP transports. Either op is set, or fault holds the reason the
+// request was rejected.
+type soapRequest struct {
+	ctx  context.Context
+	body []byte
+	op   *operation
+	// addressing holds the request's WS-Addressing headers; it is nil when
+	// it has none, and then so do the replies.
+	addressing *model.MessageAddressing
+	fault      *model.SoapEnvelope
+}
+
+// newSOAPRequest resolves the operation of a request, from its declared
+// action checked against the first SOAP Body element (see routes.route),
+// and authenticates its caller. soapAction is the action given by the
+// transport, if any.
+func newSOAPRequest(ctx context.Context, s *service.UserService, caller model.Caller, body []byte, bearerToken, soapAction string, strictActions bool) *soapRequest {
+	r := &soapRequest{ctx: ctx, body: body}
+
+	name, addressing, fault := operationRoutes.route(body, soapAction, strictActions)
+	if fault != nil {
+		r.fault = fault
+		return r
+	}
+	r.addressing = addressing
+	if r.addressing != nil && r.addressing.MessageID == "" && r.repliesElsewhere() {
+		return r.reject("Client", "MessageID header is required when ReplyTo or FaultTo is not anonymous")
+	}
+	op := operations[name]
+
+	caller, err := authenticateRequest(s, caller, body, bearerToken)
+	if err != nil {
+		fault := serviceFault("Client", err)
+		r.fault = &fault
+		return r
+	}
+
+	r.ctx = service.WithCaller(ctx, caller)
+	r.op = &op
+	return r
+}
+
+func (r *soapRequest) reject(code, message string) *soapRequest {
+	fault := model.NewSoapFault(code, message)
+	r.fault = &fault
+	return r
+}
+
+// run invokes the operation, or returns the fault the request was rejected
+// with.
+func (r *soapRequest) run(s *service.UserService) model.SoapEnvelope {
+	if r.fault != nil {
+		return *r.fault
+	}
+	return r.op.invoke(r.ctx, s, r.body)
+}
+
+// repliesElsewhere reports whether the request asked for its response to
+// be sent to an endpoint other than the connection it came in on.
+func (r *soapRequest) repliesElsewhere() bool {
+	return r.addressing != nil &&
+		(r.addressing.ReplyTo != nil && !model.IsAnonymousAddress(r.addressing.ReplyTo.Address) ||
+			r.addressing.FaultTo != nil && !model.IsAnonymousAddress(r.addressing.FaultTo.Address))
+}
+
+// asynchronous reports whether the response goes to a ReplyTo endpoint
+// other than the request's connection, so the request can be acknowledged
+// at once and run in the background.
+func (r *soapRequest) asynchronous() bool {
+	return r.addressing != nil && r.addressing.MessageID != "" &&
+		r.addressing.ReplyTo != nil && !model.IsAnonymousAddress(r.addressing.ReplyTo.Address)
+}
+
+// destination returns the endpoint a response envelope must be sent to:
+// FaultTo for faults if given, otherwise ReplyTo. It returns nil when the
+// response goes back on the request's connection or, for the W3C none
+// address, nowhere. Requests without a MessageID cannot be correlated, so
+// they are always answered on their connection.
+func (r *soapRequest) destination(env model.SoapEnvelope) *model.EndpointReference {
+	to := r.responseEndpoint(env)
+	if to == nil || r.addressing.MessageID == "" || model.IsAnonymousAddress(to.Address) || to.Address == model.NoneAddress {
+		return nil
+	}
+	return to
+}
+
+func (r *soapRequest) responseEndpoint(env model.SoapEnvelope) *model.EndpointReference {
+	if r.addressing == nil {
+		return nil
+	}
+	if _, isFault := env.Body.Payload.(model.SoapFault); isFault && r.addressing.FaultTo != nil {
+		return r.addressing.FaultTo
+	}
+	return r.addressing.ReplyTo
+}
+
+// reply adds the WS-Addressing headers of a response to env, addressed to
+// the endpoint to, or to the request's connection when to is nil. Requests
+// without addressing headers get none back.
+func (r *soapRequest) reply(env model.SoapEnvelope, to *model.EndpointReference) model.SoapEnvelope {
+	if r.addressing == nil {
+		return env
+	}
+
+	addressing := model.MessageAddressing{
+		Namespace: r.addressing.Namespace,
+		Action:    r.responseAction(env),
+		RelatesTo: r.addressing.MessageID,
+	}
+	if id, err := service.NewMessageID(); err == nil {
+		addressing.MessageID = id
+	}
+
+	header := &model.SoapHeader{}
+	if to != nil {
+		addressing.To = to.Address
+		header.Blocks = append(addressing.HeaderBlocks(), to.HeaderBlocks()...)
+	} else {
+		header.Blocks = addressing.HeaderBlocks()
+	}
+	env.Header = header
+	return env
+}
+
+// responseAction returns the wsa:Action of a response envelope.
+func (r *soapRequest) responseAction(env model.SoapEnvelope) string {
+	if _, isFault := env.Body.Payload.(model.SoapFault); isFault || r.op == nil {
+		return model.FaultAction(r.addressing.Namespace)
+	}
+	return r.op.ResponseAction()
+}
+
+// requestAddressing reads the WS-Addressing headers of a request in either
+// supported namespace. It returns nil if the request has none.
+func requestAddressing(body []byte) (*model.MessageAddressing, error) {
+	var env struct {
+		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+		Header  struct {
+			Blocks []addressingBlock `xml:",any"`
+		} `xml:"http://schemas.xmlsoap.org/soap/envelope/ Header"`
+	}
+	if err := xml.Unmarshal(body, &env); err != nil {
+		return nil, err
+	}
+
+	var addressing *model.MessageAddressing
+	for _, block := range env.Header.Blocks {
+		ns := block.XMLName.Space
+		if ns != model.AddressingNamespace && ns != model.AddressingW3CNamespace {
+			continue
+		}
+		if addressing == nil {
+			addressing = &model.MessageAddressing{Namespace: ns}
+		} else if addressing.Namespace != ns {
+			return nil, fmt.Errorf("WS-Addressing headers mix %s and %s", addressing.Namespace, ns)
+		}
+
+		text := strings.TrimSpace(block.Text)
+		switch block.XMLName.Local {
+		case "To":
+			addressing.To = text
+		case "Action":
+			addressing.Action = text
+		case "MessageID":
+			addressing.MessageID = text
+		case "RelatesTo":
+			addressing.RelatesTo = text
+		case "ReplyTo":
+			addressing.ReplyTo = block.endpointReference()
+		case "FaultTo":
+			addressing.FaultTo = block.endpointReference()
+		}
+	}
+	return addressing, nil
+}
+
+// addressingBlock is a SOAP header block that may be a WS-Addressing
+// header. Its children are matched by local name only, so endpoint
+// references decode in both namespaces.
+type addressingBlock struct {
+	XMLName             xml.Name
+	Text                string                 `xml:",chardata"`
+	Address             string                 `xml:"Address"`
+	ReferenceProperties *model.ReferenceBlocks `xml:"ReferenceProperties"`
+	ReferenceParameters *model.ReferenceBlocks `xml:"ReferenceParameters"`
+}
+
+func (b addressingBlock) endpointReference() *model.EndpointReference {
+	return &model.EndpointReference{
+		Address:             strings.TrimSpace(b.Address),
+		ReferenceProperties: b.ReferenceProperties,
+		ReferenceParameters: b.ReferenceParameters,
+	}
+}
+
+// Retry settings of ReplyDispatcher.
+const (
+	DefaultReplyAttempts = 3
+	replyRetryDelay      = time.Second
+)
+
+// ReplyDispatcher runs requests whose response goes to a non-anonymous
+// ReplyTo or FaultTo endpoint, so that transports can acknowledge them at
+// once, and sends the responses on. Failed sends are retried a few times;
+// after that the response is dropped and logged.
+type ReplyDispatcher struct {
+	Sender   *service.MessageSender
+	Attempts int
+
+	wg       sync.WaitGroup
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewReplyDispatcher creates a dispatcher with the default settings.
+func NewReplyDispatcher() *ReplyDispatcher {
+	return &ReplyDispatcher{
+		Sender:   service.NewMessageSender(),
+		Attempts: DefaultReplyAttempts,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Stop waits for running requests to finish and their responses to be
+// sent, without retrying failed sends any more.
+func (d *ReplyDispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		if d.stop != nil {
+			close(d.stop)
+		}
+	})
+	d.wg.Wait()
+}
+
+// runAndReply runs r in the background and sends its response to the
+// endpoint it asked for. A response that turns out to belong on the
+// request's connection, such as a fault without a FaultTo, is dropped
+// because that connection has already been answered.
+func (d *ReplyDispatcher) runAndReply(s *service.UserService, r *soapRequest) {
+	r.ctx = context.WithoutCancel(r.ctx)
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.send(r, r.run(s))
+	}()
+}
+
+// forward sends a response that has already been produced, in the
+// background.
+func (d *ReplyDispatcher) forward(r *soapRequest, env model.SoapEnvelope) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.send(r, env)
+	}()
+}
+
+func (d *ReplyDispatcher) send(r *soapRequest, env model.SoapEnvelope) {
+	to := r.destination(env)
+	if to == nil {
+		if endpoint := r.responseEndpoint(env); endpoint != nil && endpoint.Address == model.NoneAddress {
+			return
+		}
+		log.Printf("Dropping response to message %s: it has no non-anonymous endpoint to go to", r.addressing.MessageID)
+		return
+	}
+	action := r.responseAction(env)
+	env = r.reply(env, to)
+
+	delay := replyRetryDelay
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), service.DefaultSendTimeout)
+		err := d.Sender.Send(ctx, to.Address, action, env)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt >= max(d.Attempts, 1) {
+			log.Printf("Sending response to message %s to %s failed after %d attempts: %v", r.addressing.MessageID, to.Address, attempt, err)
+			return
+		}
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-d.stop:
+			log.Printf("Sending response to message %s to %s failed, not retrying during shutdown: %v", r.addressing.MessageID, to.Address, err)
+			return
+		}
+	}
+}
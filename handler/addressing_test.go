@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+// addressedEnvelope returns a GetUserByID request for id with the given
+// header blocks.
+func addressedEnvelope(id, headers string) []byte {
+	return []byte(`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Header>` + headers +
+		`</soap:Header><soap:Body><GetUserByID xmlns="urn:user-service"><id>` + id + `</id></GetUserByID></soap:Body></soap:Envelope>`)
+}
+
+// wsa returns a W3C WS-Addressing element.
+func wsa(name, content string) string {
+	return `<wsa:` + name + ` xmlns:wsa="` + model.AddressingW3CNamespace + `">` + content + `</wsa:` + name + `>`
+}
+
+func TestWSAddressingReplies(t *testing.T) {
+	openTestDB(t)
+	user := &model.User{Name: "Ada", Email: "ada@example.com"}
+	if err := database.CreateUser(model.Caller{Principal: "test"}, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	replies := make(chan string, 10)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		replies <- r.URL.Path + " " + string(body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(endpoint.Close)
+
+	dispatcher := NewReplyDispatcher()
+	t.Cleanup(dispatcher.Stop)
+	server := httptest.NewServer(&UserSOAPHandler{UserService: &service.UserService{}, Replies: dispatcher})
+	t.Cleanup(server.Close)
+
+	post := func(envelope []byte) (int, string) {
+		t.Helper()
+		resp, err := http.Post(server.URL, "text/xml; charset=utf-8", bytes.NewReader(envelope))
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	nextReply := func() string {
+		t.Helper()
+		select {
+		case reply := <-replies:
+			return reply
+		case <-time.After(10 * time.Second):
+			t.Fatal("no reply within 10s")
+			return ""
+		}
+	}
+	replyTo := func(address string) string { return wsa("ReplyTo", wsa("Address", address)) }
+	faultTo := func(address string) string { return wsa("FaultTo", wsa("Address", address)) }
+	anonymous := model.AnonymousAddress(model.AddressingW3CNamespace)
+
+	// An anonymous ReplyTo is answered on the connection, related to the
+	// request.
+	status, body := post(addressedEnvelope("1", wsa("MessageID", "urn:uuid:1")+replyTo(anonymous)))
+	if status != http.StatusOK || !strings.Contains(body, "GetUserByIDResponse") || !strings.Contains(body, ">urn:uuid:1</") {
+		t.Errorf("anonymous ReplyTo: %d %s, want a response relating to urn:uuid:1", status, body)
+	}
+
+	// Other endpoints are sent the response after the request is accepted.
+	status, _ = post(addressedEnvelope("1", wsa("MessageID", "urn:uuid:2")+replyTo(endpoint.URL+"/reply")))
+	if status != http.StatusAccepted {
+		t.Errorf("ReplyTo endpoint: status %d, want 202", status)
+	}
+	if reply := nextReply(); !strings.HasPrefix(reply, "/reply ") || !strings.Contains(reply, "ada@example.com") || !strings.Contains(reply, ">urn:uuid:2</") {
+		t.Errorf("reply = %s, want user 1 relating to urn:uuid:2", reply)
+	}
+
+	// Faults go to FaultTo, even when the reply would be anonymous.
+	status, _ = post(addressedEnvelope("99", wsa("MessageID", "urn:uuid:3")+replyTo(anonymous)+faultTo(endpoint.URL+"/fault")))
+	if status != http.StatusAccepted {
+		t.Errorf("FaultTo endpoint: status %d, want 202", status)
+	}
+	if reply := nextReply(); !strings.HasPrefix(reply, "/fault ") || !strings.Contains(reply, "Fault") || !strings.Contains(reply, ">urn:uuid:3</") {
+		t.Errorf("fault = %s, want a fault relating to urn:uuid:3", reply)
+	}
+
+	// Replies to the none address are dropped.
+	status, _ = post(addressedEnvelope("1", wsa("MessageID", "urn:uuid:4")+replyTo(model.NoneAddress)))
+	if status != http.StatusAccepted {
+		t.Errorf("none ReplyTo: status %d, want 202", status)
+	}
+
+	// A response for another endpoint cannot be correlated without a
+	// MessageID.
+	status, body = post(addressedEnvelope("1", replyTo(endpoint.URL+"/reply")))
+	if status == http.StatusAccepted || !strings.Contains(body, "MessageID header is required") {
+		t.Errorf("ReplyTo without MessageID: %d %s, want a fault", status, body)
+	}
+
+	dispatcher.Stop()
+	select {
+	case reply := <-replies:
+		t.Errorf("unexpected reply %s", reply)
+	default:
+	}
+}
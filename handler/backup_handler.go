@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+// BackupHandler streams a consistent copy of the database to admins. The
+// session token is sent as a Bearer token.
+type BackupHandler struct {
+	UserService *service.UserService
+}
+
+func (h *BackupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	caller, err := h.UserService.AuthenticateCaller(model.Caller{
+		Transport:  model.TransportHTTP,
+		ClientAddr: r.RemoteAddr,
+	}, token)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	filename := "users-" + time.Now().UTC().Format("20060102T150405Z") + ".db"
+	out := &backupResponseWriter{ResponseWriter: w, filename: filename}
+	n, err := h.UserService.WriteBackup(service.WithCaller(r.Context(), caller), out)
+	if err != nil {
+		if out.started {
+			// Too late for an error status; the client sees a truncated body.
+			log.Printf("Backup to %s failed after %d bytes: %v", r.RemoteAddr, n, err)
+			return
+		}
+		if errors.Is(err, service.ErrPermissionDenied) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Backup failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Streamed %d byte backup to %s", n, r.RemoteAddr)
+}
+
+// backupResponseWriter sets the download headers on the first write, so
+// errors raised before any data is produced can still be reported with a
+// proper status.
+type backupResponseWriter struct {
+	http.ResponseWriter
+	filename string
+	started  bool
+}
+
+func (w *backupResponseWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+w.filename+`"`)
+	}
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+// ChangeStreamHandler streams the change feed over a long-lived HTTP
+// response, starting after the sequence in the "since" query parameter.
+// Clients asking for text/event-stream get Server-Sent Events whose IDs are
+// change sequences, so EventSource resumes on its own via Last-Event-ID;
+// everyone else gets one JSON change per line (application/x-ndjson).
+// Like GetChanges it is for admins, whose session token is sent as a
+// Bearer token.
+type ChangeStreamHandler struct {
+	UserService *service.UserService
+	// Done, if set, is closed when the server shuts down; open streams end
+	// then instead of holding the shutdown up.
+	Done <-chan struct{}
+}
+
+func (h *ChangeStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	caller, err := h.UserService.AuthenticateCaller(model.Caller{
+		Transport:  model.TransportHTTP,
+		ClientAddr: r.RemoteAddr,
+	}, token)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	since := r.URL.Query().Get("since")
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		since = lastID
+	}
+	var sinceSequence uint64
+	if since != "" {
+		if sinceSequence, err = strconv.ParseUint(since, 10, 64); err != nil {
+			http.Error(w, "Invalid since sequence", http.StatusBadRequest)
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	ctx, cancel := context.WithCancel(service.WithCaller(r.Context(), caller))
+	defer cancel()
+	if h.Done != nil {
+		go func() {
+			select {
+			case <-h.Done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	// The response starts with the first call of emit, once the caller is
+	// known to be an admin.
+	started := false
+	err = h.UserService.StreamChanges(ctx, sinceSequence, func(changes []model.Change) error {
+		if !started {
+			started = true
+			if sse {
+				w.Header().Set("Content-Type", "text/event-stream")
+			} else {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+			}
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+		}
+		if len(changes) == 0 {
+			// Heartbeat: a comment line for SSE, an empty line for NDJSON.
+			heartbeat := "\n"
+			if sse {
+				heartbeat = ":\n\n"
+			}
+			if _, err := fmt.Fprint(w, heartbeat); err != nil {
+				return err
+			}
+		}
+		for _, change := range changes {
+			data, err := json.Marshal(change)
+			if err != nil {
+				return err
+			}
+			if sse {
+				_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.Sequence, change.Action, data)
+			} else {
+				_, err = fmt.Fprintf(w, "%s\n", data)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		flusher.Flush()
+		return nil
+	})
+	if started {
+		return
+	}
+	if errors.Is(err, service.ErrPermissionDenied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, fmt.Sprintf("Change stream failed: %v", err), http.StatusInternalServerError)
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+func TestChangeStreamNeedsAdmin(t *testing.T) {
+	openTestDB(t)
+	s := &service.UserService{}
+	ctx := context.Background()
+
+	tokens := map[string]string{}
+	for _, user := range []*model.User{
+		{Name: "Admin", Email: "admin@example.com"},
+		{Name: "Alice", Email: "alice@example.com"},
+	} {
+		if err := database.CreateUser(model.Caller{Principal: "test"}, user); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if err := s.SetPassword(ctx, user.ID, "CorrectHorse42"); err != nil {
+			t.Fatalf("SetPassword: %v", err)
+		}
+		if user.Name == "Admin" {
+			if err := service.GrantAdmin(user.ID); err != nil {
+				t.Fatalf("GrantAdmin: %v", err)
+			}
+		}
+		login, err := s.HandleAuthenticateUser(ctx, model.AuthenticateUserRequest{Email: user.Email, Password: "CorrectHorse42"})
+		if err != nil || !login.Success {
+			t.Fatalf("AuthenticateUser(%s) = %+v, %v", user.Email, login, err)
+		}
+		tokens[user.Name] = login.Token
+	}
+
+	done := make(chan struct{})
+	server := httptest.NewServer(&ChangeStreamHandler{UserService: s, Done: done})
+	defer server.Close()
+	defer close(done)
+
+	get := func(authorization string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		return resp
+	}
+
+	for _, test := range []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"anonymous", "", http.StatusUnauthorized},
+		{"invalid token", "Bearer nonsense", http.StatusUnauthorized},
+		{"user", "Bearer " + tokens["Alice"], http.StatusForbidden},
+	} {
+		resp := get(test.authorization)
+		resp.Body.Close()
+		if resp.StatusCode != test.want {
+			t.Errorf("%s: status = %d, want %d", test.name, resp.StatusCode, test.want)
+		}
+	}
+
+	resp := get("Bearer " + tokens["Admin"])
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("admin: status = %d, Content-Type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	line, err := bufio.NewReader(resp.Body).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("reading the stream: %v", err)
+	}
+	var change model.Change
+	if err := json.Unmarshal(line, &change); err != nil || change.Sequence == 0 {
+		t.Errorf("first line %q: %+v, %v", line, change, err)
+	}
+}
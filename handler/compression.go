@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// Content codings understood on requests and offered on responses. As in
+// HTTP, "deflate" is the zlib format; raw deflate data is accepted too.
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// Compression limits. Responses smaller than compressMinSize are not worth
+// compressing; decompressed requests larger than MaxDecompressedSize are
+// refused so that a small compressed body cannot expand without bound.
+const (
+	compressMinSize     = 1024
+	MaxDecompressedSize = 16 << 20
+)
+
+// MaxDecompressedDatagram is the largest message a compressed UDP datagram
+// may expand to.
+const MaxDecompressedDatagram = 64 << 10
+
+var errUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+
+// decodeRequestBody replaces the body of r with its decompressed form
+// according to its Content-Encoding.
+func decodeRequestBody(w http.ResponseWriter, r *http.Request) error {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+	reader, err := decompressor(encoding, r.Body)
+	if errors.Is(err, errUnsupportedEncoding) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s body: %w", encoding, err)
+	}
+	r.Body = http.MaxBytesReader(w, reader, MaxDecompressedSize)
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	return nil
+}
+
+// decompressor returns a reader of the data compressed in r.
+func decompressor(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case encodingGzip, "x-gzip":
+		return gzip.NewReader(r)
+	case encodingDeflate:
+		br := bufio.NewReader(r)
+		if header, err := br.Peek(2); err == nil && isZlibHeader(header) {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	}
+	return nil, fmt.Errorf("%w: %s", errUnsupportedEncoding, encoding)
+}
+
+// isZlibHeader reports whether data starts with a zlib header using the
+// deflate method.
+func isZlibHeader(data []byte) bool {
+	return len(data) >= 2 && data[0]&0x0f == 8 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0
+}
+
+// datagramEncoding returns the coding of a compressed UDP datagram, which
+// is recognised by its gzip or zlib header, or "" for plain XML. Neither
+// header can start an XML document, so no other flag is needed.
+func datagramEncoding(data []byte) string {
+	switch {
+	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
+		return encodingGzip
+	case isZlibHeader(data):
+		return encodingDeflate
+	}
+	return ""
+}
+
+// decompressDatagram returns the message compressed in a UDP datagram.
+func decompressDatagram(encoding string, data []byte) ([]byte, error) {
+	reader, err := decompressor(encoding, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	message, err := io.ReadAll(io.LimitReader(reader, MaxDecompressedDatagram+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(message) > MaxDecompressedDatagram {
+		return nil, fmt.Errorf("decompressed message exceeds %d bytes", MaxDecompressedDatagram)
+	}
+	return message, nil
+}
+
+// negotiateEncoding picks the response coding for an Accept-Encoding
+// header: gzip or deflate, whichever the client prefers, or "" for none.
+func negotiateEncoding(acceptEncoding string) string {
+	best, bestQ := "", 0.0
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(item, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if coding == "*" {
+			coding = encodingGzip
+		}
+		if coding != encodingGzip && coding != encodingDeflate || q <= 0 {
+			continue
+		}
+		if q > bestQ || q == bestQ && coding == encodingGzip {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressor returns a writer compressing into w with encoding.
+func compressor(encoding string, w io.Writer) io.WriteCloser {
+	if encoding == encodingDeflate {
+		return zlib.NewWriter(w)
+	}
+	return gzip.NewWriter(w)
+}
+
+// compress returns data compressed with encoding.
+func compress(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := compressor(encoding, &buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeCompressed writes a complete response body, compressed if the
+// client accepts it and the body is large enough to benefit.
+func writeCompressed(w http.ResponseWriter, r *http.Request, status int, body []byte) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding")); encoding != "" && len(body) >= compressMinSize {
+		if compressed, err := compress(encoding, body); err == nil {
+			w.Header().Set("Content-Encoding", encoding)
+			body = compressed
+		}
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// marshalEnvelope renders a SOAP envelope with the XML declaration,
+// compact unless indent is set.
+func marshalEnvelope(env model.SoapEnvelope, indent bool) ([]byte, error) {
+	var output []byte
+	var err error
+	if indent {
+		output, err = xml.MarshalIndent(env, "", "  ")
+	} else {
+		output, err = xml.Marshal(env)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), output...), nil
+}
+
+// marshalJSON renders a JSON response body ending in a newline, compact
+// unless indent is set.
+func marshalJSON(v any, indent bool) ([]byte, error) {
+	var output []byte
+	var err error
+	if indent {
+		output, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		output, err = json.Marshal(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(output, '\n'), nil
+}
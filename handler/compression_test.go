@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"identity":                  "",
+		"br":                        "",
+		"gzip":                      "gzip",
+		"deflate, gzip":             "gzip",
+		"deflate":                   "deflate",
+		"gzip;q=0.5, deflate":       "deflate",
+		"gzip;q=0, deflate;q=0":     "",
+		"*":                         "gzip",
+		"GZIP ; q=0.8, br;q=1.0":    "gzip",
+		"deflate;q=1, gzip;q=0.999": "deflate",
+	}
+	for header, want := range tests {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompressedDatagrams(t *testing.T) {
+	message := testEnvelope("", `<GetUserByID xmlns="urn:user-service"><id>1</id></GetUserByID>`)
+	if encoding := datagramEncoding(message); encoding != "" {
+		t.Errorf("plain XML taken for %s", encoding)
+	}
+	for _, encoding := range []string{encodingGzip, encodingDeflate} {
+		data, err := compress(encoding, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := datagramEncoding(data); got != encoding {
+			t.Errorf("datagramEncoding of %s data = %q", encoding, got)
+		}
+		if got, err := decompressDatagram(encoding, data); err != nil || !bytes.Equal(got, message) {
+			t.Errorf("decompressDatagram(%s) = %s, %v", encoding, got, err)
+		}
+	}
+
+	bomb, err := compress(encodingGzip, make([]byte, MaxDecompressedDatagram+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decompressDatagram(encodingGzip, bomb); err == nil {
+		t.Error("decompressDatagram accepted a message over MaxDecompressedDatagram")
+	}
+}
+
+func TestCompressedHTTP(t *testing.T) {
+	openTestDB(t)
+	user := &model.User{Name: "Ada", Email: "ada@example.com"}
+	if err := database.CreateUser(model.Caller{Principal: "test"}, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	server := httptest.NewServer(&UserSOAPHandler{UserService: &service.UserService{}})
+	t.Cleanup(server.Close)
+	// The client must see the response as sent, not decompressed.
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+	request := testEnvelope("", `<GetUserByID xmlns="urn:user-service"><id>1</id></GetUserByID>`)
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write(request)
+	zw.Close()
+	var deflated bytes.Buffer
+	fw, _ := flate.NewWriter(&deflated, flate.DefaultCompression)
+	fw.Write(request)
+	fw.Close()
+
+	post := func(encoding string, body []byte) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+		req.Header.Set("Content-Encoding", encoding)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+	for encoding, body := range map[string][]byte{"gzip": gzipped.Bytes(), "deflate": deflated.Bytes()} {
+		if status, response := post(encoding, body); status != http.StatusOK || !strings.Contains(response, "ada@example.com") {
+			t.Errorf("%s request: %d %s, want user 1", encoding, status, response)
+		}
+	}
+	if status, response := post("br", request); !strings.Contains(response, "<faultstring>unsupported Content-Encoding: br</faultstring>") {
+		t.Errorf("br request: %d %s, want a Client fault", status, response)
+	}
+
+	// Small responses are sent as is, large ones compressed as asked.
+	req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(request))
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	resp.Body.Close()
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		t.Errorf("small response sent with Content-Encoding %s", encoding)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"?wsdl", nil)
+	req.Header.Set("Accept-Encoding", "deflate;q=0.5, gzip")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("GET ?wsdl: %v", err)
+	}
+	defer resp.Body.Close()
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "gzip" {
+		t.Fatalf("WSDL sent with Content-Encoding %q, want gzip", encoding)
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if wsdl, err := io.ReadAll(zr); err != nil || !bytes.Contains(wsdl, []byte("definitions")) {
+		t.Errorf("WSDL = %.100s, %v", wsdl, err)
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// Dispatcher serves the operations of another SOAP service over HTTP with
+// the envelope, compression, MTOM and fault handling of the user service.
+// Operations are added with Register, usually by the Register function
+// cmd/wsdl2go generates for the service, before it serves requests.
+//
+// Errors returned by operations become Server faults whose detail names
+// their kind, as classified by service.KindOf: wrap
+// service.ErrInvalidArgument and the other sentinels to report a kind.
+type Dispatcher struct {
+	// IndentResponses pretty-prints response envelopes; they are compact
+	// by default.
+	IndentResponses bool
+	// StrictActions faults requests whose SOAPAction or wsa:Action does not
+	// name the operation of their Body element, instead of logging the
+	// mismatch and routing by the Body.
+	StrictActions bool
+	// WSDL is served at ?wsdl when set.
+	WSDL []byte
+
+	// operations maps the name of every operation, the local name of its
+	// request element, to the function invoking it.
+	operations map[string]func(ctx context.Context, body []byte) model.SoapEnvelope
+	routes     routes
+}
+
+// NewDispatcher returns a dispatcher without operations.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		operations: make(map[string]func(context.Context, []byte) model.SoapEnvelope),
+		routes:     newRoutes(),
+	}
+}
+
+// Register adds an operation to d. Requests are routed to handle like
+// those of the user service: by their Body element, the one declared by
+// the XMLName field of Req, or by action. Resp must declare its element
+// the same way.
+func Register[Req, Resp any](d *Dispatcher, action string, handle func(context.Context, Req) (Resp, error)) {
+	request := elementName(reflect.TypeFor[Req]())
+	// Responses are marshalled as the element their type declares.
+	elementName(reflect.TypeFor[Resp]())
+	if _, ok := d.operations[request.Local]; ok {
+		panic(fmt.Sprintf("handler: operation %s registered twice", request.Local))
+	}
+
+	d.routes.add(request, action)
+	d.operations[request.Local] = func(ctx context.Context, body []byte) model.SoapEnvelope {
+		req, _, fault := decodeRequest[Req](ctx, request.Local, body)
+		if fault != nil {
+			return *fault
+		}
+		response, err := handle(ctx, req)
+		if err != nil {
+			log.Printf("Service error for %s: %v", request.Local, err)
+			return serviceFault("Server", err)
+		}
+		return model.NewSoapEnvelope(response)
+	}
+}
+
+// Dispatch runs the operation a request envelope is for and returns its
+// response or fault envelope. action is the SOAP action the transport
+// gave, if any.
+func (d *Dispatcher) Dispatch(ctx context.Context, action string, body []byte) model.SoapEnvelope {
+	name, _, fault := d.routes.route(body, action, d.StrictActions)
+	if fault != nil {
+		return *fault
+	}
+	return d.operations[name](ctx, body)
+}
+
+func (d *Dispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Query().Has("wsdl") && d.WSDL != nil {
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		writeCompressed(w, r, http.StatusOK, d.WSDL)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, body, err := readSOAPBody(w, r)
+	if err != nil {
+		writeSOAPEnvelope(w, r, model.NewSoapFault("Client", err.Error()), d.IndentResponses)
+		return
+	}
+	writeSOAPEnvelope(w, r, d.Dispatch(ctx, requestAction(r), body), d.IndentResponses)
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// testEnvelope returns a request envelope with the given wsa:Action, if
+// any, and Body content.
+func testEnvelope(wsaAction, body string) []byte {
+	var header string
+	if wsaAction != "" {
+		header = `<soap:Header><wsa:Action xmlns:wsa="` + model.AddressingW3CNamespace + `">` + wsaAction + `</wsa:Action></soap:Header>`
+	}
+	return []byte(`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">` + header + `<soap:Body>` + body + `</soap:Body></soap:Envelope>`)
+}
+
+// outcome describes the result of dispatching a request: the operation
+// it ran, or the fault it was answered with.
+func outcome(env model.SoapEnvelope) string {
+	switch payload := env.Body.Payload.(type) {
+	case model.SoapFault:
+		return payload.Code + ": " + payload.String
+	case model.GetUserByIDResponse:
+		return "GetUserByID"
+	case model.CreateUserResponse:
+		return "CreateUser"
+	}
+	return fmt.Sprintf("%T", env.Body.Payload)
+}
+
+func TestDispatcherRoutesLikeUserService(t *testing.T) {
+	d := NewDispatcher()
+	Register(d, operations["GetUserByID"].Action(), func(context.Context, model.GetUserByIDRequest) (model.GetUserByIDResponse, error) {
+		return model.GetUserByIDResponse{}, nil
+	})
+	Register(d, operations["CreateUser"].Action(), func(context.Context, model.CreateUserRequest) (model.CreateUserResponse, error) {
+		return model.CreateUserResponse{}, nil
+	})
+
+	get := `<GetUserByID xmlns="urn:user-service"><id>1</id></GetUserByID>`
+	tests := []struct {
+		name       string
+		body       []byte
+		soapAction string
+		strict     bool
+		want       string
+	}{
+		{"body element", testEnvelope("", get), "", false, "GetUserByID"},
+		{"matching action", testEnvelope("", get), "urn:user-service/GetUserByID", true, "GetUserByID"},
+		{"unknown action", testEnvelope("", get), "urn:other/Thing", false, "GetUserByID"},
+		{"unknown action strict", testEnvelope("", get), "urn:other/Thing", true, "Client: Action not supported: urn:other/Thing"},
+		{"other operation's action", testEnvelope("", get), "urn:user-service/CreateUser", false, "GetUserByID"},
+		{"other operation's action strict", testEnvelope("", get), "urn:user-service/CreateUser", true,
+			"Client: SOAPAction urn:user-service/CreateUser does not match Body element {urn:user-service}GetUserByID"},
+		{"unknown wsa:Action", testEnvelope("urn:other/Thing", get), "", false, "Client: Action not supported: urn:other/Thing"},
+		{"wsa:Action over SOAPAction", testEnvelope("urn:user-service/GetUserByID", get), "urn:other/Thing", false, "GetUserByID"},
+		{"unknown operation", testEnvelope("", `<Frobnicate xmlns="urn:user-service"/>`), "", false, "MustUnderstand: Unknown operation: Frobnicate"},
+		{"empty body", testEnvelope("", ""), "", false, "Client: SOAP Body is empty"},
+		{"not an envelope", []byte(`<Envelope/>`), "", false, "Client: Invalid SOAP message"},
+	}
+	for _, tt := range tests {
+		d.StrictActions = tt.strict
+		if got := outcome(d.Dispatch(context.Background(), tt.soapAction, tt.body)); got != tt.want {
+			t.Errorf("%s: Dispatch = %q, want %q", tt.name, got, tt.want)
+		}
+
+		// The user service answers the same way.
+		name, _, fault := operationRoutes.route(tt.body, tt.soapAction, tt.strict)
+		if fault != nil {
+			name = outcome(*fault)
+		}
+		if name != tt.want {
+			t.Errorf("%s: user service routes to %q, want %q", tt.name, name, tt.want)
+		}
+	}
+}
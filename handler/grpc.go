@@ -0,0 +1,392 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+	"github.com/maasumiyaat/soap/userpb"
+)
+
+// GRPCServiceName is the full name of the service in user.proto, under
+// which the health service also reports it.
+const GRPCServiceName = "user.v1.UserService"
+
+// grpcExcluded are the operations user.proto leaves out: WS-Eventing
+// notifications are delivered to SOAP endpoints only.
+var grpcExcluded = map[string]bool{
+	"Subscribe":   true,
+	"Renew":       true,
+	"GetStatus":   true,
+	"Unsubscribe": true,
+}
+
+// NewGRPCServer returns a gRPC server running the RPCs of user.proto on s,
+// along with the standard health service and server reflection.
+//
+// Each RPC runs the operation of the same name: its request message is
+// copied into the operation's request type, matching fields by their JSON
+// names, and the response is copied back the same way. A session token
+// is read from "authorization: Bearer <token>" metadata.
+func NewGRPCServer(s *service.UserService, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(opts...)
+	server.RegisterService(grpcServiceDesc(), s)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(GRPCServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+	return server
+}
+
+// grpcFields maps model types to the indexes of their fields by the
+// number of the message field each is copied to and from. It is filled
+// once by grpcServiceDesc, which checks that user.proto and the model
+// agree, and only read afterwards.
+var grpcFields = make(map[reflect.Type]map[protoreflect.FieldNumber]int)
+
+// grpcServiceDesc describes the service of user.proto to grpc, with a
+// handler per method built from the operation of the same name. It panics
+// if the .proto and the operations have drifted apart.
+var grpcServiceDesc = sync.OnceValue(func() *grpc.ServiceDesc {
+	sd := userpb.File_user_proto.Services().ByName("UserService")
+	desc := &grpc.ServiceDesc{
+		ServiceName: string(sd.FullName()),
+		HandlerType: (*any)(nil),
+		Metadata:    userpb.File_user_proto.Path(),
+	}
+
+	methods := sd.Methods()
+	for i := range methods.Len() {
+		desc.Methods = append(desc.Methods, grpcMethod(methods.Get(i)))
+	}
+	for name := range operations {
+		if !grpcExcluded[name] && methods.ByName(protoreflect.Name(name)) == nil {
+			panic("handler: user.proto has no RPC for " + name)
+		}
+	}
+	return desc
+})
+
+// grpcMethod returns the handler of a method, which calls the operation
+// of the same name.
+func grpcMethod(method protoreflect.MethodDescriptor) grpc.MethodDesc {
+	name := string(method.Name())
+	op, ok := operations[name]
+	if !ok {
+		panic("handler: user.proto RPC " + name + " has no operation")
+	}
+	checkGRPCMessage(method.Input(), op.requestType)
+	checkGRPCMessage(method.Output(), op.responseType)
+
+	input, err := protoregistry.GlobalTypes.FindMessageByName(method.Input().FullName())
+	if err != nil {
+		panic("handler: " + err.Error())
+	}
+	output, err := protoregistry.GlobalTypes.FindMessageByName(method.Output().FullName())
+	if err != nil {
+		panic("handler: " + err.Error())
+	}
+	fullMethod := fmt.Sprintf("/%s/%s", method.Parent().FullName(), name)
+
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			in := input.New().Interface()
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			call := func(ctx context.Context, in any) (any, error) {
+				request := reflect.New(op.requestType).Elem()
+				fromProtoMessage(in.(protoreflect.ProtoMessage).ProtoReflect(), request)
+				response, err := callGRPCMethod(ctx, srv.(*service.UserService), op, request.Interface())
+				if err != nil {
+					if status.Code(err) == codes.Internal {
+						log.Printf("Service error for %s: %v", fullMethod, err)
+					}
+					return nil, err
+				}
+				out := output.New()
+				toProtoMessage(reflect.ValueOf(response), out)
+				return out.Interface(), nil
+			}
+			if interceptor == nil {
+				return call(ctx, in)
+			}
+			return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}, call)
+		},
+	}
+}
+
+// callGRPCMethod authenticates the caller of an RPC and runs op.
+func callGRPCMethod(ctx context.Context, s *service.UserService, op operation, request any) (any, error) {
+	caller := model.Caller{Transport: model.TransportGRPC}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		caller.ClientAddr = p.Addr.String()
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(value, "Bearer "); ok {
+			var err error
+			if caller, err = s.AuthenticateCaller(caller, token); err != nil {
+				return nil, grpcError(err)
+			}
+			break
+		}
+	}
+
+	response, err := op.call(service.WithCaller(ctx, caller), s, "", request)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return response, nil
+}
+
+// grpcError converts a service error to a status with the code of its kind.
+func grpcError(err error) error {
+	return status.Error(grpcCode(service.KindOf(err)), err.Error())
+}
+
+// grpcCode returns the status code for service errors of a kind.
+func grpcCode(kind service.ErrorKind) codes.Code {
+	switch kind {
+	case service.KindInvalidArgument:
+		return codes.InvalidArgument
+	case service.KindUnauthenticated:
+		return codes.Unauthenticated
+	case service.KindPermissionDenied:
+		return codes.PermissionDenied
+	case service.KindNotFound:
+		return codes.NotFound
+	case service.KindConflict:
+		return codes.AlreadyExists
+	}
+	return codes.Internal
+}
+
+const timestampName = "google.protobuf.Timestamp"
+
+// checkGRPCMessage checks that every field of a message has a field of
+// the same JSON name and a compatible type in the model type t, and the
+// other way around, and records the pairing in grpcFields.
+func checkGRPCMessage(message protoreflect.MessageDescriptor, t reflect.Type) {
+	if _, ok := grpcFields[t]; ok {
+		return
+	}
+	byJSONName := make(map[string]int)
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		byJSONName[name] = i
+	}
+
+	index := make(map[protoreflect.FieldNumber]int)
+	grpcFields[t] = index
+	fields := message.Fields()
+	for i := range fields.Len() {
+		fd := fields.Get(i)
+		j, ok := byJSONName[fd.JSONName()]
+		if !ok {
+			panic(fmt.Sprintf("handler: %s has no field for %s", t, fd.FullName()))
+		}
+		delete(byJSONName, fd.JSONName())
+		index[fd.Number()] = j
+		checkGRPCField(fd, t.Field(j).Type)
+	}
+	for name := range byJSONName {
+		panic(fmt.Sprintf("handler: %s has no field for %s.%s", message.FullName(), t, name))
+	}
+}
+
+func checkGRPCField(fd protoreflect.FieldDescriptor, t reflect.Type) {
+	mismatch := func() {
+		panic(fmt.Sprintf("handler: %s cannot hold %s", t, fd.FullName()))
+	}
+	if fd.IsList() {
+		if t.Kind() != reflect.Slice || t.Elem().Kind() == reflect.Uint8 {
+			mismatch()
+		}
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch fd.Kind() {
+	case protoreflect.MessageKind:
+		switch {
+		case fd.Message().FullName() == timestampName:
+			if t != timeType {
+				mismatch()
+			}
+		case t.Kind() == reflect.Struct && t != timeType:
+			checkGRPCMessage(fd.Message(), t)
+		default:
+			mismatch()
+		}
+	case protoreflect.StringKind:
+		if t.Kind() != reflect.String {
+			mismatch()
+		}
+	case protoreflect.BoolKind:
+		if t.Kind() != reflect.Bool {
+			mismatch()
+		}
+	case protoreflect.Int32Kind, protoreflect.Int64Kind:
+		if t.Kind() != reflect.Int && t.Kind() != reflect.Int32 && t.Kind() != reflect.Int64 {
+			mismatch()
+		}
+	case protoreflect.Uint64Kind:
+		if t.Kind() != reflect.Uint64 {
+			mismatch()
+		}
+	case protoreflect.BytesKind:
+		if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uint8 {
+			mismatch()
+		}
+	default:
+		mismatch()
+	}
+}
+
+// fromProtoMessage copies the fields set in message to the model struct v.
+func fromProtoMessage(message protoreflect.Message, v reflect.Value) {
+	fields := message.Descriptor().Fields()
+	for number, i := range grpcFields[v.Type()] {
+		fd := fields.ByNumber(number)
+		if !message.Has(fd) {
+			continue
+		}
+		field := v.Field(i)
+		if fd.IsList() {
+			list := message.Get(fd).List()
+			slice := reflect.MakeSlice(field.Type(), list.Len(), list.Len())
+			for j := range list.Len() {
+				fromProtoValue(fd, list.Get(j), slice.Index(j))
+			}
+			field.Set(slice)
+			continue
+		}
+		fromProtoValue(fd, message.Get(fd), field)
+	}
+}
+
+// fromProtoValue stores a single value of fd in v, allocating v first if
+// it is a pointer.
+func fromProtoValue(fd protoreflect.FieldDescriptor, value protoreflect.Value, v reflect.Value) {
+	if v.Kind() == reflect.Pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	switch fd.Kind() {
+	case protoreflect.MessageKind:
+		if v.Type() == timeType {
+			v.Set(reflect.ValueOf(value.Message().Interface().(*timestamppb.Timestamp).AsTime()))
+			return
+		}
+		fromProtoMessage(value.Message(), v)
+	case protoreflect.StringKind:
+		v.SetString(value.String())
+	case protoreflect.BoolKind:
+		v.SetBool(value.Bool())
+	case protoreflect.Int32Kind, protoreflect.Int64Kind:
+		v.SetInt(value.Int())
+	case protoreflect.Uint64Kind:
+		v.SetUint(value.Uint())
+	case protoreflect.BytesKind:
+		v.SetBytes(append([]byte(nil), value.Bytes()...))
+	}
+}
+
+// toProtoMessage copies the model struct v to message, leaving zero
+// values and nil pointers unset.
+func toProtoMessage(v reflect.Value, message protoreflect.Message) {
+	fields := message.Descriptor().Fields()
+	for number, i := range grpcFields[v.Type()] {
+		fd := fields.ByNumber(number)
+		field := v.Field(i)
+		if fd.IsList() {
+			if field.Len() == 0 {
+				continue
+			}
+			list := message.NewField(fd).List()
+			for j := range field.Len() {
+				element := field.Index(j)
+				if element.Kind() == reflect.Pointer {
+					if element.IsNil() {
+						continue
+					}
+					element = element.Elem()
+				}
+				list.Append(toProtoValue(fd, element, list.NewElement))
+			}
+			message.Set(fd, protoreflect.ValueOfList(list))
+			continue
+		}
+
+		if field.Kind() == reflect.Pointer {
+			if field.IsNil() {
+				continue
+			}
+			field = field.Elem()
+		} else if field.IsZero() {
+			continue
+		}
+		message.Set(fd, toProtoValue(fd, field, func() protoreflect.Value { return message.NewField(fd) }))
+	}
+}
+
+// toProtoValue converts v to a single value of fd, using newMessage for
+// the message to fill in if fd holds messages.
+func toProtoValue(fd protoreflect.FieldDescriptor, v reflect.Value, newMessage func() protoreflect.Value) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.MessageKind:
+		if v.Type() == timeType {
+			return protoreflect.ValueOfMessage(timestamppb.New(v.Interface().(time.Time)).ProtoReflect())
+		}
+		value := newMessage()
+		toProtoMessage(v, value.Message())
+		return value
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(v.String())
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(v.Bool())
+	case protoreflect.Int32Kind:
+		return protoreflect.ValueOfInt32(int32(v.Int()))
+	case protoreflect.Int64Kind:
+		return protoreflect.ValueOfInt64(v.Int())
+	case protoreflect.Uint64Kind:
+		return protoreflect.ValueOfUint64(v.Uint())
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes(v.Bytes())
+	}
+	panic("handler: unsupported field " + string(fd.FullName()))
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+	"github.com/maasumiyaat/soap/userpb"
+)
+
+// openTestDB points database.DB at a new, migrated database for the
+// duration of t.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	if err := database.InitDB(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+}
+
+// dialGRPC serves the gRPC server of s on an in-process listener and
+// returns a connection to it.
+func dialGRPC(t *testing.T, s *service.UserService) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(s)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// invoke calls an RPC of the user service.
+func invoke(ctx context.Context, conn *grpc.ClientConn, method string, request, response proto.Message) error {
+	return conn.Invoke(ctx, "/"+GRPCServiceName+"/"+method, request, response)
+}
+
+func TestGRPCUserOperations(t *testing.T) {
+	openTestDB(t)
+	conn := dialGRPC(t, &service.UserService{})
+	ctx := context.Background()
+
+	var created userpb.CreateUserResponse
+	err := invoke(ctx, conn, "CreateUser", &userpb.CreateUserRequest{Name: "Ada Lovelace", Email: "Ada@Example.COM"}, &created)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if created.User.GetId() == 0 || created.User.GetEmail() != "Ada@example.com" || created.User.GetCreatedAt() == nil {
+		t.Errorf("CreateUser returned %v", created.User)
+	}
+
+	var got userpb.GetUserByIDResponse
+	if err := invoke(ctx, conn, "GetUserByID", &userpb.GetUserByIDRequest{Id: created.User.GetId()}, &got); err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if !proto.Equal(got.User, created.User) {
+		t.Errorf("GetUserByID = %v, want %v", got.User, created.User)
+	}
+
+	var batch userpb.BatchUsersResponse
+	err = invoke(ctx, conn, "BatchUsers", &userpb.BatchUsersRequest{Items: []*userpb.BatchItem{
+		{Operation: &userpb.BatchItem_Create{Create: &userpb.CreateUserRequest{Name: "Grace", Email: "grace@example.com"}}},
+		{Operation: &userpb.BatchItem_Create{Create: &userpb.CreateUserRequest{Name: "Edsger", Email: "edsger@example.com"}}},
+	}}, &batch)
+	if err != nil {
+		t.Fatalf("BatchUsers: %v", err)
+	}
+	if !batch.Committed || batch.Succeeded != 2 || batch.Results[1].GetUser().GetName() != "Edsger" {
+		t.Errorf("BatchUsers = %v", &batch)
+	}
+
+	codesByRequest := []struct {
+		method   string
+		request  proto.Message
+		response proto.Message
+		code     codes.Code
+	}{
+		{"GetUserByID", &userpb.GetUserByIDRequest{Id: 999}, &userpb.GetUserByIDResponse{}, codes.NotFound},
+		{"CreateUser", &userpb.CreateUserRequest{Name: "Bad", Email: "not an email"}, &userpb.CreateUserResponse{}, codes.InvalidArgument},
+		{"CreateUser", &userpb.CreateUserRequest{Name: "Ada", Email: "ada@example.com"}, &userpb.CreateUserResponse{}, codes.AlreadyExists},
+		{"ExportUsers", &userpb.ExportUsersRequest{Format: model.BulkFormatCSV}, &userpb.ExportUsersResponse{}, codes.PermissionDenied},
+		{"UpdateUser", &userpb.UpdateUserRequest{Id: created.User.GetId(), Name: "Ada King"}, &userpb.UpdateUserResponse{}, codes.PermissionDenied},
+	}
+	for _, c := range codesByRequest {
+		if err := invoke(ctx, conn, c.method, c.request, c.response); status.Code(err) != c.code {
+			t.Errorf("%s(%v) error = %v, want code %s", c.method, c.request, err, c.code)
+		}
+	}
+}
+
+func TestGRPCSession(t *testing.T) {
+	openTestDB(t)
+	s := &service.UserService{}
+	conn := dialGRPC(t, s)
+	ctx := context.Background()
+
+	admin := &model.User{Name: "Admin", Email: "admin@example.com"}
+	if err := database.CreateUser(model.Caller{Principal: "test"}, admin); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := service.GrantAdmin(admin.ID); err != nil {
+		t.Fatalf("GrantAdmin: %v", err)
+	}
+	if err := s.SetPassword(ctx, admin.ID, "CorrectHorse42"); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+
+	var login userpb.AuthenticateUserResponse
+	err := invoke(ctx, conn, "AuthenticateUser", &userpb.AuthenticateUserRequest{Email: admin.Email, Password: "CorrectHorse42"}, &login)
+	if err != nil || !login.Success || login.Token == "" {
+		t.Fatalf("AuthenticateUser = %v, %v", &login, err)
+	}
+
+	authorized := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+login.Token)
+	var export userpb.ExportUsersResponse
+	if err := invoke(authorized, conn, "ExportUsers", &userpb.ExportUsersRequest{Format: model.BulkFormatCSV}, &export); err != nil {
+		t.Fatalf("ExportUsers with an admin session: %v", err)
+	}
+	if export.Count != 1 {
+		t.Errorf("ExportUsers count = %d, want 1", export.Count)
+	}
+
+	var batch userpb.BatchUsersResponse
+	err = invoke(authorized, conn, "BatchUsers", &userpb.BatchUsersRequest{Items: []*userpb.BatchItem{
+		{Operation: &userpb.BatchItem_Update{Update: &userpb.UpdateUserRequest{Id: int32(admin.ID), Name: "Root"}}},
+	}}, &batch)
+	if err != nil {
+		t.Fatalf("BatchUsers with an admin session: %v", err)
+	}
+	if !batch.Committed || batch.Results[0].GetUser().GetName() != "Root" {
+		t.Errorf("BatchUsers = %v", &batch)
+	}
+
+	invalid := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer nonsense")
+	err = invoke(invalid, conn, "GetUserByID", &userpb.GetUserByIDRequest{Id: int32(admin.ID)}, &userpb.GetUserByIDResponse{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("GetUserByID with an invalid token: error = %v, want Unauthenticated", err)
+	}
+}
+
+func TestGRPCHealthAndReflection(t *testing.T) {
+	openTestDB(t)
+	conn := dialGRPC(t, &service.UserService{})
+	ctx := context.Background()
+
+	health := healthpb.NewHealthClient(conn)
+	for _, name := range []string{"", GRPCServiceName} {
+		response, err := health.Check(ctx, &healthpb.HealthCheckRequest{Service: name})
+		if err != nil || response.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("Check(%q) = %v, %v, want SERVING", name, response, err)
+		}
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatalf("ServerReflectionInfo: %v", err)
+	}
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	response, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	var services []string
+	for _, s := range response.GetListServicesResponse().GetService() {
+		services = append(services, s.Name)
+	}
+	if !slices.Contains(services, GRPCServiceName) {
+		t.Errorf("reflection lists %v, want %s among them", services, GRPCServiceName)
+	}
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+// JSON-RPC limits: the size of an HTTP request body and the number of
+// calls in one batch.
+const (
+	MaxJSONRPCMessageSize = 1 << 20
+	MaxJSONRPCBatchSize   = 100
+)
+
+// JSON-RPC 2.0 error codes. Service errors get a code in the range the
+// specification leaves to servers, one per service.ErrorKind, except for
+// invalid arguments, which are invalid params.
+const (
+	jsonRPCParseError       = -32700
+	jsonRPCInvalidRequest   = -32600
+	jsonRPCMethodNotFound   = -32601
+	jsonRPCInvalidParams    = -32602
+	jsonRPCServerError      = -32000
+	jsonRPCUnauthenticated  = -32001
+	jsonRPCPermissionDenied = -32003
+	jsonRPCNotFound         = -32004
+	jsonRPCConflict         = -32009
+)
+
+// JSONRPCHandler serves every SOAP operation as a JSON-RPC 2.0 method of
+// the same name over HTTP POST. The session token is taken from the
+// "session" member of the params or else from a Bearer token.
+type JSONRPCHandler struct {
+	UserService *service.UserService
+	// IndentResponses pretty-prints responses; they are compact by default.
+	IndentResponses bool
+}
+
+func (h *JSONRPCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := decodeRequestBody(w, r); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errUnsupportedEncoding) {
+			status = http.StatusUnsupportedMediaType
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxJSONRPCMessageSize))
+	if err != nil {
+		if tooLarge := (*http.MaxBytesError)(nil); errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	bearerToken, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	response := runJSONRPC(r.Context(), h.UserService, model.Caller{
+		Transport:  model.TransportHTTP,
+		ClientAddr: r.RemoteAddr,
+	}, bearerToken, body)
+	if response == nil {
+		// Only notifications, which are not answered.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	output, err := marshalJSON(response, h.IndentResponses)
+	if err != nil {
+		log.Printf("Error marshalling JSON-RPC response: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeCompressed(w, r, http.StatusOK, output)
+}
+
+// isJSONMessage reports whether a datagram holds JSON rather than a SOAP
+// envelope, judging by its first significant byte.
+func isJSONMessage(data []byte) bool {
+	data = bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	return len(data) > 0 && (data[0] == '{' || data[0] == '[')
+}
+
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	// ID is nil for notifications, which get no response.
+	ID json.RawMessage `json:"id"`
+}
+
+type jsonRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// jsonRPCExtras are the members of the params that are not request fields:
+// the session token, and the wse:Identifier header of Renew, GetStatus and
+// Unsubscribe.
+type jsonRPCExtras struct {
+	Session    string `json:"session"`
+	Identifier string `json:"identifier"`
+}
+
+// runJSONRPC runs a JSON-RPC message, a single request or a batch, and
+// returns the response to send: a response, a slice of them, or nil if
+// there is none because only notifications were sent.
+func runJSONRPC(ctx context.Context, s *service.UserService, caller model.Caller, bearerToken string, message []byte) any {
+	message = bytes.TrimSpace(bytes.TrimPrefix(message, []byte("\xef\xbb\xbf")))
+	if !json.Valid(message) {
+		return jsonRPCFailure(nil, jsonRPCParseError, "Parse error")
+	}
+	if message[0] != '[' {
+		if response := runJSONRPCCall(ctx, s, caller, bearerToken, message); response != nil {
+			return response
+		}
+		return nil
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(message, &batch); err != nil {
+		return jsonRPCFailure(nil, jsonRPCParseError, "Parse error")
+	}
+	if len(batch) == 0 {
+		return jsonRPCFailure(nil, jsonRPCInvalidRequest, "Invalid Request: empty batch")
+	}
+	if len(batch) > MaxJSONRPCBatchSize {
+		return jsonRPCFailure(nil, jsonRPCInvalidRequest, fmt.Sprintf("Invalid Request: batch contains %d calls, the maximum is %d", len(batch), MaxJSONRPCBatchSize))
+	}
+
+	var responses []*jsonRPCResponse
+	for _, call := range batch {
+		if response := runJSONRPCCall(ctx, s, caller, bearerToken, call); response != nil {
+			responses = append(responses, response)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return responses
+}
+
+// runJSONRPCCall runs one request object, returning nil for notifications.
+func runJSONRPCCall(ctx context.Context, s *service.UserService, caller model.Caller, bearerToken string, message json.RawMessage) *jsonRPCResponse {
+	var request jsonRPCRequest
+	if err := json.Unmarshal(message, &request); err != nil {
+		return jsonRPCFailure(nil, jsonRPCInvalidRequest, "Invalid Request")
+	}
+	if id := bytes.TrimSpace(request.ID); len(id) > 0 && id[0] != '"' && id[0] != '-' && (id[0] < '0' || id[0] > '9') && string(id) != "null" {
+		return jsonRPCFailure(nil, jsonRPCInvalidRequest, "Invalid Request: id must be a string, number or null")
+	}
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		return jsonRPCFailure(request.ID, jsonRPCInvalidRequest, `Invalid Request: jsonrpc must be "2.0" and method is required`)
+	}
+
+	result, code, err := callJSONRPCMethod(ctx, s, caller, bearerToken, request)
+	if request.ID == nil {
+		if err != nil {
+			log.Printf("JSON-RPC notification %s failed: %v", request.Method, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return jsonRPCFailure(request.ID, code, err.Error())
+	}
+	return &jsonRPCResponse{JSONRPC: "2.0", Result: result, ID: request.ID}
+}
+
+// callJSONRPCMethod decodes the params of a request into the request type
+// of the operation named by its method and calls the service, returning
+// the error code to report if it fails.
+func callJSONRPCMethod(ctx context.Context, s *service.UserService, caller model.Caller, bearerToken string, request jsonRPCRequest) (any, int, error) {
+	op, ok := operations[request.Method]
+	if !ok {
+		return nil, jsonRPCMethodNotFound, fmt.Errorf("Method not found: %s", request.Method)
+	}
+
+	params := bytes.TrimSpace(request.Params)
+	if len(params) == 0 || string(params) == "null" {
+		params = []byte("{}")
+	}
+	if params[0] != '{' {
+		return nil, jsonRPCInvalidParams, errors.New("Invalid params: params must be an object")
+	}
+	var extras jsonRPCExtras
+	if err := json.Unmarshal(params, &extras); err != nil {
+		return nil, jsonRPCInvalidParams, fmt.Errorf("Invalid params: %v", err)
+	}
+	value := reflect.New(op.requestType)
+	if err := json.Unmarshal(params, value.Interface()); err != nil {
+		return nil, jsonRPCInvalidParams, fmt.Errorf("Invalid params: %v", err)
+	}
+
+	token := extras.Session
+	if token == "" {
+		token = bearerToken
+	}
+	if token != "" {
+		var err error
+		if caller, err = s.AuthenticateCaller(caller, token); err != nil {
+			return nil, jsonRPCErrorCode(err), err
+		}
+	}
+
+	result, err := op.call(service.WithCaller(ctx, caller), s, extras.Identifier, value.Elem().Interface())
+	if err != nil {
+		log.Printf("Service error for %s: %v", request.Method, err)
+		return nil, jsonRPCErrorCode(err), err
+	}
+	return result, 0, nil
+}
+
+func jsonRPCFailure(id json.RawMessage, code int, message string) *jsonRPCResponse {
+	return &jsonRPCResponse{JSONRPC: "2.0", Error: &jsonRPCError{Code: code, Message: message}, ID: id}
+}
+
+// jsonRPCErrorCode returns the error code for a service error.
+func jsonRPCErrorCode(err error) int {
+	switch service.KindOf(err) {
+	case service.KindInvalidArgument:
+		return jsonRPCInvalidParams
+	case service.KindUnauthenticated:
+		return jsonRPCUnauthenticated
+	case service.KindPermissionDenied:
+		return jsonRPCPermissionDenied
+	case service.KindNotFound:
+		return jsonRPCNotFound
+	case service.KindConflict:
+		return jsonRPCConflict
+	}
+	return jsonRPCServerError
+}
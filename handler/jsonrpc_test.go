@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+func TestJSONRPC(t *testing.T) {
+	openTestDB(t)
+	user := &model.User{Name: "Ada", Email: "ada@example.com"}
+	if err := database.CreateUser(model.Caller{Principal: "test"}, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	s := &service.UserService{}
+	server := httptest.NewServer(&JSONRPCHandler{UserService: s})
+	t.Cleanup(server.Close)
+
+	get := `{"jsonrpc":"2.0","method":"GetUserByID","params":{"id":1},"id":1}`
+	tests := []struct {
+		name, message string
+		status        int
+		// want are substrings of the response body.
+		want []string
+	}{
+		{"call", get, http.StatusOK, []string{`"id":1}`, `"email":"ada@example.com"`}},
+		{"string id", `{"jsonrpc":"2.0","method":"GetUserByID","params":{"id":1},"id":"a"}`, http.StatusOK, []string{`"id":"a"}`}},
+		{"notification", `{"jsonrpc":"2.0","method":"GetUserByID","params":{"id":1}}`, http.StatusNoContent, nil},
+		{"parse error", `{"jsonrpc":`, http.StatusOK, []string{`"code":-32700`, `"id":null`}},
+		{"not 2.0", `{"jsonrpc":"1.0","method":"GetUserByID","id":2}`, http.StatusOK, []string{`"code":-32600`, `"id":2`}},
+		{"object id", `{"jsonrpc":"2.0","method":"GetUserByID","id":{}}`, http.StatusOK, []string{`"code":-32600`, `"id":null`}},
+		{"unknown method", `{"jsonrpc":"2.0","method":"Frobnicate","id":3}`, http.StatusOK, []string{`"code":-32601`}},
+		{"params array", `{"jsonrpc":"2.0","method":"GetUserByID","params":[1],"id":4}`, http.StatusOK, []string{`"code":-32602`}},
+		{"invalid argument", `{"jsonrpc":"2.0","method":"CreateUser","params":{"name":"Bob","email":"bob"},"id":5}`, http.StatusOK, []string{`"code":-32602`}},
+		{"not found", `{"jsonrpc":"2.0","method":"GetUserByID","params":{"id":99},"id":6}`, http.StatusOK, []string{`"code":-32004`}},
+		{"permission denied", `{"jsonrpc":"2.0","method":"DeleteUser","params":{"id":1},"id":7}`, http.StatusOK, []string{`"code":-32003`}},
+		{"invalid session", `{"jsonrpc":"2.0","method":"GetUserByID","params":{"id":1,"session":"nonsense"},"id":8}`, http.StatusOK, []string{`"code":-32001`}},
+		{"empty batch", `[]`, http.StatusOK, []string{`"code":-32600`}},
+		{"batch", `[` + get + `,{"jsonrpc":"2.0","method":"GetUserByID","params":{"id":1}},{"jsonrpc":"2.0","method":"Frobnicate","id":9}]`,
+			http.StatusOK, []string{`[{"jsonrpc":"2.0","result":`, `"code":-32601`, `"id":9}]`}},
+		{"batch of notifications", `[{"jsonrpc":"2.0","method":"GetUserByID","params":{"id":1}}]`, http.StatusNoContent, nil},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(tt.message))
+		if err != nil {
+			t.Fatalf("%s: POST: %v", tt.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(body), want) {
+				t.Errorf("%s: response %s, want %s in it", tt.name, body, want)
+			}
+		}
+	}
+
+	// The UDP port answers JSON-RPC next to SOAP.
+	udp := NewUDPSOAPHandler(s)
+	if err := udp.StartUDPServer("127.0.0.1:0"); err != nil {
+		t.Fatalf("StartUDPServer: %v", err)
+	}
+	t.Cleanup(udp.Stop)
+	conn, err := net.Dial("udp", udp.conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(get)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64<<10)
+	n, err := conn.Read(buf)
+	if err != nil || !strings.Contains(string(buf[:n]), `"email":"ada@example.com"`) {
+		t.Errorf("UDP response = %s, %v, want user 1", buf[:n], err)
+	}
+}
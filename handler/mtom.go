@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strings"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// Limits on MTOM requests. Each part is read with its own limit, so an
+// oversized attachment is refused as soon as it passes it.
+const (
+	MaxMTOMMessageSize = 8 << 20
+	MaxAttachmentSize  = 4 << 20
+)
+
+// mtomRootID is the Content-ID of the envelope part of MTOM responses.
+const mtomRootID = "root.message@user-service"
+
+var errAttachmentTooLarge = fmt.Errorf("attachment exceeds %d bytes", MaxAttachmentSize)
+
+// isMTOM reports whether a Content-Type is that of an MTOM message: a
+// multipart/related package whose root is an XOP document.
+func isMTOM(contentType string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "multipart/related" && strings.EqualFold(params["type"], "application/xop+xml")
+}
+
+// acceptsMTOM reports whether an MTOM response may be sent for r: the
+// request was MTOM itself, or its Accept header lists multipart/related.
+func acceptsMTOM(r *http.Request) bool {
+	return isMTOM(r.Header.Get("Content-Type")) || strings.Contains(r.Header.Get("Accept"), "multipart/related")
+}
+
+// readMTOM splits an MTOM request into its root XOP document, the SOAP
+// envelope, and its attachments keyed by Content-ID. Parts are read one at
+// a time from the request body, each into memory, since attachments are
+// stored whole; reading stops as soon as a part passes MaxAttachmentSize.
+func readMTOM(w http.ResponseWriter, r *http.Request) ([]byte, map[string]*mimePart, error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if params["boundary"] == "" {
+		return nil, nil, errors.New("multipart/related without boundary")
+	}
+	start := contentID(params["start"])
+
+	reader := multipart.NewReader(http.MaxBytesReader(w, r.Body, MaxMTOMMessageSize), params["boundary"])
+	var root []byte
+	parts := make(map[string]*mimePart)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, MaxAttachmentSize+1))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(data) > MaxAttachmentSize {
+			return nil, nil, errAttachmentTooLarge
+		}
+
+		id := contentID(part.Header.Get("Content-ID"))
+		if root == nil && (start == "" || id == start) {
+			if mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); mediaType != "application/xop+xml" {
+				return nil, nil, fmt.Errorf("root part is %s, not application/xop+xml", mediaType)
+			}
+			root = data
+			continue
+		}
+		if id == "" {
+			return nil, nil, errors.New("attachment part without Content-ID")
+		}
+		parts[id] = &mimePart{contentType: part.Header.Get("Content-Type"), data: data}
+	}
+	if root == nil {
+		return nil, nil, errors.New("missing root part")
+	}
+	return root, parts, nil
+}
+
+// contentID strips the angle brackets around a Content-ID or start value.
+func contentID(value string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(value), "<"), ">")
+}
+
+// mimePart is an attachment of an MTOM message.
+type mimePart struct {
+	contentType string
+	data        []byte
+}
+
+type attachmentsKey struct{}
+
+// withAttachments returns ctx carrying the attachments of an MTOM
+// request, for resolveAttachments.
+func withAttachments(ctx context.Context, parts map[string]*mimePart) context.Context {
+	return context.WithValue(ctx, attachmentsKey{}, parts)
+}
+
+// resolveAttachments fills in every Attachment of a decoded request that
+// refers to a MIME part with the part's data. request must be a pointer.
+func resolveAttachments(ctx context.Context, request any) error {
+	parts, _ := ctx.Value(attachmentsKey{}).(map[string]*mimePart)
+	return walkAttachments(reflect.ValueOf(request), func(a *model.Attachment) error {
+		if a.ContentID == "" {
+			return nil
+		}
+		part, ok := parts[a.ContentID]
+		if !ok {
+			return fmt.Errorf("attachment cid:%s not found", a.ContentID)
+		}
+		a.Data = part.data
+		if a.ContentType == "" {
+			a.ContentType = part.contentType
+		}
+		a.ContentID = ""
+		return nil
+	})
+}
+
+// externalizeAttachments moves the non-empty attachments of a response
+// into MIME parts, leaving xop:Include elements in the envelope. It
+// returns the parts in document order; env is left alone if there are
+// none.
+func externalizeAttachments(env *model.SoapEnvelope) []outgoingPart {
+	payload := env.Body.Payload
+	if payload == nil {
+		return nil
+	}
+	// Work on a copy, as the payload is not addressable inside env.
+	v := reflect.New(reflect.TypeOf(payload))
+	v.Elem().Set(reflect.ValueOf(payload))
+
+	var parts []outgoingPart
+	_ = walkAttachments(v, func(a *model.Attachment) error {
+		if len(a.Data) == 0 {
+			return nil
+		}
+		a.ContentID = fmt.Sprintf("attachment%d@user-service", len(parts)+1)
+		parts = append(parts, outgoingPart{id: a.ContentID, contentType: a.ContentType, data: a.Data})
+		return nil
+	})
+	if len(parts) > 0 {
+		env.Body.Payload = v.Elem().Interface()
+	}
+	return parts
+}
+
+type outgoingPart struct {
+	id          string
+	contentType string
+	data        []byte
+}
+
+var attachmentType = reflect.TypeFor[model.Attachment]()
+
+// walkAttachments calls fn for every Attachment reachable from v through
+// exported struct fields, pointers and slices.
+func walkAttachments(v reflect.Value, fn func(*model.Attachment) error) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return walkAttachments(v.Elem(), fn)
+	case reflect.Struct:
+		if v.Type() == attachmentType {
+			return fn(v.Addr().Interface().(*model.Attachment))
+		}
+		for i := range v.NumField() {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := walkAttachments(v.Field(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return nil
+		}
+		for i := range v.Len() {
+			if err := walkAttachments(v.Index(i), fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeMTOM writes a response as an MTOM package: the envelope as the root
+// XOP part followed by one binary part per attachment, streamed to w and
+// compressed on the way if the client accepts it.
+func writeMTOM(w http.ResponseWriter, r *http.Request, env model.SoapEnvelope, parts []outgoingPart) {
+	output, err := xml.Marshal(env)
+	if err != nil {
+		log.Printf("Error marshalling response: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var body io.Writer = w
+	w.Header().Add("Vary", "Accept-Encoding")
+	if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+		zw := compressor(encoding, w)
+		defer zw.Close()
+		w.Header().Set("Content-Encoding", encoding)
+		body = zw
+	}
+
+	mw := multipart.NewWriter(body)
+	w.Header().Set("Content-Type", fmt.Sprintf(`multipart/related; type="application/xop+xml"; start="<%s>"; start-info="text/xml"; boundary=%s`,
+		mtomRootID, mw.Boundary()))
+	w.WriteHeader(http.StatusOK)
+
+	root, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {`application/xop+xml; charset=utf-8; type="text/xml"`},
+		"Content-Transfer-Encoding": {"8bit"},
+		"Content-ID":                {"<" + mtomRootID + ">"},
+	})
+	if err != nil {
+		log.Printf("Error writing MTOM response: %v", err)
+		return
+	}
+	_, _ = io.WriteString(root, xml.Header)
+	_, _ = root.Write(output)
+
+	for _, p := range parts {
+		contentType := p.contentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"binary"},
+			"Content-ID":                {"<" + p.id + ">"},
+		})
+		if err != nil {
+			log.Printf("Error writing MTOM response: %v", err)
+			return
+		}
+		if _, err := part.Write(p.data); err != nil {
+			log.Printf("Error writing MTOM response: %v", err)
+			return
+		}
+	}
+	_ = mw.Close()
+}
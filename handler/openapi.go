@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// OpenAPIPath is where the REST handler serves its OpenAPI document.
+const OpenAPIPath = "/api/openapi.json"
+
+// serveOpenAPI writes the OpenAPI document of the REST API, with the
+// server the request reached as its base URL.
+func (h *RESTHandler) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	output, err := json.MarshalIndent(buildOpenAPI(scheme+"://"+r.Host), "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling OpenAPI document: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeCompressed(w, r, http.StatusOK, append(output, '\n'))
+}
+
+// buildOpenAPI describes every REST route as an OpenAPI 3.1 operation.
+// Schemas are derived from the Go types of the bodies by their JSON
+// encoding, and each operation names the SOAP operation it shares, with
+// the action that operation has in the WSDL.
+func buildOpenAPI(server string) openAPIDocument {
+	schemas := newJSONSchemaBuilder()
+	problemSchema := schemas.schema(reflect.TypeFor[problem]())
+
+	doc := openAPIDocument{
+		OpenAPI: "3.1.0",
+		Info: openAPIInfo{
+			Title:       "User Service",
+			Version:     "1.0.0",
+			Description: "JSON API over the operations of the user SOAP service. Errors are RFC 9457 problem details.",
+		},
+		Servers: []openAPIServer{{URL: server}},
+		Paths:   make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: schemas.definitions,
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", Description: "Session token from the AuthenticateUser SOAP operation"},
+			},
+		},
+		// A session is optional, as for the SOAP operations.
+		Security: []map[string][]string{{}, {"bearerAuth": {}}},
+	}
+
+	for _, route := range restRoutes {
+		op := &openAPIOperation{
+			OperationID: route.operationID,
+			Summary:     route.summary,
+			Description: fmt.Sprintf("Runs the %s SOAP operation.", route.operation),
+			SOAPAction:  operations[route.operation].Action(),
+			Responses:   make(map[string]*openAPIResponse),
+		}
+
+		for _, segment := range strings.Split(route.path, "/") {
+			if name, ok := strings.CutPrefix(segment, "{"); ok {
+				op.Parameters = append(op.Parameters, openAPIParameter{
+					Name: strings.TrimSuffix(name, "}"), In: "path", Required: true,
+					Schema: &jsonSchema{Type: "integer"},
+				})
+			}
+		}
+		if route.operation == "SearchUsers" {
+			fields := reflect.TypeFor[model.SearchUsersRequest]()
+			for _, param := range searchParams {
+				field, _ := fields.FieldByName(param.field)
+				schema := schemas.schema(field.Type)
+				schema.Enum = param.enum
+				op.Parameters = append(op.Parameters, openAPIParameter{
+					Name: param.name, In: "query", Description: param.description, Schema: schema,
+				})
+			}
+		}
+
+		problems := map[int]string{
+			http.StatusUnauthorized: "invalid or expired session",
+		}
+		if route.body != nil {
+			op.RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content: map[string]openAPIMediaType{
+					"application/json": {Schema: schemas.schema(route.body), Example: route.bodyExample},
+				},
+			}
+			problems[http.StatusRequestEntityTooLarge] = fmt.Sprintf("Request body exceeds %d bytes", MaxRESTBodySize)
+			problems[http.StatusUnsupportedMediaType] = "Request body must be application/json"
+		}
+		for status, detail := range route.problems {
+			problems[status] = detail
+		}
+
+		success := &openAPIResponse{Description: http.StatusText(route.status)}
+		if route.response != nil {
+			success.Content = map[string]openAPIMediaType{
+				"application/json": {Schema: schemas.schema(route.response), Example: route.responseExample},
+			}
+		}
+		if route.status == http.StatusCreated {
+			success.Headers = map[string]openAPIHeader{
+				"Location": {Description: "Path of the new user", Schema: &jsonSchema{Type: "string"}},
+			}
+		}
+		op.Responses[strconv.Itoa(route.status)] = success
+
+		for status, detail := range problems {
+			op.Responses[strconv.Itoa(status)] = &openAPIResponse{
+				Description: http.StatusText(status),
+				Content: map[string]openAPIMediaType{
+					"application/problem+json": {Schema: problemSchema, Example: problem{
+						Type:     "about:blank",
+						Title:    http.StatusText(status),
+						Status:   status,
+						Detail:   detail,
+						Instance: strings.ReplaceAll(route.path, "{id}", "1"),
+					}},
+				},
+			}
+		}
+		op.Responses["default"] = &openAPIResponse{
+			Description: "Unexpected error",
+			Content:     map[string]openAPIMediaType{"application/problem+json": {Schema: problemSchema}},
+		}
+
+		if doc.Paths[route.path] == nil {
+			doc.Paths[route.path] = make(map[string]*openAPIOperation)
+		}
+		doc.Paths[route.path][strings.ToLower(route.method)] = op
+	}
+	return doc
+}
+
+// jsonSchemaBuilder derives JSON Schemas from Go types, following the
+// encoding/json rules those types are marshalled with. Structs become
+// named component schemas referred to with $ref.
+type jsonSchemaBuilder struct {
+	definitions map[string]*jsonSchema
+}
+
+func newJSONSchemaBuilder() *jsonSchemaBuilder {
+	return &jsonSchemaBuilder{definitions: make(map[string]*jsonSchema)}
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// schema returns the schema of values of t.
+func (b *jsonSchemaBuilder) schema(t reflect.Type) *jsonSchema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct:
+		return &jsonSchema{Ref: "#/components/schemas/" + b.define(t)}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint64:
+		return &jsonSchema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &jsonSchema{Type: "integer", Format: "int32"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &jsonSchema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &jsonSchema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	}
+	return &jsonSchema{}
+}
+
+// define adds the object schema of the struct t to the components, named
+// after the Go type, and returns its name.
+func (b *jsonSchemaBuilder) define(t reflect.Type) string {
+	first, size := utf8.DecodeRuneInString(t.Name())
+	name := string(unicode.ToUpper(first)) + t.Name()[size:]
+	if _, ok := b.definitions[name]; ok {
+		return name
+	}
+	schema := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema)}
+	b.definitions[name] = schema
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		property, options, _ := strings.Cut(tag, ",")
+		if property == "" {
+			property = field.Name
+		}
+		schema.Properties[property] = b.schema(field.Type)
+		optional := slices.ContainsFunc(strings.Split(options, ","), func(option string) bool {
+			return option == "omitempty" || option == "omitzero"
+		})
+		if !optional {
+			schema.Required = append(schema.Required, property)
+		}
+	}
+	return name
+}
+
+// OpenAPI 3.1 document structure, limited to what the REST API uses.
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers"`
+	Security   []map[string][]string                   `json:"security,omitempty"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	// SOAPAction is the action of the SOAP operation sharing the
+	// operation's service method, as an OpenAPI extension.
+	SOAPAction string `json:"x-soap-action,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Schema      *jsonSchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Headers     map[string]openAPIHeader    `json:"headers,omitempty"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIHeader struct {
+	Description string      `json:"description,omitempty"`
+	Schema      *jsonSchema `json:"schema"`
+}
+
+type openAPIMediaType struct {
+	Schema  *jsonSchema `json:"schema"`
+	Example any         `json:"example,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*jsonSchema           `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type openAPISecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// jsonSchema is a JSON Schema (draft 2020-12, as used by OpenAPI 3.1).
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	ContentEncoding      string                 `json:"contentEncoding,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/maasumiyaat/soap/service"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	server := httptest.NewServer(NewRESTHandler(&service.UserService{}))
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + OpenAPIPath)
+	if err != nil {
+		t.Fatalf("GET %s: %v", OpenAPIPath, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("GET %s = %d %s", OpenAPIPath, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	var doc openAPIDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("OpenAPI document: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" || len(doc.Servers) != 1 || doc.Servers[0].URL != server.URL {
+		t.Errorf("document is OpenAPI %s for servers %+v, want 3.1.0 for %s", doc.OpenAPI, doc.Servers, server.URL)
+	}
+
+	// Every route is described, with the action of its SOAP operation.
+	for _, route := range restRoutes {
+		op := doc.Paths[route.path][strings.ToLower(route.method)]
+		if op == nil {
+			t.Errorf("%s %s is not described", route.method, route.path)
+			continue
+		}
+		if op.OperationID != route.operationID || op.SOAPAction != operations[route.operation].Action() {
+			t.Errorf("%s %s is %s with action %s, want %s with the action of %s",
+				route.method, route.path, op.OperationID, op.SOAPAction, route.operationID, route.operation)
+		}
+		if _, ok := op.Responses[strconv.Itoa(route.status)]; !ok {
+			t.Errorf("%s %s has no %d response", route.method, route.path, route.status)
+		}
+	}
+
+	// Every $ref points at a component schema.
+	var check func(where string, schema *jsonSchema)
+	check = func(where string, schema *jsonSchema) {
+		if schema == nil {
+			return
+		}
+		if name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/"); schema.Ref != "" && (!ok || doc.Components.Schemas[name] == nil) {
+			t.Errorf("%s refers to missing schema %s", where, schema.Ref)
+		}
+		check(where, schema.Items)
+		check(where, schema.AdditionalProperties)
+		for _, property := range schema.Properties {
+			check(where, property)
+		}
+	}
+	for name, schema := range doc.Components.Schemas {
+		check("schema "+name, schema)
+	}
+	for path, methods := range doc.Paths {
+		for method, op := range methods {
+			where := method + " " + path
+			for _, param := range op.Parameters {
+				check(where, param.Schema)
+			}
+			if op.RequestBody != nil {
+				for _, media := range op.RequestBody.Content {
+					check(where, media.Schema)
+				}
+			}
+			for _, response := range op.Responses {
+				for _, media := range response.Content {
+					check(where, media.Schema)
+				}
+			}
+		}
+	}
+
+	user := doc.Components.Schemas["User"]
+	if user == nil || user.Properties["email"] == nil || user.Properties["email"].Type != "string" {
+		t.Errorf("User schema = %+v, want a string email property", user)
+	}
+}
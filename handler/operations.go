@@ -0,0 +1,281 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+// operation is a service method exposed over SOAP, and through call over
+// the JSON transports.
+type operation struct {
+	// Request and Response name the elements the operation reads from and
+	// writes to the SOAP Body.
+	Request  xml.Name
+	Response xml.Name
+	// requestType and responseType are the Go types of those elements,
+	// which the WSDL's schema is derived from.
+	requestType  reflect.Type
+	responseType reflect.Type
+	// invoke decodes a complete SOAP request envelope, calls the service
+	// method and builds the response (or fault) envelope.
+	invoke func(ctx context.Context, s *service.UserService, body []byte) model.SoapEnvelope
+	// call runs the service method on a request of requestType that was
+	// decoded some other way, such as from JSON, with the wse:Identifier of
+	// subscription manager operations.
+	call func(ctx context.Context, s *service.UserService, identifier string, request any) (any, error)
+}
+
+// Action returns the WS-Addressing action of requests to op: the request
+// element's namespace and name joined by a slash.
+func (op operation) Action() string {
+	return op.Request.Space + "/" + op.Request.Local
+}
+
+// ResponseAction returns the WS-Addressing action of op's responses.
+func (op operation) ResponseAction() string {
+	return op.Response.Space + "/" + op.Response.Local
+}
+
+// operations maps the local name of the first SOAP Body element to the
+// operation handling it. Both the HTTP and UDP transports dispatch through it.
+var operations = map[string]operation{
+	"GetUserByID": soapOperation("GetUserByID", (*service.UserService).HandleGetUserByID),
+	"CreateUser":  soapOperation("CreateUser", (*service.UserService).HandleCreateUser),
+	"UpdateUser":  soapOperation("UpdateUser", (*service.UserService).HandleUpdateUser),
+	"DeleteUser":  soapOperation("DeleteUser", (*service.UserService).HandleDeleteUser),
+	"RestoreUser": soapOperation("RestoreUser", (*service.UserService).HandleRestoreUser),
+	"PurgeUser":   soapOperation("PurgeUser", (*service.UserService).HandlePurgeUser),
+
+	"GetUserHistory": soapOperation("GetUserHistory", (*service.UserService).HandleGetUserHistory),
+	"GetChanges":     soapOperation("GetChanges", (*service.UserService).HandleGetChanges),
+
+	"SetPassword":      soapOperation("SetPassword", (*service.UserService).HandleSetPassword),
+	"ChangePassword":   soapOperation("ChangePassword", (*service.UserService).HandleChangePassword),
+	"AuthenticateUser": soapOperation("AuthenticateUser", (*service.UserService).HandleAuthenticateUser),
+
+	"CreateGroup":       soapOperation("CreateGroup", (*service.UserService).HandleCreateGroup),
+	"GetGroup":          soapOperation("GetGroup", (*service.UserService).HandleGetGroup),
+	"UpdateGroup":       soapOperation("UpdateGroup", (*service.UserService).HandleUpdateGroup),
+	"DeleteGroup":       soapOperation("DeleteGroup", (*service.UserService).HandleDeleteGroup),
+	"AddGroupMember":    soapOperation("AddGroupMember", (*service.UserService).HandleAddGroupMember),
+	"RemoveGroupMember": soapOperation("RemoveGroupMember", (*service.UserService).HandleRemoveGroupMember),
+	"ListGroupMembers":  soapOperation("ListGroupMembers", (*service.UserService).HandleListGroupMembers),
+	"ListUserGroups":    soapOperation("ListUserGroups", (*service.UserService).HandleListUserGroups),
+
+	"VerifyEmail":              soapOperation("VerifyEmail", (*service.UserService).HandleVerifyEmail),
+	"RequestEmailVerification": soapOperation("RequestEmailVerification", (*service.UserService).HandleRequestEmailVerification),
+
+	"BatchUsers":  soapOperation("BatchUsers", (*service.UserService).HandleBatchUsers),
+	"SearchUsers": soapOperation("SearchUsers", (*service.UserService).HandleSearchUsers),
+
+	"ImportUsers": soapOperation("ImportUsers", (*service.UserService).HandleImportUsers),
+	"ExportUsers": soapOperation("ExportUsers", (*service.UserService).HandleExportUsers),
+
+	"SetUserAvatar": soapOperation("SetUserAvatar", (*service.UserService).HandleSetUserAvatar),
+	"GetUserAvatar": soapOperation("GetUserAvatar", (*service.UserService).HandleGetUserAvatar),
+
+	"BackupDatabase": soapOperation("BackupDatabase", (*service.UserService).HandleBackupDatabase),
+
+	"CreateWebhook":          soapOperation("CreateWebhook", (*service.UserService).HandleCreateWebhook),
+	"ListWebhooks":           soapOperation("ListWebhooks", (*service.UserService).HandleListWebhooks),
+	"DeleteWebhook":          soapOperation("DeleteWebhook", (*service.UserService).HandleDeleteWebhook),
+	"ListWebhookDeadLetters": soapOperation("ListWebhookDeadLetters", (*service.UserService).HandleListWebhookDeadLetters),
+	"RedeliverWebhook":       soapOperation("RedeliverWebhook", (*service.UserService).HandleRedeliverWebhook),
+
+	// WS-Eventing source and subscription manager
+	"Subscribe":   soapOperation("Subscribe", (*service.UserService).HandleSubscribe),
+	"Renew":       subscriptionOperation("Renew", (*service.UserService).HandleRenew),
+	"GetStatus":   subscriptionOperation("GetStatus", (*service.UserService).HandleGetStatus),
+	"Unsubscribe": subscriptionOperation("Unsubscribe", (*service.UserService).HandleUnsubscribe),
+}
+
+// operationRoutes routes requests to operations by their Body element and
+// WS-Addressing action.
+var operationRoutes = func() routes {
+	rt := newRoutes()
+	for _, op := range operations {
+		rt.add(op.Request, op.Action())
+	}
+	return rt
+}()
+
+// soapOperation adapts a UserService method to an operation. The request type
+// must carry its own XMLName so it is matched inside the SOAP Body.
+func soapOperation[Req, Resp any](name string, handle func(*service.UserService, context.Context, Req) (Resp, error)) operation {
+	return subscriptionOperation(name, func(s *service.UserService, ctx context.Context, _ string, request Req) (Resp, error) {
+		return handle(s, ctx, request)
+	})
+}
+
+// subscriptionOperation adapts a WS-Eventing subscription manager method,
+// which is also passed the wse:Identifier header naming the subscription.
+func subscriptionOperation[Req, Resp any](name string, handle func(*service.UserService, context.Context, string, Req) (Resp, error)) operation {
+	invoke := func(ctx context.Context, s *service.UserService, body []byte) model.SoapEnvelope {
+		request, header, fault := decodeRequest[Req](ctx, name, body)
+		if fault != nil {
+			return *fault
+		}
+
+		var identifier string
+		if header != nil {
+			identifier = header.Identifier
+		}
+		response, err := handle(s, ctx, identifier, request)
+		if err != nil {
+			log.Printf("Service error for %s: %v", name, err)
+			return serviceFault("Server", err)
+		}
+
+		return model.NewSoapEnvelope(response)
+	}
+
+	call := func(ctx context.Context, s *service.UserService, identifier string, request any) (any, error) {
+		return handle(s, ctx, identifier, request.(Req))
+	}
+
+	requestType, responseType := reflect.TypeFor[Req](), reflect.TypeFor[Resp]()
+	return operation{
+		Request:      elementName(requestType),
+		Response:     elementName(responseType),
+		requestType:  requestType,
+		responseType: responseType,
+		invoke:       invoke,
+		call:         call,
+	}
+}
+
+// decodeRequest decodes the request of the named operation from a SOAP
+// envelope, with the attachments it refers to, or returns the fault to
+// answer with.
+func decodeRequest[Req any](ctx context.Context, name string, body []byte) (Req, *model.SoapHeader, *model.SoapEnvelope) {
+	var requestEnv struct {
+		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+		Header  *model.SoapHeader
+		Body    struct {
+			XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
+			Request Req
+		}
+	}
+
+	if err := xml.Unmarshal(body, &requestEnv); err != nil {
+		log.Printf("Error unmarshalling %s request: %v", name, err)
+		fault := model.NewSoapFault("Client", "Invalid "+name+" Request Structure")
+		return requestEnv.Body.Request, nil, &fault
+	}
+
+	if err := resolveAttachments(ctx, &requestEnv.Body.Request); err != nil {
+		log.Printf("Error resolving %s attachments: %v", name, err)
+		fault := model.NewSoapFault("Client", err.Error())
+		return requestEnv.Body.Request, nil, &fault
+	}
+	return requestEnv.Body.Request, requestEnv.Header, nil
+}
+
+// serviceFault returns a fault reporting a service error, with the kind
+// of the error in its detail.
+func serviceFault(code string, err error) model.SoapEnvelope {
+	return model.NewServiceFault(code, err.Error(), service.KindOf(err).String())
+}
+
+// elementName returns the XML element name declared by the XMLName field
+// of a request or response type.
+func elementName(t reflect.Type) xml.Name {
+	name, ok := typeElementName(t)
+	if !ok {
+		panic("handler: " + t.String() + " has no XMLName field")
+	}
+	return name
+}
+
+// typeElementName returns the element name declared by the XMLName field
+// of a struct type, if it has one.
+func typeElementName(t reflect.Type) (xml.Name, bool) {
+	if t.Kind() != reflect.Struct {
+		return xml.Name{}, false
+	}
+	field, ok := t.FieldByName("XMLName")
+	if !ok {
+		return xml.Name{}, false
+	}
+	tag, _, _ := strings.Cut(field.Tag.Get("xml"), ",")
+	if space, local, ok := strings.Cut(tag, " "); ok {
+		return xml.Name{Space: space, Local: local}, true
+	}
+	return xml.Name{Local: tag}, tag != ""
+}
+
+// authenticateRequest resolves the session token of a request, taken from
+// the SOAP Session header or else from bearerToken, and returns caller with
+// the authenticated principal. Requests without a token stay anonymous.
+func authenticateRequest(s *service.UserService, caller model.Caller, body []byte, bearerToken string) (model.Caller, error) {
+	var env model.SoapEnvelope
+	if err := xml.Unmarshal(body, &env); err != nil {
+		return caller, err
+	}
+
+	token := bearerToken
+	if env.Header != nil && env.Header.Session != nil && env.Header.Session.Token != "" {
+		token = env.Header.Session.Token
+	}
+	if token == "" {
+		return caller, nil
+	}
+	return s.AuthenticateCaller(caller, token)
+}
+
+// errEmptyBody is returned by requestOperation when the SOAP Body has no
+// child element.
+var errEmptyBody = errors.New("SOAP Body is empty")
+
+// requestOperation returns the name of the first element inside the SOAP
+// Body of a request envelope, which identifies the operation to invoke.
+func requestOperation(body []byte) (xml.Name, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	depth := 0
+	inBody := false
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			if depth == 0 && !inBody {
+				return xml.Name{}, errors.New("missing SOAP Envelope")
+			}
+			return xml.Name{}, errEmptyBody
+		}
+		if err != nil {
+			return xml.Name{}, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case inBody:
+				return t.Name, nil
+			case depth == 1 && t.Name != soapEnvelopeName:
+				return xml.Name{}, errors.New("root element is not a SOAP Envelope")
+			case depth == 2 && t.Name == soapBodyName:
+				inBody = true
+			}
+		case xml.EndElement:
+			depth--
+			if inBody {
+				return xml.Name{}, errEmptyBody
+			}
+		}
+	}
+}
+
+var (
+	soapEnvelopeName = xml.Name{Space: "http://schemas.xmlsoap.org/soap/envelope/", Local: "Envelope"}
+	soapBodyName     = xml.Name{Space: "http://schemas.xmlsoap.org/soap/envelope/", Local: "Body"}
+)
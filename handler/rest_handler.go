@@ -0,0 +1,452 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+// MaxRESTBodySize limits the JSON bodies accepted by the REST API.
+const MaxRESTBodySize = 1 << 20
+
+// RESTHandler serves the user operations as a JSON API:
+//
+//	GET    /api/users       search users, with the SearchUsers fields as query parameters
+//	POST   /api/users       create a user
+//	GET    /api/users/{id}  get a user
+//	PUT    /api/users/{id}  replace the name and email of a user
+//	PATCH  /api/users/{id}  change the name and/or email of a user
+//	DELETE /api/users/{id}  delete a user
+//
+// Requests run through the same UserService methods as the SOAP operations,
+// with the same optional Bearer session, so both APIs validate and behave
+// alike. Errors are sent as RFC 9457 problem details. The OpenAPI document
+// of the API is served at OpenAPIPath.
+type RESTHandler struct {
+	UserService *service.UserService
+	// IndentResponses pretty-prints response bodies; they are compact by
+	// default.
+	IndentResponses bool
+
+	mux *http.ServeMux
+}
+
+// NewRESTHandler creates a REST handler for the API under /api/.
+func NewRESTHandler(s *service.UserService) *RESTHandler {
+	h := &RESTHandler{UserService: s, mux: http.NewServeMux()}
+	allowed := make(map[string][]string)
+	for _, route := range restRoutes {
+		h.mux.HandleFunc(route.method+" "+route.path, func(w http.ResponseWriter, r *http.Request) {
+			route.serve(h, w, r)
+		})
+		allowed[route.path] = append(allowed[route.path], route.method)
+	}
+	h.mux.HandleFunc("GET "+OpenAPIPath, h.serveOpenAPI)
+	allowed[OpenAPIPath] = append(allowed[OpenAPIPath], http.MethodGet)
+
+	// Patterns without a method only match the methods no route handles.
+	for path, methods := range allowed {
+		h.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			h.writeProblem(w, r, http.StatusMethodNotAllowed, fmt.Sprintf("%s is not supported on %s", r.Method, r.URL.Path))
+		})
+	}
+	h.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		h.writeProblem(w, r, http.StatusNotFound, "No resource at "+r.URL.Path)
+	})
+	return h
+}
+
+func (h *RESTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := decodeRequestBody(w, r); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errUnsupportedEncoding) {
+			status = http.StatusUnsupportedMediaType
+		}
+		h.writeProblem(w, r, status, err.Error())
+		return
+	}
+
+	caller := model.Caller{
+		Transport:  model.TransportHTTP,
+		ClientAddr: r.RemoteAddr,
+	}
+	if token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); token != "" {
+		var err error
+		if caller, err = h.UserService.AuthenticateCaller(caller, token); err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+	}
+	h.mux.ServeHTTP(w, r.WithContext(service.WithCaller(r.Context(), caller)))
+}
+
+// restRoute is an endpoint of the REST API. The same table registers the
+// handlers and generates the OpenAPI document.
+type restRoute struct {
+	method, path string
+	// operation is the SOAP operation sharing the endpoint's service
+	// method, and operationID its name in the OpenAPI document.
+	operation   string
+	operationID string
+	summary     string
+	// body and response are the types of the JSON request and success
+	// response bodies, nil if there is none, with examples of each.
+	body, response               reflect.Type
+	bodyExample, responseExample any
+	status                       int
+	// problems are the error statuses the endpoint responds with besides
+	// those every endpoint may, with an example detail for each.
+	problems map[int]string
+	serve    func(h *RESTHandler, w http.ResponseWriter, r *http.Request)
+}
+
+var restRoutes = []restRoute{
+	{
+		method: http.MethodGet, path: "/api/users",
+		operation: "SearchUsers", operationID: "listUsers",
+		summary:  "Search users, a page at a time",
+		response: reflect.TypeFor[userList](), responseExample: userList{Users: []model.User{exampleUser}, Total: 1},
+		status:   http.StatusOK,
+		problems: map[int]string{http.StatusBadRequest: `unknown status "gone"`},
+		serve:    (*RESTHandler).listUsers,
+	},
+	{
+		method: http.MethodPost, path: "/api/users",
+		operation: "CreateUser", operationID: "createUser",
+		summary: "Create a user",
+		body:    reflect.TypeFor[userFields](), bodyExample: userFields{Name: exampleUser.Name, Email: exampleUser.Email},
+		response: reflect.TypeFor[model.User](), responseExample: exampleUser,
+		status: http.StatusCreated,
+		problems: map[int]string{
+			http.StatusBadRequest: `invalid email address "alice"`,
+			http.StatusConflict:   "user creation failed: email alice@example.com: email already in use",
+		},
+		serve: (*RESTHandler).createUser,
+	},
+	{
+		method: http.MethodGet, path: "/api/users/{id}",
+		operation: "GetUserByID", operationID: "getUser",
+		summary:  "Get a user",
+		response: reflect.TypeFor[model.User](), responseExample: exampleUser,
+		status: http.StatusOK,
+		problems: map[int]string{
+			http.StatusBadRequest: "invalid user ID",
+			http.StatusNotFound:   "user retrieval failed: user with ID 1 not found",
+		},
+		serve: (*RESTHandler).getUser,
+	},
+	{
+		method: http.MethodPut, path: "/api/users/{id}",
+		operation: "UpdateUser", operationID: "replaceUser",
+		summary: "Replace the name and email of a user; both are required",
+		body:    reflect.TypeFor[userFields](), bodyExample: userFields{Name: exampleUser.Name, Email: exampleUser.Email},
+		response: reflect.TypeFor[model.User](), responseExample: exampleUser,
+		status: http.StatusOK,
+		problems: map[int]string{
+			http.StatusBadRequest: "name and email are required",
+			http.StatusNotFound:   "user not found: user with ID 1 not found",
+			http.StatusForbidden:  "permission denied: this operation requires an authenticated session",
+			http.StatusConflict:   "user update failed: email alice@example.com: email already in use",
+		},
+		serve: func(h *RESTHandler, w http.ResponseWriter, r *http.Request) { h.updateUser(w, r, true) },
+	},
+	{
+		method: http.MethodPatch, path: "/api/users/{id}",
+		operation: "UpdateUser", operationID: "updateUser",
+		summary: "Change the name and/or email of a user; empty fields are left unchanged",
+		body:    reflect.TypeFor[userFields](), bodyExample: userFields{Name: exampleUser.Name},
+		response: reflect.TypeFor[model.User](), responseExample: exampleUser,
+		status: http.StatusOK,
+		problems: map[int]string{
+			http.StatusBadRequest: `invalid email address "alice"`,
+			http.StatusNotFound:   "user not found: user with ID 1 not found",
+			http.StatusForbidden:  "permission denied: this operation requires an authenticated session",
+			http.StatusConflict:   "user update failed: email alice@example.com: email already in use",
+		},
+		serve: func(h *RESTHandler, w http.ResponseWriter, r *http.Request) { h.updateUser(w, r, false) },
+	},
+	{
+		method: http.MethodDelete, path: "/api/users/{id}",
+		operation: "DeleteUser", operationID: "deleteUser",
+		summary: "Delete a user; deleted users can be restored with the RestoreUser SOAP operation",
+		status:  http.StatusNoContent,
+		problems: map[int]string{
+			http.StatusBadRequest: "invalid user ID",
+			http.StatusNotFound:   "user with ID 1 not found",
+			http.StatusForbidden:  "permission denied: this operation requires an authenticated session",
+		},
+		serve: (*RESTHandler).deleteUser,
+	},
+}
+
+// exampleUser is the user shown in the examples of the OpenAPI document.
+var exampleUser = model.User{
+	ID:        1,
+	Name:      "Alice Johnson",
+	Email:     "alice@example.com",
+	Verified:  true,
+	CreatedAt: time.Date(2024, time.January, 15, 9, 30, 0, 0, time.UTC),
+}
+
+// userFields is the request body of POST, PUT and PATCH. Read-only fields
+// of a user, such as those of a representation fetched with GET, are
+// ignored.
+type userFields struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// userList is the response body of GET /api/users.
+type userList struct {
+	Users []model.User `json:"users"`
+	Total int          `json:"total"`
+	// NextOffset is the offset of the next page, or absent on the last page.
+	NextOffset int `json:"nextOffset,omitempty"`
+}
+
+func (h *RESTHandler) listUsers(w http.ResponseWriter, r *http.Request) {
+	request, err := searchRequest(r)
+	if err != nil {
+		h.writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	response, err := h.UserService.HandleSearchUsers(r.Context(), request)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	list := userList{Users: response.Users, Total: response.Total, NextOffset: response.NextOffset}
+	if list.Users == nil {
+		list.Users = []model.User{}
+	}
+	h.writeJSON(w, r, http.StatusOK, list)
+}
+
+func (h *RESTHandler) createUser(w http.ResponseWriter, r *http.Request) {
+	var fields userFields
+	if !h.readJSON(w, r, &fields) {
+		return
+	}
+	response, err := h.UserService.HandleCreateUser(r.Context(), model.CreateUserRequest{Name: fields.Name, Email: fields.Email})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/users/%d", response.User.ID))
+	h.writeJSON(w, r, http.StatusCreated, response.User)
+}
+
+func (h *RESTHandler) getUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+	response, err := h.UserService.HandleGetUserByID(r.Context(), model.GetUserByIDRequest{ID: id})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	h.writeJSON(w, r, http.StatusOK, response.User)
+}
+
+// updateUser serves PUT, which replaces the representation and so needs
+// both fields, and PATCH, which leaves empty fields unchanged as
+// UpdateUser does.
+func (h *RESTHandler) updateUser(w http.ResponseWriter, r *http.Request, replace bool) {
+	id, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+	var fields userFields
+	if !h.readJSON(w, r, &fields) {
+		return
+	}
+	if replace && (fields.Name == "" || fields.Email == "") {
+		h.writeProblem(w, r, http.StatusBadRequest, "name and email are required")
+		return
+	}
+	response, err := h.UserService.HandleUpdateUser(r.Context(), model.UpdateUserRequest{ID: id, Name: fields.Name, Email: fields.Email})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	h.writeJSON(w, r, http.StatusOK, response.User)
+}
+
+func (h *RESTHandler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.UserService.DeleteUser(r.Context(), id); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userID parses the {id} path segment, writing a problem and returning
+// false if it is not a number.
+func (h *RESTHandler) userID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.writeProblem(w, r, http.StatusBadRequest, "invalid user ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// queryParam is a query parameter of GET /api/users and the SearchUsers
+// request field it sets.
+type queryParam struct {
+	name, field string
+	description string
+	enum        []string
+}
+
+var searchParams = []queryParam{
+	{name: "q", field: "Query", description: "Text to search for"},
+	{name: "field", field: "Field", description: "Field to search; any by default",
+		enum: []string{model.SearchFieldName, model.SearchFieldEmail, model.SearchFieldAny}},
+	{name: "match", field: "Match", description: "prefix matches the start of any word (default), substring anywhere",
+		enum: []string{model.SearchMatchPrefix, model.SearchMatchSubstring}},
+	{name: "status", field: "Status", description: "Users to include; active by default",
+		enum: []string{model.UserStatusActive, model.UserStatusDeleted, model.SearchStatusAll}},
+	{name: "createdAfter", field: "CreatedAfter", description: "Only users created after this time"},
+	{name: "createdBefore", field: "CreatedBefore", description: "Only users created before this time"},
+	{name: "offset", field: "Offset", description: "Number of users to skip, from nextOffset of the previous page"},
+	{name: "limit", field: "Limit", description: fmt.Sprintf("Page size; %d by default, at most %d", service.DefaultSearchPageSize, service.MaxSearchPageSize)},
+}
+
+// searchRequest builds a SearchUsers request from the query parameters of
+// GET /api/users. Times are RFC 3339.
+func searchRequest(r *http.Request) (model.SearchUsersRequest, error) {
+	query := r.URL.Query()
+	var request model.SearchUsersRequest
+	fields := reflect.ValueOf(&request).Elem()
+	for _, param := range searchParams {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		switch field := fields.FieldByName(param.field); field.Interface().(type) {
+		case string:
+			field.SetString(value)
+		case int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return request, fmt.Errorf("invalid %s %q", param.name, value)
+			}
+			field.SetInt(int64(n))
+		case *time.Time:
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return request, fmt.Errorf("invalid %s %q: not an RFC 3339 time", param.name, value)
+			}
+			field.Set(reflect.ValueOf(&t))
+		}
+	}
+	return request, nil
+}
+
+// readJSON decodes a JSON request body into v, writing a problem and
+// returning false if it cannot.
+func (h *RESTHandler) readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		h.writeProblem(w, r, http.StatusUnsupportedMediaType, "Request body must be application/json")
+		return false
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRESTBodySize))
+	err = decoder.Decode(v)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("unexpected data after the JSON value")
+	}
+	if tooLarge := (*http.MaxBytesError)(nil); errors.As(err, &tooLarge) {
+		h.writeProblem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit))
+		return false
+	}
+	if err != nil {
+		h.writeProblem(w, r, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func (h *RESTHandler) writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	h.writeBody(w, r, status, "application/json", v)
+}
+
+// problem is an RFC 9457 problem details object.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+func (h *RESTHandler) writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	}
+	h.writeBody(w, r, status, "application/problem+json", problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+}
+
+// writeError writes a service error as a problem with the status matching
+// its kind.
+func (h *RESTHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := restStatus(service.KindOf(err))
+	if status == http.StatusInternalServerError {
+		log.Printf("Service error for %s %s: %v", r.Method, r.URL.Path, err)
+	}
+	h.writeProblem(w, r, status, err.Error())
+}
+
+func (h *RESTHandler) writeBody(w http.ResponseWriter, r *http.Request, status int, contentType string, v any) {
+	output, err := marshalJSON(v, h.IndentResponses)
+	if err != nil {
+		log.Printf("Error marshalling response: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	writeCompressed(w, r, status, output)
+}
+
+// restStatus returns the HTTP status for service errors of a kind.
+func restStatus(kind service.ErrorKind) int {
+	switch kind {
+	case service.KindInvalidArgument:
+		return http.StatusBadRequest
+	case service.KindUnauthenticated:
+		return http.StatusUnauthorized
+	case service.KindPermissionDenied:
+		return http.StatusForbidden
+	case service.KindNotFound:
+		return http.StatusNotFound
+	case service.KindConflict:
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+// restResponse is a response of the REST API with its decoded JSON body.
+type restResponse struct {
+	status int
+	header http.Header
+	body   map[string]any
+}
+
+func TestRESTUsers(t *testing.T) {
+	openTestDB(t)
+	s := &service.UserService{}
+	admin := &model.User{Name: "Admin", Email: "admin@example.com"}
+	if err := database.CreateUser(model.Caller{Principal: "test"}, admin); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := service.GrantAdmin(admin.ID); err != nil {
+		t.Fatalf("GrantAdmin: %v", err)
+	}
+	if err := s.SetPassword(context.Background(), admin.ID, "CorrectHorse42"); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	login, err := s.HandleAuthenticateUser(context.Background(), model.AuthenticateUserRequest{Email: admin.Email, Password: "CorrectHorse42"})
+	if err != nil || login.Token == "" {
+		t.Fatalf("AuthenticateUser = %+v, %v", login, err)
+	}
+	server := httptest.NewServer(NewRESTHandler(s))
+	t.Cleanup(server.Close)
+
+	call := func(method, path, token, body string) restResponse {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		r := restResponse{status: resp.StatusCode, header: resp.Header}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &r.body); err != nil {
+				t.Fatalf("%s %s: body %s: %v", method, path, data, err)
+			}
+		}
+		return r
+	}
+	// isProblem reports whether r is a problem details object for status.
+	isProblem := func(r restResponse, status int) bool {
+		return r.status == status && r.header.Get("Content-Type") == "application/problem+json" && r.body["status"] == float64(status)
+	}
+
+	created := call("POST", "/api/users", login.Token, `{"name":"Ada","email":"ada@example.com"}`)
+	if created.status != http.StatusCreated || created.body["name"] != "Ada" {
+		t.Fatalf("POST /api/users = %d %v", created.status, created.body)
+	}
+	path := created.header.Get("Location")
+	if path == "" {
+		t.Fatal("POST /api/users sent no Location")
+	}
+
+	if r := call("GET", path, "", ""); r.status != http.StatusOK || r.body["email"] != "ada@example.com" {
+		t.Errorf("GET %s = %d %v", path, r.status, r.body)
+	}
+	if r := call("PATCH", path, login.Token, `{"name":"Ada Lovelace"}`); r.status != http.StatusOK ||
+		r.body["name"] != "Ada Lovelace" || r.body["email"] != "ada@example.com" {
+		t.Errorf("PATCH %s = %d %v, want the name changed and the email kept", path, r.status, r.body)
+	}
+	if r := call("GET", "/api/users?q=love&limit=1", "", ""); r.status != http.StatusOK || r.body["total"] != float64(1) {
+		t.Errorf("GET /api/users?q=love = %d %v, want 1 user", r.status, r.body)
+	}
+
+	problems := []struct {
+		method, path, token, body string
+		status                    int
+	}{
+		{"POST", "/api/users", login.Token, `{"name":"Ada","email":"ada"}`, http.StatusBadRequest},
+		{"POST", "/api/users", login.Token, `{"name":`, http.StatusBadRequest},
+		{"POST", "/api/users", login.Token, `{"name":"Ada","email":"ADA@example.com"}`, http.StatusConflict},
+		{"PUT", path, login.Token, `{"name":"Ada"}`, http.StatusBadRequest},
+		{"PUT", path, "", `{"name":"Ada","email":"ada@example.org"}`, http.StatusForbidden},
+		{"GET", path, "nonsense", "", http.StatusUnauthorized},
+		{"GET", "/api/users/ada", "", "", http.StatusBadRequest},
+		{"GET", "/api/users/999", "", "", http.StatusNotFound},
+		{"GET", "/api/users?limit=many", "", "", http.StatusBadRequest},
+		{"PATCH", "/api/users", login.Token, `{}`, http.StatusMethodNotAllowed},
+		{"GET", "/api/groups", "", "", http.StatusNotFound},
+	}
+	for _, p := range problems {
+		if r := call(p.method, p.path, p.token, p.body); !isProblem(r, p.status) {
+			t.Errorf("%s %s %s = %d %s %v, want a %d problem", p.method, p.path, p.body, r.status, r.header.Get("Content-Type"), r.body, p.status)
+		}
+	}
+
+	if r := call("DELETE", path, login.Token, ""); r.status != http.StatusNoContent {
+		t.Errorf("DELETE %s = %d %v", path, r.status, r.body)
+	}
+	if r := call("GET", path, "", ""); !isProblem(r, http.StatusNotFound) {
+		t.Errorf("GET %s after DELETE = %d %v, want 404", path, r.status, r.body)
+	}
+}
@@ -1,11 +1,13 @@
 package handler
 
 import (
-	"encoding/xml"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/maasumiyaat/soap/model"
 	"github.com/maasumiyaat/soap/service"
@@ -13,161 +15,109 @@ import (
 
 type UserSOAPHandler struct {
 	UserService *service.UserService
+	// Replies sends responses to non-anonymous WS-Addressing endpoints;
+	// without it every response is returned on the connection.
+	Replies *ReplyDispatcher
+	// IndentResponses pretty-prints response envelopes; they are compact
+	// by default.
+	IndentResponses bool
+	// StrictActions faults requests whose SOAPAction or wsa:Action does not
+	// name the operation of their Body element, instead of logging the
+	// mismatch and routing by the Body.
+	StrictActions bool
 }
 
 func (h *UserSOAPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Query().Has("wsdl") {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		serveWSDL(w, r, scheme+"://"+r.Host+r.URL.Path)
+		return
+	}
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	body, err := io.ReadAll(r.Body)
+	ctx, body, err := readSOAPBody(w, r)
 	if err != nil {
-		h.writeSOAPFault(w, "Client", "Failed to read request body")
+		h.writeSOAPFault(w, r, "Client", err.Error())
 		return
 	}
 
-	var initialEnv model.SoapEnvelope
-	if err := xml.Unmarshal(body, &initialEnv); err != nil {
-		log.Printf("Error un-marshalling SOAP envelope: %v", err)
-		h.writeSOAPFault(w, "Client", "Invalid SOAP message")
-		return
-	}
+	bearerToken, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	req := newSOAPRequest(ctx, h.UserService, model.Caller{
+		Transport:  model.TransportHTTP,
+		ClientAddr: r.RemoteAddr,
+	}, body, bearerToken, requestAction(r), h.StrictActions)
 
-	if initialEnv.Body.Payload == nil {
-		h.writeSOAPFault(w, "Client", "SOAP Body is empty")
+	// Responses for another endpoint are produced in the background; the
+	// request itself is only acknowledged.
+	if req.asynchronous() && h.Replies != nil {
+		w.WriteHeader(http.StatusAccepted)
+		h.Replies.runAndReply(h.UserService, req)
 		return
 	}
 
-	xmlName := initialEnv.Body.Payload.(xml.StartElement).Name
-	opName := xmlName.Local
-
-	var responseEnv model.SoapEnvelope
-	switch opName {
-	case "GetUserByID":
-		responseEnv = h.handleGetUserByID(body)
-	case "CreateUser":
-		responseEnv = h.handleCreateUser(body)
-	case "UpdateUser":
-		responseEnv = h.handleUpdateUser(body)
-	case "DeleteUser":
-		responseEnv = h.handleDeleteUser(body)
-	default:
-		h.writeSOAPFault(w, "MustUnderstand", fmt.Sprintf("Unknown operation: %s", opName))
+	env := req.run(h.UserService)
+	if req.destination(env) != nil && h.Replies != nil {
+		// A fault for a separate FaultTo endpoint.
+		w.WriteHeader(http.StatusAccepted)
+		h.Replies.forward(req, env)
 		return
 	}
-
-	h.writeSOAPResponse(w, responseEnv)
-}
-
-func (h *UserSOAPHandler) writeSOAPFault(w http.ResponseWriter, code, message string) {
-	faultEnv := model.NewSoapFault(code, message)
-	h.writeSOAPResponse(w, faultEnv)
+	writeSOAPEnvelope(w, r, req.reply(env, nil), h.IndentResponses)
 }
 
-func (h *UserSOAPHandler) writeSOAPResponse(w http.ResponseWriter, env model.SoapEnvelope) {
-	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
-	w.Header().Set("SOAPAction", "")
-
-	output, err := xml.MarshalIndent(env, "", "  ")
-	if err != nil {
-		log.Printf("Error marshalling response: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte(xml.Header))
-	_, _ = w.Write(output)
-}
-
-func (h *UserSOAPHandler) handleGetUserByID(body []byte) model.SoapEnvelope {
-	var requestEnv struct {
-		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
-		Body    struct {
-			XMLName xml.Name                 `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
-			Request model.GetUserByIDRequest `xml:"urn:user-service GetUserByID"`
-		}
-	}
-
-	if err := xml.Unmarshal(body, &requestEnv); err != nil {
-		log.Printf("Error unmarshalling GetUserByID request: %v", err)
-		return model.NewSoapFault("Client", "Invalid GetUserByID Request Structure")
-	}
-
-	response, err := h.UserService.HandleGetUserByID(requestEnv.Body.Request)
-	if err != nil {
-		log.Printf("Service error for GetUserByID: %v", err)
-		return model.NewSoapFault("Server", err.Error())
-	}
-
-	return model.NewSoapEnvelope(response)
+func (h *UserSOAPHandler) writeSOAPFault(w http.ResponseWriter, r *http.Request, code, message string) {
+	writeSOAPEnvelope(w, r, model.NewSoapFault(code, message), h.IndentResponses)
 }
 
-func (h *UserSOAPHandler) handleCreateUser(body []byte) model.SoapEnvelope {
-	var requestEnv struct {
-		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
-		Body    struct {
-			XMLName xml.Name                `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
-			Request model.CreateUserRequest `xml:"urn:user-service CreateUser"`
-		}
-	}
-
-	if err := xml.Unmarshal(body, &requestEnv); err != nil {
-		log.Printf("Error unmarshalling CreateUser request: %v", err)
-		return model.NewSoapFault("Client", "Invalid CreateUser Request Structure")
-	}
-
-	response, err := h.UserService.HandleCreateUser(requestEnv.Body.Request)
-	if err != nil {
-		log.Printf("Service error for CreateUser: %v", err)
-		return model.NewSoapFault("Server", err.Error())
+// readSOAPBody reads the envelope of an HTTP SOAP request, decompressed,
+// or the root part of an MTOM message, whose attachments the returned
+// context carries. Errors are to be answered with a Client fault.
+func readSOAPBody(w http.ResponseWriter, r *http.Request) (context.Context, []byte, error) {
+	if err := decodeRequestBody(w, r); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		return nil, nil, err
 	}
 
-	return model.NewSoapEnvelope(response)
-}
-
-func (h *UserSOAPHandler) handleUpdateUser(body []byte) model.SoapEnvelope {
-	var requestEnv struct {
-		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
-		Body    struct {
-			XMLName xml.Name                `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
-			Request model.UpdateUserRequest `xml:"urn:user-service UpdateUser"`
+	if isMTOM(r.Header.Get("Content-Type")) {
+		body, parts, err := readMTOM(w, r)
+		if err != nil {
+			log.Printf("Error reading MTOM request: %v", err)
+			return nil, nil, errors.New("Invalid MTOM message: " + err.Error())
 		}
+		return withAttachments(r.Context(), parts), body, nil
 	}
-
-	if err := xml.Unmarshal(body, &requestEnv); err != nil {
-		log.Printf("Error unmarshalling UpdateUser request: %v", err)
-		return model.NewSoapFault("Client", "Invalid UpdateUser Request Structure")
+	body, err := io.ReadAll(r.Body)
+	if tooLarge := (*http.MaxBytesError)(nil); errors.As(err, &tooLarge) {
+		return nil, nil, fmt.Errorf("Request body exceeds %d bytes", tooLarge.Limit)
 	}
-
-	response, err := h.UserService.HandleUpdateUser(requestEnv.Body.Request)
 	if err != nil {
-		log.Printf("Service error for UpdateUser: %v", err)
-		return model.NewSoapFault("Server", err.Error())
+		return nil, nil, errors.New("Failed to read request body")
 	}
-
-	return model.NewSoapEnvelope(response)
+	return r.Context(), body, nil
 }
 
-func (h *UserSOAPHandler) handleDeleteUser(body []byte) model.SoapEnvelope {
-	var requestEnv struct {
-		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
-		Body    struct {
-			XMLName xml.Name                `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
-			Request model.DeleteUserRequest `xml:"urn:user-service DeleteUser"`
+// writeSOAPEnvelope writes a response envelope, as an MTOM package when it
+// has attachments and the client accepts one.
+func writeSOAPEnvelope(w http.ResponseWriter, r *http.Request, env model.SoapEnvelope, indent bool) {
+	if acceptsMTOM(r) {
+		if parts := externalizeAttachments(&env); len(parts) > 0 {
+			writeMTOM(w, r, env, parts)
+			return
 		}
 	}
 
-	if err := xml.Unmarshal(body, &requestEnv); err != nil {
-		log.Printf("Error unmarshalling DeleteUser request: %v", err)
-		return model.NewSoapFault("Client", "Invalid DeleteUser Request Structure")
-	}
-
-	response, err := h.UserService.HandleDeleteUser(requestEnv.Body.Request)
+	output, err := marshalEnvelope(env, indent)
 	if err != nil {
-		log.Printf("Service error for DeleteUser: %v", err)
-		return model.NewSoapFault("Server", err.Error())
+		log.Printf("Error marshalling response: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
 
-	return model.NewSoapEnvelope(response)
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	writeCompressed(w, r, http.StatusOK, output)
 }
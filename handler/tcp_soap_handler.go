@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+// MaxTCPMessageSize bounds the length of one framed TCP message.
+const MaxTCPMessageSize = 1 << 20
+
+// TCPIdleTimeout closes TCP connections that send nothing for this long.
+const TCPIdleTimeout = 5 * time.Minute
+
+// TCPSOAPHandler serves SOAP and JSON-RPC over TCP. Each message, request
+// or response, is framed by its length as a 4-byte big-endian integer.
+// Requests on a connection are answered in order; like UDP datagrams,
+// frames may be gzip or zlib compressed and are then answered in kind.
+type TCPSOAPHandler struct {
+	UserService *service.UserService
+	// Replies sends responses to non-anonymous WS-Addressing endpoints;
+	// without it every response is returned on the connection.
+	Replies *ReplyDispatcher
+	// IndentResponses pretty-prints responses; they are compact by default.
+	IndentResponses bool
+	// StrictActions faults requests whose wsa:Action does not name the
+	// operation of their Body element.
+	StrictActions bool
+
+	listener net.Listener
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewTCPSOAPHandler creates a new TCP SOAP handler
+func NewTCPSOAPHandler(userService *service.UserService) *TCPSOAPHandler {
+	return &TCPSOAPHandler{
+		UserService: userService,
+		conns:       make(map[net.Conn]struct{}),
+	}
+}
+
+// StartTCPServer starts the TCP SOAP server
+func (h *TCPSOAPHandler) StartTCPServer(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to start TCP server: %v", err)
+	}
+
+	h.listener = listener
+	log.Printf("TCP SOAP Server listening on %s", address)
+
+	go h.acceptTCPConnections()
+	return nil
+}
+
+// Stop closes the listener and every open connection, and waits for the
+// requests in progress to finish.
+func (h *TCPSOAPHandler) Stop() {
+	if h.listener == nil {
+		return
+	}
+	h.listener.Close()
+	h.mu.Lock()
+	for conn := range h.conns {
+		conn.Close()
+	}
+	h.mu.Unlock()
+	h.wg.Wait()
+}
+
+func (h *TCPSOAPHandler) acceptTCPConnections() {
+	for {
+		conn, err := h.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("Error accepting TCP connection: %v", err)
+			continue
+		}
+
+		h.mu.Lock()
+		h.conns[conn] = struct{}{}
+		h.mu.Unlock()
+		h.wg.Add(1)
+		go h.serveTCPConnection(conn)
+	}
+}
+
+// serveTCPConnection answers the requests of a connection until the
+// client closes it, goes idle or sends a malformed frame.
+func (h *TCPSOAPHandler) serveTCPConnection(conn net.Conn) {
+	defer h.wg.Done()
+	defer func() {
+		h.mu.Lock()
+		delete(h.conns, conn)
+		h.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(TCPIdleTimeout))
+		data, err := readTCPFrame(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Error reading TCP message from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		response := h.processTCPRequest(data, conn.RemoteAddr())
+		if response == nil {
+			continue
+		}
+		if err := writeTCPFrame(conn, response); err != nil {
+			log.Printf("Error sending TCP response to %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// processTCPRequest runs one SOAP or JSON-RPC request and returns the
+// frame to answer with, or nil if the response goes elsewhere or there is
+// none.
+func (h *TCPSOAPHandler) processTCPRequest(data []byte, clientAddr net.Addr) []byte {
+	log.Printf("Received TCP request from %s, size: %d bytes", clientAddr, len(data))
+	caller := model.Caller{
+		Transport:  model.TransportTCP,
+		ClientAddr: clientAddr.String(),
+	}
+
+	encoding := datagramEncoding(data)
+	if encoding != "" {
+		message, err := decompressDatagram(encoding, data)
+		if err != nil {
+			log.Printf("Error decompressing TCP request: %v", err)
+			return h.encodeTCPResponse(encoding, model.NewSoapFault("Client", "Invalid "+encoding+" payload"))
+		}
+		data = message
+	}
+
+	if isJSONMessage(data) {
+		response := runJSONRPC(context.Background(), h.UserService, caller, "", data)
+		if response == nil {
+			return nil
+		}
+		output, err := marshalJSON(response, h.IndentResponses)
+		if err != nil {
+			log.Printf("Error marshalling TCP JSON-RPC response: %v", err)
+			return nil
+		}
+		return compressTCPResponse(encoding, output)
+	}
+
+	req := newSOAPRequest(context.Background(), h.UserService, caller, data, "", "", h.StrictActions)
+	if req.asynchronous() && h.Replies != nil {
+		h.Replies.runAndReply(h.UserService, req)
+		return nil
+	}
+
+	env := req.run(h.UserService)
+	if req.destination(env) != nil && h.Replies != nil {
+		h.Replies.forward(req, env)
+		return nil
+	}
+	return h.encodeTCPResponse(encoding, req.reply(env, nil))
+}
+
+func (h *TCPSOAPHandler) encodeTCPResponse(encoding string, env model.SoapEnvelope) []byte {
+	output, err := marshalEnvelope(env, h.IndentResponses)
+	if err != nil {
+		log.Printf("Error marshalling TCP SOAP response: %v", err)
+		return nil
+	}
+	return compressTCPResponse(encoding, output)
+}
+
+// compressTCPResponse compresses a response with encoding unless it is
+// empty.
+func compressTCPResponse(encoding string, response []byte) []byte {
+	if encoding == "" {
+		return response
+	}
+	compressed, err := compress(encoding, response)
+	if err != nil {
+		log.Printf("Error compressing TCP response: %v", err)
+		return nil
+	}
+	return compressed
+}
+
+// readTCPFrame reads one length-prefixed message.
+func readTCPFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxTCPMessageSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds %d", size, MaxTCPMessageSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// writeTCPFrame writes one length-prefixed message.
+func writeTCPFrame(w io.Writer, data []byte) error {
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(data)), uint32(len(data)))
+	_, err := w.Write(append(frame, data...))
+	return err
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+func TestTCPSOAPServer(t *testing.T) {
+	openTestDB(t)
+	user := &model.User{Name: "Ada", Email: "ada@example.com"}
+	if err := database.CreateUser(model.Caller{Principal: "test"}, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	// Without StartTCPServer, as when -tcp is not given, there is nothing
+	// to stop.
+	h := NewTCPSOAPHandler(&service.UserService{})
+	h.Stop()
+
+	if err := h.StartTCPServer("127.0.0.1:0"); err != nil {
+		t.Fatalf("StartTCPServer: %v", err)
+	}
+	t.Cleanup(h.Stop)
+	addr := h.listener.Addr().String()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	// Two requests on one connection are answered in order.
+	for range 2 {
+		request := testEnvelope("", `<GetUserByID xmlns="urn:user-service"><id>1</id></GetUserByID>`)
+		if err := writeTCPFrame(conn, request); err != nil {
+			t.Fatalf("writeTCPFrame: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		response, err := readTCPFrame(conn)
+		if err != nil {
+			t.Fatalf("readTCPFrame: %v", err)
+		}
+		if !bytes.Contains(response, []byte("GetUserByIDResponse")) || !bytes.Contains(response, []byte("ada@example.com")) {
+			t.Errorf("response = %s, want user 1", response)
+		}
+	}
+
+	// Stop closes the open connection as well as the listener.
+	done := make(chan struct{})
+	go func() {
+		h.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return with a connection open")
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := readTCPFrame(conn); err == nil {
+		t.Error("the connection is still open after Stop")
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Error("the server still accepts connections after Stop")
+	}
+}
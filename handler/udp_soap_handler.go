@@ -1,7 +1,8 @@
 package handler
 
 import (
-	"encoding/xml"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -12,7 +13,16 @@ import (
 
 type UDPSOAPHandler struct {
 	UserService *service.UserService
-	conn        *net.UDPConn
+	// Replies sends responses to non-anonymous WS-Addressing endpoints;
+	// without it every response goes back to the packet source.
+	Replies *ReplyDispatcher
+	// IndentResponses pretty-prints response envelopes; they are compact
+	// by default, so that more fits in a datagram.
+	IndentResponses bool
+	// StrictActions faults requests whose wsa:Action does not name the
+	// operation of their Body element.
+	StrictActions bool
+	conn          *net.UDPConn
 }
 
 // NewUDPSOAPHandler creates a new UDP SOAP handler
@@ -56,174 +66,119 @@ func (h *UDPSOAPHandler) handleUDPRequests() {
 
 	for {
 		n, clientAddr, err := h.conn.ReadFromUDP(buffer)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
 		if err != nil {
 			log.Printf("Error reading UDP message: %v", err)
 			continue
 		}
 
+		// The buffer is reused for the next datagram, so hand the goroutine
+		// its own copy of the message.
+		data := make([]byte, n)
+		copy(data, buffer[:n])
+
 		// Process the SOAP request in a goroutine to handle concurrent requests
-		go h.processUDPSOAPRequest(buffer[:n], clientAddr)
+		go h.processUDPSOAPRequest(data, clientAddr)
 	}
 }
 
 // processUDPSOAPRequest processes a single UDP SOAP request
 func (h *UDPSOAPHandler) processUDPSOAPRequest(data []byte, clientAddr *net.UDPAddr) {
-	log.Printf("Received UDP SOAP request from %s, size: %d bytes", clientAddr, len(data))
+	log.Printf("Received UDP request from %s, size: %d bytes", clientAddr, len(data))
 
-	// Parse SOAP envelope
-	var initialEnv model.SoapEnvelope
-	if err := xml.Unmarshal(data, &initialEnv); err != nil {
-		log.Printf("Error unmarshalling UDP SOAP envelope: %v", err)
-		h.sendUDPSOAPFault(clientAddr, "Client", "Invalid SOAP message")
-		return
-	}
-
-	if initialEnv.Body.Payload == nil {
-		h.sendUDPSOAPFault(clientAddr, "Client", "SOAP Body is empty")
-		return
+	// Compressed requests are answered in kind.
+	encoding := datagramEncoding(data)
+	if encoding != "" {
+		message, err := decompressDatagram(encoding, data)
+		if err != nil {
+			log.Printf("Error decompressing UDP SOAP request: %v", err)
+			h.sendUDPSOAPFault(clientAddr, encoding, "Client", "Invalid "+encoding+" payload")
+			return
+		}
+		data = message
 	}
 
-	// Extract operation name
-	xmlName := initialEnv.Body.Payload.(xml.StartElement).Name
-	opName := xmlName.Local
-
-	var responseEnv model.SoapEnvelope
-	switch opName {
-	case "GetUserByID":
-		responseEnv = h.handleUDPGetUserByID(data)
-	case "CreateUser":
-		responseEnv = h.handleUDPCreateUser(data)
-	case "UpdateUser":
-		responseEnv = h.handleUDPUpdateUser(data)
-	case "DeleteUser":
-		responseEnv = h.handleUDPDeleteUser(data)
-	default:
-		h.sendUDPSOAPFault(clientAddr, "MustUnderstand", fmt.Sprintf("Unknown operation: %s", opName))
+	// JSON-RPC messages share the port; they start with { or [, which
+	// cannot start an XML document.
+	if isJSONMessage(data) {
+		h.processUDPJSONRPC(data, clientAddr, encoding)
 		return
 	}
 
-	h.sendUDPSOAPResponse(clientAddr, responseEnv)
-}
-
-// sendUDPSOAPFault sends a SOAP fault response via UDP
-func (h *UDPSOAPHandler) sendUDPSOAPFault(clientAddr *net.UDPAddr, code, message string) {
-	faultEnv := model.NewSoapFault(code, message)
-	h.sendUDPSOAPResponse(clientAddr, faultEnv)
-}
+	req := newSOAPRequest(context.Background(), h.UserService, model.Caller{
+		Transport:  model.TransportUDP,
+		ClientAddr: clientAddr.String(),
+	}, data, "", "", h.StrictActions)
 
-// sendUDPSOAPResponse sends a SOAP response via UDP
-func (h *UDPSOAPHandler) sendUDPSOAPResponse(clientAddr *net.UDPAddr, env model.SoapEnvelope) {
-	output, err := xml.MarshalIndent(env, "", "  ")
-	if err != nil {
-		log.Printf("Error marshalling UDP SOAP response: %v", err)
+	// An anonymous ReplyTo means the packet source; responses for any other
+	// endpoint are sent there instead.
+	if req.asynchronous() && h.Replies != nil {
+		h.Replies.runAndReply(h.UserService, req)
 		return
 	}
 
-	// Prepare XML response with header
-	response := []byte(xml.Header)
-	response = append(response, output...)
-
-	// Send response back to client
-	_, err = h.conn.WriteToUDP(response, clientAddr)
-	if err != nil {
-		log.Printf("Error sending UDP SOAP response: %v", err)
-	} else {
-		log.Printf("Sent UDP SOAP response to %s, size: %d bytes", clientAddr, len(response))
+	env := req.run(h.UserService)
+	if req.destination(env) != nil && h.Replies != nil {
+		h.Replies.forward(req, env)
+		return
 	}
+	h.sendUDPSOAPResponse(clientAddr, encoding, req.reply(env, nil))
 }
 
-// handleUDPGetUserByID handles GetUserByID operation over UDP
-func (h *UDPSOAPHandler) handleUDPGetUserByID(body []byte) model.SoapEnvelope {
-	var requestEnv struct {
-		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
-		Body    struct {
-			XMLName xml.Name                 `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
-			Request model.GetUserByIDRequest `xml:"urn:user-service GetUserByID"`
-		}
-	}
-
-	if err := xml.Unmarshal(body, &requestEnv); err != nil {
-		log.Printf("Error unmarshalling UDP GetUserByID request: %v", err)
-		return model.NewSoapFault("Client", "Invalid GetUserByID Request Structure")
+// processUDPJSONRPC runs a JSON-RPC request or batch received via UDP and
+// sends the response, if any, back compressed like the request.
+func (h *UDPSOAPHandler) processUDPJSONRPC(data []byte, clientAddr *net.UDPAddr, encoding string) {
+	response := runJSONRPC(context.Background(), h.UserService, model.Caller{
+		Transport:  model.TransportUDP,
+		ClientAddr: clientAddr.String(),
+	}, "", data)
+	if response == nil {
+		return
 	}
 
-	response, err := h.UserService.HandleGetUserByID(requestEnv.Body.Request)
+	output, err := marshalJSON(response, h.IndentResponses)
 	if err != nil {
-		log.Printf("Service error for UDP GetUserByID: %v", err)
-		return model.NewSoapFault("Server", err.Error())
+		log.Printf("Error marshalling UDP JSON-RPC response: %v", err)
+		return
 	}
-
-	return model.NewSoapEnvelope(response)
+	h.sendUDP(clientAddr, encoding, output)
 }
 
-// handleUDPCreateUser handles CreateUser operation over UDP
-func (h *UDPSOAPHandler) handleUDPCreateUser(body []byte) model.SoapEnvelope {
-	var requestEnv struct {
-		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
-		Body    struct {
-			XMLName xml.Name                `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
-			Request model.CreateUserRequest `xml:"urn:user-service CreateUser"`
-		}
-	}
-
-	if err := xml.Unmarshal(body, &requestEnv); err != nil {
-		log.Printf("Error unmarshalling UDP CreateUser request: %v", err)
-		return model.NewSoapFault("Client", "Invalid CreateUser Request Structure")
-	}
-
-	response, err := h.UserService.HandleCreateUser(requestEnv.Body.Request)
-	if err != nil {
-		log.Printf("Service error for UDP CreateUser: %v", err)
-		return model.NewSoapFault("Server", err.Error())
-	}
-
-	return model.NewSoapEnvelope(response)
+// sendUDPSOAPFault sends a SOAP fault response via UDP
+func (h *UDPSOAPHandler) sendUDPSOAPFault(clientAddr *net.UDPAddr, encoding, code, message string) {
+	faultEnv := model.NewSoapFault(code, message)
+	h.sendUDPSOAPResponse(clientAddr, encoding, faultEnv)
 }
 
-// handleUDPUpdateUser handles UpdateUser operation over UDP
-func (h *UDPSOAPHandler) handleUDPUpdateUser(body []byte) model.SoapEnvelope {
-	var requestEnv struct {
-		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
-		Body    struct {
-			XMLName xml.Name                `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
-			Request model.UpdateUserRequest `xml:"urn:user-service UpdateUser"`
-		}
-	}
-
-	if err := xml.Unmarshal(body, &requestEnv); err != nil {
-		log.Printf("Error unmarshalling UDP UpdateUser request: %v", err)
-		return model.NewSoapFault("Client", "Invalid UpdateUser Request Structure")
-	}
-
-	response, err := h.UserService.HandleUpdateUser(requestEnv.Body.Request)
+// sendUDPSOAPResponse sends a SOAP response via UDP, compressed with
+// encoding unless it is empty
+func (h *UDPSOAPHandler) sendUDPSOAPResponse(clientAddr *net.UDPAddr, encoding string, env model.SoapEnvelope) {
+	response, err := marshalEnvelope(env, h.IndentResponses)
 	if err != nil {
-		log.Printf("Service error for UDP UpdateUser: %v", err)
-		return model.NewSoapFault("Server", err.Error())
+		log.Printf("Error marshalling UDP SOAP response: %v", err)
+		return
 	}
-
-	return model.NewSoapEnvelope(response)
+	h.sendUDP(clientAddr, encoding, response)
 }
 
-// handleUDPDeleteUser handles DeleteUser operation over UDP
-func (h *UDPSOAPHandler) handleUDPDeleteUser(body []byte) model.SoapEnvelope {
-	var requestEnv struct {
-		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
-		Body    struct {
-			XMLName xml.Name                `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
-			Request model.DeleteUserRequest `xml:"urn:user-service DeleteUser"`
+// sendUDP sends a response datagram, compressed with encoding unless it is
+// empty
+func (h *UDPSOAPHandler) sendUDP(clientAddr *net.UDPAddr, encoding string, response []byte) {
+	if encoding != "" {
+		var err error
+		if response, err = compress(encoding, response); err != nil {
+			log.Printf("Error compressing UDP response: %v", err)
+			return
 		}
 	}
 
-	if err := xml.Unmarshal(body, &requestEnv); err != nil {
-		log.Printf("Error unmarshalling UDP DeleteUser request: %v", err)
-		return model.NewSoapFault("Client", "Invalid DeleteUser Request Structure")
-	}
-
-	response, err := h.UserService.HandleDeleteUser(requestEnv.Body.Request)
+	// Send response back to client
+	_, err := h.conn.WriteToUDP(response, clientAddr)
 	if err != nil {
-		log.Printf("Service error for UDP DeleteUser: %v", err)
-		return model.NewSoapFault("Server", err.Error())
+		log.Printf("Error sending UDP response: %v", err)
+	} else {
+		log.Printf("Sent UDP response to %s, size: %d bytes", clientAddr, len(response))
 	}
-
-	return model.NewSoapEnvelope(response)
 }
@@ -0,0 +1,514 @@
+package handler
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// Namespaces of a WSDL 1.1 document and its SOAP 1.1 binding.
+const (
+	wsdlSOAPNamespace = "http://schemas.xmlsoap.org/wsdl/soap/"
+	xsdNamespace      = "http://www.w3.org/2001/XMLSchema"
+	wsamNamespace     = "http://www.w3.org/2007/05/addressing/metadata"
+	soapHTTPTransport = "http://schemas.xmlsoap.org/soap/http"
+)
+
+// serviceNamespace is the target namespace of the WSDL; operations in
+// other namespaces (WS-Eventing) still get their messages and port type
+// entries defined in it.
+const serviceNamespace = "urn:user-service"
+
+// wsdlPrefixes are the prefixes QNames in the WSDL use for the namespaces
+// of the service's elements.
+var wsdlPrefixes = map[string]string{
+	serviceNamespace:          "tns",
+	model.EventingNamespace:   "wse",
+	model.AddressingNamespace: "wsa",
+	xsdNamespace:              "xs",
+}
+
+// serveWSDL writes the WSDL of the service, generated from the operations
+// registry, with location as the address of its SOAP port.
+func serveWSDL(w http.ResponseWriter, r *http.Request, location string) {
+	output, err := WSDL(location)
+	if err != nil {
+		log.Printf("Error marshalling WSDL: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	writeCompressed(w, r, http.StatusOK, output)
+}
+
+// WSDL returns the WSDL document served at ?wsdl, with location as the
+// address of its SOAP port.
+func WSDL(location string) ([]byte, error) {
+	output, err := xml.MarshalIndent(buildWSDL(location), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), output...), nil
+}
+
+// buildWSDL describes every operation as a document/literal operation of
+// one SOAP 1.1 port. The soapAction and wsam:Action of each input are the
+// operation's Action, the URI requests may be routed by.
+func buildWSDL(location string) wsdlDefinitions {
+	schemas := newSchemaBuilder()
+	defs := wsdlDefinitions{
+		Name:            "UserService",
+		TargetNamespace: serviceNamespace,
+		Prefixes: []xml.Attr{
+			{Name: xml.Name{Local: "xmlns:soap"}, Value: wsdlSOAPNamespace},
+			{Name: xml.Name{Local: "xmlns:wsam"}, Value: wsamNamespace},
+		},
+		PortType: wsdlPortType{Name: "UserServicePortType"},
+		Binding: wsdlBinding{
+			Name: "UserServiceBinding",
+			Type: "tns:UserServicePortType",
+			SOAPBinding: wsdlSOAPBinding{
+				Style:     "document",
+				Transport: soapHTTPTransport,
+			},
+		},
+		Service: wsdlService{
+			Name: "UserService",
+			Port: wsdlPort{
+				Name:    "UserServicePort",
+				Binding: "tns:UserServiceBinding",
+				Address: wsdlSOAPAddress{Location: location},
+			},
+		},
+	}
+
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		op := operations[name]
+		schemas.element(op.Request, op.requestType)
+		schemas.element(op.Response, op.responseType)
+
+		defs.Messages = append(defs.Messages,
+			wsdlMessage{Name: name + "Request", Part: wsdlPart{Name: "parameters", Element: qualifiedName(op.Request.Space, op.Request.Local)}},
+			wsdlMessage{Name: name + "Response", Part: wsdlPart{Name: "parameters", Element: qualifiedName(op.Response.Space, op.Response.Local)}},
+		)
+		defs.PortType.Operations = append(defs.PortType.Operations, wsdlPortOperation{
+			Name:   name,
+			Input:  wsdlParam{Message: "tns:" + name + "Request", Action: op.Action()},
+			Output: wsdlParam{Message: "tns:" + name + "Response", Action: op.ResponseAction()},
+		})
+		defs.Binding.Operations = append(defs.Binding.Operations, wsdlBindingOperation{
+			Name:          name,
+			SOAPOperation: wsdlSOAPOperation{SOAPAction: op.Action()},
+			Input:         wsdlBindingParam{Body: wsdlSOAPBody{Use: "literal"}},
+			Output:        wsdlBindingParam{Body: wsdlSOAPBody{Use: "literal"}},
+		})
+	}
+
+	for _, ns := range schemas.order {
+		if prefix, ok := wsdlPrefixes[ns]; ok && ns != serviceNamespace {
+			defs.Prefixes = append(defs.Prefixes, xml.Attr{Name: xml.Name{Local: "xmlns:" + prefix}, Value: ns})
+		}
+		defs.Types.Schemas = append(defs.Types.Schemas, schemas.schemas[ns])
+	}
+	defs.Prefixes = append([]xml.Attr{
+		{Name: xml.Name{Local: "xmlns:tns"}, Value: serviceNamespace},
+		{Name: xml.Name{Local: "xmlns:xs"}, Value: xsdNamespace},
+	}, defs.Prefixes...)
+	return defs
+}
+
+// qualifiedName returns the QName of local in the namespace ns, using the
+// prefix declared for ns in the WSDL.
+func qualifiedName(ns, local string) string {
+	return wsdlPrefixes[ns] + ":" + local
+}
+
+// schemaBuilder derives XML Schema declarations from the Go types of
+// operation requests and responses, following the encoding/xml rules
+// those types are marshalled with. It keeps one schema per namespace.
+type schemaBuilder struct {
+	schemas  map[string]*xsdSchema
+	order    []string
+	types    map[string]map[string]bool
+	elements map[string]map[string]bool
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		schemas:  make(map[string]*xsdSchema),
+		types:    make(map[string]map[string]bool),
+		elements: make(map[string]map[string]bool),
+	}
+}
+
+func (b *schemaBuilder) schema(ns string) *xsdSchema {
+	if s, ok := b.schemas[ns]; ok {
+		return s
+	}
+	if _, ok := wsdlPrefixes[ns]; !ok {
+		panic("handler: no WSDL prefix for namespace " + ns)
+	}
+	s := &xsdSchema{TargetNamespace: ns, ElementFormDefault: "qualified"}
+	b.schemas[ns] = s
+	b.types[ns] = make(map[string]bool)
+	b.elements[ns] = make(map[string]bool)
+	b.order = append(b.order, ns)
+	return s
+}
+
+// element declares the global element name with the type of t.
+func (b *schemaBuilder) element(name xml.Name, t reflect.Type) {
+	s := b.schema(name.Space)
+	if b.elements[name.Space][name.Local] {
+		return
+	}
+	b.elements[name.Space][name.Local] = true
+	s.Elements = append(s.Elements, xsdGlobalElement{Name: name.Local, Type: b.typeName(name.Space, t)})
+}
+
+// importNamespace lets the schema of ns refer to declarations in other.
+func (b *schemaBuilder) importNamespace(ns, other string) {
+	s := b.schema(ns)
+	if ns == other || slices.ContainsFunc(s.Imports, func(i xsdImport) bool { return i.Namespace == other }) {
+		return
+	}
+	s.Imports = append(s.Imports, xsdImport{Namespace: other})
+}
+
+// typeName returns the QName of the schema type of t, defining it in the
+// schema of ns if it is a struct.
+func (b *schemaBuilder) typeName(ns string, t reflect.Type) string {
+	if t == reflect.TypeFor[time.Time]() {
+		return "xs:dateTime"
+	}
+	if t == attachmentType {
+		b.attachmentType(ns)
+		return qualifiedName(ns, t.Name())
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "xs:string"
+	case reflect.Bool:
+		return "xs:boolean"
+	case reflect.Int, reflect.Int32:
+		return "xs:int"
+	case reflect.Int64:
+		return "xs:long"
+	case reflect.Uint, reflect.Uint32:
+		return "xs:unsignedInt"
+	case reflect.Uint64:
+		return "xs:unsignedLong"
+	case reflect.Float32:
+		return "xs:float"
+	case reflect.Float64:
+		return "xs:double"
+	case reflect.Struct:
+		b.complexType(ns, t)
+		return qualifiedName(ns, t.Name())
+	}
+	panic("handler: no schema type for " + t.String())
+}
+
+// attachmentType defines the type of model.Attachment elements in the
+// schema of ns: base64 content, which MTOM messages replace with an
+// xop:Include, with an optional xmime:contentType.
+func (b *schemaBuilder) attachmentType(ns string) {
+	s := b.schema(ns)
+	if b.types[ns][attachmentType.Name()] {
+		return
+	}
+	b.types[ns][attachmentType.Name()] = true
+	s.ComplexTypes = append(s.ComplexTypes, &xsdComplexType{
+		Name: attachmentType.Name(),
+		SimpleContent: &xsdSimpleContent{Extension: xsdExtension{
+			Base:         "xs:base64Binary",
+			AnyAttribute: &xsdAnyAttribute{Namespace: "##other", ProcessContents: "lax"},
+		}},
+	})
+}
+
+// complexType defines the complex type of the struct t in the schema of
+// ns, named after the Go type.
+func (b *schemaBuilder) complexType(ns string, t reflect.Type) {
+	s := b.schema(ns)
+	if b.types[ns][t.Name()] {
+		return
+	}
+	b.types[ns][t.Name()] = true
+
+	ct := &xsdComplexType{Name: t.Name()}
+	s.ComplexTypes = append(s.ComplexTypes, ct)
+
+	var particles []xsdElement
+	var attributes []xsdAttribute
+	var anyAttribute *xsdAnyAttribute
+	var chardata bool
+
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := range t.NumField() {
+			f := t.Field(i)
+			tag := f.Tag.Get("xml")
+			if !f.IsExported() || tag == "-" || f.Name == "XMLName" {
+				continue
+			}
+			if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+				addFields(f.Type)
+				continue
+			}
+
+			tagName, opts, _ := strings.Cut(tag, ",")
+			flags := strings.Split(opts, ",")
+			space, local, ok := strings.Cut(tagName, " ")
+			if !ok {
+				space, local = "", tagName
+			}
+
+			elem, minOccurs, maxOccurs := f.Type, "", ""
+			if slices.Contains(flags, "omitempty") {
+				minOccurs = "0"
+			}
+			if elem.Kind() == reflect.Slice {
+				elem, minOccurs, maxOccurs = elem.Elem(), "0", "unbounded"
+			}
+			if elem.Kind() == reflect.Pointer {
+				elem, minOccurs = elem.Elem(), "0"
+			}
+
+			switch {
+			case slices.Contains(flags, "attr"):
+				if space != "" {
+					anyAttribute = &xsdAnyAttribute{Namespace: "##other", ProcessContents: "lax"}
+					continue
+				}
+				if local == "" {
+					local = f.Name
+				}
+				attributes = append(attributes, xsdAttribute{Name: local, Type: b.typeName(ns, elem)})
+				continue
+			case slices.Contains(flags, "chardata"):
+				chardata = true
+				continue
+			case slices.Contains(flags, "innerxml"):
+				ct.Mixed = true
+				fallthrough
+			case slices.Contains(flags, "any"):
+				particles = append(particles, xsdElement{
+					XMLName:         xml.Name{Local: "any"},
+					ProcessContents: "lax",
+					MinOccurs:       "0",
+					MaxOccurs:       maxOccurs,
+				})
+				continue
+			}
+
+			if local == "" {
+				if name, ok := typeElementName(elem); ok {
+					space, local = name.Space, name.Local
+				} else {
+					local = f.Name
+				}
+			}
+			if space != "" {
+				// Elements in an explicit namespace are declared globally
+				// in their own schema and referenced.
+				b.element(xml.Name{Space: space, Local: local}, elem)
+				b.importNamespace(ns, space)
+				particles = append(particles, xsdElement{
+					XMLName:   xml.Name{Local: "element"},
+					Ref:       qualifiedName(space, local),
+					MinOccurs: minOccurs,
+					MaxOccurs: maxOccurs,
+				})
+				continue
+			}
+			particles = append(particles, xsdElement{
+				XMLName:   xml.Name{Local: "element"},
+				Name:      local,
+				Type:      b.typeName(ns, elem),
+				MinOccurs: minOccurs,
+				MaxOccurs: maxOccurs,
+			})
+		}
+	}
+	addFields(t)
+
+	if chardata {
+		ct.SimpleContent = &xsdSimpleContent{Extension: xsdExtension{
+			Base:         "xs:string",
+			Attributes:   attributes,
+			AnyAttribute: anyAttribute,
+		}}
+		return
+	}
+	if len(particles) > 0 {
+		ct.Sequence = &xsdSequence{Particles: particles}
+	}
+	ct.Attributes = attributes
+	ct.AnyAttribute = anyAttribute
+}
+
+// WSDL 1.1 document structure. Children without a namespace in their tag
+// inherit the namespace of their parent.
+type wsdlDefinitions struct {
+	XMLName         xml.Name   `xml:"http://schemas.xmlsoap.org/wsdl/ definitions"`
+	Name            string     `xml:"name,attr"`
+	TargetNamespace string     `xml:"targetNamespace,attr"`
+	Prefixes        []xml.Attr `xml:",any,attr"`
+	Types           struct {
+		Schemas []*xsdSchema
+	} `xml:"types"`
+	Messages []wsdlMessage `xml:"message"`
+	PortType wsdlPortType  `xml:"portType"`
+	Binding  wsdlBinding   `xml:"binding"`
+	Service  wsdlService   `xml:"service"`
+}
+
+type wsdlMessage struct {
+	Name string   `xml:"name,attr"`
+	Part wsdlPart `xml:"part"`
+}
+
+type wsdlPart struct {
+	Name    string `xml:"name,attr"`
+	Element string `xml:"element,attr"`
+}
+
+type wsdlPortType struct {
+	Name       string              `xml:"name,attr"`
+	Operations []wsdlPortOperation `xml:"operation"`
+}
+
+type wsdlPortOperation struct {
+	Name   string    `xml:"name,attr"`
+	Input  wsdlParam `xml:"input"`
+	Output wsdlParam `xml:"output"`
+}
+
+type wsdlParam struct {
+	Message string `xml:"message,attr"`
+	Action  string `xml:"wsam:Action,attr"`
+}
+
+type wsdlBinding struct {
+	Name        string                 `xml:"name,attr"`
+	Type        string                 `xml:"type,attr"`
+	SOAPBinding wsdlSOAPBinding        `xml:"http://schemas.xmlsoap.org/wsdl/soap/ binding"`
+	Operations  []wsdlBindingOperation `xml:"operation"`
+}
+
+type wsdlSOAPBinding struct {
+	Style     string `xml:"style,attr"`
+	Transport string `xml:"transport,attr"`
+}
+
+type wsdlBindingOperation struct {
+	Name          string            `xml:"name,attr"`
+	SOAPOperation wsdlSOAPOperation `xml:"http://schemas.xmlsoap.org/wsdl/soap/ operation"`
+	Input         wsdlBindingParam  `xml:"input"`
+	Output        wsdlBindingParam  `xml:"output"`
+}
+
+type wsdlSOAPOperation struct {
+	SOAPAction string `xml:"soapAction,attr"`
+}
+
+type wsdlBindingParam struct {
+	Body wsdlSOAPBody `xml:"http://schemas.xmlsoap.org/wsdl/soap/ body"`
+}
+
+type wsdlSOAPBody struct {
+	Use string `xml:"use,attr"`
+}
+
+type wsdlService struct {
+	Name string   `xml:"name,attr"`
+	Port wsdlPort `xml:"port"`
+}
+
+type wsdlPort struct {
+	Name    string          `xml:"name,attr"`
+	Binding string          `xml:"binding,attr"`
+	Address wsdlSOAPAddress `xml:"http://schemas.xmlsoap.org/wsdl/soap/ address"`
+}
+
+type wsdlSOAPAddress struct {
+	Location string `xml:"location,attr"`
+}
+
+// XML Schema structure, declared with the XML Schema namespace as the
+// default namespace of each schema element.
+type xsdSchema struct {
+	XMLName            xml.Name           `xml:"http://www.w3.org/2001/XMLSchema schema"`
+	TargetNamespace    string             `xml:"targetNamespace,attr"`
+	ElementFormDefault string             `xml:"elementFormDefault,attr"`
+	Imports            []xsdImport        `xml:"import"`
+	Elements           []xsdGlobalElement `xml:"element"`
+	ComplexTypes       []*xsdComplexType  `xml:"complexType"`
+}
+
+type xsdImport struct {
+	Namespace string `xml:"namespace,attr"`
+}
+
+type xsdGlobalElement struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// xsdElement is a local element declaration, or an xs:any wildcard when its
+// XMLName says so.
+type xsdElement struct {
+	XMLName         xml.Name
+	Name            string `xml:"name,attr,omitempty"`
+	Ref             string `xml:"ref,attr,omitempty"`
+	Type            string `xml:"type,attr,omitempty"`
+	ProcessContents string `xml:"processContents,attr,omitempty"`
+	MinOccurs       string `xml:"minOccurs,attr,omitempty"`
+	MaxOccurs       string `xml:"maxOccurs,attr,omitempty"`
+}
+
+type xsdComplexType struct {
+	Name          string            `xml:"name,attr"`
+	Mixed         bool              `xml:"mixed,attr,omitempty"`
+	Sequence      *xsdSequence      `xml:"sequence"`
+	SimpleContent *xsdSimpleContent `xml:"simpleContent"`
+	Attributes    []xsdAttribute    `xml:"attribute"`
+	AnyAttribute  *xsdAnyAttribute  `xml:"anyAttribute"`
+}
+
+type xsdSequence struct {
+	Particles []xsdElement
+}
+
+type xsdSimpleContent struct {
+	Extension xsdExtension `xml:"extension"`
+}
+
+type xsdExtension struct {
+	Base         string           `xml:"base,attr"`
+	Attributes   []xsdAttribute   `xml:"attribute"`
+	AnyAttribute *xsdAnyAttribute `xml:"anyAttribute"`
+}
+
+type xsdAttribute struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type xsdAnyAttribute struct {
+	Namespace       string `xml:"namespace,attr"`
+	ProcessContents string `xml:"processContents,attr"`
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maasumiyaat/soap/service"
+)
+
+func TestWSDLServed(t *testing.T) {
+	server := httptest.NewServer(&UserSOAPHandler{UserService: &service.UserService{}})
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + "/soap/user?wsdl")
+	if err != nil {
+		t.Fatalf("GET ?wsdl: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/xml") {
+		t.Fatalf("GET ?wsdl = %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	var defs struct {
+		Binding wsdlBinding `xml:"binding"`
+		Service wsdlService `xml:"service"`
+	}
+	if err := xml.Unmarshal(body, &defs); err != nil {
+		t.Fatalf("WSDL: %v", err)
+	}
+	if got, want := defs.Service.Port.Address.Location, server.URL+"/soap/user"; got != want {
+		t.Errorf("port address = %s, want %s", got, want)
+	}
+
+	// Every operation is bound with the action requests are routed by.
+	actions := map[string]string{}
+	for _, op := range defs.Binding.Operations {
+		actions[op.Name] = op.SOAPOperation.SOAPAction
+	}
+	for name, op := range operations {
+		if actions[name] != op.Action() {
+			t.Errorf("%s bound with soapAction %q, want %q", name, actions[name], op.Action())
+		}
+	}
+	if len(actions) != len(operations) {
+		t.Errorf("WSDL binds %d operations, want %d", len(actions), len(operations))
+	}
+}
+
+// The client is generated from client/user.wsdl, so it must not fall
+// behind the operations registry.
+func TestClientWSDLIsCurrent(t *testing.T) {
+	checkedIn, err := os.ReadFile(filepath.Join("..", "client", "user.wsdl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wsdl, err := WSDL("http://localhost:8180/soap/user")
+	if err != nil {
+		t.Fatalf("WSDL: %v", err)
+	}
+	if !bytes.Equal(checkedIn, append(wsdl, '\n')) {
+		t.Error("client/user.wsdl is out of date, run go generate in client")
+	}
+}
@@ -0,0 +1,231 @@
+package wsdl
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// builder collects the declarations of a document and those it imports,
+// and resolves the references between them once all are read.
+type builder struct {
+	// fetch reads imported documents; Parse leaves it nil.
+	fetch  func(location string) ([]byte, error)
+	loaded map[string]bool
+	defs   *Definitions
+
+	// messages maps WSDL 1.1 messages to the element of their one part.
+	messages   map[xml.Name]xml.Name
+	actions    map[string]string
+	operations []pendingOperation
+
+	serviceName  string
+	docName      string
+	portTypeName string
+}
+
+// pendingOperation is an operation whose messages may be declared in a
+// document not read yet.
+type pendingOperation struct {
+	name          string
+	input, output xml.Name
+	// messages is set when input and output name WSDL 1.1 messages rather
+	// than elements.
+	messages bool
+	// action is the wsam:Action of the input.
+	action string
+}
+
+func newBuilder(fetch func(string) ([]byte, error)) *builder {
+	return &builder{
+		fetch:  fetch,
+		loaded: make(map[string]bool),
+		defs: &Definitions{
+			elements:     make(map[xml.Name]Element),
+			complexTypes: make(map[xml.Name]*ComplexType),
+			simpleTypes:  make(map[xml.Name]SimpleType),
+		},
+		messages: make(map[xml.Name]xml.Name),
+		actions:  make(map[string]string),
+	}
+}
+
+// addDocument reads a WSDL 1.1 or 2.0 document or a schema. namespace is
+// the target namespace given to schemas without one, as for an include.
+func (b *builder) addDocument(data []byte, location, namespace string) error {
+	var root struct{ XMLName xml.Name }
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("parsing %s failed: %w", describeLocation(location), err)
+	}
+
+	var err error
+	switch root.XMLName {
+	case xml.Name{Space: Namespace, Local: "definitions"}:
+		err = b.addWSDL11(data, location)
+	case xml.Name{Space: Namespace20, Local: "description"}:
+		err = b.addWSDL20(data, location)
+	case xml.Name{Space: XSDNamespace, Local: "schema"}:
+		var s schema
+		if err = xml.Unmarshal(data, &s); err == nil {
+			err = b.addSchema(s, nil, location, namespace)
+		}
+	default:
+		return fmt.Errorf("parsing %s failed: root element is %s, not WSDL definitions or a schema", describeLocation(location), formatName(root.XMLName))
+	}
+	if err != nil {
+		return fmt.Errorf("parsing %s failed: %w", describeLocation(location), err)
+	}
+	return nil
+}
+
+// load reads the document at ref, relative to the document at base, unless
+// it was read already.
+func (b *builder) load(ref, base, namespace string) error {
+	if b.fetch == nil {
+		return fmt.Errorf("cannot import %s: Parse does not follow imports, use Load", ref)
+	}
+	location := resolveLocation(base, ref)
+	if b.loaded[location] {
+		return nil
+	}
+	b.loaded[location] = true
+	data, err := b.fetch(location)
+	if err != nil {
+		return err
+	}
+	return b.addDocument(data, location, namespace)
+}
+
+// finish resolves element references and operation messages, once every
+// document has been read.
+func (b *builder) finish() (*Definitions, error) {
+	defs := b.defs
+	visited := make(map[*ComplexType]bool)
+	for _, e := range defs.Elements {
+		if err := b.resolveReferences(e.ComplexType, visited); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range defs.ComplexTypes {
+		if err := b.resolveReferences(t, visited); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, op := range b.operations {
+		input, output := op.input, op.output
+		if op.messages {
+			var ok bool
+			if input, ok = b.messages[op.input]; !ok {
+				return nil, fmt.Errorf("operation %s: no single-element message %s", op.name, formatName(op.input))
+			}
+			if output, ok = b.messages[op.output]; !ok && op.output != (xml.Name{}) {
+				return nil, fmt.Errorf("operation %s: no single-element message %s", op.name, formatName(op.output))
+			}
+		}
+		for _, name := range []xml.Name{input, output} {
+			if _, ok := defs.elements[name]; !ok && name != (xml.Name{}) {
+				return nil, fmt.Errorf("operation %s: no declaration of element %s", op.name, formatName(name))
+			}
+		}
+
+		action := b.actions[op.name]
+		if action == "" {
+			action = op.action
+		}
+		defs.Operations = append(defs.Operations, Operation{
+			Name:   op.name,
+			Action: action,
+			Input:  input,
+			Output: output,
+		})
+	}
+
+	switch {
+	case b.serviceName != "":
+		defs.Name = b.serviceName
+	case b.docName != "":
+		defs.Name = b.docName
+	default:
+		defs.Name = b.portTypeName
+	}
+	return defs, nil
+}
+
+// resolveReferences replaces the element references in t, and the anonymous
+// types of its elements, by the global elements they refer to.
+func (b *builder) resolveReferences(t *ComplexType, visited map[*ComplexType]bool) error {
+	if t == nil || visited[t] {
+		return nil
+	}
+	visited[t] = true
+	for i, e := range t.Elements {
+		if !e.ref {
+			if err := b.resolveReferences(e.ComplexType, visited); err != nil {
+				return err
+			}
+			continue
+		}
+		global, ok := b.defs.elements[e.Name]
+		if !ok {
+			return fmt.Errorf("reference to undeclared element %s", formatName(e.Name))
+		}
+		global.MinOccurs, global.MaxOccurs = e.MinOccurs, e.MaxOccurs
+		t.Elements[i] = global
+	}
+	return nil
+}
+
+// readLocation reads a file, or fetches an http or https URL.
+func readLocation(location string) ([]byte, error) {
+	if !isURL(location) {
+		return os.ReadFile(location)
+	}
+	resp, err := http.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s failed: %s", location, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// resolveLocation resolves the location ref found in the document at base.
+func resolveLocation(base, ref string) string {
+	if isURL(ref) {
+		return ref
+	}
+	if isURL(base) {
+		baseURL, err := url.Parse(base)
+		if err != nil {
+			return ref
+		}
+		refURL, err := url.Parse(ref)
+		if err != nil {
+			return ref
+		}
+		return baseURL.ResolveReference(refURL).String()
+	}
+	if filepath.IsAbs(ref) {
+		return ref
+	}
+	return filepath.Join(filepath.Dir(base), filepath.FromSlash(ref))
+}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+func describeLocation(location string) string {
+	if location == "" {
+		return "WSDL"
+	}
+	return location
+}
@@ -0,0 +1,335 @@
+package wsdl
+
+import (
+	"encoding/xml"
+	"errors"
+)
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// schemaContext carries what the declarations of a schema are read with.
+type schemaContext struct {
+	namespace           string
+	elementsQualified   bool
+	attributesQualified bool
+	scope               *scope
+}
+
+// addSchema adds the global declarations of a schema, after those of the
+// schemas it imports or includes by location. namespace is used when the
+// schema has no target namespace of its own.
+func (b *builder) addSchema(s schema, parent *scope, location, namespace string) error {
+	ctx := schemaContext{
+		namespace:           s.TargetNamespace,
+		elementsQualified:   s.ElementFormDefault == "qualified",
+		attributesQualified: s.AttributeFormDefault == "qualified",
+		scope:               newScope(parent, s.Attrs),
+	}
+	if ctx.namespace == "" {
+		ctx.namespace = namespace
+	}
+
+	for _, imp := range s.Imports {
+		// The schemas of XML Schema itself and of the xml: attributes are
+		// built in.
+		if imp.SchemaLocation == "" || imp.Namespace == XSDNamespace || imp.Namespace == xmlNamespace {
+			continue
+		}
+		if err := b.load(imp.SchemaLocation, location, ""); err != nil {
+			return err
+		}
+	}
+	for _, inc := range s.Includes {
+		if err := b.load(inc.SchemaLocation, location, ctx.namespace); err != nil {
+			return err
+		}
+	}
+
+	defs := b.defs
+	for _, t := range s.SimpleTypes {
+		name := xml.Name{Space: ctx.namespace, Local: t.Name}
+		simpleType := SimpleType{Name: name, Base: simpleBase(t, ctx)}
+		if t.Restriction != nil {
+			for _, e := range t.Restriction.Enumerations {
+				simpleType.Enumeration = append(simpleType.Enumeration, e.Value)
+			}
+		}
+		defs.simpleTypes[name] = simpleType
+		defs.SimpleTypes = append(defs.SimpleTypes, simpleType)
+	}
+	for _, t := range s.ComplexTypes {
+		name := xml.Name{Space: ctx.namespace, Local: t.Name}
+		complexType := b.complexType(t, ctx, name)
+		defs.complexTypes[name] = complexType
+		defs.ComplexTypes = append(defs.ComplexTypes, complexType)
+	}
+	for _, e := range s.Elements {
+		element := b.element(e, ctx, true)
+		defs.elements[element.Name] = element
+		defs.Elements = append(defs.Elements, element)
+	}
+	return nil
+}
+
+// element converts an element declaration. References are resolved by
+// finish, once all global elements are known.
+func (b *builder) element(e xsdElement, ctx schemaContext, global bool) Element {
+	if e.Ref != "" {
+		return Element{Name: ctx.scope.resolve(e.Ref), MinOccurs: e.MinOccurs, MaxOccurs: e.MaxOccurs, ref: true}
+	}
+
+	element := Element{MinOccurs: e.MinOccurs, MaxOccurs: e.MaxOccurs}
+	element.Name.Local = e.Name
+	// Local elements are in the target namespace when they are qualified.
+	if global || e.Form == "qualified" || e.Form == "" && ctx.elementsQualified {
+		element.Name.Space = ctx.namespace
+	}
+	switch {
+	case e.Type != "":
+		element.Type = ctx.scope.resolve(e.Type)
+	case e.ComplexType != nil:
+		element.ComplexType = b.complexType(*e.ComplexType, ctx, xml.Name{})
+	case e.SimpleType != nil:
+		element.Type = simpleBase(*e.SimpleType, ctx)
+	default:
+		element.Type = xml.Name{Space: XSDNamespace, Local: "anyType"}
+	}
+	return element
+}
+
+func (b *builder) complexType(t complexType, ctx schemaContext, name xml.Name) *ComplexType {
+	complexType := &ComplexType{Name: name, Namespace: ctx.namespace}
+	content := t.content
+	switch {
+	case t.SimpleContent != nil:
+		complexType.SimpleContent = true
+		if d := t.SimpleContent.derivation(); d != nil {
+			complexType.Base = ctx.scope.resolve(d.Base)
+			content = d.content
+		}
+	case t.ComplexContent != nil:
+		if d := t.ComplexContent.Extension; d != nil {
+			complexType.Base = ctx.scope.resolve(d.Base)
+			content = d.content
+		} else if d := t.ComplexContent.Restriction; d != nil {
+			// A restriction repeats the content it keeps.
+			content = d.content
+		}
+	}
+
+	for _, p := range []*particle{content.Sequence, content.Choice, content.All} {
+		if p != nil {
+			complexType.Elements = b.particle(*p, ctx, complexType.Elements, false, false)
+		}
+	}
+	for _, a := range content.Attributes {
+		complexType.Attributes = append(complexType.Attributes, attribute(a, ctx))
+	}
+	return complexType
+}
+
+// particle appends the elements of a sequence, choice or all group to
+// elements, flattening nested groups. Elements of optional groups and of
+// choices become optional, and those of repeated groups repeated.
+func (b *builder) particle(p particle, ctx schemaContext, elements []Element, optional, repeated bool) []Element {
+	optional = optional || p.choice || p.minOccurs == "0"
+	repeated = repeated || p.maxOccurs != "" && p.maxOccurs != "0" && p.maxOccurs != "1"
+	for _, item := range p.items {
+		if item.group != nil {
+			elements = b.particle(*item.group, ctx, elements, optional, repeated)
+			continue
+		}
+		element := b.element(*item.element, ctx, false)
+		if optional {
+			element.MinOccurs = "0"
+		}
+		if repeated {
+			element.MaxOccurs = "unbounded"
+		}
+		elements = append(elements, element)
+	}
+	return elements
+}
+
+func attribute(a xsdAttribute, ctx schemaContext) Attribute {
+	attr := Attribute{Use: a.Use}
+	switch {
+	case a.Ref != "":
+		// Global attributes are not kept; referenced ones, such as
+		// xml:lang, are taken as strings.
+		attr.Name = ctx.scope.resolve(a.Ref)
+		attr.Type = xml.Name{Space: XSDNamespace, Local: "string"}
+		return attr
+	case a.Type != "":
+		attr.Type = ctx.scope.resolve(a.Type)
+	case a.SimpleType != nil:
+		attr.Type = simpleBase(*a.SimpleType, ctx)
+	default:
+		attr.Type = xml.Name{Space: XSDNamespace, Local: "string"}
+	}
+	attr.Name.Local = a.Name
+	if a.Form == "qualified" || a.Form == "" && ctx.attributesQualified {
+		attr.Name.Space = ctx.namespace
+	}
+	return attr
+}
+
+// simpleBase returns the type a simple type restricts, or xs:string for
+// lists and unions.
+func simpleBase(t simpleType, ctx schemaContext) xml.Name {
+	switch {
+	case t.Restriction == nil:
+		return xml.Name{Space: XSDNamespace, Local: "string"}
+	case t.Restriction.Base != "":
+		return ctx.scope.resolve(t.Restriction.Base)
+	case t.Restriction.SimpleType != nil:
+		return simpleBase(*t.Restriction.SimpleType, ctx)
+	}
+	return xml.Name{Space: XSDNamespace, Local: "string"}
+}
+
+// The XML Schema structure, limited to what the builder reads.
+type schema struct {
+	TargetNamespace      string     `xml:"targetNamespace,attr"`
+	ElementFormDefault   string     `xml:"elementFormDefault,attr"`
+	AttributeFormDefault string     `xml:"attributeFormDefault,attr"`
+	Attrs                []xml.Attr `xml:",any,attr"`
+	Imports              []struct {
+		Namespace      string `xml:"namespace,attr"`
+		SchemaLocation string `xml:"schemaLocation,attr"`
+	} `xml:"http://www.w3.org/2001/XMLSchema import"`
+	Includes []struct {
+		SchemaLocation string `xml:"schemaLocation,attr"`
+	} `xml:"http://www.w3.org/2001/XMLSchema include"`
+	Elements     []xsdElement  `xml:"http://www.w3.org/2001/XMLSchema element"`
+	ComplexTypes []complexType `xml:"http://www.w3.org/2001/XMLSchema complexType"`
+	SimpleTypes  []simpleType  `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+}
+
+type xsdElement struct {
+	Name        string       `xml:"name,attr"`
+	Ref         string       `xml:"ref,attr"`
+	Type        string       `xml:"type,attr"`
+	Form        string       `xml:"form,attr"`
+	MinOccurs   string       `xml:"minOccurs,attr"`
+	MaxOccurs   string       `xml:"maxOccurs,attr"`
+	ComplexType *complexType `xml:"http://www.w3.org/2001/XMLSchema complexType"`
+	SimpleType  *simpleType  `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+}
+
+type xsdAttribute struct {
+	Name       string      `xml:"name,attr"`
+	Ref        string      `xml:"ref,attr"`
+	Type       string      `xml:"type,attr"`
+	Use        string      `xml:"use,attr"`
+	Form       string      `xml:"form,attr"`
+	SimpleType *simpleType `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+}
+
+// content is the content model of a complex type or of a derivation.
+type content struct {
+	Sequence   *particle      `xml:"http://www.w3.org/2001/XMLSchema sequence"`
+	Choice     *particle      `xml:"http://www.w3.org/2001/XMLSchema choice"`
+	All        *particle      `xml:"http://www.w3.org/2001/XMLSchema all"`
+	Attributes []xsdAttribute `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+}
+
+type complexType struct {
+	Name string `xml:"name,attr"`
+	content
+	SimpleContent  *derivations `xml:"http://www.w3.org/2001/XMLSchema simpleContent"`
+	ComplexContent *derivations `xml:"http://www.w3.org/2001/XMLSchema complexContent"`
+}
+
+type derivations struct {
+	Extension   *derivation `xml:"http://www.w3.org/2001/XMLSchema extension"`
+	Restriction *derivation `xml:"http://www.w3.org/2001/XMLSchema restriction"`
+}
+
+func (d *derivations) derivation() *derivation {
+	if d.Extension != nil {
+		return d.Extension
+	}
+	return d.Restriction
+}
+
+type derivation struct {
+	Base string `xml:"base,attr"`
+	content
+}
+
+type simpleType struct {
+	Name        string `xml:"name,attr"`
+	Restriction *struct {
+		Base         string      `xml:"base,attr"`
+		SimpleType   *simpleType `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+		Enumerations []struct {
+			Value string `xml:"value,attr"`
+		} `xml:"http://www.w3.org/2001/XMLSchema enumeration"`
+	} `xml:"http://www.w3.org/2001/XMLSchema restriction"`
+}
+
+// particle is a sequence, choice or all group. It is decoded by hand to
+// keep its elements and nested groups in document order.
+type particle struct {
+	choice               bool
+	minOccurs, maxOccurs string
+	items                []particleItem
+}
+
+// particleItem is either an element or a nested group.
+type particleItem struct {
+	element *xsdElement
+	group   *particle
+}
+
+func (p *particle) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	p.choice = start.Name.Local == "choice"
+	for _, attr := range start.Attr {
+		switch attr.Name {
+		case xml.Name{Local: "minOccurs"}:
+			p.minOccurs = attr.Value
+		case xml.Name{Local: "maxOccurs"}:
+			p.maxOccurs = attr.Value
+		}
+	}
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.EndElement:
+			return nil
+		case xml.StartElement:
+			if t.Name.Space != XSDNamespace {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			switch t.Name.Local {
+			case "element":
+				var e xsdElement
+				if err := d.DecodeElement(&e, &t); err != nil {
+					return err
+				}
+				p.items = append(p.items, particleItem{element: &e})
+			case "sequence", "choice", "all":
+				var group particle
+				if err := d.DecodeElement(&group, &t); err != nil {
+					return err
+				}
+				p.items = append(p.items, particleItem{group: &group})
+			case "group":
+				return errors.New("xs:group references are not supported")
+			default:
+				// Wildcards such as xs:any describe no particular element.
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
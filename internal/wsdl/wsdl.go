@@ -0,0 +1,215 @@
+// Package wsdl reads the parts of a WSDL 1.1 or 2.0 document that code
+// generators need: its document/literal operations, with their SOAP
+// actions and the global elements of their messages, and the XML Schema
+// declarations of those elements and their types. Schemas may be inline
+// or imported; Load follows imports and includes from other files.
+package wsdl
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Namespaces of WSDL 1.1 and 2.0, their SOAP bindings, XML Schema and
+// WS-Addressing metadata.
+const (
+	Namespace       = "http://schemas.xmlsoap.org/wsdl/"
+	SOAPNamespace   = "http://schemas.xmlsoap.org/wsdl/soap/"
+	SOAP12Namespace = "http://schemas.xmlsoap.org/wsdl/soap12/"
+	Namespace20     = "http://www.w3.org/ns/wsdl"
+	SOAP20Namespace = "http://www.w3.org/ns/wsdl/soap"
+	XSDNamespace    = "http://www.w3.org/2001/XMLSchema"
+	WSAMNamespace   = "http://www.w3.org/2007/05/addressing/metadata"
+)
+
+// Definitions is a parsed WSDL document with the documents it imports.
+type Definitions struct {
+	// Name is the name of the first service, or else of the document or
+	// its first port type.
+	Name            string
+	TargetNamespace string
+	// Location is the address of the first SOAP port, if any.
+	Location   string
+	Operations []Operation
+	// Elements, ComplexTypes and SimpleTypes are the global declarations
+	// of all schemas, in document order.
+	Elements     []Element
+	ComplexTypes []*ComplexType
+	SimpleTypes  []SimpleType
+
+	elements     map[xml.Name]Element
+	complexTypes map[xml.Name]*ComplexType
+	simpleTypes  map[xml.Name]SimpleType
+}
+
+// Operation is a document/literal operation of a port type or interface.
+type Operation struct {
+	Name string
+	// Action is the SOAP action of the binding, else the wsam:Action of
+	// the input.
+	Action string
+	// Input and Output name the global elements of the request and
+	// response messages. Output is empty for one-way operations.
+	Input  xml.Name
+	Output xml.Name
+}
+
+// OneWay reports whether the operation has no response.
+func (op Operation) OneWay() bool {
+	return op.Output == xml.Name{}
+}
+
+// Element is an element declaration: a global element, or a local one in
+// the content of a complex type. References to global elements are
+// replaced by the declaration they refer to, with the occurrence
+// constraints of the reference.
+type Element struct {
+	Name xml.Name
+	// Type is the QName of the element's type, such as
+	// {http://www.w3.org/2001/XMLSchema}int. It is empty when the element
+	// declares an anonymous complex type instead. Anonymous simple types
+	// are replaced by their base type.
+	Type        xml.Name
+	ComplexType *ComplexType
+	MinOccurs   string
+	MaxOccurs   string
+
+	// ref is set on references until they are resolved.
+	ref bool
+}
+
+// Repeated reports whether the element may occur more than once.
+func (e Element) Repeated() bool {
+	return e.MaxOccurs != "" && e.MaxOccurs != "0" && e.MaxOccurs != "1"
+}
+
+// Optional reports whether the element may be left out.
+func (e Element) Optional() bool {
+	return e.MinOccurs == "0"
+}
+
+// Attribute is an attribute declaration of a complex type.
+type Attribute struct {
+	Name xml.Name
+	Type xml.Name
+	// Use is "required", "optional" or "prohibited"; empty means optional.
+	Use string
+}
+
+// ComplexType is a complex type declaration. Elements lists the elements
+// of its content in order, flattening nested groups; elements of a choice
+// are optional.
+type ComplexType struct {
+	// Name is empty for anonymous types.
+	Name xml.Name
+	// Namespace is the target namespace of the declaring schema, which
+	// anonymous types have too.
+	Namespace string
+	// Base is the type this one extends, if any. With SimpleContent set it
+	// is the simple type of the content, such as xs:base64Binary for
+	// binary data with attributes.
+	Base          xml.Name
+	SimpleContent bool
+	Elements      []Element
+	Attributes    []Attribute
+}
+
+// SimpleType is a named simple type, reduced to the built-in or named
+// type it restricts. Lists and unions have xs:string as Base.
+type SimpleType struct {
+	Name        xml.Name
+	Base        xml.Name
+	Enumeration []string
+}
+
+// Element returns the global element declaration of name.
+func (d *Definitions) Element(name xml.Name) (Element, bool) {
+	e, ok := d.elements[name]
+	return e, ok
+}
+
+// ComplexType returns the complex type declaration of name.
+func (d *Definitions) ComplexType(name xml.Name) (*ComplexType, bool) {
+	t, ok := d.complexTypes[name]
+	return t, ok
+}
+
+// SimpleType returns the simple type declaration of name.
+func (d *Definitions) SimpleType(name xml.Name) (SimpleType, bool) {
+	t, ok := d.simpleTypes[name]
+	return t, ok
+}
+
+// Parse reads a WSDL 1.1 or 2.0 document whose schemas are all inline.
+// Documents importing others by location must be read with Load.
+func Parse(data []byte) (*Definitions, error) {
+	b := newBuilder(nil)
+	if err := b.addDocument(data, "", ""); err != nil {
+		return nil, err
+	}
+	return b.finish()
+}
+
+// Load reads the WSDL 1.1 or 2.0 document at location, a file path or an
+// http or https URL, with the WSDL documents and schemas it imports or
+// includes. Relative locations are resolved against the importing
+// document's.
+func Load(location string) (*Definitions, error) {
+	b := newBuilder(readLocation)
+	data, err := readLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	b.loaded[location] = true
+	if err := b.addDocument(data, location, ""); err != nil {
+		return nil, err
+	}
+	return b.finish()
+}
+
+// scope holds the namespace prefixes declared on an element and its
+// ancestors, for resolving QNames in attribute values.
+type scope struct {
+	parent   *scope
+	prefixes map[string]string
+}
+
+func newScope(parent *scope, attrs []xml.Attr) *scope {
+	s := &scope{parent: parent, prefixes: make(map[string]string)}
+	for _, attr := range attrs {
+		switch {
+		case attr.Name.Space == "xmlns":
+			s.prefixes[attr.Name.Local] = attr.Value
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			s.prefixes[""] = attr.Value
+		}
+	}
+	return s
+}
+
+// resolve expands a QName such as "tns:User" to its namespace and local
+// name.
+func (s *scope) resolve(qname string) xml.Name {
+	if qname == "" {
+		return xml.Name{}
+	}
+	prefix, local, ok := strings.Cut(qname, ":")
+	if !ok {
+		prefix, local = "", qname
+	}
+	for ; s != nil; s = s.parent {
+		if ns, ok := s.prefixes[prefix]; ok {
+			return xml.Name{Space: ns, Local: local}
+		}
+	}
+	return xml.Name{Local: local}
+}
+
+// formatName writes a QName as {namespace}local for error messages.
+func formatName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return fmt.Sprintf("{%s}%s", name.Space, name.Local)
+}
@@ -0,0 +1,143 @@
+package wsdl
+
+import "encoding/xml"
+
+// addWSDL11 adds the declarations of a WSDL 1.1 document, after those of
+// the documents it imports.
+func (b *builder) addWSDL11(data []byte, location string) error {
+	var doc definitions
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	scope := newScope(nil, doc.Attrs)
+	if b.defs.TargetNamespace == "" {
+		b.defs.TargetNamespace = doc.TargetNamespace
+	}
+	if b.docName == "" {
+		b.docName = doc.Name
+	}
+
+	for _, imp := range doc.Imports {
+		if err := b.load(imp.Location, location, ""); err != nil {
+			return err
+		}
+	}
+	for _, s := range doc.Types.Schemas {
+		if err := b.addSchema(s, scope, location, ""); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range doc.Messages {
+		if len(m.Parts) != 1 || m.Parts[0].Element == "" {
+			continue
+		}
+		b.messages[xml.Name{Space: doc.TargetNamespace, Local: m.Name}] = scope.resolve(m.Parts[0].Element)
+	}
+	for _, bnd := range doc.Bindings {
+		for _, op := range bnd.Operations {
+			for _, soapOp := range []*soapOperation{op.SOAPOperation, op.SOAP12Operation} {
+				if soapOp != nil && soapOp.SOAPAction != "" {
+					b.actions[op.Name] = soapOp.SOAPAction
+				}
+			}
+		}
+	}
+	for _, s := range doc.Services {
+		if b.serviceName == "" {
+			b.serviceName = s.Name
+		}
+		for _, p := range s.Ports {
+			for _, address := range []*soapAddress{p.Address, p.SOAP12Address} {
+				if address != nil && b.defs.Location == "" {
+					b.defs.Location = address.Location
+				}
+			}
+		}
+	}
+
+	for _, pt := range doc.PortTypes {
+		if b.portTypeName == "" {
+			b.portTypeName = pt.Name
+		}
+		for _, op := range pt.Operations {
+			if op.Input == nil {
+				continue
+			}
+			pending := pendingOperation{
+				name:     op.Name,
+				input:    scope.resolve(op.Input.Message),
+				messages: true,
+				action:   op.Input.Action,
+			}
+			if op.Output != nil {
+				pending.output = scope.resolve(op.Output.Message)
+			}
+			b.operations = append(b.operations, pending)
+		}
+	}
+	return nil
+}
+
+// The WSDL 1.1 structure, limited to what addWSDL11 reads.
+type definitions struct {
+	XMLName         xml.Name
+	Name            string     `xml:"name,attr"`
+	TargetNamespace string     `xml:"targetNamespace,attr"`
+	Attrs           []xml.Attr `xml:",any,attr"`
+	Imports         []struct {
+		Location string `xml:"location,attr"`
+	} `xml:"http://schemas.xmlsoap.org/wsdl/ import"`
+	Types struct {
+		Schemas []schema `xml:"http://www.w3.org/2001/XMLSchema schema"`
+	} `xml:"http://schemas.xmlsoap.org/wsdl/ types"`
+	Messages  []message     `xml:"http://schemas.xmlsoap.org/wsdl/ message"`
+	PortTypes []portType    `xml:"http://schemas.xmlsoap.org/wsdl/ portType"`
+	Bindings  []binding     `xml:"http://schemas.xmlsoap.org/wsdl/ binding"`
+	Services  []wsdlService `xml:"http://schemas.xmlsoap.org/wsdl/ service"`
+}
+
+type message struct {
+	Name  string `xml:"name,attr"`
+	Parts []struct {
+		Element string `xml:"element,attr"`
+	} `xml:"http://schemas.xmlsoap.org/wsdl/ part"`
+}
+
+type portType struct {
+	Name       string `xml:"name,attr"`
+	Operations []struct {
+		Name  string `xml:"name,attr"`
+		Input *struct {
+			Message string `xml:"message,attr"`
+			Action  string `xml:"http://www.w3.org/2007/05/addressing/metadata Action,attr"`
+		} `xml:"http://schemas.xmlsoap.org/wsdl/ input"`
+		Output *struct {
+			Message string `xml:"message,attr"`
+		} `xml:"http://schemas.xmlsoap.org/wsdl/ output"`
+	} `xml:"http://schemas.xmlsoap.org/wsdl/ operation"`
+}
+
+type binding struct {
+	Operations []struct {
+		Name            string         `xml:"name,attr"`
+		SOAPOperation   *soapOperation `xml:"http://schemas.xmlsoap.org/wsdl/soap/ operation"`
+		SOAP12Operation *soapOperation `xml:"http://schemas.xmlsoap.org/wsdl/soap12/ operation"`
+	} `xml:"http://schemas.xmlsoap.org/wsdl/ operation"`
+}
+
+type soapOperation struct {
+	SOAPAction string `xml:"soapAction,attr"`
+}
+
+type wsdlService struct {
+	Name  string `xml:"name,attr"`
+	Ports []struct {
+		Address       *soapAddress `xml:"http://schemas.xmlsoap.org/wsdl/soap/ address"`
+		SOAP12Address *soapAddress `xml:"http://schemas.xmlsoap.org/wsdl/soap12/ address"`
+	} `xml:"http://schemas.xmlsoap.org/wsdl/ port"`
+}
+
+type soapAddress struct {
+	Location string `xml:"location,attr"`
+}
@@ -0,0 +1,125 @@
+package wsdl
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// addWSDL20 adds the declarations of a WSDL 2.0 description, after those
+// of the descriptions it imports or includes.
+func (b *builder) addWSDL20(data []byte, location string) error {
+	var doc description
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	scope := newScope(nil, doc.Attrs)
+	if b.defs.TargetNamespace == "" {
+		b.defs.TargetNamespace = doc.TargetNamespace
+	}
+
+	for _, imp := range append(doc.Imports, doc.Includes...) {
+		if imp.Location == "" {
+			continue
+		}
+		if err := b.load(imp.Location, location, ""); err != nil {
+			return err
+		}
+	}
+	for _, s := range doc.Types.Schemas {
+		if err := b.addSchema(s, scope, location, ""); err != nil {
+			return err
+		}
+	}
+
+	for _, bnd := range doc.Bindings {
+		for _, op := range bnd.Operations {
+			if op.Action != "" {
+				b.actions[scope.resolve(op.Ref).Local] = op.Action
+			}
+		}
+	}
+	for _, s := range doc.Services {
+		if b.serviceName == "" {
+			b.serviceName = s.Name
+		}
+		for _, e := range s.Endpoints {
+			if b.defs.Location == "" {
+				b.defs.Location = e.Address
+			}
+		}
+	}
+
+	for _, i := range doc.Interfaces {
+		if b.portTypeName == "" {
+			b.portTypeName = i.Name
+		}
+		for _, op := range i.Operations {
+			if op.Input == nil {
+				continue
+			}
+			input, err := messageElement(scope, op.Name, op.Input.Element)
+			if err != nil {
+				return err
+			}
+			pending := pendingOperation{name: op.Name, input: input, action: op.Input.Action}
+			if op.Output != nil {
+				if pending.output, err = messageElement(scope, op.Name, op.Output.Element); err != nil {
+					return err
+				}
+			}
+			b.operations = append(b.operations, pending)
+		}
+	}
+	return nil
+}
+
+// messageElement resolves the element of a WSDL 2.0 message, which must be
+// a global element rather than a wildcard such as #any.
+func messageElement(scope *scope, operation, element string) (xml.Name, error) {
+	switch element {
+	case "", "#any", "#none", "#other":
+		return xml.Name{}, fmt.Errorf("operation %s: message content %q is not an element", operation, element)
+	}
+	return scope.resolve(element), nil
+}
+
+// The WSDL 2.0 structure, limited to what addWSDL20 reads.
+type description struct {
+	XMLName         xml.Name
+	TargetNamespace string     `xml:"targetNamespace,attr"`
+	Attrs           []xml.Attr `xml:",any,attr"`
+	Imports         []struct {
+		Location string `xml:"location,attr"`
+	} `xml:"http://www.w3.org/ns/wsdl import"`
+	Includes []struct {
+		Location string `xml:"location,attr"`
+	} `xml:"http://www.w3.org/ns/wsdl include"`
+	Types struct {
+		Schemas []schema `xml:"http://www.w3.org/2001/XMLSchema schema"`
+	} `xml:"http://www.w3.org/ns/wsdl types"`
+	Interfaces []struct {
+		Name       string `xml:"name,attr"`
+		Operations []struct {
+			Name  string `xml:"name,attr"`
+			Input *struct {
+				Element string `xml:"element,attr"`
+				Action  string `xml:"http://www.w3.org/2007/05/addressing/metadata Action,attr"`
+			} `xml:"http://www.w3.org/ns/wsdl input"`
+			Output *struct {
+				Element string `xml:"element,attr"`
+			} `xml:"http://www.w3.org/ns/wsdl output"`
+		} `xml:"http://www.w3.org/ns/wsdl operation"`
+	} `xml:"http://www.w3.org/ns/wsdl interface"`
+	Bindings []struct {
+		Operations []struct {
+			Ref    string `xml:"ref,attr"`
+			Action string `xml:"http://www.w3.org/ns/wsdl/soap action,attr"`
+		} `xml:"http://www.w3.org/ns/wsdl operation"`
+	} `xml:"http://www.w3.org/ns/wsdl binding"`
+	Services []struct {
+		Name      string `xml:"name,attr"`
+		Endpoints []struct {
+			Address string `xml:"address,attr"`
+		} `xml:"http://www.w3.org/ns/wsdl endpoint"`
+	} `xml:"http://www.w3.org/ns/wsdl service"`
+}
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/maasumiyaat/soap/database"
 	"github.com/maasumiyaat/soap/handler"
@@ -12,53 +19,233 @@ import (
 )
 
 const (
-	DBPath   = "user.db"
-	HTTPPort = ":8180"
-	UDPPort  = ":8181"
+	// DBPath is the database file used unless -db or DBPathEnv say
+	// otherwise.
+	DBPath    = "user.db"
+	DBPathEnv = "SOAP_DB_PATH"
+	HTTPPort  = ":8180"
+	UDPPort   = ":8181"
+	// GRPCAddr is where the gRPC server listens unless -grpc says
+	// otherwise.
+	GRPCAddr = "localhost:8182"
+	// EventingAddress is where WS-Eventing subscribers manage their
+	// subscriptions.
+	EventingAddress = "http://localhost" + HTTPPort + "/soap/user"
+
+	// Deleted users are kept as tombstones for this long before being purged.
+	TombstoneRetention = 30 * 24 * time.Hour
+	RetentionInterval  = time.Hour
+	// Change feed consumers offline for longer than this must resynchronise
+	// from an export.
+	ChangeLogRetention = 30 * 24 * time.Hour
+
+	// ShutdownTimeout bounds how long in-flight HTTP requests may take to
+	// finish after a stop signal.
+	ShutdownTimeout = 10 * time.Second
 )
 
+// seedCaller is recorded in the audit trail for users loaded from a fixture.
+var seedCaller = model.Caller{Principal: "system", Transport: "seed"}
+
 func main() {
+	// Subcommands such as import and export work on the database directly.
+	if len(os.Args) > 1 && os.Args[1] != "" && os.Args[1][0] != '-' {
+		if err := runCommand(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	dbPath := flag.String("db", defaultDBPath(), "database file (also $"+DBPathEnv+")")
+	seedPath := flag.String("seed", "", "fixture file (csv, jsonl or xml) loaded when the database has no users")
+	backupDir := flag.String("backup-dir", "", "directory for BackupDatabase and scheduled backups")
+	backupInterval := flag.Duration("backup-interval", 0, "take a backup this often (needs -backup-dir; 0 disables)")
+	backupKeep := flag.Int("backup-keep", service.DefaultBackupKeep, "number of backups to keep in -backup-dir")
+	strictActions := flag.Bool("strict-actions", false, "fault requests whose SOAPAction or wsa:Action does not match their Body element")
+	grpcAddr := flag.String("grpc", GRPCAddr, "address of the gRPC server (empty disables it)")
+	tcpAddr := flag.String("tcp", "", "address of the TCP SOAP server, e.g. localhost:8183 (empty, the default, disables it)")
+	indent := flag.Bool("indent", false, "pretty-print SOAP and JSON responses instead of sending them compact")
+	flag.Parse()
+
 	// 1. Initialize Database
-	if err := database.InitDB(DBPath); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	if err := database.InitDB(*dbPath); err != nil {
+		log.Fatalf("Failed to initialize database %s: %v", *dbPath, err)
 	}
 	defer database.DB.Close()
-	defer os.Remove(DBPath)
 
-	// 2. Seed Initial Data (Optional, but useful for testing)
-	initialUser := &model.User{
-		Name:  "Alice Johnson",
-		Email: "alice@example.com",
+	// 2. Setup Layers
+	userService := &service.UserService{EventingAddress: EventingAddress}
+	if *backupDir != "" {
+		userService.Backups = &service.Backups{Dir: *backupDir, Keep: *backupKeep}
 	}
-	if err := database.SaveUser(initialUser); err != nil {
-		log.Fatalf("Failed to seed initial user: %v", err)
+
+	// 3. Seed fixture data, only if asked to and only into an empty database
+	if *seedPath != "" {
+		if err := seedDatabase(userService, *seedPath); err != nil {
+			log.Fatalf("Failed to seed database from %s: %v", *seedPath, err)
+		}
 	}
-	log.Printf("Seeded user with ID: %d", initialUser.ID)
 
-	// 3. Setup Layers
-	userService := &service.UserService{}
+	// Responses to WS-Addressing ReplyTo endpoints are sent in the
+	// background; let them finish before the database closes
+	replies := handler.NewReplyDispatcher()
+	defer replies.Stop()
 
 	// HTTP SOAP Handler
 	httpSoapHandler := &handler.UserSOAPHandler{
-		UserService: userService,
+		UserService:     userService,
+		Replies:         replies,
+		StrictActions:   *strictActions,
+		IndentResponses: *indent,
 	}
 
 	// UDP SOAP Handler
 	udpSoapHandler := handler.NewUDPSOAPHandler(userService)
+	udpSoapHandler.Replies = replies
+	udpSoapHandler.StrictActions = *strictActions
+	udpSoapHandler.IndentResponses = *indent
+
+	// TCP SOAP Handler
+	tcpSoapHandler := handler.NewTCPSOAPHandler(userService)
+	tcpSoapHandler.Replies = replies
+	tcpSoapHandler.StrictActions = *strictActions
+	tcpSoapHandler.IndentResponses = *indent
 
-	// 4. Start UDP SOAP Server
+	// REST/JSON API over the same service
+	restHandler := handler.NewRESTHandler(userService)
+	restHandler.IndentResponses = *indent
+
+	// 4. Start tombstone retention and backup jobs
+	retentionJob := service.NewRetentionJob(TombstoneRetention, RetentionInterval)
+	retentionJob.ChangeRetention = ChangeLogRetention
+	retentionJob.Start()
+	defer retentionJob.Stop()
+
+	if *backupInterval > 0 {
+		if userService.Backups == nil {
+			log.Fatal("-backup-interval needs -backup-dir")
+		}
+		backupJob := service.NewBackupJob(userService.Backups, *backupInterval)
+		backupJob.Start()
+		defer backupJob.Stop()
+	}
+
+	// 5. Start webhook and WS-Eventing delivery
+	webhookDispatcher := service.NewWebhookDispatcher()
+	webhookDispatcher.Start()
+	defer webhookDispatcher.Stop()
+
+	eventingDispatcher := service.NewEventingDispatcher(EventingAddress)
+	eventingDispatcher.Start()
+	defer eventingDispatcher.Stop()
+
+	// 6. Start UDP and TCP SOAP Servers
 	if err := udpSoapHandler.StartUDPServer("localhost" + UDPPort); err != nil {
 		log.Fatalf("Failed to start UDP SOAP server: %v", err)
 	}
 	defer udpSoapHandler.Stop()
 
-	// 5. Setup HTTP Server
-	http.Handle("/soap/user", httpSoapHandler)
+	if *tcpAddr != "" {
+		if err := tcpSoapHandler.StartTCPServer(*tcpAddr); err != nil {
+			log.Fatalf("Failed to start TCP SOAP server: %v", err)
+		}
+		defer tcpSoapHandler.Stop()
+	}
+
+	// 7. Start gRPC Server
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("Failed to start gRPC server: %v", err)
+		}
+		grpcServer := handler.NewGRPCServer(userService)
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Printf("gRPC Server failed: %v", err)
+			}
+		}()
+		defer grpcServer.GracefulStop()
+		log.Printf("gRPC Server listening on %s", listener.Addr())
+	}
+
+	// 8. Setup HTTP Server
+	mux := http.NewServeMux()
+	mux.Handle("/soap/user", httpSoapHandler)
+	mux.Handle("/api/", restHandler)
+	mux.Handle("/jsonrpc", &handler.JSONRPCHandler{UserService: userService, IndentResponses: *indent})
+	mux.Handle("/admin/backup", &handler.BackupHandler{UserService: userService})
+	streamsDone := make(chan struct{})
+	mux.Handle("/changes/stream", &handler.ChangeStreamHandler{UserService: userService, Done: streamsDone})
+	server := &http.Server{Addr: HTTPPort, Handler: mux}
+	server.RegisterOnShutdown(func() { close(streamsDone) })
 
+	log.Printf("Using database %s", *dbPath)
 	log.Printf("HTTP SOAP Server starting on http://localhost%s/soap/user", HTTPPort)
 	log.Printf("UDP SOAP Server listening on localhost%s", UDPPort)
+	log.Printf("REST API at http://localhost%s/api/users", HTTPPort)
+	log.Printf("JSON-RPC at http://localhost%s/jsonrpc and on the UDP port", HTTPPort)
+
+	// 9. Serve until interrupted, then shut down cleanly so the database
+	// is closed properly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		log.Printf("HTTP Server failed: %v", err)
+	case <-ctx.Done():
+		log.Println("Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP Server shutdown failed: %v", err)
+		}
+	}
+}
+
+// defaultDBPath returns the database path from DBPathEnv, or DBPath.
+func defaultDBPath() string {
+	if path := os.Getenv(DBPathEnv); path != "" {
+		return path
+	}
+	return DBPath
+}
+
+// seedDatabase imports the users in a fixture file, keeping their IDs. It
+// does nothing once the database holds users, so restarting with the same
+// -seed flag is harmless.
+func seedDatabase(s *service.UserService, path string) error {
+	hasUsers, err := database.HasUsers()
+	if err != nil {
+		return err
+	}
+	if hasUsers {
+		log.Printf("Database already has users, not seeding from %s", path)
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-	if err := http.ListenAndServe(HTTPPort, nil); err != nil {
-		log.Fatalf("HTTP Server failed: %v", err)
+	ctx := service.WithCaller(context.Background(), seedCaller)
+	report, err := s.ImportUsers(ctx, f, service.ImportOptions{
+		Format:      formatFromPath(path),
+		PreserveIDs: true,
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range report.Errors {
+		log.Printf("Seed record %d rejected: %s", e.Record, e.Message)
 	}
+	log.Printf("Seeded %d users from %s", report.Imported, path)
+	return nil
 }
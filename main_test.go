@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+	"github.com/maasumiyaat/soap/service"
+)
+
+func TestDefaultDBPath(t *testing.T) {
+	t.Setenv(DBPathEnv, "")
+	if got := defaultDBPath(); got != DBPath {
+		t.Errorf("defaultDBPath() = %q, want %q", got, DBPath)
+	}
+	t.Setenv(DBPathEnv, "/var/lib/soap/users.db")
+	if got := defaultDBPath(); got != "/var/lib/soap/users.db" {
+		t.Errorf("defaultDBPath() with $%s = %q", DBPathEnv, got)
+	}
+}
+
+func TestSeedDatabaseKeepsExistingData(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "user.db")
+	fixture := filepath.Join("fixtures", "users.jsonl")
+	s := &service.UserService{}
+
+	if err := database.InitDB(dbPath); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	if err := seedDatabase(s, fixture); err != nil {
+		t.Fatalf("seedDatabase: %v", err)
+	}
+	if _, err := database.UpdateUser(seedCaller, 1, func(u *model.User) error { u.Name = "Alice Cooper"; return nil }); err != nil {
+		t.Fatalf("renaming Alice: %v", err)
+	}
+	database.DB.Close()
+
+	// A restart with the same -seed flag finds the data and leaves it alone.
+	if err := database.InitDB(dbPath); err != nil {
+		t.Fatalf("InitDB after restart: %v", err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+	if err := seedDatabase(s, fixture); err != nil {
+		t.Fatalf("seedDatabase after restart: %v", err)
+	}
+	user, err := database.GetUserByID(1)
+	if err != nil || user.Name != "Alice Cooper" {
+		t.Errorf("user 1 after restart = %+v, %v, want the renamed Alice", user, err)
+	}
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"bytes"
+	"encoding/xml"
+)
+
+// WS-Addressing namespaces. Requests may use either; replies use the one
+// the request used. Endpoint references the service hands out use the
+// August 2004 submission, as WS-Eventing requires.
+const (
+	AddressingNamespace    = "http://schemas.xmlsoap.org/ws/2004/08/addressing"
+	AddressingW3CNamespace = "http://www.w3.org/2005/08/addressing"
+)
+
+// AnonymousAddress returns the address that stands for "reply on the
+// connection the request came in on" in the WS-Addressing namespace ns.
+func AnonymousAddress(ns string) string {
+	if ns == AddressingW3CNamespace {
+		return AddressingW3CNamespace + "/anonymous"
+	}
+	return AddressingNamespace + "/role/anonymous"
+}
+
+// NoneAddress is the W3C address of an endpoint that discards messages.
+const NoneAddress = AddressingW3CNamespace + "/none"
+
+// IsAnonymousAddress reports whether address is empty or anonymous in
+// either WS-Addressing namespace.
+func IsAnonymousAddress(address string) bool {
+	return address == "" || address == AnonymousAddress(AddressingW3CNamespace) || address == AnonymousAddress(AddressingNamespace)
+}
+
+// FaultAction returns the action of faults in the WS-Addressing namespace
+// ns.
+func FaultAction(ns string) string {
+	return ns + "/fault"
+}
+
+// EndpointReference is a WS-Addressing endpoint: an address plus header
+// blocks to include in every message sent to it.
+type EndpointReference struct {
+	Address             string           `json:"address" xml:"http://schemas.xmlsoap.org/ws/2004/08/addressing Address"`
+	ReferenceProperties *ReferenceBlocks `json:"referenceProperties,omitempty" xml:"http://schemas.xmlsoap.org/ws/2004/08/addressing ReferenceProperties,omitempty"`
+	ReferenceParameters *ReferenceBlocks `json:"referenceParameters,omitempty" xml:"http://schemas.xmlsoap.org/ws/2004/08/addressing ReferenceParameters,omitempty"`
+}
+
+// ReferenceBlocks holds the opaque elements of an endpoint reference.
+type ReferenceBlocks struct {
+	Blocks []HeaderBlock `json:"blocks" xml:",any"`
+}
+
+// HeaderBlock is a SOAP header element kept verbatim, such as a reference
+// parameter echoed back to the endpoint that issued it.
+type HeaderBlock struct {
+	XMLName xml.Name
+	Value   string `json:"value" xml:",innerxml"`
+}
+
+// HeaderBlocks returns the reference properties and parameters of e, which
+// must be sent as SOAP header blocks with every message addressed to it.
+func (e EndpointReference) HeaderBlocks() []HeaderBlock {
+	var blocks []HeaderBlock
+	if e.ReferenceProperties != nil {
+		blocks = append(blocks, e.ReferenceProperties.Blocks...)
+	}
+	if e.ReferenceParameters != nil {
+		blocks = append(blocks, e.ReferenceParameters.Blocks...)
+	}
+	return blocks
+}
+
+// MessageAddressing holds the WS-Addressing headers of a message, in the
+// namespace Namespace.
+type MessageAddressing struct {
+	Namespace string
+	To        string
+	Action    string
+	MessageID string
+	// RelatesTo is the MessageID of the request a reply answers.
+	RelatesTo string
+	ReplyTo   *EndpointReference
+	FaultTo   *EndpointReference
+}
+
+// HeaderBlocks returns the To, Action, MessageID and RelatesTo headers of
+// m that are set.
+func (m MessageAddressing) HeaderBlocks() []HeaderBlock {
+	var blocks []HeaderBlock
+	for _, h := range []struct{ local, value string }{
+		{"To", m.To},
+		{"Action", m.Action},
+		{"MessageID", m.MessageID},
+		{"RelatesTo", m.RelatesTo},
+	} {
+		if h.value == "" {
+			continue
+		}
+		var text bytes.Buffer
+		xml.EscapeText(&text, []byte(h.value))
+		blocks = append(blocks, HeaderBlock{
+			XMLName: xml.Name{Space: m.Namespace, Local: h.local},
+			Value:   text.String(),
+		})
+	}
+	return blocks
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Namespaces of XOP includes and of the xmime:contentType attribute.
+const (
+	XOPNamespace   = "http://www.w3.org/2004/08/xop/include"
+	XMIMENamespace = "http://www.w3.org/2005/05/xmlmime"
+)
+
+// Attachment is binary element content, an xs:base64Binary. In an MTOM
+// message it travels as a separate MIME part that the element refers to
+// with an xop:Include; otherwise it is inline base64 text.
+type Attachment struct {
+	Data []byte `json:"data"`
+	// ContentType is the media type of Data, carried in the xmime:contentType
+	// attribute or, for MIME parts, the part's Content-Type.
+	ContentType string `json:"contentType,omitempty"`
+	// ContentID names the MIME part holding Data. It is set when the
+	// element is, or is to be, an xop:Include rather than inline data.
+	ContentID string `json:"-"`
+}
+
+type xopInclude struct {
+	XMLName xml.Name `json:"-" xml:"http://www.w3.org/2004/08/xop/include Include"`
+	Href    string   `json:"href" xml:"href,attr"`
+}
+
+// MarshalXML writes an xop:Include for attachments with a ContentID and
+// base64 text for the rest.
+func (a Attachment) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if a.ContentType != "" {
+		start.Attr = append(start.Attr,
+			xml.Attr{Name: xml.Name{Local: "xmlns:xmime"}, Value: XMIMENamespace},
+			xml.Attr{Name: xml.Name{Local: "xmime:contentType"}, Value: a.ContentType})
+	}
+	if a.ContentID != "" {
+		include := xopInclude{Href: "cid:" + url.PathEscape(a.ContentID)}
+		return e.EncodeElement(struct {
+			Include xopInclude
+		}{include}, start)
+	}
+	return e.EncodeElement(base64.StdEncoding.EncodeToString(a.Data), start)
+}
+
+// UnmarshalXML reads inline base64 text, or the content ID of an
+// xop:Include whose data the transport has to supply.
+func (a *Attachment) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var element struct {
+		Include *xopInclude
+		Text    string `json:"text" xml:",chardata"`
+	}
+	if err := d.DecodeElement(&element, &start); err != nil {
+		return err
+	}
+	for _, attr := range start.Attr {
+		if attr.Name.Space == XMIMENamespace && attr.Name.Local == "contentType" {
+			a.ContentType = strings.TrimSpace(attr.Value)
+		}
+	}
+
+	if element.Include != nil {
+		href, ok := strings.CutPrefix(strings.TrimSpace(element.Include.Href), "cid:")
+		if !ok {
+			return fmt.Errorf("xop:Include href %q is not a cid: URL", element.Include.Href)
+		}
+		id, err := url.PathUnescape(href)
+		if err != nil {
+			return fmt.Errorf("xop:Include href %q: %w", element.Include.Href, err)
+		}
+		a.ContentID = id
+		return nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(element.Text), ""))
+	if err != nil {
+		return fmt.Errorf("invalid base64 data: %w", err)
+	}
+	a.Data = data
+	return nil
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Transports through which a request can reach the service.
+const (
+	TransportHTTP = "HTTP"
+	TransportUDP  = "UDP"
+	TransportTCP  = "TCP"
+	TransportGRPC = "gRPC"
+	// TransportCLI marks changes made by command-line tools on the server.
+	TransportCLI = "CLI"
+)
+
+// Caller identifies who performed an operation and how it reached the service.
+type Caller struct {
+	Principal string
+	// UserID is the authenticated user, or zero for anonymous callers.
+	UserID     int
+	Transport  string
+	ClientAddr string
+}
+
+// Actions recorded in the audit trail.
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+	// AuditActionPassword records a password being set; no snapshots are kept.
+	AuditActionPassword = "password"
+	// AuditActionAvatar records a new avatar; the image is not kept.
+	AuditActionAvatar = "avatar"
+)
+
+// AuditEntry is a single immutable record in a user's change history.
+type AuditEntry struct {
+	Sequence   uint64    `json:"sequence" xml:"sequence"`
+	UserID     int       `json:"userId" xml:"userId"`
+	Action     string    `json:"action" xml:"action"`
+	Before     *User     `json:"before,omitempty" xml:"Before,omitempty"`
+	After      *User     `json:"after,omitempty" xml:"After,omitempty"`
+	Principal  string    `json:"principal" xml:"principal"`
+	Transport  string    `json:"transport" xml:"transport"`
+	ClientAddr string    `json:"clientAddr,omitempty" xml:"clientAddr,omitempty"`
+	Timestamp  time.Time `json:"timestamp" xml:"timestamp"`
+}
+
+// GetUserHistory Operation
+type GetUserHistoryRequest struct {
+	XMLName       xml.Name `json:"-" xml:"urn:user-service GetUserHistory"`
+	ID            int      `json:"id" xml:"id"`
+	AfterSequence uint64   `json:"afterSequence" xml:"afterSequence"`
+	Limit         int      `json:"limit" xml:"limit"`
+}
+
+type GetUserHistoryResponse struct {
+	XMLName xml.Name     `json:"-" xml:"urn:user-service GetUserHistoryResponse"`
+	Entries []AuditEntry `json:"entries" xml:"Entry"`
+	// NextSequence is the afterSequence value to request the next page with;
+	// it is zero when there are no more entries.
+	NextSequence uint64 `json:"nextSequence,omitempty" xml:"nextSequence,omitempty"`
+}
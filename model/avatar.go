@@ -0,0 +1,42 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Avatar describes a user's profile picture. The image itself is stored
+// apart from this metadata and returned only by GetUserAvatar.
+type Avatar struct {
+	UserID      int    `json:"userId" xml:"userId"`
+	ContentType string `json:"contentType" xml:"contentType"`
+	Size        int    `json:"size" xml:"size"`
+	// SHA256 is the hex encoded digest of the image, usable as an ETag.
+	SHA256    string    `json:"sha256" xml:"sha256"`
+	UpdatedAt time.Time `json:"updatedAt" xml:"updatedAt"`
+}
+
+// SetUserAvatar Operation. Data should be sent as an MTOM attachment over
+// HTTP; its content type comes from the part or xmime:contentType.
+type SetUserAvatarRequest struct {
+	XMLName xml.Name   `json:"-" xml:"urn:user-service SetUserAvatar"`
+	ID      int        `json:"id" xml:"id"`
+	Data    Attachment `json:"data" xml:"data"`
+}
+
+type SetUserAvatarResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service SetUserAvatarResponse"`
+	Avatar  Avatar   `json:"avatar" xml:"Avatar"`
+}
+
+// GetUserAvatar Operation
+type GetUserAvatarRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service GetUserAvatar"`
+	ID      int      `json:"id" xml:"id"`
+}
+
+type GetUserAvatarResponse struct {
+	XMLName xml.Name   `json:"-" xml:"urn:user-service GetUserAvatarResponse"`
+	Avatar  Avatar     `json:"avatar" xml:"Avatar"`
+	Data    Attachment `json:"data" xml:"data"`
+}
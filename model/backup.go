@@ -0,0 +1,25 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// BackupInfo describes a verified backup file.
+type BackupInfo struct {
+	Path          string    `xml:"path,omitempty" json:"path,omitempty"`
+	Size          int64     `xml:"size" json:"size"`
+	CreatedAt     time.Time `xml:"createdAt" json:"createdAt"`
+	SchemaVersion int       `xml:"schemaVersion" json:"schemaVersion"`
+	Users         int       `xml:"users" json:"users"`
+}
+
+// BackupDatabase Operation (admin)
+type BackupDatabaseRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service BackupDatabase"`
+}
+
+type BackupDatabaseResponse struct {
+	XMLName xml.Name   `json:"-" xml:"urn:user-service BackupDatabaseResponse"`
+	Backup  BackupInfo `json:"backup" xml:"Backup"`
+}
@@ -0,0 +1,65 @@
+package model
+
+import "encoding/xml"
+
+// Batch execution modes.
+const (
+	// BatchModeAllOrNothing runs every item in one transaction and rolls all
+	// of them back if any item fails.
+	BatchModeAllOrNothing = "allOrNothing"
+	// BatchModeContinueOnError records failing items and carries on with the
+	// rest; the successful items are committed together.
+	BatchModeContinueOnError = "continueOnError"
+)
+
+// Per-item outcomes reported in a BatchUsersResponse.
+const (
+	BatchStatusSucceeded   = "succeeded"
+	BatchStatusFailed      = "failed"
+	BatchStatusRolledBack  = "rolledBack"
+	BatchStatusNotExecuted = "notExecuted"
+)
+
+// BatchUsers Operation
+type BatchUsersRequest struct {
+	XMLName xml.Name    `json:"-" xml:"urn:user-service BatchUsers"`
+	Mode    string      `json:"mode" xml:"mode"`
+	Items   []BatchItem `json:"items" xml:"Item"`
+}
+
+// BatchItem holds exactly one sub-operation.
+type BatchItem struct {
+	Create *CreateUserRequest
+	Update *UpdateUserRequest
+	Delete *DeleteUserRequest
+}
+
+type BatchUsersResponse struct {
+	XMLName   xml.Name          `json:"-" xml:"urn:user-service BatchUsersResponse"`
+	Mode      string            `json:"mode" xml:"mode"`
+	Committed bool              `json:"committed" xml:"committed"`
+	Succeeded int               `json:"succeeded" xml:"succeeded"`
+	Failed    int               `json:"failed" xml:"failed"`
+	Results   []BatchItemResult `json:"results" xml:"Result"`
+}
+
+type BatchItemResult struct {
+	Index  int             `json:"index" xml:"index"`
+	Status string          `json:"status" xml:"status"`
+	User   *User           `json:"user,omitempty" xml:"User,omitempty"`
+	Fault  *BatchItemFault `json:"fault,omitempty" xml:"Fault,omitempty"`
+	// Warning reports a problem with a succeeded item that did not undo
+	// it, such as a verification email that could not be issued.
+	Warning string `json:"warning,omitempty" xml:"warning,omitempty"`
+}
+
+// BatchItemFault describes why a single batch item failed. It mirrors the
+// fields of a SOAP fault without failing the whole envelope.
+type BatchItemFault struct {
+	Code   string `json:"faultcode" xml:"faultcode"`
+	String string `json:"faultstring" xml:"faultstring"`
+	// ErrorKind classifies the error like the detail of a SOAP fault:
+	// InvalidArgument, Unauthenticated, PermissionDenied, NotFound,
+	// Conflict or Internal.
+	ErrorKind string `json:"errorKind" xml:"errorKind"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import "encoding/xml"
+
+// Formats understood by ImportUsers and ExportUsers.
+const (
+	BulkFormatCSV   = "csv"
+	BulkFormatJSONL = "jsonl"
+	BulkFormatXML   = "xml"
+)
+
+// ImportReport summarises an import. Records are numbered from 1 in the
+// order they appear in the input.
+type ImportReport struct {
+	DryRun    bool `json:"dryRun" xml:"dryRun"`
+	Processed int  `json:"processed" xml:"processed"`
+	Imported  int  `json:"imported" xml:"imported"`
+	Failed    int  `json:"failed" xml:"failed"`
+	// Errors lists the first failures; ErrorsTruncated is set when more
+	// records failed than are listed.
+	Errors          []ImportError `json:"errors" xml:"Error"`
+	ErrorsTruncated bool          `json:"errorsTruncated" xml:"errorsTruncated"`
+}
+
+// ImportError describes why a single record was rejected.
+type ImportError struct {
+	Record  int    `xml:"record" json:"record"`
+	ID      int    `xml:"id,omitempty" json:"id,omitempty"`
+	Email   string `xml:"email,omitempty" json:"email,omitempty"`
+	Message string `xml:"message" json:"message"`
+}
+
+// ImportUsers Operation (admin). Data holds the whole file in the given
+// format; XML data must be escaped or wrapped in CDATA.
+type ImportUsersRequest struct {
+	XMLName     xml.Name `json:"-" xml:"urn:user-service ImportUsers"`
+	Format      string   `json:"format" xml:"format"`
+	DryRun      bool     `json:"dryRun" xml:"dryRun"`
+	PreserveIDs bool     `json:"preserveIds" xml:"preserveIds"`
+	Data        string   `json:"data" xml:"data"`
+}
+
+type ImportUsersResponse struct {
+	XMLName xml.Name     `json:"-" xml:"urn:user-service ImportUsersResponse"`
+	Report  ImportReport `json:"report" xml:"Report"`
+}
+
+// ExportUsers Operation (admin)
+type ExportUsersRequest struct {
+	XMLName        xml.Name `json:"-" xml:"urn:user-service ExportUsers"`
+	Format         string   `json:"format" xml:"format"`
+	IncludeDeleted bool     `json:"includeDeleted" xml:"includeDeleted"`
+}
+
+type ExportUsersResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service ExportUsersResponse"`
+	Format  string   `json:"format" xml:"format"`
+	Count   int      `json:"count" xml:"count"`
+	Data    string   `json:"data" xml:"data"`
+}
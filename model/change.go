@@ -0,0 +1,36 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Change is an entry in the change data capture feed. Sequences increase by
+// one for every committed user mutation, across all users.
+type Change struct {
+	Sequence  uint64    `json:"sequence" xml:"sequence"`
+	Action    string    `json:"action" xml:"action"`
+	UserID    int       `json:"userId" xml:"userId"`
+	Timestamp time.Time `json:"timestamp" xml:"timestamp"`
+	// User is the record after the change. Deletes carry the tombstoned
+	// record; purges have none.
+	User *User `json:"user,omitempty" xml:"User,omitempty"`
+}
+
+// GetChanges Operation
+type GetChangesRequest struct {
+	XMLName       xml.Name `json:"-" xml:"urn:user-service GetChanges"`
+	SinceSequence uint64   `json:"sinceSequence" xml:"sinceSequence"`
+	Limit         int      `json:"limit" xml:"limit"`
+}
+
+type GetChangesResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service GetChangesResponse"`
+	Changes []Change `json:"changes" xml:"Change"`
+	// NextSequence is the sinceSequence value to request the next page
+	// with; it is zero when the consumer has caught up.
+	NextSequence uint64 `json:"nextSequence,omitempty" xml:"nextSequence,omitempty"`
+	// OldestSequence is the oldest change still retained. A consumer whose
+	// checkpoint is below OldestSequence-1 has missed changes.
+	OldestSequence uint64 `json:"oldestSequence,omitempty" xml:"oldestSequence,omitempty"`
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Credential is the stored password state of a user. It is kept apart from
+// User so that hashes never appear in user responses.
+type Credential struct {
+	UserID int `json:"userId"`
+	// Hash is an encoded Argon2id hash including its parameters and salt.
+	Hash           string     `json:"hash"`
+	FailedAttempts int        `json:"failedAttempts"`
+	LockedUntil    *time.Time `json:"lockedUntil,omitempty"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// Session is an authenticated login created by AuthenticateUser. Only a hash
+// of its token is stored.
+type Session struct {
+	UserID    int       `json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SetPassword Operation
+type SetPasswordRequest struct {
+	XMLName  xml.Name `json:"-" xml:"urn:user-service SetPassword"`
+	ID       int      `json:"id" xml:"id"`
+	Password string   `json:"password" xml:"password"`
+}
+
+type SetPasswordResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service SetPasswordResponse"`
+	Success bool     `json:"success" xml:"success"`
+	Message string   `json:"message" xml:"message"`
+}
+
+// ChangePassword Operation
+type ChangePasswordRequest struct {
+	XMLName         xml.Name `json:"-" xml:"urn:user-service ChangePassword"`
+	ID              int      `json:"id" xml:"id"`
+	CurrentPassword string   `json:"currentPassword" xml:"currentPassword"`
+	NewPassword     string   `json:"newPassword" xml:"newPassword"`
+}
+
+type ChangePasswordResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service ChangePasswordResponse"`
+	Success bool     `json:"success" xml:"success"`
+	Message string   `json:"message" xml:"message"`
+}
+
+// AuthenticateUser Operation
+type AuthenticateUserRequest struct {
+	XMLName  xml.Name `json:"-" xml:"urn:user-service AuthenticateUser"`
+	Email    string   `json:"email" xml:"email"`
+	Password string   `json:"password" xml:"password"`
+}
+
+type AuthenticateUserResponse struct {
+	XMLName   xml.Name   `json:"-" xml:"urn:user-service AuthenticateUserResponse"`
+	Success   bool       `json:"success" xml:"success"`
+	Message   string     `json:"message,omitempty" xml:"message,omitempty"`
+	Token     string     `json:"token,omitempty" xml:"token,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" xml:"expiresAt,omitempty"`
+	User      *User      `json:"user,omitempty" xml:"User,omitempty"`
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// EventingNamespace is the namespace of WS-Eventing (August 2004), which
+// is used together with AddressingNamespace.
+const EventingNamespace = "http://schemas.xmlsoap.org/ws/2004/08/eventing"
+
+// WS-Eventing URIs understood by the service.
+const (
+	// EventingDeliveryModePush is the only supported delivery mode: every
+	// notification is sent to NotifyTo as soon as it is available.
+	EventingDeliveryModePush = EventingNamespace + "/DeliveryModes/Push"
+	// EventingFilterDialectActions selects change actions (create, update,
+	// delete, restore, purge) from a space separated list.
+	EventingFilterDialectActions = "urn:user-service:eventing:actions"
+
+	// SubscriptionEndDeliveryFailure is the SubscriptionEnd status sent when
+	// notifications to a subscriber keep failing.
+	SubscriptionEndDeliveryFailure = EventingNamespace + "/DeliveryFailure"
+
+	// Actions of the messages sent to subscribers.
+	SubscriptionEndAction = EventingNamespace + "/SubscriptionEnd"
+	UserChangedAction     = "urn:user-service/UserChanged"
+)
+
+// EventSubscription is a WS-Eventing subscription to user changes. ID is
+// the wse:Identifier the subscriber presents to the subscription manager.
+// Notifications are delivered in change order; LastSequence is the last
+// change delivered to NotifyTo.
+type EventSubscription struct {
+	ID           string             `json:"id"`
+	NotifyTo     EndpointReference  `json:"notifyTo"`
+	EndTo        *EndpointReference `json:"endTo,omitempty"`
+	Actions      []string           `json:"actions,omitempty"`
+	Expires      time.Time          `json:"expires"`
+	LastSequence uint64             `json:"lastSequence"`
+	// Failures counts consecutive failed deliveries; the next attempt is
+	// not made before NextAttemptAt.
+	Failures      int       `json:"failures,omitempty"`
+	NextAttemptAt time.Time `json:"nextAttemptAt,omitempty"`
+	LastError     string    `json:"lastError,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// EventingDelivery is the wse:Delivery element of a Subscribe request.
+type EventingDelivery struct {
+	Mode     string            `json:"mode" xml:"Mode,attr"`
+	NotifyTo EndpointReference `json:"notifyTo" xml:"NotifyTo"`
+}
+
+// EventingFilter is the wse:Filter element of a Subscribe request.
+type EventingFilter struct {
+	Dialect string `json:"dialect" xml:"Dialect,attr"`
+	Value   string `json:"value" xml:",chardata"`
+}
+
+// Subscribe Operation. Expires is an xs:duration or xs:dateTime; the
+// service chooses when it is empty.
+type SubscribeRequest struct {
+	XMLName  xml.Name           `json:"-" xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Subscribe"`
+	EndTo    *EndpointReference `json:"endTo,omitempty" xml:"EndTo"`
+	Delivery EventingDelivery   `json:"delivery" xml:"Delivery"`
+	Expires  string             `json:"expires" xml:"Expires"`
+	Filter   *EventingFilter    `json:"filter,omitempty" xml:"Filter"`
+}
+
+type SubscribeResponse struct {
+	XMLName             xml.Name          `json:"-" xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing SubscribeResponse"`
+	SubscriptionManager EndpointReference `json:"subscriptionManager" xml:"SubscriptionManager"`
+	Expires             string            `json:"expires" xml:"Expires"`
+}
+
+// Renew Operation; the subscription is named by the wse:Identifier header.
+type RenewRequest struct {
+	XMLName xml.Name `json:"-" xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Renew"`
+	Expires string   `json:"expires" xml:"Expires"`
+}
+
+type RenewResponse struct {
+	XMLName xml.Name `json:"-" xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing RenewResponse"`
+	Expires string   `json:"expires" xml:"Expires"`
+}
+
+// GetStatus Operation; the subscription is named by the wse:Identifier
+// header.
+type GetStatusRequest struct {
+	XMLName xml.Name `json:"-" xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing GetStatus"`
+}
+
+type GetStatusResponse struct {
+	XMLName xml.Name `json:"-" xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing GetStatusResponse"`
+	Expires string   `json:"expires" xml:"Expires"`
+}
+
+// Unsubscribe Operation; the subscription is named by the wse:Identifier
+// header.
+type UnsubscribeRequest struct {
+	XMLName xml.Name `json:"-" xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Unsubscribe"`
+}
+
+type UnsubscribeResponse struct {
+	XMLName xml.Name `json:"-" xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing UnsubscribeResponse"`
+}
+
+// SubscriptionEnd is sent to a subscription's EndTo endpoint when the
+// service ends it for a reason other than Unsubscribe or expiry.
+type SubscriptionEnd struct {
+	XMLName             xml.Name          `json:"-" xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing SubscriptionEnd"`
+	SubscriptionManager EndpointReference `json:"subscriptionManager" xml:"SubscriptionManager"`
+	Status              string            `json:"status" xml:"Status"`
+	Reason              *EventingReason   `json:"reason,omitempty" xml:"Reason,omitempty"`
+}
+
+type EventingReason struct {
+	Lang string `json:"lang" xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Text string `json:"text" xml:",chardata"`
+}
@@ -0,0 +1,133 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+type Group struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt,omitzero"`
+}
+
+// DefaultGroupRole is given to members added without an explicit role.
+const DefaultGroupRole = "member"
+
+// Membership links a user to a group with a role. It is stored under both
+// the group and the user so either side can be listed directly.
+type Membership struct {
+	GroupID int       `json:"groupId" xml:"groupId"`
+	UserID  int       `json:"userId" xml:"userId"`
+	Role    string    `json:"role" xml:"role"`
+	AddedAt time.Time `json:"addedAt" xml:"addedAt"`
+}
+
+// CreateGroup Operation
+type CreateGroupRequest struct {
+	XMLName     xml.Name `json:"-" xml:"urn:user-service CreateGroup"`
+	Name        string   `json:"name" xml:"name"`
+	Description string   `json:"description" xml:"description"`
+}
+
+type CreateGroupResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service CreateGroupResponse"`
+	Group   Group    `json:"group" xml:"Group"`
+}
+
+// GetGroup Operation
+type GetGroupRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service GetGroup"`
+	ID      int      `json:"id" xml:"id"`
+}
+
+type GetGroupResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service GetGroupResponse"`
+	Group   Group    `json:"group" xml:"Group"`
+}
+
+// UpdateGroup Operation
+type UpdateGroupRequest struct {
+	XMLName     xml.Name `json:"-" xml:"urn:user-service UpdateGroup"`
+	ID          int      `json:"id" xml:"id"`
+	Name        string   `json:"name" xml:"name"`
+	Description string   `json:"description" xml:"description"`
+}
+
+type UpdateGroupResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service UpdateGroupResponse"`
+	Group   Group    `json:"group" xml:"Group"`
+}
+
+// DeleteGroup Operation
+type DeleteGroupRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service DeleteGroup"`
+	ID      int      `json:"id" xml:"id"`
+}
+
+type DeleteGroupResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service DeleteGroupResponse"`
+	Success bool     `json:"success" xml:"success"`
+	Message string   `json:"message" xml:"message"`
+}
+
+// AddGroupMember Operation
+type AddGroupMemberRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service AddGroupMember"`
+	GroupID int      `json:"groupId" xml:"groupId"`
+	UserID  int      `json:"userId" xml:"userId"`
+	Role    string   `json:"role" xml:"role"`
+}
+
+type AddGroupMemberResponse struct {
+	XMLName    xml.Name   `json:"-" xml:"urn:user-service AddGroupMemberResponse"`
+	Membership Membership `json:"membership" xml:"Membership"`
+}
+
+// RemoveGroupMember Operation
+type RemoveGroupMemberRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service RemoveGroupMember"`
+	GroupID int      `json:"groupId" xml:"groupId"`
+	UserID  int      `json:"userId" xml:"userId"`
+}
+
+type RemoveGroupMemberResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service RemoveGroupMemberResponse"`
+	Success bool     `json:"success" xml:"success"`
+	Message string   `json:"message" xml:"message"`
+}
+
+// ListGroupMembers Operation
+type ListGroupMembersRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service ListGroupMembers"`
+	GroupID int      `json:"groupId" xml:"groupId"`
+}
+
+type GroupMember struct {
+	User    User      `json:"user" xml:"User"`
+	Role    string    `json:"role" xml:"role"`
+	AddedAt time.Time `json:"addedAt" xml:"addedAt"`
+}
+
+type ListGroupMembersResponse struct {
+	XMLName xml.Name      `json:"-" xml:"urn:user-service ListGroupMembersResponse"`
+	Members []GroupMember `json:"members" xml:"Member"`
+}
+
+// ListUserGroups Operation
+type ListUserGroupsRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service ListUserGroups"`
+	UserID  int      `json:"userId" xml:"userId"`
+}
+
+type UserGroup struct {
+	Group   Group     `json:"group" xml:"Group"`
+	Role    string    `json:"role" xml:"role"`
+	AddedAt time.Time `json:"addedAt" xml:"addedAt"`
+}
+
+type ListUserGroupsResponse struct {
+	XMLName xml.Name    `json:"-" xml:"urn:user-service ListUserGroupsResponse"`
+	Groups  []UserGroup `json:"groups" xml:"Membership"`
+}
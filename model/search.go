@@ -0,0 +1,44 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// SearchUsers fields, match modes and the status filter value matching
+// users in any status.
+const (
+	SearchFieldName  = "name"
+	SearchFieldEmail = "email"
+	SearchFieldAny   = "any"
+
+	SearchMatchPrefix    = "prefix"
+	SearchMatchSubstring = "substring"
+
+	SearchStatusAll = "all"
+)
+
+// SearchUsers Operation
+type SearchUsersRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service SearchUsers"`
+	Query   string   `json:"query" xml:"query"`
+	// Field is "name", "email" or "any" (default).
+	Field string `json:"field" xml:"field"`
+	// Match is "prefix" (default), matching the start of any word, or
+	// "substring".
+	Match string `json:"match" xml:"match"`
+	// Status is "active" (default), "deleted" or "all".
+	Status        string     `json:"status" xml:"status"`
+	CreatedAfter  *time.Time `json:"createdAfter,omitempty" xml:"createdAfter"`
+	CreatedBefore *time.Time `json:"createdBefore,omitempty" xml:"createdBefore"`
+	Offset        int        `json:"offset" xml:"offset"`
+	Limit         int        `json:"limit" xml:"limit"`
+}
+
+type SearchUsersResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service SearchUsersResponse"`
+	Users   []User   `json:"users" xml:"User"`
+	Total   int      `json:"total" xml:"total"`
+	// NextOffset is the offset of the next page, or zero on the last page.
+	NextOffset int `json:"nextOffset,omitempty" xml:"nextOffset,omitempty"`
+}
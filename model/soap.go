@@ -3,10 +3,29 @@ package model
 import "encoding/xml"
 
 type SoapEnvelope struct {
-	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+	XMLName xml.Name    `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+	Header  *SoapHeader `xml:",omitempty"`
 	Body    SoapBody
 }
 
+// SoapHeader holds the header blocks understood by the service.
+type SoapHeader struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Header"`
+	Session *SessionHeader
+	// Identifier names the subscription in WS-Eventing Renew, GetStatus and
+	// Unsubscribe requests.
+	Identifier string `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Identifier,omitempty"`
+	// Blocks holds any other header blocks, such as WS-Addressing headers
+	// and the reference parameters of the endpoint a message is sent to.
+	Blocks []HeaderBlock `xml:",any"`
+}
+
+// SessionHeader carries the session token returned by AuthenticateUser.
+type SessionHeader struct {
+	XMLName xml.Name `xml:"urn:user-service Session"`
+	Token   string   `xml:"token"`
+}
+
 type SoapBody struct {
 	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
 	// Payload will hold the specific request or response struct (e.g., GetUserByIDRequest)
@@ -14,9 +33,17 @@ type SoapBody struct {
 }
 
 type SoapFault struct {
-	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Fault"`
-	Code    string   `xml:"faultcode"`
-	String  string   `xml:"faultstring"`
+	XMLName xml.Name     `xml:"http://schemas.xmlsoap.org/soap/envelope/ Fault"`
+	Code    string       `xml:"faultcode"`
+	String  string       `xml:"faultstring"`
+	Detail  *FaultDetail `xml:"detail,omitempty"`
+}
+
+// FaultDetail is the detail of faults caused by service errors.
+type FaultDetail struct {
+	// ErrorKind classifies the error: InvalidArgument, Unauthenticated,
+	// PermissionDenied, NotFound, Conflict or Internal.
+	ErrorKind string `xml:"urn:user-service errorKind"`
 }
 
 func NewSoapEnvelope(payload interface{}) SoapEnvelope {
@@ -34,3 +61,14 @@ func NewSoapFault(code, message string) SoapEnvelope {
 	}
 	return NewSoapEnvelope(fault)
 }
+
+// NewServiceFault returns a fault whose detail names the kind of service
+// error it reports.
+func NewServiceFault(code, message, errorKind string) SoapEnvelope {
+	fault := SoapFault{
+		Code:   code,
+		String: message,
+		Detail: &FaultDetail{ErrorKind: errorKind},
+	}
+	return NewSoapEnvelope(fault)
+}
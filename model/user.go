@@ -1,56 +1,104 @@
 package model
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"time"
+)
 
 type User struct {
 	ID    int    `json:"id"`
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	// Verified is set once the user confirms Email with VerifyEmail. It is
+	// cleared whenever the email changes.
+	Verified bool `json:"verified"`
+	// CreatedAt is set when the user is first stored.
+	CreatedAt time.Time `json:"createdAt,omitzero"`
+	// DeletedAt is the tombstone timestamp set by DeleteUser; deleted users
+	// are hidden from lookups until they are restored or purged.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
 }
 
 type GetUserByIDRequest struct {
-	XMLName xml.Name `xml:"urn:user-service GetUserByID"`
-	ID      int      `xml:"id"`
+	XMLName xml.Name `json:"-" xml:"urn:user-service GetUserByID"`
+	ID      int      `json:"id" xml:"id"`
 }
 
 type GetUserByIDResponse struct {
-	XMLName xml.Name `xml:"urn:user-service GetUserByIDResponse"`
-	User    User     `xml:"User"`
+	XMLName xml.Name `json:"-" xml:"urn:user-service GetUserByIDResponse"`
+	User    User     `json:"user" xml:"User"`
 }
 
 // CreateUser Operation
 type CreateUserRequest struct {
-	XMLName xml.Name `xml:"urn:user-service CreateUser"`
-	Name    string   `xml:"name"`
-	Email   string   `xml:"email"`
+	XMLName xml.Name `json:"-" xml:"urn:user-service CreateUser"`
+	Name    string   `json:"name" xml:"name"`
+	Email   string   `json:"email" xml:"email"`
 }
 
 type CreateUserResponse struct {
-	XMLName xml.Name `xml:"urn:user-service CreateUserResponse"`
-	User    User     `xml:"User"`
+	XMLName xml.Name `json:"-" xml:"urn:user-service CreateUserResponse"`
+	User    User     `json:"user" xml:"User"`
 }
 
 // UpdateUser Operation
 type UpdateUserRequest struct {
-	XMLName xml.Name `xml:"urn:user-service UpdateUser"`
-	ID      int      `xml:"id"`
-	Name    string   `xml:"name"`
-	Email   string   `xml:"email"`
+	XMLName xml.Name `json:"-" xml:"urn:user-service UpdateUser"`
+	ID      int      `json:"id" xml:"id"`
+	Name    string   `json:"name" xml:"name"`
+	Email   string   `json:"email" xml:"email"`
 }
 
 type UpdateUserResponse struct {
-	XMLName xml.Name `xml:"urn:user-service UpdateUserResponse"`
-	User    User     `xml:"User"`
+	XMLName xml.Name `json:"-" xml:"urn:user-service UpdateUserResponse"`
+	User    User     `json:"user" xml:"User"`
 }
 
 // DeleteUser Operation
 type DeleteUserRequest struct {
-	XMLName xml.Name `xml:"urn:user-service DeleteUser"`
-	ID      int      `xml:"id"`
+	XMLName xml.Name `json:"-" xml:"urn:user-service DeleteUser"`
+	ID      int      `json:"id" xml:"id"`
 }
 
 type DeleteUserResponse struct {
-	XMLName xml.Name `xml:"urn:user-service DeleteUserResponse"`
-	Success bool     `xml:"success"`
-	Message string   `xml:"message"`
+	XMLName xml.Name `json:"-" xml:"urn:user-service DeleteUserResponse"`
+	Success bool     `json:"success" xml:"success"`
+	Message string   `json:"message" xml:"message"`
+}
+
+// RestoreUser Operation
+type RestoreUserRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service RestoreUser"`
+	ID      int      `json:"id" xml:"id"`
+}
+
+type RestoreUserResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service RestoreUserResponse"`
+	User    User     `json:"user" xml:"User"`
+}
+
+// PurgeUser Operation
+type PurgeUserRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service PurgeUser"`
+	ID      int      `json:"id" xml:"id"`
+}
+
+type PurgeUserResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service PurgeUserResponse"`
+	Success bool     `json:"success" xml:"success"`
+	Message string   `json:"message" xml:"message"`
+}
+
+// User statuses, derived from the deletion tombstone.
+const (
+	UserStatusActive  = "active"
+	UserStatusDeleted = "deleted"
+)
+
+// Status reports whether the user is active or soft-deleted.
+func (u *User) Status() string {
+	if u.DeletedAt != nil {
+		return UserStatusDeleted
+	}
+	return UserStatusActive
 }
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// EmailVerification is an outstanding email verification token. Only a hash
+// of the token is stored; the token is valid for Email only, so changing the
+// address again invalidates it.
+type EmailVerification struct {
+	UserID    int       `json:"userId"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// VerifyEmail Operation
+type VerifyEmailRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service VerifyEmail"`
+	Token   string   `json:"token" xml:"token"`
+}
+
+type VerifyEmailResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service VerifyEmailResponse"`
+	Success bool     `json:"success" xml:"success"`
+	Message string   `json:"message" xml:"message"`
+	User    *User    `json:"user,omitempty" xml:"User,omitempty"`
+}
+
+// RequestEmailVerification Operation
+type RequestEmailVerificationRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service RequestEmailVerification"`
+	ID      int      `json:"id" xml:"id"`
+}
+
+type RequestEmailVerificationResponse struct {
+	XMLName   xml.Name   `json:"-" xml:"urn:user-service RequestEmailVerificationResponse"`
+	Success   bool       `json:"success" xml:"success"`
+	Message   string     `json:"message" xml:"message"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" xml:"expiresAt,omitempty"`
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Payload formats of webhook deliveries.
+const (
+	WebhookFormatJSON = "json"
+	WebhookFormatSOAP = "soap"
+)
+
+// Webhook is a subscription to user changes. Events lists the change actions
+// to deliver (create, update, delete, restore, purge); empty means all.
+// Secret keys the HMAC signature of every delivery and is only returned
+// when the webhook is created.
+type Webhook struct {
+	ID        int       `json:"id" xml:"id"`
+	URL       string    `json:"url" xml:"url"`
+	Events    []string  `json:"events,omitempty" xml:"event"`
+	Format    string    `json:"format" xml:"format"`
+	Secret    string    `json:"secret" xml:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt" xml:"createdAt"`
+}
+
+// WebhookDelivery is a change waiting in the outbox to be delivered to a
+// webhook, or one that was dead-lettered after too many failed attempts.
+type WebhookDelivery struct {
+	ID            uint64    `json:"id" xml:"id"`
+	WebhookID     int       `json:"webhookId" xml:"webhookId"`
+	Change        Change    `json:"change" xml:"Change"`
+	Attempts      int       `json:"attempts" xml:"attempts"`
+	NextAttemptAt time.Time `json:"nextAttemptAt" xml:"nextAttemptAt"`
+	LastError     string    `json:"lastError,omitempty" xml:"lastError,omitempty"`
+	CreatedAt     time.Time `json:"createdAt" xml:"createdAt"`
+}
+
+// WebhookPayload is the JSON body of a delivery.
+type WebhookPayload struct {
+	DeliveryID uint64 `json:"deliveryId"`
+	WebhookID  int    `json:"webhookId"`
+	Change
+}
+
+// UserChanged is the SOAP body of change notifications.
+type UserChanged struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service UserChanged"`
+	Change  Change   `json:"change" xml:"Change"`
+}
+
+// CreateWebhook Operation (admin)
+type CreateWebhookRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service CreateWebhook"`
+	URL     string   `json:"url" xml:"url"`
+	Events  []string `json:"events" xml:"event"`
+	Format  string   `json:"format" xml:"format"`
+	Secret  string   `json:"secret" xml:"secret"`
+}
+
+type CreateWebhookResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service CreateWebhookResponse"`
+	Webhook Webhook  `json:"webhook" xml:"Webhook"`
+}
+
+// ListWebhooks Operation (admin)
+type ListWebhooksRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service ListWebhooks"`
+}
+
+type ListWebhooksResponse struct {
+	XMLName  xml.Name  `json:"-" xml:"urn:user-service ListWebhooksResponse"`
+	Webhooks []Webhook `json:"webhooks" xml:"Webhook"`
+}
+
+// DeleteWebhook Operation (admin)
+type DeleteWebhookRequest struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service DeleteWebhook"`
+	ID      int      `json:"id" xml:"id"`
+}
+
+type DeleteWebhookResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service DeleteWebhookResponse"`
+	Success bool     `json:"success" xml:"success"`
+	Message string   `json:"message" xml:"message"`
+}
+
+// ListWebhookDeadLetters Operation (admin)
+type ListWebhookDeadLettersRequest struct {
+	XMLName   xml.Name `json:"-" xml:"urn:user-service ListWebhookDeadLetters"`
+	WebhookID int      `json:"webhookId" xml:"webhookId"`
+}
+
+type ListWebhookDeadLettersResponse struct {
+	XMLName    xml.Name          `json:"-" xml:"urn:user-service ListWebhookDeadLettersResponse"`
+	Deliveries []WebhookDelivery `json:"deliveries" xml:"Delivery"`
+}
+
+// RedeliverWebhook Operation (admin): moves a dead-lettered delivery back
+// into the outbox.
+type RedeliverWebhookRequest struct {
+	XMLName    xml.Name `json:"-" xml:"urn:user-service RedeliverWebhook"`
+	DeliveryID uint64   `json:"deliveryId" xml:"deliveryId"`
+}
+
+type RedeliverWebhookResponse struct {
+	XMLName xml.Name `json:"-" xml:"urn:user-service RedeliverWebhookResponse"`
+	Success bool     `json:"success" xml:"success"`
+	Message string   `json:"message" xml:"message"`
+}
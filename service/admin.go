@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+// AdminGroupName is the group whose members may call admin operations.
+const AdminGroupName = "admins"
+
+// ErrPermissionDenied is returned when the caller may not perform an
+// operation.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// requireAdmin fails unless the caller is authenticated and a member of the
+// admins group.
+func requireAdmin(ctx context.Context) error {
+	caller := CallerFromContext(ctx)
+	if caller.UserID == 0 {
+		return fmt.Errorf("%w: admin operations require an authenticated session", ErrPermissionDenied)
+	}
+
+	group, err := database.GetGroupByName(AdminGroupName)
+	if errors.Is(err, database.ErrGroupNotFound) {
+		return fmt.Errorf("%w: %s is not an admin", ErrPermissionDenied, caller.Principal)
+	}
+	if err != nil {
+		return err
+	}
+	membership, err := database.GetMembership(group.ID, caller.UserID)
+	if err != nil {
+		return err
+	}
+	if membership == nil {
+		return fmt.Errorf("%w: %s is not an admin", ErrPermissionDenied, caller.Principal)
+	}
+	return nil
+}
+
+// requireSelfOrAdmin fails unless the caller is authenticated as the user
+// with the given ID or is an admin.
+func requireSelfOrAdmin(ctx context.Context, userID int) error {
+	caller := CallerFromContext(ctx)
+	if caller.UserID == 0 {
+		return fmt.Errorf("%w: this operation requires an authenticated session", ErrPermissionDenied)
+	}
+	if caller.UserID == userID {
+		return nil
+	}
+	return requireAdmin(ctx)
+}
+
+// authorizeGroupChange guards changes to the admins group, including
+// creating it or renaming another group to take its name: only admins may
+// make them. The first admin is added from the command line with
+// GrantAdmin.
+func authorizeGroupChange(ctx context.Context, groupID int, newName string) error {
+	if strings.EqualFold(strings.TrimSpace(newName), AdminGroupName) {
+		return requireAdmin(ctx)
+	}
+
+	group, err := database.GetGroupByName(AdminGroupName)
+	if errors.Is(err, database.ErrGroupNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if group.ID != groupID {
+		return nil
+	}
+	return requireAdmin(ctx)
+}
+
+// GrantAdmin adds a user to the admins group, creating the group if needed.
+// It does no authorization: it makes the first admin, from the command
+// line, when nobody may call admin operations yet.
+func GrantAdmin(userID int) error {
+	group, err := database.GetGroupByName(AdminGroupName)
+	if errors.Is(err, database.ErrGroupNotFound) {
+		group = &model.Group{Name: AdminGroupName, Description: "Members may call admin operations"}
+		err = database.CreateGroup(group)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = database.AddGroupMember(group.ID, userID, model.DefaultGroupRole)
+	return err
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+func TestAdminGroupNeedsAdmin(t *testing.T) {
+	openTestDB(t)
+	s := &UserService{}
+	mallory := createTestUser(t, "Mallory", "mallory@example.com")
+
+	// Nobody may create the group or take its name over SOAP.
+	for name, callerID := range map[string]int{"anonymous": 0, "user": mallory.ID} {
+		ctx := callerContext(callerID)
+		if _, err := s.HandleCreateGroup(ctx, model.CreateGroupRequest{Name: " Admins "}); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: CreateGroup admins error = %v, want ErrPermissionDenied", name, err)
+		}
+	}
+	other, err := s.HandleCreateGroup(callerContext(0), model.CreateGroupRequest{Name: "staff"})
+	if err != nil {
+		t.Fatalf("CreateGroup staff: %v", err)
+	}
+	rename := model.UpdateGroupRequest{ID: other.Group.ID, Name: "admins"}
+	if _, err := s.HandleUpdateGroup(callerContext(mallory.ID), rename); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("UpdateGroup to admins error = %v, want ErrPermissionDenied", err)
+	}
+
+	// An empty admins group is no different.
+	admin := createTestUser(t, "Admin", "admin@example.com")
+	makeAdmin(t, admin.ID)
+	group, err := database.GetGroupByName(AdminGroupName)
+	if err != nil {
+		t.Fatalf("GetGroupByName: %v", err)
+	}
+	if err := database.RemoveGroupMember(group.ID, admin.ID); err != nil {
+		t.Fatalf("RemoveGroupMember: %v", err)
+	}
+	add := model.AddGroupMemberRequest{GroupID: group.ID, UserID: mallory.ID}
+	for name, callerID := range map[string]int{"anonymous": 0, "user": mallory.ID} {
+		if _, err := s.HandleAddGroupMember(callerContext(callerID), add); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: AddGroupMember to empty admins error = %v, want ErrPermissionDenied", name, err)
+		}
+	}
+
+	makeAdmin(t, admin.ID)
+	if _, err := s.HandleAddGroupMember(callerContext(admin.ID), add); err != nil {
+		t.Errorf("admin: AddGroupMember: %v", err)
+	}
+}
+
+func TestDeleteUserAuthorization(t *testing.T) {
+	openTestDB(t)
+	s := &UserService{}
+	admin := createTestUser(t, "Admin", "admin@example.com")
+	alice := createTestUser(t, "Alice", "alice@example.com")
+	bob := createTestUser(t, "Bob", "bob@example.com")
+	makeAdmin(t, admin.ID)
+
+	for name, callerID := range map[string]int{"anonymous": 0, "other": bob.ID} {
+		ctx := callerContext(callerID)
+		if _, err := s.HandleDeleteUser(ctx, model.DeleteUserRequest{ID: admin.ID}); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: DeleteUser error = %v, want ErrPermissionDenied", name, err)
+		}
+		batch := model.BatchUsersRequest{Items: []model.BatchItem{{Delete: &model.DeleteUserRequest{ID: admin.ID}}}}
+		if _, err := s.HandleBatchUsers(ctx, batch); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: BatchUsers delete error = %v, want ErrPermissionDenied", name, err)
+		}
+		if _, err := s.HandlePurgeUser(ctx, model.PurgeUserRequest{ID: admin.ID}); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: PurgeUser error = %v, want ErrPermissionDenied", name, err)
+		}
+		update := model.UpdateUserRequest{ID: admin.ID, Email: "mallory@example.com"}
+		if _, err := s.HandleUpdateUser(ctx, update); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: UpdateUser error = %v, want ErrPermissionDenied", name, err)
+		}
+		batch = model.BatchUsersRequest{Items: []model.BatchItem{{Update: &update}}}
+		if _, err := s.HandleBatchUsers(ctx, batch); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: BatchUsers update error = %v, want ErrPermissionDenied", name, err)
+		}
+	}
+	if user, err := database.GetUserByID(admin.ID); err != nil || user.Email != "admin@example.com" {
+		t.Fatalf("a denied update changed the admin: %+v, %v", user, err)
+	}
+	if err := requireAdmin(callerContext(admin.ID)); err != nil {
+		t.Fatalf("the admin lost the role to a denied request: %v", err)
+	}
+
+	if response, err := s.HandleDeleteUser(callerContext(alice.ID), model.DeleteUserRequest{ID: alice.ID}); err != nil || !response.Success {
+		t.Errorf("self: DeleteUser = %+v, %v", response, err)
+	}
+	if response, err := s.HandleDeleteUser(callerContext(admin.ID), model.DeleteUserRequest{ID: bob.ID}); err != nil || !response.Success {
+		t.Errorf("admin: DeleteUser = %+v, %v", response, err)
+	}
+
+	for name, callerID := range map[string]int{"anonymous": 0, "other": bob.ID} {
+		if _, err := s.HandleRestoreUser(callerContext(callerID), model.RestoreUserRequest{ID: alice.ID}); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: RestoreUser error = %v, want ErrPermissionDenied", name, err)
+		}
+	}
+	if _, err := s.HandleRestoreUser(callerContext(alice.ID), model.RestoreUserRequest{ID: alice.ID}); err != nil {
+		t.Errorf("self: RestoreUser: %v", err)
+	}
+	if _, err := s.HandleRestoreUser(callerContext(admin.ID), model.RestoreUserRequest{ID: bob.ID}); err != nil {
+		t.Errorf("admin: RestoreUser: %v", err)
+	}
+	if _, err := s.HandleUpdateUser(callerContext(alice.ID), model.UpdateUserRequest{ID: alice.ID, Name: "Alice B"}); err != nil {
+		t.Errorf("self: UpdateUser: %v", err)
+	}
+}
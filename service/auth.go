@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+// Defaults used when the corresponding UserService field is zero.
+const (
+	DefaultMaxFailedLogins = 5
+	DefaultLockoutDuration = 15 * time.Minute
+	DefaultSessionTTL      = 24 * time.Hour
+)
+
+// errInvalidLogin is reported for unknown emails and wrong passwords alike
+// so that callers cannot probe which accounts exist.
+var errInvalidLogin = &kindError{kind: ErrUnauthenticated, msg: "invalid email or password"}
+
+// HandleSetPassword sets a password without the current one, which only
+// admins may do. Users change their own password with ChangePassword.
+func (s *UserService) HandleSetPassword(ctx context.Context, request model.SetPasswordRequest) (model.SetPasswordResponse, error) {
+	if request.ID <= 0 {
+		return model.SetPasswordResponse{}, invalidArgument("invalid user ID")
+	}
+	if err := requireAdmin(ctx); err != nil {
+		if CallerFromContext(ctx).UserID == request.ID {
+			return model.SetPasswordResponse{}, fmt.Errorf("%w: use ChangePassword to change your own password", ErrPermissionDenied)
+		}
+		return model.SetPasswordResponse{}, err
+	}
+
+	if err := s.SetPassword(ctx, request.ID, request.Password); err != nil {
+		return model.SetPasswordResponse{}, err
+	}
+
+	response := model.SetPasswordResponse{
+		Success: true,
+		Message: fmt.Sprintf("Password set for user with ID %d", request.ID),
+	}
+	return response, nil
+}
+
+// HandleChangePassword replaces a password after checking the current one.
+// The caller must be logged in as the user or be an admin.
+func (s *UserService) HandleChangePassword(ctx context.Context, request model.ChangePasswordRequest) (model.ChangePasswordResponse, error) {
+	if request.ID <= 0 {
+		return model.ChangePasswordResponse{}, invalidArgument("invalid user ID")
+	}
+	if err := requireSelfOrAdmin(ctx, request.ID); err != nil {
+		return model.ChangePasswordResponse{}, err
+	}
+
+	if err := s.checkPassword(request.ID, request.CurrentPassword); err != nil {
+		return model.ChangePasswordResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+	if err := s.passwordPolicy().Validate(request.NewPassword); err != nil {
+		return model.ChangePasswordResponse{}, err
+	}
+
+	if err := s.storePassword(ctx, request.ID, request.NewPassword); err != nil {
+		return model.ChangePasswordResponse{}, err
+	}
+
+	response := model.ChangePasswordResponse{
+		Success: true,
+		Message: fmt.Sprintf("Password changed for user with ID %d", request.ID),
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleAuthenticateUser(ctx context.Context, request model.AuthenticateUserRequest) (model.AuthenticateUserResponse, error) {
+	if request.Email == "" || request.Password == "" {
+		return model.AuthenticateUserResponse{}, invalidArgument("email and password are required")
+	}
+
+	users, err := database.FindUsersByEmail(request.Email)
+	if err != nil {
+		return model.AuthenticateUserResponse{}, fmt.Errorf("authentication failed: %w", err)
+	}
+	if len(users) != 1 {
+		_, _ = verifyPassword(dummyHash(), request.Password)
+		return model.AuthenticateUserResponse{Success: false, Message: errInvalidLogin.Error()}, nil
+	}
+	user := users[0]
+
+	if err := s.checkPassword(user.ID, request.Password); err != nil {
+		return model.AuthenticateUserResponse{Success: false, Message: err.Error()}, nil
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		return model.AuthenticateUserResponse{}, fmt.Errorf("authentication failed: %w", err)
+	}
+	now := time.Now().UTC()
+	session := &model.Session{
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.sessionTTL()),
+	}
+	if err := database.RecordLoginSuccess(user.ID, hashToken(token), session); err != nil {
+		return model.AuthenticateUserResponse{}, fmt.Errorf("authentication failed: %w", err)
+	}
+
+	response := model.AuthenticateUserResponse{
+		Success:   true,
+		Token:     token,
+		ExpiresAt: &session.ExpiresAt,
+		User:      &user,
+	}
+	return response, nil
+}
+
+// AuthenticateCaller resolves a session token and returns caller with the
+// owning user filled in as the principal.
+func (s *UserService) AuthenticateCaller(caller model.Caller, token string) (model.Caller, error) {
+	session, err := database.GetSession(hashToken(token))
+	if err != nil {
+		return caller, unauthenticated("invalid or expired session")
+	}
+	if _, err := database.GetUserByID(session.UserID); err != nil {
+		return caller, unauthenticated("invalid or expired session")
+	}
+
+	caller.UserID = session.UserID
+	caller.Principal = fmt.Sprintf("user:%d", session.UserID)
+	return caller, nil
+}
+
+// checkPassword verifies password against the stored hash of a user,
+// enforcing and updating the failed-attempt lockout. The returned error is
+// safe to show to the caller.
+func (s *UserService) checkPassword(userID int, password string) error {
+	cred, err := database.GetCredential(userID)
+	if errors.Is(err, database.ErrNoCredential) {
+		_, _ = verifyPassword(dummyHash(), password)
+		return errInvalidLogin
+	}
+	if err != nil {
+		return fmt.Errorf("password check failed")
+	}
+
+	if cred.LockedUntil != nil && time.Now().Before(*cred.LockedUntil) {
+		return unauthenticated("account is locked until %s", cred.LockedUntil.Format(time.RFC3339))
+	}
+
+	ok, err := verifyPassword(cred.Hash, password)
+	if err != nil {
+		return fmt.Errorf("password check failed")
+	}
+	if !ok {
+		cred, err := database.RecordLoginFailure(userID, s.maxFailedLogins(), s.lockoutDuration())
+		if err == nil && cred.LockedUntil != nil && time.Now().Before(*cred.LockedUntil) {
+			return unauthenticated("too many failed attempts, account is locked until %s", cred.LockedUntil.Format(time.RFC3339))
+		}
+		return errInvalidLogin
+	}
+	return nil
+}
+
+// SetPassword checks password against the policy and stores it for a user,
+// ending the user's sessions. It does no authorization; HandleSetPassword
+// does.
+func (s *UserService) SetPassword(ctx context.Context, userID int, password string) error {
+	if err := s.passwordPolicy().Validate(password); err != nil {
+		return err
+	}
+	return s.storePassword(ctx, userID, password)
+}
+
+func (s *UserService) storePassword(ctx context.Context, userID int, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return fmt.Errorf("password hashing failed: %w", err)
+	}
+	if err := database.SetCredential(CallerFromContext(ctx), userID, hash); err != nil {
+		return fmt.Errorf("password update failed: %w", err)
+	}
+	return nil
+}
+
+func (s *UserService) passwordPolicy() PasswordPolicy {
+	if s.PasswordPolicy == (PasswordPolicy{}) {
+		return DefaultPasswordPolicy
+	}
+	return s.PasswordPolicy
+}
+
+func (s *UserService) maxFailedLogins() int {
+	if s.MaxFailedLogins <= 0 {
+		return DefaultMaxFailedLogins
+	}
+	return s.MaxFailedLogins
+}
+
+func (s *UserService) lockoutDuration() time.Duration {
+	if s.LockoutDuration <= 0 {
+		return DefaultLockoutDuration
+	}
+	return s.LockoutDuration
+}
+
+func (s *UserService) sessionTTL() time.Duration {
+	if s.SessionTTL <= 0 {
+		return DefaultSessionTTL
+	}
+	return s.SessionTTL
+}
+
+func newSessionToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken is the key a session is stored under; raw tokens are never
+// written to the database.
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+const testPassword = "CorrectHorse42"
+
+// openTestDB points database.DB at a new, migrated database for the
+// duration of t.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	if err := database.InitDB(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+}
+
+func createTestUser(t *testing.T, name, email string) *model.User {
+	t.Helper()
+	user := &model.User{Name: name, Email: email}
+	if err := database.CreateUser(model.Caller{Principal: "test"}, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+// makeAdmin adds a user to the admins group.
+func makeAdmin(t *testing.T, userID int) {
+	t.Helper()
+	if err := GrantAdmin(userID); err != nil {
+		t.Fatalf("GrantAdmin: %v", err)
+	}
+}
+
+// callerContext returns a context for requests from a logged-in user, or
+// from an anonymous caller if userID is zero.
+func callerContext(userID int) context.Context {
+	return WithCaller(context.Background(), model.Caller{UserID: userID})
+}
+
+func TestSetPasswordAuthorization(t *testing.T) {
+	openTestDB(t)
+	s := &UserService{}
+	admin := createTestUser(t, "Admin", "admin@example.com")
+	alice := createTestUser(t, "Alice", "alice@example.com")
+	bob := createTestUser(t, "Bob", "bob@example.com")
+	makeAdmin(t, admin.ID)
+
+	denied := map[string]int{
+		"anonymous": 0,
+		"self":      alice.ID,
+		"other":     bob.ID,
+	}
+	for name, callerID := range denied {
+		request := model.SetPasswordRequest{ID: alice.ID, Password: testPassword}
+		if _, err := s.HandleSetPassword(callerContext(callerID), request); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: SetPassword error = %v, want ErrPermissionDenied", name, err)
+		}
+	}
+	if _, err := database.GetCredential(alice.ID); !errors.Is(err, database.ErrNoCredential) {
+		t.Fatalf("a denied SetPassword stored a password: %v", err)
+	}
+
+	request := model.SetPasswordRequest{ID: alice.ID, Password: testPassword}
+	if _, err := s.HandleSetPassword(callerContext(admin.ID), request); err != nil {
+		t.Fatalf("admin: SetPassword: %v", err)
+	}
+	login, err := s.HandleAuthenticateUser(context.Background(), model.AuthenticateUserRequest{Email: alice.Email, Password: testPassword})
+	if err != nil || !login.Success {
+		t.Errorf("AuthenticateUser = %+v, %v after the admin set the password", login, err)
+	}
+}
+
+func TestChangePasswordAuthorization(t *testing.T) {
+	openTestDB(t)
+	s := &UserService{}
+	alice := createTestUser(t, "Alice", "alice@example.com")
+	bob := createTestUser(t, "Bob", "bob@example.com")
+	if err := s.SetPassword(context.Background(), alice.ID, testPassword); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+
+	request := model.ChangePasswordRequest{ID: alice.ID, CurrentPassword: testPassword, NewPassword: "BatteryStaple99"}
+	for name, callerID := range map[string]int{"anonymous": 0, "other": bob.ID} {
+		if _, err := s.HandleChangePassword(callerContext(callerID), request); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: ChangePassword error = %v, want ErrPermissionDenied", name, err)
+		}
+	}
+
+	response, err := s.HandleChangePassword(callerContext(alice.ID), request)
+	if err != nil || !response.Success {
+		t.Fatalf("self: ChangePassword = %+v, %v", response, err)
+	}
+
+	wrong := request
+	wrong.CurrentPassword = testPassword
+	wrong.NewPassword = "AnotherSecret77"
+	response, err = s.HandleChangePassword(callerContext(alice.ID), wrong)
+	if err != nil || response.Success {
+		t.Errorf("ChangePassword with the old password = %+v, %v, want no success", response, err)
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+// MaxAvatarSize is the largest avatar image accepted, in bytes.
+const MaxAvatarSize = 512 << 10
+
+// AvatarContentTypes are the image types accepted as avatars. Each is
+// checked against the image data, so a mislabelled file is rejected.
+var AvatarContentTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+func (s *UserService) HandleSetUserAvatar(ctx context.Context, request model.SetUserAvatarRequest) (model.SetUserAvatarResponse, error) {
+	if request.ID <= 0 {
+		return model.SetUserAvatarResponse{}, invalidArgument("invalid user ID")
+	}
+	if err := requireSelfOrAdmin(ctx, request.ID); err != nil {
+		return model.SetUserAvatarResponse{}, err
+	}
+	data := request.Data.Data
+	if len(data) == 0 {
+		return model.SetUserAvatarResponse{}, invalidArgument("avatar data is empty")
+	}
+	if len(data) > MaxAvatarSize {
+		return model.SetUserAvatarResponse{}, invalidArgument("avatar is %d bytes, the limit is %d", len(data), MaxAvatarSize)
+	}
+	contentType, err := avatarContentType(request.Data.ContentType, data)
+	if err != nil {
+		return model.SetUserAvatarResponse{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	avatar := &model.Avatar{
+		UserID:      request.ID,
+		ContentType: contentType,
+		Size:        len(data),
+		SHA256:      hex.EncodeToString(sum[:]),
+		UpdatedAt:   time.Now().UTC(),
+	}
+	if err := database.SetAvatar(CallerFromContext(ctx), avatar, data); err != nil {
+		return model.SetUserAvatarResponse{}, fmt.Errorf("avatar update failed: %w", err)
+	}
+
+	response := model.SetUserAvatarResponse{
+		Avatar: *avatar,
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleGetUserAvatar(ctx context.Context, request model.GetUserAvatarRequest) (model.GetUserAvatarResponse, error) {
+	avatar, data, err := database.GetAvatar(request.ID)
+	if err != nil {
+		return model.GetUserAvatarResponse{}, fmt.Errorf("avatar retrieval failed: %w", err)
+	}
+
+	response := model.GetUserAvatarResponse{
+		Avatar: *avatar,
+		Data:   model.Attachment{Data: data, ContentType: avatar.ContentType},
+	}
+	return response, nil
+}
+
+// avatarContentType checks the declared media type of an avatar, without
+// parameters, against AvatarContentTypes and against the type sniffed from
+// its data.
+func avatarContentType(declared string, data []byte) (string, error) {
+	if strings.TrimSpace(declared) == "" {
+		return "", invalidArgument("avatar content type is missing")
+	}
+	contentType, _, err := mime.ParseMediaType(declared)
+	if err != nil {
+		return "", invalidArgument("invalid avatar content type %q: %v", declared, err)
+	}
+	if !slices.Contains(AvatarContentTypes, contentType) {
+		return "", invalidArgument("avatar content type %s is not allowed (allowed: %s)", contentType, strings.Join(AvatarContentTypes, ", "))
+	}
+	if sniffed := http.DetectContentType(data); sniffed != contentType {
+		return "", invalidArgument("avatar data is %s, not %s", sniffed, contentType)
+	}
+	return contentType, nil
+}
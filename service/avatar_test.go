@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+func TestAvatarContentTypeKinds(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	for _, declared := range []string{"", "image/png; =broken", "text/plain", "image/gif"} {
+		if _, err := avatarContentType(declared, png); KindOf(err) != KindInvalidArgument {
+			t.Errorf("avatarContentType(%q) error = %v of kind %s, want InvalidArgument", declared, err, KindOf(err))
+		}
+	}
+	if got, err := avatarContentType("image/png; charset=binary", png); err != nil || got != "image/png" {
+		t.Errorf("avatarContentType(image/png) = %q, %v", got, err)
+	}
+}
+
+func TestSetUserAvatarAuthorization(t *testing.T) {
+	openTestDB(t)
+	s := &UserService{}
+	admin := createTestUser(t, "Admin", "admin@example.com")
+	alice := createTestUser(t, "Alice", "alice@example.com")
+	bob := createTestUser(t, "Bob", "bob@example.com")
+	makeAdmin(t, admin.ID)
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	request := model.SetUserAvatarRequest{ID: alice.ID, Data: model.Attachment{Data: png, ContentType: "image/png"}}
+	for name, callerID := range map[string]int{"anonymous": 0, "other": bob.ID} {
+		if _, err := s.HandleSetUserAvatar(callerContext(callerID), request); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: SetUserAvatar error = %v, want ErrPermissionDenied", name, err)
+		}
+	}
+	if _, _, err := database.GetAvatar(alice.ID); err == nil {
+		t.Error("a denied SetUserAvatar stored an avatar")
+	}
+
+	for name, callerID := range map[string]int{"self": alice.ID, "admin": admin.ID} {
+		if _, err := s.HandleSetUserAvatar(callerContext(callerID), request); err != nil {
+			t.Errorf("%s: SetUserAvatar: %v", name, err)
+		}
+	}
+}
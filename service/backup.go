@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+// DefaultBackupKeep is used when Backups.Keep is zero.
+const DefaultBackupKeep = 7
+
+// Backup files are named after the time they were taken, so sorting the
+// names sorts them by age.
+const (
+	backupPrefix     = "users-"
+	backupSuffix     = ".db"
+	backupTimeFormat = "20060102T150405.000Z"
+)
+
+// Backups writes verified backup files into Dir and deletes all but the Keep
+// newest.
+type Backups struct {
+	Dir  string
+	Keep int
+	mu   sync.Mutex
+}
+
+// Create takes a backup of the open database, verifies it and rotates old
+// backups out.
+func (b *Backups) Create() (model.BackupInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := os.MkdirAll(b.Dir, 0700); err != nil {
+		return model.BackupInfo{}, err
+	}
+	name := backupPrefix + time.Now().UTC().Format(backupTimeFormat) + backupSuffix
+	info, err := database.BackupToFile(filepath.Join(b.Dir, name))
+	if err != nil {
+		return info, err
+	}
+
+	if err := b.rotate(); err != nil {
+		log.Printf("Backup rotation in %s failed: %v", b.Dir, err)
+	}
+	return info, nil
+}
+
+// rotate deletes the oldest backups beyond Keep.
+func (b *Backups) rotate() error {
+	entries, err := os.ReadDir(b.Dir)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	keep := b.Keep
+	if keep <= 0 {
+		keep = DefaultBackupKeep
+	}
+	for len(names) > keep {
+		if err := os.Remove(filepath.Join(b.Dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// WriteBackup streams a consistent copy of the database to w for an admin
+// caller.
+func (s *UserService) WriteBackup(ctx context.Context, w io.Writer) (int64, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return 0, err
+	}
+	return database.Backup(w)
+}
+
+func (s *UserService) HandleBackupDatabase(ctx context.Context, request model.BackupDatabaseRequest) (model.BackupDatabaseResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return model.BackupDatabaseResponse{}, err
+	}
+	if s.Backups == nil {
+		return model.BackupDatabaseResponse{}, fmt.Errorf("no backup directory is configured")
+	}
+
+	info, err := s.Backups.Create()
+	if err != nil {
+		return model.BackupDatabaseResponse{}, fmt.Errorf("backup failed: %w", err)
+	}
+
+	response := model.BackupDatabaseResponse{
+		Backup: info,
+	}
+	return response, nil
+}
+
+// BackupJob periodically takes backups.
+type BackupJob struct {
+	Backups  *Backups
+	Interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewBackupJob creates a job that backs up into backups every interval.
+func NewBackupJob(backups *Backups, interval time.Duration) *BackupJob {
+	return &BackupJob{
+		Backups:  backups,
+		Interval: interval,
+	}
+}
+
+// Start runs the job in the background until Stop is called
+func (j *BackupJob) Start() {
+	j.stop = make(chan struct{})
+	j.done = make(chan struct{})
+
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(j.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if info, err := j.Backups.Create(); err != nil {
+					log.Printf("Scheduled backup failed: %v", err)
+				} else {
+					log.Printf("Scheduled backup written to %s (%d bytes, %d users)", info.Path, info.Size, info.Users)
+				}
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the background job and waits for a running backup to finish
+func (j *BackupJob) Stop() {
+	if j.stop != nil {
+		close(j.stop)
+		<-j.done
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+// MaxBatchSize is the largest number of items accepted in one BatchUsers call.
+const MaxBatchSize = 10000
+
+// errBatchAborted rolls back an all-or-nothing batch after an item failed.
+var errBatchAborted = errors.New("batch aborted")
+
+// HandleBatchUsers runs a list of create/update/delete sub-operations in a
+// single transaction. In all-or-nothing mode the first failing item aborts
+// and rolls back the whole batch; in continue-on-error mode failing items
+// are reported and the remaining items are still committed. An item whose
+// user was stored there is committed too, so it is reported as succeeded
+// even if no verification token could be issued for it, with a warning.
+func (s *UserService) HandleBatchUsers(ctx context.Context, request model.BatchUsersRequest) (model.BatchUsersResponse, error) {
+	mode := request.Mode
+	if mode == "" {
+		mode = model.BatchModeAllOrNothing
+	}
+	if mode != model.BatchModeAllOrNothing && mode != model.BatchModeContinueOnError {
+		return model.BatchUsersResponse{}, invalidArgument("unknown batch mode %q", request.Mode)
+	}
+	if len(request.Items) == 0 {
+		return model.BatchUsersResponse{}, invalidArgument("batch contains no items")
+	}
+	if len(request.Items) > MaxBatchSize {
+		return model.BatchUsersResponse{}, invalidArgument("batch contains %d items, the maximum is %d", len(request.Items), MaxBatchSize)
+	}
+
+	// Updates and deletes are authorized like UpdateUser and DeleteUser,
+	// before the transaction starts.
+	for _, item := range request.Items {
+		id := 0
+		switch {
+		case item.Update != nil:
+			id = item.Update.ID
+		case item.Delete != nil:
+			id = item.Delete.ID
+		}
+		if id <= 0 {
+			continue
+		}
+		if err := requireSelfOrAdmin(ctx, id); err != nil {
+			return model.BatchUsersResponse{}, err
+		}
+	}
+
+	results := make([]model.BatchItemResult, len(request.Items))
+	for i := range results {
+		results[i] = model.BatchItemResult{Index: i, Status: model.BatchStatusNotExecuted}
+	}
+
+	var pending verifications
+	err := database.Update(CallerFromContext(ctx), func(t *database.Tx) error {
+		for i, item := range request.Items {
+			user, err := s.runBatchItem(t, item, &pending)
+			if err != nil && user != nil && mode == model.BatchModeContinueOnError {
+				results[i].Warning = err.Error()
+				err = nil
+			}
+			if err != nil {
+				results[i].Status = model.BatchStatusFailed
+				results[i].Fault = &model.BatchItemFault{Code: "Server", String: err.Error(), ErrorKind: KindOf(err).String()}
+				if mode == model.BatchModeAllOrNothing {
+					return errBatchAborted
+				}
+				continue
+			}
+			results[i].Status = model.BatchStatusSucceeded
+			results[i].User = user
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchAborted) {
+		return model.BatchUsersResponse{}, fmt.Errorf("batch failed: %w", err)
+	}
+	if err == nil {
+		pending.send(ctx, s.notifier())
+	}
+
+	response := model.BatchUsersResponse{
+		Mode:      mode,
+		Committed: err == nil,
+		Results:   results,
+	}
+	for i := range results {
+		if !response.Committed && results[i].Status == model.BatchStatusSucceeded {
+			results[i].Status = model.BatchStatusRolledBack
+			results[i].User = nil
+		}
+		switch results[i].Status {
+		case model.BatchStatusSucceeded:
+			response.Succeeded++
+		case model.BatchStatusFailed:
+			response.Failed++
+		}
+	}
+	return response, nil
+}
+
+// runBatchItem executes a single batch sub-operation within t. Deleted users
+// are returned as nil. Like createUser and updateUser, it returns the stored
+// user along with the error when only issuing a verification token failed.
+func (s *UserService) runBatchItem(t *database.Tx, item model.BatchItem, pending *verifications) (*model.User, error) {
+	switch {
+	case item.Create != nil && item.Update == nil && item.Delete == nil:
+		return s.createUser(t, *item.Create, pending)
+	case item.Update != nil && item.Create == nil && item.Delete == nil:
+		return s.updateUser(t, *item.Update, pending)
+	case item.Delete != nil && item.Create == nil && item.Update == nil:
+		return nil, s.deleteUser(t, *item.Delete)
+	default:
+		return nil, invalidArgument("batch item must contain exactly one of CreateUser, UpdateUser or DeleteUser")
+	}
+}
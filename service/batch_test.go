@@ -0,0 +1,70 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+// batchOf returns a batch creating users with the given emails and updating
+// user 99, which does not exist, after the first of them.
+func batchOf(mode string, emails ...string) model.BatchUsersRequest {
+	request := model.BatchUsersRequest{Mode: mode}
+	for i, email := range emails {
+		request.Items = append(request.Items, model.BatchItem{Create: &model.CreateUserRequest{Name: email, Email: email}})
+		if i == 0 {
+			request.Items = append(request.Items, model.BatchItem{Update: &model.UpdateUserRequest{ID: 99, Name: "Nobody"}})
+		}
+	}
+	return request
+}
+
+func batchStatuses(response model.BatchUsersResponse) []string {
+	var statuses []string
+	for _, result := range response.Results {
+		statuses = append(statuses, result.Status)
+	}
+	return statuses
+}
+
+func TestBatchUsersModes(t *testing.T) {
+	openTestDB(t)
+	s := &UserService{}
+	admin := createTestUser(t, "Admin", "admin@example.com")
+	makeAdmin(t, admin.ID)
+	ctx := callerContext(admin.ID)
+
+	response, err := s.HandleBatchUsers(ctx, batchOf("", "ada@example.com", "grace@example.com"))
+	if err != nil {
+		t.Fatalf("allOrNothing: BatchUsers: %v", err)
+	}
+	want := []string{model.BatchStatusRolledBack, model.BatchStatusFailed, model.BatchStatusNotExecuted}
+	if response.Mode != model.BatchModeAllOrNothing || response.Committed || response.Succeeded != 0 || response.Failed != 1 ||
+		!slices.Equal(batchStatuses(response), want) {
+		t.Errorf("allOrNothing: BatchUsers = %+v, want statuses %v and nothing committed", response, want)
+	}
+	if users, err := database.FindUsersByEmail("ada@example.com"); err != nil || len(users) != 0 {
+		t.Errorf("allOrNothing: the rolled back user exists: %v, %v", users, err)
+	}
+
+	response, err = s.HandleBatchUsers(ctx, batchOf(model.BatchModeContinueOnError, "ada@example.com", "admin@example.com", "grace@example.com"))
+	if err != nil {
+		t.Fatalf("continueOnError: BatchUsers: %v", err)
+	}
+	want = []string{model.BatchStatusSucceeded, model.BatchStatusFailed, model.BatchStatusFailed, model.BatchStatusSucceeded}
+	if !response.Committed || response.Succeeded != 2 || response.Failed != 2 || !slices.Equal(batchStatuses(response), want) {
+		t.Errorf("continueOnError: BatchUsers = %+v, want statuses %v", response, want)
+	}
+	for i, kind := range map[int]string{1: "NotFound", 2: "Conflict"} {
+		if fault := response.Results[i].Fault; fault == nil || fault.Code != "Server" || fault.ErrorKind != kind {
+			t.Errorf("continueOnError: item %d fault = %+v, want a Server fault of kind %s", i, fault, kind)
+		}
+	}
+	for _, email := range []string{"ada@example.com", "grace@example.com"} {
+		if users, err := database.FindUsersByEmail(email); err != nil || len(users) != 1 {
+			t.Errorf("continueOnError: users with %s = %v, %v, want the committed one", email, users, err)
+		}
+	}
+}
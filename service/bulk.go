@@ -0,0 +1,267 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+const (
+	// ImportBatchSize is the number of records written per transaction, so
+	// an import never holds more than one batch in memory.
+	ImportBatchSize = 1000
+	// MaxImportErrors caps the failures listed in an ImportReport.
+	MaxImportErrors = 1000
+)
+
+// errDryRun rolls back the transactions of a dry-run import.
+var errDryRun = errors.New("dry run")
+
+// ImportOptions controls ImportUsers.
+type ImportOptions struct {
+	Format string
+	// DryRun validates and checks every record against the database
+	// without storing anything.
+	DryRun bool
+	// PreserveIDs keeps the IDs given in the input instead of assigning new
+	// ones. Records without an ID are still assigned one.
+	PreserveIDs bool
+}
+
+// ExportOptions controls ExportUsers.
+type ExportOptions struct {
+	Format         string
+	IncludeDeleted bool
+}
+
+// ImportUsers reads users from r and stores them in batches of
+// ImportBatchSize. Invalid records and records that conflict with stored
+// users are listed in the report and skipped; the rest are imported. The
+// returned error is only set when the input or the database fails, in which
+// case batches committed so far are kept.
+//
+// Imported users keep their verified flag and no verification tokens are
+// sent; use RequestEmailVerification for addresses that need confirming.
+func (s *UserService) ImportUsers(ctx context.Context, r io.Reader, opts ImportOptions) (model.ImportReport, error) {
+	report := model.ImportReport{DryRun: opts.DryRun}
+
+	reader, err := newUserReader(opts.Format, r)
+	if err != nil {
+		return report, err
+	}
+
+	// Dry-run batches are rolled back, so conflicts between records of
+	// different batches have to be tracked here.
+	var seenIDs map[int]int
+	var seenEmails map[string]int
+	if opts.DryRun {
+		seenIDs = make(map[int]int)
+		seenEmails = make(map[string]int)
+	}
+
+	fail := func(record int, user *model.User, err error) {
+		report.Failed++
+		if len(report.Errors) == MaxImportErrors {
+			report.ErrorsTruncated = true
+			return
+		}
+		e := model.ImportError{Record: record, Message: err.Error()}
+		if user != nil {
+			e.ID, e.Email = user.ID, user.Email
+		}
+		report.Errors = append(report.Errors, e)
+	}
+
+	type pendingUser struct {
+		record int
+		user   model.User
+	}
+	batch := make([]pendingUser, 0, ImportBatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		imported := 0
+		err := database.Update(CallerFromContext(ctx), func(t *database.Tx) error {
+			for i := range batch {
+				if err := t.ImportUser(&batch[i].user); err != nil {
+					fail(batch[i].record, &batch[i].user, err)
+					continue
+				}
+				imported++
+			}
+			if opts.DryRun {
+				return errDryRun
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errDryRun) {
+			return fmt.Errorf("import failed after %d records: %w", report.Processed, err)
+		}
+		report.Imported += imported
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		user, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var recErr *recordError
+		if errors.As(err, &recErr) {
+			report.Processed++
+			fail(report.Processed, nil, err)
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("reading record %d: %w", report.Processed+1, err)
+		}
+		report.Processed++
+
+		if err := validateImportedUser(user, opts.PreserveIDs); err != nil {
+			fail(report.Processed, user, err)
+			continue
+		}
+		if opts.DryRun {
+			if err := checkDuplicate(seenIDs, seenEmails, user, report.Processed); err != nil {
+				fail(report.Processed, user, err)
+				continue
+			}
+		}
+
+		batch = append(batch, pendingUser{record: report.Processed, user: *user})
+		if len(batch) == ImportBatchSize {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return report, err
+	}
+	// Records rejected by the database are only reported when their batch
+	// is written, after later records that failed validation.
+	slices.SortStableFunc(report.Errors, func(a, b model.ImportError) int {
+		return a.Record - b.Record
+	})
+	return report, nil
+}
+
+// validateImportedUser checks and normalizes a record read from an import
+// file, clearing its ID unless IDs are preserved.
+func validateImportedUser(user *model.User, preserveIDs bool) error {
+	user.Name = strings.TrimSpace(user.Name)
+	if user.Name == "" || user.Email == "" {
+		return invalidArgument("name and email are required")
+	}
+	email, err := NormalizeEmail(user.Email)
+	if err != nil {
+		return err
+	}
+	user.Email = email
+
+	if !preserveIDs {
+		user.ID = 0
+	} else if user.ID < 0 {
+		return invalidArgument("invalid user ID %d", user.ID)
+	}
+	return nil
+}
+
+// checkDuplicate reports records of a dry run that reuse an ID or active
+// email seen earlier in the same input.
+func checkDuplicate(ids map[int]int, emails map[string]int, user *model.User, record int) error {
+	if user.ID != 0 {
+		if first, ok := ids[user.ID]; ok {
+			return fmt.Errorf("user with ID %d: %w (record %d)", user.ID, database.ErrUserExists, first)
+		}
+		ids[user.ID] = record
+	}
+	if user.DeletedAt == nil {
+		key := strings.ToLower(user.Email)
+		if first, ok := emails[key]; ok {
+			return fmt.Errorf("email %s: %w (record %d)", user.Email, database.ErrEmailTaken, first)
+		}
+		emails[key] = record
+	}
+	return nil
+}
+
+// ExportUsers writes every user to w from a single consistent snapshot and
+// returns the number of users written.
+func (s *UserService) ExportUsers(ctx context.Context, w io.Writer, opts ExportOptions) (int, error) {
+	writer, err := newUserWriter(opts.Format, w)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = database.ExportUsers(opts.IncludeDeleted, func(user *model.User) error {
+		if count%ImportBatchSize == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		count++
+		return writer.Write(user)
+	})
+	if err != nil {
+		return count, fmt.Errorf("export failed: %w", err)
+	}
+	return count, writer.Close()
+}
+
+func (s *UserService) HandleImportUsers(ctx context.Context, request model.ImportUsersRequest) (model.ImportUsersResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return model.ImportUsersResponse{}, err
+	}
+
+	report, err := s.ImportUsers(ctx, strings.NewReader(request.Data), ImportOptions{
+		Format:      request.Format,
+		DryRun:      request.DryRun,
+		PreserveIDs: request.PreserveIDs,
+	})
+	if err != nil {
+		return model.ImportUsersResponse{}, err
+	}
+
+	response := model.ImportUsersResponse{
+		Report: report,
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleExportUsers(ctx context.Context, request model.ExportUsersRequest) (model.ExportUsersResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return model.ExportUsersResponse{}, err
+	}
+
+	var buf bytes.Buffer
+	count, err := s.ExportUsers(ctx, &buf, ExportOptions{
+		Format:         request.Format,
+		IncludeDeleted: request.IncludeDeleted,
+	})
+	if err != nil {
+		return model.ExportUsersResponse{}, err
+	}
+
+	response := model.ExportUsersResponse{
+		Format: request.Format,
+		Count:  count,
+		Data:   buf.String(),
+	}
+	return response, nil
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// maxJSONLineSize bounds a single JSON Lines record.
+const maxJSONLineSize = 1 << 20
+
+// csvColumns is the header written by CSV exports. Imports accept the
+// columns in any order, ignoring case and unknown columns; name and email
+// are required.
+var csvColumns = []string{"id", "name", "email", "verified", "createdAt", "deletedAt"}
+
+// userReader reads the records of an import file one at a time.
+type userReader interface {
+	// Next returns the next record, or io.EOF at the end of the input. A
+	// *recordError concerns only the current record and reading can go on;
+	// any other error is fatal.
+	Next() (*model.User, error)
+}
+
+// userWriter writes the records of an export file. Close writes whatever
+// trailer the format needs; it does not close the underlying writer.
+type userWriter interface {
+	Write(user *model.User) error
+	Close() error
+}
+
+// recordError reports a record that could not be decoded.
+type recordError struct {
+	err error
+}
+
+func (e *recordError) Error() string { return e.err.Error() }
+func (e *recordError) Unwrap() error { return e.err }
+
+func newUserReader(format string, r io.Reader) (userReader, error) {
+	switch format {
+	case model.BulkFormatCSV:
+		return newCSVUserReader(r)
+	case model.BulkFormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxJSONLineSize)
+		return &jsonlUserReader{scanner: scanner}, nil
+	case model.BulkFormatXML:
+		return &xmlUserReader{decoder: xml.NewDecoder(r)}, nil
+	default:
+		return nil, invalidArgument("unknown format %q", format)
+	}
+}
+
+func newUserWriter(format string, w io.Writer) (userWriter, error) {
+	switch format {
+	case model.BulkFormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvColumns); err != nil {
+			return nil, err
+		}
+		return &csvUserWriter{writer: cw}, nil
+	case model.BulkFormatJSONL:
+		return &jsonlUserWriter{encoder: json.NewEncoder(w)}, nil
+	case model.BulkFormatXML:
+		return newXMLUserWriter(w)
+	default:
+		return nil, invalidArgument("unknown format %q", format)
+	}
+}
+
+type csvUserReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVUserReader(r io.Reader) (*csvUserReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, invalidArgument("CSV input has no header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "email"} {
+		if _, ok := columns[required]; !ok {
+			return nil, invalidArgument("CSV header has no %q column", required)
+		}
+	}
+	return &csvUserReader{reader: reader, columns: columns}, nil
+}
+
+func (r *csvUserReader) Next() (*model.User, error) {
+	row, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, &recordError{err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	field := func(name string) string {
+		if i, ok := r.columns[strings.ToLower(name)]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	user := &model.User{
+		Name:  field("name"),
+		Email: field("email"),
+	}
+	if v := field("id"); v != "" {
+		if user.ID, err = strconv.Atoi(v); err != nil {
+			return nil, &recordError{fmt.Errorf("invalid id %q", v)}
+		}
+	}
+	if v := field("verified"); v != "" {
+		if user.Verified, err = strconv.ParseBool(v); err != nil {
+			return nil, &recordError{fmt.Errorf("invalid verified flag %q", v)}
+		}
+	}
+	if v := field("createdAt"); v != "" {
+		if user.CreatedAt, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return nil, &recordError{fmt.Errorf("invalid createdAt %q", v)}
+		}
+	}
+	if v := field("deletedAt"); v != "" {
+		deletedAt, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, &recordError{fmt.Errorf("invalid deletedAt %q", v)}
+		}
+		user.DeletedAt = &deletedAt
+	}
+	return user, nil
+}
+
+type csvUserWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvUserWriter) Write(user *model.User) error {
+	var deletedAt string
+	if user.DeletedAt != nil {
+		deletedAt = user.DeletedAt.Format(time.RFC3339Nano)
+	}
+	return w.writer.Write([]string{
+		strconv.Itoa(user.ID),
+		user.Name,
+		user.Email,
+		strconv.FormatBool(user.Verified),
+		user.CreatedAt.Format(time.RFC3339Nano),
+		deletedAt,
+	})
+}
+
+func (w *csvUserWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// jsonlUserReader reads one JSON encoded model.User per line, skipping
+// blank lines.
+type jsonlUserReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *jsonlUserReader) Next() (*model.User, error) {
+	for r.scanner.Scan() {
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+		var user model.User
+		if err := json.Unmarshal([]byte(line), &user); err != nil {
+			return nil, &recordError{fmt.Errorf("invalid JSON: %v", err)}
+		}
+		return &user, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+type jsonlUserWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlUserWriter) Write(user *model.User) error {
+	return w.encoder.Encode(user)
+}
+
+func (w *jsonlUserWriter) Close() error {
+	return nil
+}
+
+// xmlUserReader reads every User element in a document, in the same form
+// as User elements in SOAP responses, wherever they appear. XML syntax
+// errors are fatal because the decoder cannot resynchronise after them.
+type xmlUserReader struct {
+	decoder *xml.Decoder
+}
+
+func (r *xmlUserReader) Next() (*model.User, error) {
+	for {
+		tok, err := r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "User" {
+			continue
+		}
+
+		var user model.User
+		if err := r.decoder.DecodeElement(&user, &start); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+}
+
+// xmlUsersName is the root element of XML exports.
+var xmlUsersName = xml.Name{Space: "urn:user-service", Local: "Users"}
+
+type xmlUserWriter struct {
+	w       io.Writer
+	encoder *xml.Encoder
+}
+
+func newXMLUserWriter(w io.Writer) (*xmlUserWriter, error) {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return nil, err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.EncodeToken(xml.StartElement{Name: xmlUsersName}); err != nil {
+		return nil, err
+	}
+	return &xmlUserWriter{w: w, encoder: encoder}, nil
+}
+
+func (w *xmlUserWriter) Write(user *model.User) error {
+	return w.encoder.Encode(user)
+}
+
+func (w *xmlUserWriter) Close() error {
+	if err := w.encoder.EncodeToken(xml.EndElement{Name: xmlUsersName}); err != nil {
+		return err
+	}
+	if err := w.encoder.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.w, "\n")
+	return err
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+// Page sizes for GetChanges and StreamChanges.
+const (
+	DefaultChangesPageSize = 100
+	MaxChangesPageSize     = 1000
+)
+
+// ChangeHeartbeat is how often StreamChanges reports an idle feed, so that
+// proxies and clients can tell a quiet stream from a dead connection.
+const ChangeHeartbeat = 30 * time.Second
+
+func (s *UserService) HandleGetChanges(ctx context.Context, request model.GetChangesRequest) (model.GetChangesResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return model.GetChangesResponse{}, err
+	}
+
+	limit := request.Limit
+	if limit <= 0 {
+		limit = DefaultChangesPageSize
+	}
+	if limit > MaxChangesPageSize {
+		limit = MaxChangesPageSize
+	}
+
+	changes, more, oldest, err := database.GetChanges(request.SinceSequence, limit)
+	if err != nil {
+		return model.GetChangesResponse{}, fmt.Errorf("change retrieval failed: %w", err)
+	}
+
+	response := model.GetChangesResponse{
+		Changes:        changes,
+		OldestSequence: oldest,
+	}
+	if more {
+		response.NextSequence = changes[len(changes)-1].Sequence
+	}
+	return response, nil
+}
+
+// StreamChanges calls emit with every change after sinceSequence, in order
+// and in pages of up to MaxChangesPageSize, then keeps waiting for new
+// changes until ctx is done or emit fails. While the feed is idle emit is
+// called with no changes every ChangeHeartbeat, and once right away if
+// there are no changes yet, so emit is always called once the stream has
+// started. Like GetChanges it needs an admin: other callers get an error
+// matching ErrPermissionDenied and emit is never called.
+func (s *UserService) StreamChanges(ctx context.Context, sinceSequence uint64, emit func(changes []model.Change) error) error {
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+
+	heartbeat := time.NewTicker(ChangeHeartbeat)
+	defer heartbeat.Stop()
+
+	for started := false; ; started = true {
+		committed := database.ChangesCommitted()
+		changes, more, _, err := database.GetChanges(sinceSequence, MaxChangesPageSize)
+		if err != nil {
+			return fmt.Errorf("change retrieval failed: %w", err)
+		}
+		if len(changes) > 0 || !started {
+			if err := emit(changes); err != nil {
+				return err
+			}
+		}
+		if len(changes) > 0 {
+			sinceSequence = changes[len(changes)-1].Sequence
+			heartbeat.Reset(ChangeHeartbeat)
+		}
+		if more {
+			continue
+		}
+
+		select {
+		case <-committed:
+		case <-heartbeat.C:
+			if err := emit(nil); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+func TestGetChangesNeedsAdmin(t *testing.T) {
+	openTestDB(t)
+	s := &UserService{}
+	admin := createTestUser(t, "Admin", "admin@example.com")
+	alice := createTestUser(t, "Alice", "alice@example.com")
+	makeAdmin(t, admin.ID)
+
+	for name, callerID := range map[string]int{"anonymous": 0, "user": alice.ID} {
+		ctx := callerContext(callerID)
+		if _, err := s.HandleGetChanges(ctx, model.GetChangesRequest{}); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: GetChanges error = %v, want ErrPermissionDenied", name, err)
+		}
+		emitted := false
+		err := s.StreamChanges(ctx, 0, func([]model.Change) error {
+			emitted = true
+			return nil
+		})
+		if !errors.Is(err, ErrPermissionDenied) || emitted {
+			t.Errorf("%s: StreamChanges error = %v, emitted = %v, want ErrPermissionDenied before any change", name, err, emitted)
+		}
+	}
+
+	response, err := s.HandleGetChanges(callerContext(admin.ID), model.GetChangesRequest{})
+	if err != nil {
+		t.Fatalf("admin: GetChanges: %v", err)
+	}
+	if len(response.Changes) < 2 {
+		t.Errorf("admin: GetChanges returned %d changes, want the two creates", len(response.Changes))
+	}
+
+	// The stream starts with the backlog and ends with its context.
+	ctx, cancel := context.WithCancel(callerContext(admin.ID))
+	var streamed []model.Change
+	err = s.StreamChanges(ctx, 0, func(changes []model.Change) error {
+		streamed = append(streamed, changes...)
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || len(streamed) != len(response.Changes) {
+		t.Errorf("admin: StreamChanges = %d changes, %v, want %d, context.Canceled", len(streamed), err, len(response.Changes))
+	}
+}
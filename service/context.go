@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// AnonymousPrincipal is recorded for callers that did not identify themselves.
+const AnonymousPrincipal = "anonymous"
+
+type callerKey struct{}
+
+// WithCaller returns a copy of ctx carrying the caller of the current request.
+func WithCaller(ctx context.Context, caller model.Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller stored by WithCaller. Missing fields
+// default to an anonymous principal.
+func CallerFromContext(ctx context.Context) model.Caller {
+	caller, _ := ctx.Value(callerKey{}).(model.Caller)
+	if caller.Principal == "" {
+		caller.Principal = AnonymousPrincipal
+	}
+	return caller
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+	"golang.org/x/net/idna"
+)
+
+// DefaultVerificationTTL is used when UserService.VerificationTTL is zero.
+const DefaultVerificationTTL = 48 * time.Hour
+
+// NormalizeEmail checks that raw is a plain RFC 5322 addr-spec (no display
+// name or angle brackets) and returns it trimmed, with the domain lowercased
+// and converted to its ASCII (punycode) form. The local part is kept as is.
+func NormalizeEmail(raw string) (string, error) {
+	email := strings.TrimSpace(raw)
+	if email == "" {
+		return "", invalidArgument("email is required")
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || strings.ContainsAny(email, "<>") {
+		return "", invalidArgument("invalid email address %q", raw)
+	}
+
+	at := strings.LastIndex(addr.Address, "@")
+	local, domain := addr.Address[:at], addr.Address[at+1:]
+	if len(local) > 64 {
+		return "", invalidArgument("invalid email address %q: local part is longer than 64 characters", raw)
+	}
+	if strings.HasPrefix(domain, "[") {
+		return "", invalidArgument("invalid email address %q: address literals are not accepted", raw)
+	}
+
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", invalidArgument("invalid email address %q: %v", raw, err)
+	}
+	if !strings.Contains(ascii, ".") {
+		return "", invalidArgument("invalid email address %q: domain must be fully qualified", raw)
+	}
+
+	normalized := local + "@" + strings.ToLower(ascii)
+	if len(normalized) > 254 {
+		return "", invalidArgument("invalid email address %q: longer than 254 characters", raw)
+	}
+	return normalized, nil
+}
+
+func (s *UserService) HandleVerifyEmail(ctx context.Context, request model.VerifyEmailRequest) (model.VerifyEmailResponse, error) {
+	if request.Token == "" {
+		return model.VerifyEmailResponse{}, invalidArgument("token is required")
+	}
+
+	user, err := database.VerifyEmail(CallerFromContext(ctx), hashToken(request.Token))
+	if errors.Is(err, database.ErrVerificationNotFound) ||
+		errors.Is(err, database.ErrVerificationExpired) ||
+		errors.Is(err, database.ErrVerificationStale) ||
+		errors.Is(err, database.ErrUserNotFound) {
+		return model.VerifyEmailResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+	if err != nil {
+		return model.VerifyEmailResponse{}, fmt.Errorf("email verification failed: %w", err)
+	}
+
+	response := model.VerifyEmailResponse{
+		Success: true,
+		Message: fmt.Sprintf("Email %s verified", user.Email),
+		User:    user,
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleRequestEmailVerification(ctx context.Context, request model.RequestEmailVerificationRequest) (model.RequestEmailVerificationResponse, error) {
+	if request.ID <= 0 {
+		return model.RequestEmailVerificationResponse{}, invalidArgument("invalid user ID")
+	}
+
+	var pending verifications
+	err := database.Update(CallerFromContext(ctx), func(t *database.Tx) error {
+		user, err := t.GetUser(request.ID)
+		if err != nil {
+			return err
+		}
+		if user.Verified {
+			return nil
+		}
+		return s.issueVerification(t, *user, &pending)
+	})
+	if err != nil {
+		return model.RequestEmailVerificationResponse{}, fmt.Errorf("verification request failed: %w", err)
+	}
+
+	if len(pending) == 0 {
+		return model.RequestEmailVerificationResponse{
+			Success: false,
+			Message: fmt.Sprintf("Email of user with ID %d is already verified", request.ID),
+		}, nil
+	}
+	pending.send(ctx, s.notifier())
+
+	response := model.RequestEmailVerificationResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("Verification sent to %s", pending[0].user.Email),
+		ExpiresAt: &pending[0].expiresAt,
+	}
+	return response, nil
+}
+
+// verification is a token issued inside a transaction that still has to be
+// sent to its user.
+type verification struct {
+	user      model.User
+	token     string
+	expiresAt time.Time
+}
+
+// verifications collects tokens to send once the transaction that stored
+// them has committed, so users never receive a token that was rolled back.
+type verifications []verification
+
+func (v verifications) send(ctx context.Context, notifier Notifier) {
+	for _, pending := range v {
+		if err := notifier.SendEmailVerification(ctx, pending.user, pending.token, pending.expiresAt); err != nil {
+			log.Printf("Failed to send email verification to user %d: %v", pending.user.ID, err)
+		}
+	}
+}
+
+// issueVerification stores a new verification token for user's current
+// email within t and queues it on pending.
+func (s *UserService) issueVerification(t *database.Tx, user model.User, pending *verifications) error {
+	token, err := newSessionToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	v := &model.EmailVerification{
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.verificationTTL()),
+	}
+	if err := t.CreateEmailVerification(hashToken(token), v); err != nil {
+		return err
+	}
+
+	*pending = append(*pending, verification{user: user, token: token, expiresAt: v.ExpiresAt})
+	return nil
+}
+
+func (s *UserService) notifier() Notifier {
+	if s.Notifier == nil {
+		return LogNotifier{}
+	}
+	return s.Notifier
+}
+
+func (s *UserService) verificationTTL() time.Duration {
+	if s.VerificationTTL <= 0 {
+		return DefaultVerificationTTL
+	}
+	return s.VerificationTTL
+}
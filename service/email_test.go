@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// tokenNotifier records the last verification token sent to each user.
+type tokenNotifier map[int]string
+
+func (n tokenNotifier) SendEmailVerification(ctx context.Context, user model.User, token string, expiresAt time.Time) error {
+	n[user.ID] = token
+	return nil
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	valid := map[string]string{
+		" Ada@Example.COM ":  "Ada@example.com",
+		"ada@bücher.example": "ada@xn--bcher-kva.example",
+	}
+	for raw, want := range valid {
+		if got, err := NormalizeEmail(raw); err != nil || got != want {
+			t.Errorf("NormalizeEmail(%q) = %q, %v, want %q", raw, got, err, want)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"ada",
+		"Ada <ada@example.com>",
+		"<ada@example.com>",
+		"ada@localhost",
+		"ada@[127.0.0.1]",
+		"a1234567890123456789012345678901234567890123456789012345678901234@example.com",
+	}
+	for _, raw := range invalid {
+		if got, err := NormalizeEmail(raw); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("NormalizeEmail(%q) = %q, %v, want an invalid argument", raw, got, err)
+		}
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	openTestDB(t)
+	notifier := tokenNotifier{}
+	s := &UserService{Notifier: notifier}
+	admin := createTestUser(t, "Admin", "admin@example.com")
+	makeAdmin(t, admin.ID)
+	ctx := callerContext(admin.ID)
+
+	created, err := s.HandleCreateUser(ctx, model.CreateUserRequest{Name: "Ada", Email: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	first := notifier[created.User.ID]
+	if first == "" || created.User.Verified {
+		t.Fatalf("CreateUser sent token %q for user %+v, want a token for an unverified user", first, created.User)
+	}
+
+	// Changing the email makes the token sent for the old address stale.
+	if _, err := s.HandleUpdateUser(ctx, model.UpdateUserRequest{ID: created.User.ID, Name: "Ada", Email: "ada@example.org"}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	second := notifier[created.User.ID]
+	if second == first {
+		t.Fatal("UpdateUser did not send a new token")
+	}
+	if response, err := s.HandleVerifyEmail(ctx, model.VerifyEmailRequest{Token: first}); err != nil || response.Success {
+		t.Errorf("VerifyEmail(old token) = %+v, %v, want no success", response, err)
+	}
+
+	response, err := s.HandleVerifyEmail(ctx, model.VerifyEmailRequest{Token: second})
+	if err != nil || !response.Success || !response.User.Verified || response.User.Email != "ada@example.org" {
+		t.Errorf("VerifyEmail = %+v, %v, want ada@example.org verified", response, err)
+	}
+	if response, err := s.HandleVerifyEmail(ctx, model.VerifyEmailRequest{Token: second}); err != nil || response.Success {
+		t.Errorf("VerifyEmail(used token) = %+v, %v, want no success", response, err)
+	}
+
+	request, err := s.HandleRequestEmailVerification(ctx, model.RequestEmailVerificationRequest{ID: created.User.ID})
+	if err != nil || request.Success {
+		t.Errorf("RequestEmailVerification(verified) = %+v, %v, want no success", request, err)
+	}
+	if _, err := s.HandleVerifyEmail(ctx, model.VerifyEmailRequest{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("VerifyEmail without token error = %v, want an invalid argument", err)
+	}
+}
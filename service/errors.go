@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/maasumiyaat/soap/database"
+)
+
+// Sentinels matched by errors.Is on service errors of the corresponding
+// kind. The errors themselves keep their own messages.
+var (
+	// ErrInvalidArgument marks requests that are malformed or fail
+	// validation; retrying them unchanged cannot succeed.
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrUnauthenticated marks requests whose credentials or session were
+	// not accepted.
+	ErrUnauthenticated = errors.New("unauthenticated")
+)
+
+// ErrorKind classifies a service error so that each transport can map it
+// to its own status: an HTTP status, a SOAP fault code and so on.
+type ErrorKind int
+
+const (
+	// KindInternal is a failure of the service rather than the request.
+	KindInternal ErrorKind = iota
+	KindInvalidArgument
+	KindUnauthenticated
+	KindPermissionDenied
+	KindNotFound
+	// KindConflict is a request that clashes with the stored state, such
+	// as an email address already in use.
+	KindConflict
+)
+
+var kindNames = [...]string{
+	KindInternal:         "Internal",
+	KindInvalidArgument:  "InvalidArgument",
+	KindUnauthenticated:  "Unauthenticated",
+	KindPermissionDenied: "PermissionDenied",
+	KindNotFound:         "NotFound",
+	KindConflict:         "Conflict",
+}
+
+// String returns the name of k, such as "NotFound", as sent in the detail
+// of SOAP faults.
+func (k ErrorKind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return fmt.Sprintf("ErrorKind(%d)", int(k))
+	}
+	return kindNames[k]
+}
+
+// KindOf returns the kind of an error returned by a UserService method.
+func KindOf(err error) ErrorKind {
+	switch {
+	case errors.Is(err, ErrInvalidArgument),
+		errors.Is(err, ErrInvalidExpirationTime),
+		errors.Is(err, database.ErrInvalidBackup),
+		errors.Is(err, database.ErrVerificationExpired),
+		errors.Is(err, database.ErrVerificationStale):
+		return KindInvalidArgument
+	case errors.Is(err, ErrUnauthenticated):
+		return KindUnauthenticated
+	case errors.Is(err, ErrPermissionDenied):
+		return KindPermissionDenied
+	case errors.Is(err, database.ErrUserNotFound),
+		errors.Is(err, database.ErrGroupNotFound),
+		errors.Is(err, database.ErrMembershipNotFound),
+		errors.Is(err, database.ErrAvatarNotFound),
+		errors.Is(err, database.ErrVerificationNotFound),
+		errors.Is(err, database.ErrWebhookNotFound),
+		errors.Is(err, database.ErrDeliveryNotFound),
+		errors.Is(err, database.ErrSubscriptionNotFound):
+		return KindNotFound
+	case errors.Is(err, database.ErrEmailTaken),
+		errors.Is(err, database.ErrUserExists),
+		errors.Is(err, database.ErrUserNotDeleted),
+		errors.Is(err, database.ErrGroupNameTaken):
+		return KindConflict
+	}
+	return KindInternal
+}
+
+// kindError is an error of a given kind whose message is shown as is, so
+// classifying an error does not change what callers see.
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func (e *kindError) Error() string { return e.msg }
+
+func (e *kindError) Is(target error) bool { return target == e.kind }
+
+// invalidArgument formats an error matching ErrInvalidArgument.
+func invalidArgument(format string, args ...any) error {
+	return &kindError{kind: ErrInvalidArgument, msg: fmt.Sprintf(format, args...)}
+}
+
+// unauthenticated formats an error matching ErrUnauthenticated.
+func unauthenticated(format string, args ...any) error {
+	return &kindError{kind: ErrUnauthenticated, msg: fmt.Sprintf(format, args...)}
+}
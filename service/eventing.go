@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+// Subscription lifetimes. Subscribers asking for longer are granted
+// MaxSubscriptionExpiry and must renew.
+const (
+	DefaultSubscriptionExpiry = time.Hour
+	MaxSubscriptionExpiry     = 24 * time.Hour
+)
+
+// ErrInvalidExpirationTime is returned for wse:Expires values that are
+// malformed or already past.
+var ErrInvalidExpirationTime = errors.New("invalid expiration time")
+
+// HandleSubscribe creates a WS-Eventing subscription delivering UserChanged
+// notifications to the NotifyTo endpoint. Subscribing is an admin
+// operation; the returned identifier is all that is needed to renew, query
+// or cancel the subscription.
+func (s *UserService) HandleSubscribe(ctx context.Context, request model.SubscribeRequest) (model.SubscribeResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return model.SubscribeResponse{}, err
+	}
+
+	mode := strings.TrimSpace(request.Delivery.Mode)
+	if mode != "" && mode != model.EventingDeliveryModePush {
+		return model.SubscribeResponse{}, invalidArgument("delivery mode %s is not supported, only %s", mode, model.EventingDeliveryModePush)
+	}
+	notifyTo := request.Delivery.NotifyTo
+	if err := checkNotificationAddress(&notifyTo); err != nil {
+		return model.SubscribeResponse{}, fmt.Errorf("NotifyTo: %w", err)
+	}
+	if request.EndTo != nil {
+		if err := checkNotificationAddress(request.EndTo); err != nil {
+			return model.SubscribeResponse{}, fmt.Errorf("EndTo: %w", err)
+		}
+	}
+
+	actions, err := filterActions(request.Filter)
+	if err != nil {
+		return model.SubscribeResponse{}, err
+	}
+
+	now := time.Now().UTC()
+	expires, asDuration, err := subscriptionExpiry(request.Expires, now)
+	if err != nil {
+		return model.SubscribeResponse{}, err
+	}
+
+	id, err := NewMessageID()
+	if err != nil {
+		return model.SubscribeResponse{}, fmt.Errorf("subscription failed: %w", err)
+	}
+	sub := &model.EventSubscription{
+		ID:       id,
+		NotifyTo: notifyTo,
+		EndTo:    request.EndTo,
+		Actions:  actions,
+		Expires:  expires,
+	}
+	if err := database.CreateEventSubscription(sub); err != nil {
+		return model.SubscribeResponse{}, fmt.Errorf("subscription failed: %w", err)
+	}
+
+	response := model.SubscribeResponse{
+		SubscriptionManager: SubscriptionManager(s.EventingAddress, id),
+		Expires:             formatExpires(expires, now, asDuration),
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleRenew(ctx context.Context, identifier string, request model.RenewRequest) (model.RenewResponse, error) {
+	if _, err := activeSubscription(identifier); err != nil {
+		return model.RenewResponse{}, err
+	}
+
+	now := time.Now().UTC()
+	expires, asDuration, err := subscriptionExpiry(request.Expires, now)
+	if err != nil {
+		return model.RenewResponse{}, err
+	}
+	if err := database.RenewEventSubscription(identifier, expires); err != nil {
+		return model.RenewResponse{}, fmt.Errorf("renewal failed: %w", err)
+	}
+
+	response := model.RenewResponse{
+		Expires: formatExpires(expires, now, asDuration),
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleGetStatus(ctx context.Context, identifier string, request model.GetStatusRequest) (model.GetStatusResponse, error) {
+	sub, err := activeSubscription(identifier)
+	if err != nil {
+		return model.GetStatusResponse{}, err
+	}
+
+	response := model.GetStatusResponse{
+		Expires: sub.Expires.UTC().Format(time.RFC3339),
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleUnsubscribe(ctx context.Context, identifier string, request model.UnsubscribeRequest) (model.UnsubscribeResponse, error) {
+	if _, err := activeSubscription(identifier); err != nil {
+		return model.UnsubscribeResponse{}, err
+	}
+	if _, err := database.DeleteEventSubscription(identifier); err != nil {
+		return model.UnsubscribeResponse{}, fmt.Errorf("unsubscribe failed: %w", err)
+	}
+	return model.UnsubscribeResponse{}, nil
+}
+
+// SubscriptionManager returns the endpoint reference of the subscription
+// manager at address for the subscription id, which subscribers send
+// Renew, GetStatus and Unsubscribe requests to.
+func SubscriptionManager(address, id string) model.EndpointReference {
+	return model.EndpointReference{
+		Address: address,
+		ReferenceParameters: &model.ReferenceBlocks{
+			Blocks: []model.HeaderBlock{{
+				XMLName: xml.Name{Space: model.EventingNamespace, Local: "Identifier"},
+				Value:   id,
+			}},
+		},
+	}
+}
+
+// activeSubscription returns the subscription named by a wse:Identifier
+// header. Expired subscriptions are treated as unknown.
+func activeSubscription(identifier string) (*model.EventSubscription, error) {
+	identifier = strings.TrimSpace(identifier)
+	if identifier == "" {
+		return nil, invalidArgument("missing wse:Identifier header")
+	}
+	sub, err := database.GetEventSubscription(identifier)
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(sub.Expires) {
+		return nil, fmt.Errorf("subscription %s: %w", identifier, database.ErrSubscriptionNotFound)
+	}
+	return sub, nil
+}
+
+// checkNotificationAddress normalizes the address of an endpoint that
+// notifications are sent to: an absolute http or https URL, or a
+// soap.udp://host:port address.
+func checkNotificationAddress(epr *model.EndpointReference) error {
+	target, err := url.Parse(strings.TrimSpace(epr.Address))
+	if err != nil || target.Host == "" {
+		return invalidArgument("address %q is not an absolute URL", epr.Address)
+	}
+	switch target.Scheme {
+	case "http", "https":
+	case "soap.udp":
+		if target.Port() == "" {
+			return invalidArgument("address %q has no port", epr.Address)
+		}
+	default:
+		return invalidArgument("address %q must use http, https or soap.udp", epr.Address)
+	}
+	epr.Address = target.String()
+	return nil
+}
+
+// filterActions returns the change actions selected by a Subscribe filter;
+// none means every action.
+func filterActions(filter *model.EventingFilter) ([]string, error) {
+	if filter == nil {
+		return nil, nil
+	}
+	if filter.Dialect != model.EventingFilterDialectActions {
+		return nil, invalidArgument("filter dialect %q is not supported, only %s", filter.Dialect, model.EventingFilterDialectActions)
+	}
+
+	var actions []string
+	for _, action := range strings.Fields(filter.Value) {
+		action = strings.ToLower(action)
+		if !slices.Contains(changeActions, action) {
+			return nil, invalidArgument("unknown change action %q (known: %s)", action, strings.Join(changeActions, ", "))
+		}
+		if !slices.Contains(actions, action) {
+			actions = append(actions, action)
+		}
+	}
+	return actions, nil
+}
+
+// subscriptionExpiry resolves a requested wse:Expires value, an xs:duration
+// or an xs:dateTime, to the expiry granted at now, capped at
+// MaxSubscriptionExpiry. It also reports whether a duration was requested,
+// so the response can answer in kind.
+func subscriptionExpiry(requested string, now time.Time) (time.Time, bool, error) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		return now.Add(DefaultSubscriptionExpiry), true, nil
+	}
+
+	if strings.HasPrefix(requested, "P") {
+		d, err := parseXSDuration(requested)
+		if err != nil || d <= 0 {
+			return time.Time{}, false, fmt.Errorf("%w: %q", ErrInvalidExpirationTime, requested)
+		}
+		return now.Add(min(d, MaxSubscriptionExpiry)), true, nil
+	}
+
+	expires, err := time.Parse(time.RFC3339Nano, requested)
+	if err != nil {
+		// xs:dateTime allows omitting the time zone; take it as UTC.
+		expires, err = time.Parse("2006-01-02T15:04:05.999999999", requested)
+	}
+	if err != nil || !expires.After(now) {
+		return time.Time{}, false, fmt.Errorf("%w: %q", ErrInvalidExpirationTime, requested)
+	}
+	return minTime(expires.UTC(), now.Add(MaxSubscriptionExpiry)), false, nil
+}
+
+// formatExpires renders an expiry as an xs:duration from now or as an
+// xs:dateTime.
+func formatExpires(expires, now time.Time, asDuration bool) string {
+	if !asDuration {
+		return expires.UTC().Format(time.RFC3339)
+	}
+
+	d := expires.Sub(now).Round(time.Second)
+	out := "PT"
+	if h := d / time.Hour; h > 0 {
+		out += strconv.Itoa(int(h)) + "H"
+	}
+	if m := d % time.Hour / time.Minute; m > 0 {
+		out += strconv.Itoa(int(m)) + "M"
+	}
+	if sec := d % time.Minute / time.Second; sec > 0 || out == "PT" {
+		out += strconv.Itoa(int(sec)) + "S"
+	}
+	return out
+}
+
+var xsDurationPattern = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseXSDuration parses a non-negative xs:duration. Years and months are
+// taken as 365 and 30 days.
+func parseXSDuration(s string) (time.Duration, error) {
+	m := xsDurationPattern.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, invalidArgument("invalid duration %q", s)
+	}
+
+	units := []time.Duration{365 * 24 * time.Hour, 30 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var total float64
+	for i, unit := range units {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return 0, err
+		}
+		total += n * float64(unit)
+	}
+	if total >= math.MaxInt64 {
+		return 0, invalidArgument("duration %q is too long", s)
+	}
+	return time.Duration(total), nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
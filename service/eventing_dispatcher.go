@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+// Defaults for EventingDispatcher fields left at zero.
+const (
+	DefaultEventingMaxAttempts = 10
+	DefaultEventingBaseDelay   = 5 * time.Second
+	DefaultEventingMaxDelay    = 30 * time.Minute
+)
+
+// eventingPollInterval is how often subscriptions waiting on a retry are
+// checked; new changes wake the dispatcher straight away.
+const eventingPollInterval = time.Second
+
+// EventingDispatcher sends UserChanged notifications to WS-Eventing
+// subscribers. Each subscription receives the changes it selected in
+// order, one message per change, over HTTP or SOAP-over-UDP. A failed
+// notification is retried with exponential backoff; after MaxAttempts
+// consecutive failures the subscription is ended with a SubscriptionEnd
+// message to its EndTo endpoint.
+//
+// Subscriptions survive restarts, so stopping the dispatcher does not end
+// them; delivery resumes where it left off.
+type EventingDispatcher struct {
+	// ManagerAddress is the subscription manager address quoted in
+	// SubscriptionEnd messages.
+	ManagerAddress string
+	Sender         *MessageSender
+	MaxAttempts    int
+	BaseDelay      time.Duration
+	MaxDelay       time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+
+	mu       sync.Mutex
+	inFlight map[string]bool
+}
+
+// NewEventingDispatcher creates a dispatcher with the default settings.
+func NewEventingDispatcher(managerAddress string) *EventingDispatcher {
+	return &EventingDispatcher{
+		ManagerAddress: managerAddress,
+		Sender:         NewMessageSender(),
+		MaxAttempts:    DefaultEventingMaxAttempts,
+		BaseDelay:      DefaultEventingBaseDelay,
+		MaxDelay:       DefaultEventingMaxDelay,
+	}
+}
+
+// Start runs the dispatcher in the background until Stop is called
+func (d *EventingDispatcher) Start() {
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	d.inFlight = make(map[string]bool)
+
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(eventingPollInterval)
+		defer ticker.Stop()
+
+		var wg sync.WaitGroup
+		defer wg.Wait()
+
+		for {
+			committed := database.ChangesCommitted()
+			for _, sub := range d.pending() {
+				d.setInFlight(sub.ID, true)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer d.setInFlight(sub.ID, false)
+					d.drain(sub)
+				}()
+			}
+
+			select {
+			case <-committed:
+			case <-ticker.C:
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the dispatcher and waits for running notifications to finish
+func (d *EventingDispatcher) Stop() {
+	if d.stop != nil {
+		close(d.stop)
+		<-d.done
+	}
+}
+
+// pending returns the live subscriptions that have changes to deliver and
+// are not waiting for a retry or already being delivered to.
+func (d *EventingDispatcher) pending() []model.EventSubscription {
+	subs, err := database.ListEventSubscriptions()
+	if err != nil {
+		log.Printf("Reading event subscriptions failed: %v", err)
+		return nil
+	}
+	latest, err := database.LatestChangeSequence()
+	if err != nil {
+		log.Printf("Reading change log failed: %v", err)
+		return nil
+	}
+
+	now := time.Now()
+	var due []model.EventSubscription
+	for _, sub := range subs {
+		if sub.LastSequence >= latest || !now.Before(sub.Expires) || sub.NextAttemptAt.After(now) || d.isInFlight(sub.ID) {
+			continue
+		}
+		due = append(due, sub)
+	}
+	return due
+}
+
+func (d *EventingDispatcher) isInFlight(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.inFlight[id]
+}
+
+func (d *EventingDispatcher) setInFlight(id string, running bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if running {
+		d.inFlight[id] = true
+	} else {
+		delete(d.inFlight, id)
+	}
+}
+
+// drain notifies a subscriber of every change after its last delivered one,
+// stopping at the first failure.
+func (d *EventingDispatcher) drain(sub model.EventSubscription) {
+	for {
+		changes, more, _, err := database.GetChanges(sub.LastSequence, DefaultChangesPageSize)
+		if err != nil {
+			log.Printf("Reading change log for subscription %s failed: %v", sub.ID, err)
+			return
+		}
+
+		delivered := sub.LastSequence
+		var sendErr error
+		for _, change := range changes {
+			if len(sub.Actions) == 0 || slices.Contains(sub.Actions, change.Action) {
+				sendErr = d.send(sub.NotifyTo, model.UserChangedAction, model.UserChanged{Change: change})
+				if sendErr != nil {
+					break
+				}
+			}
+			delivered = change.Sequence
+		}
+
+		updated, err := d.record(sub, delivered, sendErr)
+		if err != nil {
+			log.Printf("Recording delivery to subscription %s failed: %v", sub.ID, err)
+			return
+		}
+		if updated == nil || sendErr != nil || !more {
+			return
+		}
+		sub = *updated
+
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+	}
+}
+
+// record stores the progress of a subscription and ends it once it has
+// failed MaxAttempts times in a row.
+func (d *EventingDispatcher) record(sub model.EventSubscription, delivered uint64, sendErr error) (*model.EventSubscription, error) {
+	next := time.Now().Add(d.backoff(sub.Failures + 1))
+	updated, err := database.RecordEventDelivery(sub.ID, delivered, sendErr, next.UTC())
+	if err != nil || updated == nil || sendErr == nil {
+		return updated, err
+	}
+
+	if updated.Failures < d.MaxAttempts {
+		log.Printf("Notification to subscription %s failed (attempt %d), retrying at %s: %v",
+			sub.ID, updated.Failures, next.Format(time.RFC3339), sendErr)
+		return updated, nil
+	}
+
+	log.Printf("Ending subscription %s after %d failed notifications: %v", sub.ID, updated.Failures, sendErr)
+	if _, err := database.DeleteEventSubscription(sub.ID); err != nil {
+		return updated, err
+	}
+	d.end(updated, model.SubscriptionEndDeliveryFailure,
+		fmt.Sprintf("Notifications to %s failed %d times: %v", sub.NotifyTo.Address, updated.Failures, sendErr))
+	return nil, nil
+}
+
+// end tells the subscriber that the service ended a subscription, if it
+// asked to be told.
+func (d *EventingDispatcher) end(sub *model.EventSubscription, status, reason string) {
+	if sub.EndTo == nil {
+		return
+	}
+	message := model.SubscriptionEnd{
+		SubscriptionManager: SubscriptionManager(d.ManagerAddress, sub.ID),
+		Status:              status,
+		Reason:              &model.EventingReason{Lang: "en", Text: reason},
+	}
+	if err := d.send(*sub.EndTo, model.SubscriptionEndAction, message); err != nil {
+		log.Printf("Sending SubscriptionEnd for %s to %s failed: %v", sub.ID, sub.EndTo.Address, err)
+	}
+}
+
+// send delivers a one-way message to an endpoint, addressed with
+// WS-Addressing headers and the endpoint's reference parameters.
+func (d *EventingDispatcher) send(to model.EndpointReference, action string, payload any) error {
+	messageID, err := NewMessageID()
+	if err != nil {
+		return err
+	}
+	addressing := model.MessageAddressing{
+		Namespace: model.AddressingNamespace,
+		To:        to.Address,
+		Action:    action,
+		MessageID: messageID,
+	}
+	env := model.NewSoapEnvelope(payload)
+	env.Header = &model.SoapHeader{
+		Blocks: append(addressing.HeaderBlocks(), to.HeaderBlocks()...),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-d.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return d.Sender.Send(ctx, to.Address, action, env)
+}
+
+// backoff returns the delay before the next attempt: BaseDelay doubled for
+// every failed attempt, capped at MaxDelay.
+func (d *EventingDispatcher) backoff(attempts int) time.Duration {
+	delay := d.BaseDelay
+	for i := 1; i < attempts && delay < d.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.MaxDelay)
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+func TestSubscriptionExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		requested string
+		want      time.Time
+		formatted string
+	}{
+		{"", now.Add(DefaultSubscriptionExpiry), "PT1H"},
+		{"PT90M", now.Add(90 * time.Minute), "PT1H30M"},
+		{"PT0.5S", now.Add(500 * time.Millisecond), "PT1S"},
+		{"P2D", now.Add(MaxSubscriptionExpiry), "PT24H"},
+		{"2026-01-02T05:04:05Z", now.Add(2 * time.Hour), "2026-01-02T05:04:05Z"},
+		{"2026-01-02T05:04:05", now.Add(2 * time.Hour), "2026-01-02T05:04:05Z"},
+		{"2026-02-01T00:00:00+01:00", now.Add(MaxSubscriptionExpiry), "2026-01-03T03:04:05Z"},
+	}
+	for _, test := range tests {
+		expires, asDuration, err := subscriptionExpiry(test.requested, now)
+		if err != nil || !expires.Equal(test.want) {
+			t.Errorf("subscriptionExpiry(%q) = %s, %v, want %s", test.requested, expires, err, test.want)
+			continue
+		}
+		if got := formatExpires(expires, now, asDuration); got != test.formatted {
+			t.Errorf("formatExpires for %q = %s, want %s", test.requested, got, test.formatted)
+		}
+	}
+
+	for _, requested := range []string{"P", "PT", "PT0S", "-PT1H", "1h", "2026-01-01T00:00:00Z"} {
+		if _, _, err := subscriptionExpiry(requested, now); !errors.Is(err, ErrInvalidExpirationTime) {
+			t.Errorf("subscriptionExpiry(%q) error = %v, want ErrInvalidExpirationTime", requested, err)
+		}
+	}
+}
+
+func TestSubscriptionLifecycle(t *testing.T) {
+	openTestDB(t)
+	s := &UserService{EventingAddress: "http://localhost:8180/soap/user"}
+	admin := createTestUser(t, "Admin", "admin@example.com")
+	user := createTestUser(t, "Alice", "alice@example.com")
+	makeAdmin(t, admin.ID)
+	ctx := callerContext(admin.ID)
+	subscribe := model.SubscribeRequest{
+		Delivery: model.EventingDelivery{NotifyTo: model.EndpointReference{Address: "http://localhost:9000/notify"}},
+		Expires:  "PT10M",
+		Filter:   &model.EventingFilter{Dialect: model.EventingFilterDialectActions, Value: "create Delete create"},
+	}
+
+	if _, err := s.HandleSubscribe(callerContext(user.ID), subscribe); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Subscribe as a user error = %v, want ErrPermissionDenied", err)
+	}
+	invalid := map[string]func(r *model.SubscribeRequest){
+		"mode":    func(r *model.SubscribeRequest) { r.Delivery.Mode = "urn:pull" },
+		"address": func(r *model.SubscribeRequest) { r.Delivery.NotifyTo.Address = "ftp://localhost/notify" },
+		"udp":     func(r *model.SubscribeRequest) { r.Delivery.NotifyTo.Address = "soap.udp://localhost" },
+		"dialect": func(r *model.SubscribeRequest) {
+			r.Filter = &model.EventingFilter{Dialect: "urn:xpath", Value: "create"}
+		},
+		"action": func(r *model.SubscribeRequest) {
+			r.Filter = &model.EventingFilter{Dialect: model.EventingFilterDialectActions, Value: "rename"}
+		},
+	}
+	for name, change := range invalid {
+		request := subscribe
+		change(&request)
+		if _, err := s.HandleSubscribe(ctx, request); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: Subscribe error = %v, want ErrInvalidArgument", name, err)
+		}
+	}
+
+	response, err := s.HandleSubscribe(ctx, subscribe)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if response.Expires != "PT10M" || response.SubscriptionManager.Address != s.EventingAddress {
+		t.Errorf("Subscribe = %+v, want an expiry of PT10M at %s", response, s.EventingAddress)
+	}
+	blocks := response.SubscriptionManager.ReferenceParameters.Blocks
+	if len(blocks) != 1 || blocks[0].XMLName.Local != "Identifier" {
+		t.Fatalf("reference parameters = %+v, want a wse:Identifier", blocks)
+	}
+	id := blocks[0].Value
+	if sub, err := database.GetEventSubscription(id); err != nil || strings.Join(sub.Actions, " ") != "create delete" {
+		t.Errorf("stored subscription = %+v, %v, want actions create and delete", sub, err)
+	}
+
+	if renewed, err := s.HandleRenew(ctx, id, model.RenewRequest{Expires: "PT20M"}); err != nil || renewed.Expires != "PT20M" {
+		t.Errorf("Renew = %+v, %v, want PT20M", renewed, err)
+	}
+	status, err := s.HandleGetStatus(ctx, id, model.GetStatusRequest{})
+	if expires, _ := time.Parse(time.RFC3339, status.Expires); err != nil || time.Until(expires) < 19*time.Minute {
+		t.Errorf("GetStatus = %+v, %v, want about 20 minutes left", status, err)
+	}
+	if _, err := s.HandleUnsubscribe(ctx, id, model.UnsubscribeRequest{}); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	if _, err := s.HandleGetStatus(ctx, id, model.GetStatusRequest{}); !errors.Is(err, database.ErrSubscriptionNotFound) {
+		t.Errorf("GetStatus after Unsubscribe error = %v, want ErrSubscriptionNotFound", err)
+	}
+	if _, err := s.HandleRenew(ctx, "", model.RenewRequest{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Renew without identifier error = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestEventingDispatcher(t *testing.T) {
+	openTestDB(t)
+	s := &UserService{EventingAddress: "http://localhost:8180/soap/user"}
+	admin := createTestUser(t, "Admin", "admin@example.com")
+	makeAdmin(t, admin.ID)
+	ctx := callerContext(admin.ID)
+	receiver := newWebhookReceiver(t)
+
+	_, err := s.HandleSubscribe(ctx, model.SubscribeRequest{
+		Delivery: model.EventingDelivery{NotifyTo: model.EndpointReference{Address: receiver.URL + "/notify"}},
+		EndTo:    &model.EndpointReference{Address: receiver.URL + "/end"},
+		Filter:   &model.EventingFilter{Dialect: model.EventingFilterDialectActions, Value: "delete"},
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	d := NewEventingDispatcher(s.EventingAddress)
+	d.MaxAttempts = 2
+	d.BaseDelay = time.Millisecond
+	d.MaxDelay = time.Millisecond
+	d.Start()
+	t.Cleanup(d.Stop)
+
+	// Only the delete passes the filter.
+	created, err := s.HandleCreateUser(ctx, model.CreateUserRequest{Name: "Ada", Email: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := s.HandleDeleteUser(ctx, model.DeleteUserRequest{ID: created.User.ID}); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	req := receiver.next(t)
+	var envelope struct {
+		Body struct {
+			UserChanged model.UserChanged
+		}
+	}
+	if err := xml.Unmarshal(req.body, &envelope); err != nil {
+		t.Fatalf("notification: %v", err)
+	}
+	if change := envelope.Body.UserChanged.Change; req.path != "/notify" || change.Action != model.AuditActionDelete || change.UserID != created.User.ID {
+		t.Errorf("notification to %s = %+v, want the delete of user %d", req.path, change, created.User.ID)
+	}
+	if !strings.Contains(string(req.body), model.UserChangedAction) {
+		t.Errorf("notification has no %s action header:\n%s", model.UserChangedAction, req.body)
+	}
+
+	// A subscriber that keeps failing is ended and told so at EndTo.
+	receiver.status.Store(http.StatusInternalServerError)
+	if _, err := s.HandleRestoreUser(ctx, model.RestoreUserRequest{ID: created.User.ID}); err != nil {
+		t.Fatalf("RestoreUser: %v", err)
+	}
+	if _, err := s.HandleDeleteUser(ctx, model.DeleteUserRequest{ID: created.User.ID}); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	for range d.MaxAttempts {
+		if req := receiver.next(t); req.path != "/notify" {
+			t.Fatalf("delivery to %s, want a retried notification", req.path)
+		}
+	}
+	req = receiver.next(t)
+	var end struct {
+		Body struct {
+			SubscriptionEnd model.SubscriptionEnd
+		}
+	}
+	if err := xml.Unmarshal(req.body, &end); err != nil {
+		t.Fatalf("SubscriptionEnd: %v", err)
+	}
+	if req.path != "/end" || end.Body.SubscriptionEnd.Status != model.SubscriptionEndDeliveryFailure {
+		t.Errorf("message to %s = %+v, want a DeliveryFailure SubscriptionEnd", req.path, end.Body.SubscriptionEnd)
+	}
+	if subs, err := database.ListEventSubscriptions(); err != nil || len(subs) != 0 {
+		t.Errorf("subscriptions = %+v, %v, want none", subs, err)
+	}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+func (s *UserService) HandleCreateGroup(ctx context.Context, request model.CreateGroupRequest) (model.CreateGroupResponse, error) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return model.CreateGroupResponse{}, invalidArgument("group name is required")
+	}
+	if err := authorizeGroupChange(ctx, 0, name); err != nil {
+		return model.CreateGroupResponse{}, err
+	}
+
+	group := &model.Group{
+		Name:        name,
+		Description: request.Description,
+	}
+	if err := database.CreateGroup(group); err != nil {
+		return model.CreateGroupResponse{}, fmt.Errorf("group creation failed: %w", err)
+	}
+
+	response := model.CreateGroupResponse{
+		Group: *group,
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleGetGroup(ctx context.Context, request model.GetGroupRequest) (model.GetGroupResponse, error) {
+	group, err := database.GetGroupByID(request.ID)
+	if err != nil {
+		return model.GetGroupResponse{}, fmt.Errorf("group retrieval failed: %w", err)
+	}
+
+	response := model.GetGroupResponse{
+		Group: *group,
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleUpdateGroup(ctx context.Context, request model.UpdateGroupRequest) (model.UpdateGroupResponse, error) {
+	if request.ID <= 0 {
+		return model.UpdateGroupResponse{}, invalidArgument("invalid group ID")
+	}
+	if err := authorizeGroupChange(ctx, request.ID, request.Name); err != nil {
+		return model.UpdateGroupResponse{}, err
+	}
+
+	group, err := database.UpdateGroup(request.ID, func(group *model.Group) error {
+		if name := strings.TrimSpace(request.Name); name != "" {
+			group.Name = name
+		}
+		if request.Description != "" {
+			group.Description = request.Description
+		}
+		return nil
+	})
+	if err != nil {
+		return model.UpdateGroupResponse{}, fmt.Errorf("group update failed: %w", err)
+	}
+
+	response := model.UpdateGroupResponse{
+		Group: *group,
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleDeleteGroup(ctx context.Context, request model.DeleteGroupRequest) (model.DeleteGroupResponse, error) {
+	if request.ID <= 0 {
+		return model.DeleteGroupResponse{}, invalidArgument("invalid group ID")
+	}
+	if err := authorizeGroupChange(ctx, request.ID, ""); err != nil {
+		return model.DeleteGroupResponse{}, err
+	}
+
+	err := database.DeleteGroup(request.ID)
+	if errors.Is(err, database.ErrGroupNotFound) {
+		return model.DeleteGroupResponse{
+			Success: false,
+			Message: fmt.Sprintf("Group with ID %d not found", request.ID),
+		}, nil
+	}
+	if err != nil {
+		return model.DeleteGroupResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to delete group: %v", err),
+		}, nil
+	}
+
+	response := model.DeleteGroupResponse{
+		Success: true,
+		Message: fmt.Sprintf("Group with ID %d deleted successfully", request.ID),
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleAddGroupMember(ctx context.Context, request model.AddGroupMemberRequest) (model.AddGroupMemberResponse, error) {
+	if request.GroupID <= 0 || request.UserID <= 0 {
+		return model.AddGroupMemberResponse{}, invalidArgument("invalid group or user ID")
+	}
+	if err := authorizeGroupChange(ctx, request.GroupID, ""); err != nil {
+		return model.AddGroupMemberResponse{}, err
+	}
+	role := strings.TrimSpace(request.Role)
+	if role == "" {
+		role = model.DefaultGroupRole
+	}
+
+	membership, err := database.AddGroupMember(request.GroupID, request.UserID, role)
+	if err != nil {
+		return model.AddGroupMemberResponse{}, fmt.Errorf("adding group member failed: %w", err)
+	}
+
+	response := model.AddGroupMemberResponse{
+		Membership: *membership,
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleRemoveGroupMember(ctx context.Context, request model.RemoveGroupMemberRequest) (model.RemoveGroupMemberResponse, error) {
+	if request.GroupID <= 0 || request.UserID <= 0 {
+		return model.RemoveGroupMemberResponse{}, invalidArgument("invalid group or user ID")
+	}
+	if err := authorizeGroupChange(ctx, request.GroupID, ""); err != nil {
+		return model.RemoveGroupMemberResponse{}, err
+	}
+
+	err := database.RemoveGroupMember(request.GroupID, request.UserID)
+	if errors.Is(err, database.ErrGroupNotFound) || errors.Is(err, database.ErrMembershipNotFound) {
+		return model.RemoveGroupMemberResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+	if err != nil {
+		return model.RemoveGroupMemberResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to remove group member: %v", err),
+		}, nil
+	}
+
+	response := model.RemoveGroupMemberResponse{
+		Success: true,
+		Message: fmt.Sprintf("User with ID %d removed from group with ID %d", request.UserID, request.GroupID),
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleListGroupMembers(ctx context.Context, request model.ListGroupMembersRequest) (model.ListGroupMembersResponse, error) {
+	members, err := database.ListGroupMembers(request.GroupID)
+	if err != nil {
+		return model.ListGroupMembersResponse{}, fmt.Errorf("listing group members failed: %w", err)
+	}
+
+	response := model.ListGroupMembersResponse{
+		Members: members,
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleListUserGroups(ctx context.Context, request model.ListUserGroupsRequest) (model.ListUserGroupsResponse, error) {
+	groups, err := database.ListUserGroups(request.UserID)
+	if err != nil {
+		return model.ListUserGroupsResponse{}, fmt.Errorf("listing user groups failed: %w", err)
+	}
+
+	response := model.ListUserGroupsResponse{
+		Groups: groups,
+	}
+	return response, nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+func TestGetUserHistory(t *testing.T) {
+	openTestDB(t)
+	s := &UserService{}
+	admin := createTestUser(t, "Admin", "admin@example.com")
+	alice := createTestUser(t, "Alice", "alice@example.com")
+	bob := createTestUser(t, "Bob", "bob@example.com")
+	makeAdmin(t, admin.ID)
+
+	// Alice's history: the create and four renames.
+	for _, name := range []string{"Alice 1", "Alice 2", "Alice 3", "Alice 4"} {
+		if _, err := s.HandleUpdateUser(callerContext(alice.ID), model.UpdateUserRequest{ID: alice.ID, Name: name}); err != nil {
+			t.Fatalf("UpdateUser: %v", err)
+		}
+	}
+
+	request := model.GetUserHistoryRequest{ID: alice.ID}
+	for name, callerID := range map[string]int{"anonymous": 0, "other": bob.ID} {
+		if _, err := s.HandleGetUserHistory(callerContext(callerID), request); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: GetUserHistory error = %v, want ErrPermissionDenied", name, err)
+		}
+	}
+	if _, err := s.HandleGetUserHistory(callerContext(admin.ID), request); err != nil {
+		t.Errorf("admin: GetUserHistory: %v", err)
+	}
+
+	// Pages of two, following NextSequence until it is zero.
+	var entries []model.AuditEntry
+	request.Limit = 2
+	for pages := 0; ; pages++ {
+		if pages == 3 {
+			t.Fatalf("still paging after %d pages", pages)
+		}
+		response, err := s.HandleGetUserHistory(callerContext(alice.ID), request)
+		if err != nil {
+			t.Fatalf("self: GetUserHistory: %v", err)
+		}
+		entries = append(entries, response.Entries...)
+		if response.NextSequence == 0 {
+			break
+		}
+		if len(response.Entries) != 2 || response.NextSequence != response.Entries[1].Sequence {
+			t.Fatalf("page %d = %+v, want two entries ending at NextSequence", pages, response)
+		}
+		request.AfterSequence = response.NextSequence
+	}
+
+	if len(entries) != 5 {
+		t.Fatalf("got %d entries, want 5", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.UserID != alice.ID || (i > 0 && entry.Sequence <= entries[i-1].Sequence) {
+			t.Errorf("entry %d = %+v, want Alice's entries in order", i, entry)
+		}
+	}
+	if entries[4].After == nil || entries[4].After.Name != "Alice 4" {
+		t.Errorf("last entry = %+v, want the rename to Alice 4", entries[4])
+	}
+}
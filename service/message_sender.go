@@ -0,0 +1,101 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// DefaultSendTimeout bounds a single one-way message sent by MessageSender.
+const DefaultSendTimeout = 10 * time.Second
+
+// MessageSender delivers one-way SOAP messages to WS-Addressing endpoints:
+// http and https addresses are POSTed to, soap.udp://host:port addresses
+// get a single datagram.
+type MessageSender struct {
+	Client *http.Client
+}
+
+// NewMessageSender creates a sender with the default timeout.
+func NewMessageSender() *MessageSender {
+	return &MessageSender{
+		Client: &http.Client{Timeout: DefaultSendTimeout},
+	}
+}
+
+// Send delivers env to address. For HTTP any 2xx response counts as
+// delivered; UDP delivery is not acknowledged, so only local errors are
+// reported.
+func (m *MessageSender) Send(ctx context.Context, address, action string, env model.SoapEnvelope) error {
+	body, err := xml.Marshal(env)
+	if err != nil {
+		return err
+	}
+	body = append([]byte(xml.Header), body...)
+
+	target, err := url.Parse(address)
+	if err != nil {
+		return err
+	}
+	switch target.Scheme {
+	case "http", "https":
+		return m.post(ctx, address, action, body)
+	case "soap.udp":
+		return sendDatagram(ctx, target.Host, body)
+	default:
+		return fmt.Errorf("cannot send to %q: unsupported scheme", address)
+	}
+}
+
+func (m *MessageSender) post(ctx context.Context, address, action string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", `"`+action+`"`)
+
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded %s", address, resp.Status)
+	}
+	return nil
+}
+
+func sendDatagram(ctx context.Context, hostport string, body []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", hostport)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(body)
+	return err
+}
+
+// NewMessageID returns a random UUID URI for use as a wsa:MessageID or
+// other unique identifier.
+func NewMessageID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	buf[6] = buf[6]&0x0f | 0x40
+	buf[8] = buf[8]&0x3f | 0x80
+	return fmt.Sprintf("uuid:%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:]), nil
+}
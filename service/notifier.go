@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/maasumiyaat/soap/model"
+)
+
+// Notifier delivers verification tokens to users. Real deployments plug in
+// an email sender; LogNotifier and FileNotifier are stand-ins for
+// development and testing.
+type Notifier interface {
+	SendEmailVerification(ctx context.Context, user model.User, token string, expiresAt time.Time) error
+}
+
+// LogNotifier writes verification tokens to the server log.
+type LogNotifier struct{}
+
+func (LogNotifier) SendEmailVerification(ctx context.Context, user model.User, token string, expiresAt time.Time) error {
+	log.Printf("Email verification for user %d <%s>: token %s (expires %s)",
+		user.ID, user.Email, token, expiresAt.Format(time.RFC3339))
+	return nil
+}
+
+// FileNotifier appends each verification message as a JSON line to Path.
+type FileNotifier struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (n *FileNotifier) SendEmailVerification(ctx context.Context, user model.User, token string, expiresAt time.Time) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(struct {
+		UserID    int       `json:"userId"`
+		Email     string    `json:"email"`
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}{user.ID, user.Email, token, expiresAt})
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+// retentionCaller is recorded in the audit trail for purges made by the job.
+var retentionCaller = model.Caller{Principal: "system", Transport: "retention"}
+
+// RetentionJob periodically purges users whose deletion tombstone is older
+// than the retention period, along with expired login sessions.
+type RetentionJob struct {
+	Retention time.Duration
+	Interval  time.Duration
+	// ChangeRetention is how long change feed entries are kept; zero keeps
+	// them forever.
+	ChangeRetention time.Duration
+	stop            chan struct{}
+	done            chan struct{}
+}
+
+// NewRetentionJob creates a job that purges tombstones older than retention,
+// checking every interval.
+func NewRetentionJob(retention, interval time.Duration) *RetentionJob {
+	return &RetentionJob{
+		Retention: retention,
+		Interval:  interval,
+	}
+}
+
+// Start runs the job in the background until Stop is called
+func (j *RetentionJob) Start() {
+	j.stop = make(chan struct{})
+	j.done = make(chan struct{})
+
+	go func() {
+		defer close(j.done)
+
+		ticker := time.NewTicker(j.Interval)
+		defer ticker.Stop()
+
+		for {
+			j.RunOnce()
+			select {
+			case <-ticker.C:
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the background job and waits for a running purge to finish
+func (j *RetentionJob) Stop() {
+	if j.stop != nil {
+		close(j.stop)
+		<-j.done
+	}
+}
+
+// RunOnce purges expired tombstones, sessions, verification tokens, event
+// subscriptions and change feed entries immediately, returning the number
+// of users purged
+func (j *RetentionJob) RunOnce() (int, error) {
+	cutoff := time.Now().Add(-j.Retention)
+	purged, err := database.PurgeDeletedBefore(retentionCaller, cutoff)
+	if err != nil {
+		log.Printf("Retention purge failed: %v", err)
+		return 0, err
+	}
+	if purged > 0 {
+		log.Printf("Retention purge removed %d deleted users older than %s", purged, j.Retention)
+	}
+
+	if sessions, err := database.PurgeExpiredSessions(time.Now()); err != nil {
+		log.Printf("Expired session purge failed: %v", err)
+	} else if sessions > 0 {
+		log.Printf("Retention purge removed %d expired sessions", sessions)
+	}
+
+	if tokens, err := database.PurgeExpiredVerifications(time.Now()); err != nil {
+		log.Printf("Expired verification purge failed: %v", err)
+	} else if tokens > 0 {
+		log.Printf("Retention purge removed %d expired verification tokens", tokens)
+	}
+
+	if subs, err := database.PurgeExpiredSubscriptions(time.Now()); err != nil {
+		log.Printf("Expired subscription purge failed: %v", err)
+	} else if subs > 0 {
+		log.Printf("Retention purge removed %d expired event subscriptions", subs)
+	}
+
+	if j.ChangeRetention > 0 {
+		if changes, err := database.PurgeChangesBefore(time.Now().Add(-j.ChangeRetention)); err != nil {
+			log.Printf("Change feed purge failed: %v", err)
+		} else if changes > 0 {
+			log.Printf("Retention purge removed %d change feed entries older than %s", changes, j.ChangeRetention)
+		}
+	}
+	return purged, nil
+}
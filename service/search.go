@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+// Page sizes for SearchUsers.
+const (
+	DefaultSearchPageSize = 20
+	MaxSearchPageSize     = 200
+)
+
+func (s *UserService) HandleSearchUsers(ctx context.Context, request model.SearchUsersRequest) (model.SearchUsersResponse, error) {
+	query := database.SearchQuery{
+		Text:          request.Query,
+		Field:         request.Field,
+		Match:         request.Match,
+		Status:        request.Status,
+		CreatedAfter:  request.CreatedAfter,
+		CreatedBefore: request.CreatedBefore,
+		Offset:        request.Offset,
+		Limit:         request.Limit,
+	}
+
+	switch query.Field {
+	case "":
+		query.Field = model.SearchFieldAny
+	case model.SearchFieldName, model.SearchFieldEmail, model.SearchFieldAny:
+	default:
+		return model.SearchUsersResponse{}, invalidArgument("unknown search field %q", request.Field)
+	}
+	switch query.Match {
+	case "":
+		query.Match = model.SearchMatchPrefix
+	case model.SearchMatchPrefix, model.SearchMatchSubstring:
+	default:
+		return model.SearchUsersResponse{}, invalidArgument("unknown match mode %q", request.Match)
+	}
+	switch query.Status {
+	case "":
+		query.Status = model.UserStatusActive
+	case model.UserStatusActive, model.UserStatusDeleted, model.SearchStatusAll:
+	default:
+		return model.SearchUsersResponse{}, invalidArgument("unknown status %q", request.Status)
+	}
+
+	if query.Offset < 0 {
+		return model.SearchUsersResponse{}, invalidArgument("offset must not be negative")
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultSearchPageSize
+	}
+	if query.Limit > MaxSearchPageSize {
+		query.Limit = MaxSearchPageSize
+	}
+
+	users, total, err := database.SearchUsers(query)
+	if err != nil {
+		return model.SearchUsersResponse{}, fmt.Errorf("user search failed: %w", err)
+	}
+
+	response := model.SearchUsersResponse{
+		Users: users,
+		Total: total,
+	}
+	if next := query.Offset + len(users); next < total {
+		response.NextOffset = next
+	}
+	return response, nil
+}
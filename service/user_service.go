@@ -1,15 +1,43 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/maasumiyaat/soap/database"
 	"github.com/maasumiyaat/soap/model"
 )
 
-type UserService struct{}
+// Page sizes for GetUserHistory.
+const (
+	DefaultHistoryPageSize = 50
+	MaxHistoryPageSize     = 500
+)
+
+type UserService struct {
+	// PasswordPolicy applies to new passwords; the zero value means
+	// DefaultPasswordPolicy.
+	PasswordPolicy PasswordPolicy
+	// MaxFailedLogins consecutive wrong passwords lock an account for
+	// LockoutDuration.
+	MaxFailedLogins int
+	LockoutDuration time.Duration
+	// SessionTTL is how long tokens from AuthenticateUser stay valid.
+	SessionTTL time.Duration
+	// Notifier delivers email verification tokens; nil means LogNotifier.
+	Notifier Notifier
+	// VerificationTTL is how long verification tokens stay valid.
+	VerificationTTL time.Duration
+	// Backups is where BackupDatabase writes; nil disables the operation.
+	Backups *Backups
+	// EventingAddress is the endpoint WS-Eventing subscribers send Renew,
+	// GetStatus and Unsubscribe requests to.
+	EventingAddress string
+}
 
-func (s *UserService) HandleGetUserByID(request model.GetUserByIDRequest) (model.GetUserByIDResponse, error) {
+func (s *UserService) HandleGetUserByID(ctx context.Context, request model.GetUserByIDRequest) (model.GetUserByIDResponse, error) {
 	user, err := database.GetUserByID(request.ID)
 	if err != nil {
 		return model.GetUserByIDResponse{}, fmt.Errorf("user retrieval failed: %w", err)
@@ -21,79 +49,254 @@ func (s *UserService) HandleGetUserByID(request model.GetUserByIDRequest) (model
 	return response, nil
 }
 
-func (s *UserService) HandleCreateUser(request model.CreateUserRequest) (model.CreateUserResponse, error) {
-	if request.Name == "" || request.Email == "" {
-		return model.CreateUserResponse{}, fmt.Errorf("name and email are required")
+func (s *UserService) HandleCreateUser(ctx context.Context, request model.CreateUserRequest) (model.CreateUserResponse, error) {
+	var user *model.User
+	var pending verifications
+	err := database.Update(CallerFromContext(ctx), func(t *database.Tx) error {
+		var err error
+		user, err = s.createUser(t, request, &pending)
+		return err
+	})
+	if err != nil {
+		return model.CreateUserResponse{}, err
 	}
+	pending.send(ctx, s.notifier())
 
-	user := &model.User{
-		Name:  request.Name,
-		Email: request.Email,
+	response := model.CreateUserResponse{
+		User: *user,
 	}
+	return response, nil
+}
 
-	if err := database.SaveUser(user); err != nil {
-		return model.CreateUserResponse{}, fmt.Errorf("user creation failed: %w", err)
+func (s *UserService) HandleUpdateUser(ctx context.Context, request model.UpdateUserRequest) (model.UpdateUserResponse, error) {
+	if request.ID <= 0 {
+		return model.UpdateUserResponse{}, invalidArgument("invalid user ID")
+	}
+	if err := requireSelfOrAdmin(ctx, request.ID); err != nil {
+		return model.UpdateUserResponse{}, err
 	}
 
-	response := model.CreateUserResponse{
+	var user *model.User
+	var pending verifications
+	err := database.Update(CallerFromContext(ctx), func(t *database.Tx) error {
+		var err error
+		user, err = s.updateUser(t, request, &pending)
+		return err
+	})
+	if err != nil {
+		return model.UpdateUserResponse{}, err
+	}
+	pending.send(ctx, s.notifier())
+
+	response := model.UpdateUserResponse{
 		User: *user,
 	}
 	return response, nil
 }
 
-func (s *UserService) HandleUpdateUser(request model.UpdateUserRequest) (model.UpdateUserResponse, error) {
+func (s *UserService) HandleDeleteUser(ctx context.Context, request model.DeleteUserRequest) (model.DeleteUserResponse, error) {
 	if request.ID <= 0 {
-		return model.UpdateUserResponse{}, fmt.Errorf("invalid user ID")
+		return model.DeleteUserResponse{}, invalidArgument("invalid user ID")
 	}
 
-	// Check if user exists
-	existingUser, err := database.GetUserByID(request.ID)
+	err := s.DeleteUser(ctx, request.ID)
+	if errors.Is(err, ErrPermissionDenied) {
+		return model.DeleteUserResponse{}, err
+	}
+	if errors.Is(err, database.ErrUserNotFound) {
+		return model.DeleteUserResponse{
+			Success: false,
+			Message: fmt.Sprintf("User with ID %d not found", request.ID),
+		}, nil
+	}
 	if err != nil {
-		return model.UpdateUserResponse{}, fmt.Errorf("user not found: %w", err)
+		return model.DeleteUserResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to delete user: %v", err),
+		}, nil
+	}
+
+	response := model.DeleteUserResponse{
+		Success: true,
+		Message: fmt.Sprintf("User with ID %d deleted successfully", request.ID),
+	}
+	return response, nil
+}
+
+// DeleteUser soft-deletes a user like the DeleteUser operation, but reports
+// every failure as an error, one matching database.ErrUserNotFound if there
+// is no such active user. The caller must be logged in as the user or be an
+// admin.
+func (s *UserService) DeleteUser(ctx context.Context, id int) error {
+	if err := requireSelfOrAdmin(ctx, id); err != nil {
+		return err
+	}
+	return database.Update(CallerFromContext(ctx), func(t *database.Tx) error {
+		return s.deleteUser(t, model.DeleteUserRequest{ID: id})
+	})
+}
+
+// createUser validates a CreateUser request and stores the new user within t.
+// The new address starts unverified and a verification token for it is
+// queued on pending. If only issuing the token fails, the stored user is
+// returned together with the error.
+func (s *UserService) createUser(t *database.Tx, request model.CreateUserRequest, pending *verifications) (*model.User, error) {
+	if request.Name == "" || request.Email == "" {
+		return nil, invalidArgument("name and email are required")
+	}
+	email, err := NormalizeEmail(request.Email)
+	if err != nil {
+		return nil, err
 	}
 
-	// Update user fields
-	if request.Name != "" {
-		existingUser.Name = request.Name
+	user := &model.User{
+		Name:  request.Name,
+		Email: email,
+	}
+
+	if err := t.CreateUser(user); err != nil {
+		return nil, fmt.Errorf("user creation failed: %w", err)
+	}
+	if err := s.issueVerification(t, *user, pending); err != nil {
+		return user, fmt.Errorf("email verification failed: %w", err)
 	}
+	return user, nil
+}
+
+// updateUser applies an UpdateUser request within t. Loading, modifying and
+// storing happen in the same transaction so concurrent updates cannot
+// overwrite each other's changes. Changing the email clears Verified and
+// queues a verification token for the new address on pending. As for
+// createUser, a failure to issue the token returns the stored user too.
+func (s *UserService) updateUser(t *database.Tx, request model.UpdateUserRequest, pending *verifications) (*model.User, error) {
+	if request.ID <= 0 {
+		return nil, invalidArgument("invalid user ID")
+	}
+	var email string
 	if request.Email != "" {
-		existingUser.Email = request.Email
+		var err error
+		if email, err = NormalizeEmail(request.Email); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := database.SaveUser(existingUser); err != nil {
-		return model.UpdateUserResponse{}, fmt.Errorf("user update failed: %w", err)
+	emailChanged := false
+	user, err := t.UpdateUser(request.ID, func(user *model.User) error {
+		if request.Name != "" {
+			user.Name = request.Name
+		}
+		if email != "" && email != user.Email {
+			user.Email = email
+			user.Verified = false
+			emailChanged = true
+		}
+		return nil
+	})
+	if errors.Is(err, database.ErrUserNotFound) {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("user update failed: %w", err)
+	}
+	if emailChanged {
+		if err := s.issueVerification(t, *user, pending); err != nil {
+			return user, fmt.Errorf("email verification failed: %w", err)
+		}
 	}
+	return user, nil
+}
 
-	response := model.UpdateUserResponse{
-		User: *existingUser,
+// deleteUser soft-deletes the user named by a DeleteUser request within t,
+// returning an error matching database.ErrUserNotFound if there is none.
+func (s *UserService) deleteUser(t *database.Tx, request model.DeleteUserRequest) error {
+	if request.ID <= 0 {
+		return invalidArgument("invalid user ID")
 	}
-	return response, nil
+
+	deleted, err := t.DeleteUser(request.ID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return &database.UserNotFoundError{ID: request.ID}
+	}
+	return nil
 }
 
-func (s *UserService) HandleDeleteUser(request model.DeleteUserRequest) (model.DeleteUserResponse, error) {
+func (s *UserService) HandleRestoreUser(ctx context.Context, request model.RestoreUserRequest) (model.RestoreUserResponse, error) {
 	if request.ID <= 0 {
-		return model.DeleteUserResponse{}, fmt.Errorf("invalid user ID")
+		return model.RestoreUserResponse{}, invalidArgument("invalid user ID")
+	}
+	if err := requireSelfOrAdmin(ctx, request.ID); err != nil {
+		return model.RestoreUserResponse{}, err
 	}
 
-	// Check if user exists before deleting
-	_, err := database.GetUserByID(request.ID)
+	user, err := database.RestoreUser(CallerFromContext(ctx), request.ID)
 	if err != nil {
-		return model.DeleteUserResponse{
+		return model.RestoreUserResponse{}, fmt.Errorf("user restore failed: %w", err)
+	}
+
+	response := model.RestoreUserResponse{
+		User: *user,
+	}
+	return response, nil
+}
+
+func (s *UserService) HandlePurgeUser(ctx context.Context, request model.PurgeUserRequest) (model.PurgeUserResponse, error) {
+	if request.ID <= 0 {
+		return model.PurgeUserResponse{}, invalidArgument("invalid user ID")
+	}
+	if err := requireAdmin(ctx); err != nil {
+		return model.PurgeUserResponse{}, err
+	}
+
+	err := database.PurgeUser(CallerFromContext(ctx), request.ID)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return model.PurgeUserResponse{
 			Success: false,
 			Message: fmt.Sprintf("User with ID %d not found", request.ID),
 		}, nil
 	}
-
-	if err := database.DeleteUser(request.ID); err != nil {
-		return model.DeleteUserResponse{
+	if err != nil {
+		return model.PurgeUserResponse{
 			Success: false,
-			Message: fmt.Sprintf("Failed to delete user: %v", err),
+			Message: fmt.Sprintf("Failed to purge user: %v", err),
 		}, nil
 	}
 
-	response := model.DeleteUserResponse{
+	response := model.PurgeUserResponse{
 		Success: true,
-		Message: fmt.Sprintf("User with ID %d deleted successfully", request.ID),
+		Message: fmt.Sprintf("User with ID %d purged successfully", request.ID),
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleGetUserHistory(ctx context.Context, request model.GetUserHistoryRequest) (model.GetUserHistoryResponse, error) {
+	if request.ID <= 0 {
+		return model.GetUserHistoryResponse{}, invalidArgument("invalid user ID")
+	}
+	if err := requireSelfOrAdmin(ctx, request.ID); err != nil {
+		return model.GetUserHistoryResponse{}, err
+	}
+
+	limit := request.Limit
+	if limit <= 0 {
+		limit = DefaultHistoryPageSize
+	}
+	if limit > MaxHistoryPageSize {
+		limit = MaxHistoryPageSize
+	}
+
+	entries, more, err := database.GetUserHistory(request.ID, request.AfterSequence, limit)
+	if err != nil {
+		return model.GetUserHistoryResponse{}, fmt.Errorf("history retrieval failed: %w", err)
+	}
+
+	response := model.GetUserHistoryResponse{
+		Entries: entries,
+	}
+	if more {
+		response.NextSequence = entries[len(entries)-1].Sequence
 	}
 	return response, nil
 }
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+// changeActions are the change actions webhooks and event subscriptions
+// can select.
+var changeActions = []string{
+	model.AuditActionCreate,
+	model.AuditActionUpdate,
+	model.AuditActionDelete,
+	model.AuditActionRestore,
+	model.AuditActionPurge,
+}
+
+// minWebhookSecretLength is the shortest secret accepted from callers.
+const minWebhookSecretLength = 16
+
+func (s *UserService) HandleCreateWebhook(ctx context.Context, request model.CreateWebhookRequest) (model.CreateWebhookResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return model.CreateWebhookResponse{}, err
+	}
+
+	target, err := url.Parse(strings.TrimSpace(request.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return model.CreateWebhookResponse{}, invalidArgument("webhook URL must be an absolute http or https URL")
+	}
+
+	var events []string
+	for _, event := range request.Events {
+		event = strings.ToLower(strings.TrimSpace(event))
+		if !slices.Contains(changeActions, event) {
+			return model.CreateWebhookResponse{}, invalidArgument("unknown webhook event %q (known: %s)", event, strings.Join(changeActions, ", "))
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+
+	format := request.Format
+	if format == "" {
+		format = model.WebhookFormatJSON
+	}
+	if format != model.WebhookFormatJSON && format != model.WebhookFormatSOAP {
+		return model.CreateWebhookResponse{}, invalidArgument("unknown webhook format %q", request.Format)
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		if secret, err = newSessionToken(); err != nil {
+			return model.CreateWebhookResponse{}, err
+		}
+	} else if len(secret) < minWebhookSecretLength {
+		return model.CreateWebhookResponse{}, invalidArgument("webhook secret must be at least %d characters", minWebhookSecretLength)
+	}
+
+	webhook := &model.Webhook{
+		URL:    target.String(),
+		Events: events,
+		Format: format,
+		Secret: secret,
+	}
+	if err := database.CreateWebhook(webhook); err != nil {
+		return model.CreateWebhookResponse{}, fmt.Errorf("webhook creation failed: %w", err)
+	}
+
+	response := model.CreateWebhookResponse{
+		Webhook: *webhook,
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleListWebhooks(ctx context.Context, request model.ListWebhooksRequest) (model.ListWebhooksResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return model.ListWebhooksResponse{}, err
+	}
+
+	webhooks, err := database.ListWebhooks()
+	if err != nil {
+		return model.ListWebhooksResponse{}, fmt.Errorf("listing webhooks failed: %w", err)
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	response := model.ListWebhooksResponse{
+		Webhooks: webhooks,
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleDeleteWebhook(ctx context.Context, request model.DeleteWebhookRequest) (model.DeleteWebhookResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return model.DeleteWebhookResponse{}, err
+	}
+	if request.ID <= 0 {
+		return model.DeleteWebhookResponse{}, invalidArgument("invalid webhook ID")
+	}
+
+	err := database.DeleteWebhook(request.ID)
+	if errors.Is(err, database.ErrWebhookNotFound) {
+		return model.DeleteWebhookResponse{
+			Success: false,
+			Message: fmt.Sprintf("Webhook with ID %d not found", request.ID),
+		}, nil
+	}
+	if err != nil {
+		return model.DeleteWebhookResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to delete webhook: %v", err),
+		}, nil
+	}
+
+	response := model.DeleteWebhookResponse{
+		Success: true,
+		Message: fmt.Sprintf("Webhook with ID %d deleted successfully", request.ID),
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleListWebhookDeadLetters(ctx context.Context, request model.ListWebhookDeadLettersRequest) (model.ListWebhookDeadLettersResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return model.ListWebhookDeadLettersResponse{}, err
+	}
+
+	deliveries, err := database.ListWebhookDeadLetters(request.WebhookID)
+	if err != nil {
+		return model.ListWebhookDeadLettersResponse{}, fmt.Errorf("listing dead letters failed: %w", err)
+	}
+
+	response := model.ListWebhookDeadLettersResponse{
+		Deliveries: deliveries,
+	}
+	return response, nil
+}
+
+func (s *UserService) HandleRedeliverWebhook(ctx context.Context, request model.RedeliverWebhookRequest) (model.RedeliverWebhookResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return model.RedeliverWebhookResponse{}, err
+	}
+
+	err := database.RequeueWebhookDeadLetter(request.DeliveryID)
+	if errors.Is(err, database.ErrDeliveryNotFound) {
+		return model.RedeliverWebhookResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+	if err != nil {
+		return model.RedeliverWebhookResponse{}, fmt.Errorf("redelivery failed: %w", err)
+	}
+
+	response := model.RedeliverWebhookResponse{
+		Success: true,
+		Message: fmt.Sprintf("Delivery %d queued for redelivery", request.DeliveryID),
+	}
+	return response, nil
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+// Defaults for WebhookDispatcher fields left at zero.
+const (
+	DefaultWebhookMaxAttempts = 10
+	DefaultWebhookBaseDelay   = 5 * time.Second
+	DefaultWebhookMaxDelay    = 6 * time.Hour
+	DefaultWebhookTimeout     = 10 * time.Second
+	DefaultWebhookConcurrency = 4
+)
+
+// webhookPollInterval is how often the outbox is checked for retries that
+// became due; new changes wake the dispatcher straight away.
+const webhookPollInterval = time.Second
+
+// Headers sent with every delivery. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed by the webhook secret, prefixed with "sha256=".
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookEventHeader     = "X-Webhook-Event"
+)
+
+// SignWebhookPayload returns the signature header value for a delivery
+// body sent at timestamp (Unix seconds). Receivers recompute it to check
+// that a delivery is authentic.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookDispatcher delivers the webhook outbox in the background. Failed
+// deliveries are retried with exponential backoff and jitter, and moved to
+// the dead-letter bucket after MaxAttempts. Deliveries of one webhook are
+// not strictly ordered; receivers can order and deduplicate them by their
+// change sequence.
+type WebhookDispatcher struct {
+	Client      *http.Client
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Concurrency int
+
+	stop chan struct{}
+	done chan struct{}
+
+	mu       sync.Mutex
+	inFlight map[uint64]bool
+}
+
+// NewWebhookDispatcher creates a dispatcher with the default settings.
+func NewWebhookDispatcher() *WebhookDispatcher {
+	return &WebhookDispatcher{
+		Client:      &http.Client{Timeout: DefaultWebhookTimeout},
+		MaxAttempts: DefaultWebhookMaxAttempts,
+		BaseDelay:   DefaultWebhookBaseDelay,
+		MaxDelay:    DefaultWebhookMaxDelay,
+		Concurrency: DefaultWebhookConcurrency,
+	}
+}
+
+// Start runs the dispatcher in the background until Stop is called
+func (d *WebhookDispatcher) Start() {
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	d.inFlight = make(map[uint64]bool)
+
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		slots := make(chan struct{}, max(d.Concurrency, 1))
+
+		for {
+			committed := database.ChangesCommitted()
+			due, err := database.DueWebhookDeliveries(time.Now(), cap(slots), d.isInFlight)
+			if err != nil {
+				log.Printf("Reading webhook outbox failed: %v", err)
+			}
+			for _, delivery := range due {
+				select {
+				case slots <- struct{}{}:
+				case <-d.stop:
+					return
+				}
+				d.setInFlight(delivery.ID, true)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-slots }()
+					defer d.setInFlight(delivery.ID, false)
+					d.deliver(delivery)
+				}()
+			}
+
+			select {
+			case <-committed:
+			case <-ticker.C:
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the dispatcher and waits for running deliveries to finish
+func (d *WebhookDispatcher) Stop() {
+	if d.stop != nil {
+		close(d.stop)
+		<-d.done
+	}
+}
+
+func (d *WebhookDispatcher) isInFlight(id uint64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.inFlight[id]
+}
+
+func (d *WebhookDispatcher) setInFlight(id uint64, running bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if running {
+		d.inFlight[id] = true
+	} else {
+		delete(d.inFlight, id)
+	}
+}
+
+// deliver makes one attempt at a delivery and records the outcome. An
+// attempt cut short by Stop is not counted; the delivery stays due.
+func (d *WebhookDispatcher) deliver(delivery model.WebhookDelivery) {
+	err := d.post(delivery)
+	if err == nil {
+		if err := database.CompleteWebhookDelivery(delivery.ID); err != nil {
+			log.Printf("Completing webhook delivery %d failed: %v", delivery.ID, err)
+		}
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	deadLetter := attempts >= d.MaxAttempts
+	next := time.Now().Add(d.backoff(attempts))
+	if deadLetter {
+		log.Printf("Webhook delivery %d to webhook %d dead-lettered after %d attempts: %v", delivery.ID, delivery.WebhookID, attempts, err)
+	} else {
+		log.Printf("Webhook delivery %d to webhook %d failed (attempt %d), retrying at %s: %v",
+			delivery.ID, delivery.WebhookID, attempts, next.Format(time.RFC3339), err)
+	}
+	if err := database.FailWebhookDelivery(delivery.ID, err, next.UTC(), deadLetter); err != nil {
+		log.Printf("Recording webhook delivery %d failure failed: %v", delivery.ID, err)
+	}
+}
+
+// post sends a delivery to its webhook. Any 2xx response counts as
+// delivered.
+func (d *WebhookDispatcher) post(delivery model.WebhookDelivery) error {
+	webhook, err := database.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	contentType := "application/json"
+	if webhook.Format == model.WebhookFormatSOAP {
+		contentType = "text/xml; charset=utf-8"
+		body, err = xml.Marshal(model.NewSoapEnvelope(model.UserChanged{Change: delivery.Change}))
+		body = append([]byte(xml.Header), body...)
+	} else {
+		body, err = json.Marshal(model.WebhookPayload{
+			DeliveryID: delivery.ID,
+			WebhookID:  webhook.ID,
+			Change:     delivery.Change,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-d.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, timestamp, body))
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatUint(delivery.ID, 10))
+	req.Header.Set(WebhookEventHeader, delivery.Change.Action)
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// backoff returns the delay before the next attempt: BaseDelay doubled for
+// every failed attempt, capped at MaxDelay, with up to 20% jitter so
+// retries of many deliveries spread out.
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	delay := d.BaseDelay
+	for i := 1; i < attempts && delay < d.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.MaxDelay)
+	return delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maasumiyaat/soap/database"
+	"github.com/maasumiyaat/soap/model"
+)
+
+const testWebhookSecret = "0123456789abcdef"
+
+// webhookRequest is a delivery seen by a webhookReceiver.
+type webhookRequest struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver is a local webhook endpoint answering every delivery with
+// status.
+type webhookReceiver struct {
+	*httptest.Server
+	status   atomic.Int32
+	requests chan webhookRequest
+}
+
+func newWebhookReceiver(t *testing.T) *webhookReceiver {
+	r := &webhookReceiver{requests: make(chan webhookRequest, 100)}
+	r.status.Store(http.StatusNoContent)
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.requests <- webhookRequest{path: req.URL.Path, header: req.Header, body: body}
+		w.WriteHeader(int(r.status.Load()))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// next waits for the next delivery.
+func (r *webhookReceiver) next(t *testing.T) webhookRequest {
+	t.Helper()
+	select {
+	case req := <-r.requests:
+		return req
+	case <-time.After(10 * time.Second):
+		t.Fatal("no webhook delivery within 10s")
+		return webhookRequest{}
+	}
+}
+
+// startWebhookDispatcher runs d until the end of t, stopping it before the
+// database is closed.
+func startWebhookDispatcher(t *testing.T, d *WebhookDispatcher) {
+	d.Start()
+	t.Cleanup(d.Stop)
+}
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func outboxEmpty() bool {
+	due, err := database.DueWebhookDeliveries(time.Now().Add(24*time.Hour), 1, func(uint64) bool { return false })
+	return err == nil && len(due) == 0
+}
+
+func checkSignature(t *testing.T, req webhookRequest) {
+	t.Helper()
+	timestamp, err := strconv.ParseInt(req.header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header: %v", err)
+	}
+	if got, want := req.header.Get(WebhookSignatureHeader), SignWebhookPayload(testWebhookSecret, timestamp, req.body); got != want {
+		t.Errorf("signature = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookDispatcherDelivers(t *testing.T) {
+	openTestDB(t)
+	s := &UserService{}
+	admin := createTestUser(t, "Admin", "admin@example.com")
+	makeAdmin(t, admin.ID)
+	ctx := callerContext(admin.ID)
+	receiver := newWebhookReceiver(t)
+
+	jsonHook, err := s.HandleCreateWebhook(ctx, model.CreateWebhookRequest{
+		URL: receiver.URL + "/json", Events: []string{"create"}, Secret: testWebhookSecret,
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook json: %v", err)
+	}
+	_, err = s.HandleCreateWebhook(ctx, model.CreateWebhookRequest{
+		URL: receiver.URL + "/soap", Events: []string{"delete"}, Format: model.WebhookFormatSOAP, Secret: testWebhookSecret,
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook soap: %v", err)
+	}
+	startWebhookDispatcher(t, NewWebhookDispatcher())
+
+	created, err := s.HandleCreateUser(ctx, model.CreateUserRequest{Name: "Ada", Email: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	req := receiver.next(t)
+	if req.path != "/json" || req.header.Get("Content-Type") != "application/json" || req.header.Get(WebhookEventHeader) != "create" {
+		t.Errorf("create delivered to %s as %s, event %s", req.path, req.header.Get("Content-Type"), req.header.Get(WebhookEventHeader))
+	}
+	checkSignature(t, req)
+	var payload model.WebhookPayload
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatalf("JSON payload: %v", err)
+	}
+	if payload.WebhookID != jsonHook.Webhook.ID || payload.Action != model.AuditActionCreate || payload.UserID != created.User.ID {
+		t.Errorf("payload = %+v, want a create of user %d for webhook %d", payload, created.User.ID, jsonHook.Webhook.ID)
+	}
+
+	if _, err := s.HandleDeleteUser(ctx, model.DeleteUserRequest{ID: created.User.ID}); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	req = receiver.next(t)
+	if req.path != "/soap" || !strings.HasPrefix(req.header.Get("Content-Type"), "text/xml") {
+		t.Errorf("delete delivered to %s as %s", req.path, req.header.Get("Content-Type"))
+	}
+	checkSignature(t, req)
+	var envelope struct {
+		Body struct {
+			UserChanged model.UserChanged
+		}
+	}
+	if err := xml.Unmarshal(req.body, &envelope); err != nil {
+		t.Fatalf("SOAP payload: %v", err)
+	}
+	if change := envelope.Body.UserChanged.Change; change.Action != model.AuditActionDelete || change.UserID != created.User.ID {
+		t.Errorf("UserChanged = %+v, want a delete of user %d", change, created.User.ID)
+	}
+
+	waitFor(t, "the outbox to drain", outboxEmpty)
+	select {
+	case req := <-receiver.requests:
+		t.Errorf("unexpected delivery of %s to %s", req.header.Get(WebhookEventHeader), req.path)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebhookDispatcherDeadLetters(t *testing.T) {
+	openTestDB(t)
+	s := &UserService{}
+	admin := createTestUser(t, "Admin", "admin@example.com")
+	makeAdmin(t, admin.ID)
+	ctx := callerContext(admin.ID)
+	receiver := newWebhookReceiver(t)
+	receiver.status.Store(http.StatusInternalServerError)
+
+	hook, err := s.HandleCreateWebhook(ctx, model.CreateWebhookRequest{URL: receiver.URL, Secret: testWebhookSecret})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	d := NewWebhookDispatcher()
+	d.MaxAttempts = 3
+	d.BaseDelay = time.Millisecond
+	d.MaxDelay = 5 * time.Millisecond
+	startWebhookDispatcher(t, d)
+
+	if _, err := s.HandleCreateUser(ctx, model.CreateUserRequest{Name: "Ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	for range d.MaxAttempts {
+		receiver.next(t)
+	}
+
+	var dead []model.WebhookDelivery
+	waitFor(t, "the delivery to be dead-lettered", func() bool {
+		response, err := s.HandleListWebhookDeadLetters(ctx, model.ListWebhookDeadLettersRequest{WebhookID: hook.Webhook.ID})
+		dead = response.Deliveries
+		return err == nil && len(dead) == 1
+	})
+	if dead[0].Attempts != d.MaxAttempts || !strings.Contains(dead[0].LastError, "500") {
+		t.Errorf("dead letter = %+v, want %d attempts failing with 500", dead[0], d.MaxAttempts)
+	}
+	if !outboxEmpty() {
+		t.Error("a dead-lettered delivery is still in the outbox")
+	}
+
+	receiver.status.Store(http.StatusOK)
+	redeliver, err := s.HandleRedeliverWebhook(ctx, model.RedeliverWebhookRequest{DeliveryID: dead[0].ID})
+	if err != nil || !redeliver.Success {
+		t.Fatalf("RedeliverWebhook = %+v, %v", redeliver, err)
+	}
+	req := receiver.next(t)
+	if req.header.Get(WebhookDeliveryHeader) != strconv.FormatUint(dead[0].ID, 10) {
+		t.Errorf("redelivered delivery %s, want %d", req.header.Get(WebhookDeliveryHeader), dead[0].ID)
+	}
+	waitFor(t, "the outbox to drain", outboxEmpty)
+}
+
+func TestWebhookDispatcherStopDoesNotCountAttempt(t *testing.T) {
+	openTestDB(t)
+	s := &UserService{}
+	admin := createTestUser(t, "Admin", "admin@example.com")
+	makeAdmin(t, admin.ID)
+	ctx := callerContext(admin.ID)
+
+	// The receiver does not answer until the end of the test, so the
+	// attempt is still running at Stop.
+	received := make(chan struct{}, 1)
+	release := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	t.Cleanup(receiver.Close)
+	t.Cleanup(func() { close(release) })
+	if _, err := s.HandleCreateWebhook(ctx, model.CreateWebhookRequest{URL: receiver.URL, Secret: testWebhookSecret}); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	d := NewWebhookDispatcher()
+	d.MaxAttempts = 1
+	d.Start()
+
+	if _, err := s.HandleCreateUser(ctx, model.CreateUserRequest{Name: "Ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	select {
+	case <-received:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no webhook delivery within 10s")
+	}
+	d.Stop()
+
+	due, err := database.DueWebhookDeliveries(time.Now(), 10, func(uint64) bool { return false })
+	if err != nil || len(due) != 1 || due[0].Attempts != 0 {
+		t.Errorf("outbox after Stop = %+v, %v, want the delivery still due with no attempts", due, err)
+	}
+	if dead, err := database.ListWebhookDeadLetters(0); err != nil || len(dead) != 0 {
+		t.Errorf("dead letters = %+v, %v, want none", dead, err)
+	}
+}
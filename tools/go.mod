@@ -0,0 +1,12 @@
+module github.com/maasumiyaat/soap/tools
+
+go 1.24.4
+
+tool google.golang.org/protobuf/cmd/protoc-gen-go
+
+require (
+	github.com/bufbuild/protocompile v0.14.1
+	google.golang.org/protobuf v1.36.11
+)
+
+require golang.org/x/sync v0.8.0 // indirect
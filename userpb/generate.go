@@ -0,0 +1,5 @@
+// Package userpb holds the protocol buffer messages of the gRPC user
+// service described by user.proto.
+package userpb
+
+//go:generate go run -C ../tools ./protogen ../userpb/user.proto